@@ -2,7 +2,9 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"log"
+	"os"
 	"os/signal"
 	"path/filepath"
 	"syscall"
@@ -10,19 +12,28 @@ import (
 
 	"go.uber.org/zap"
 
+	"github.com/open-apime/apime/internal/alert"
 	"github.com/open-apime/apime/internal/api/handler"
 	"github.com/open-apime/apime/internal/api/middleware"
 	"github.com/open-apime/apime/internal/app"
+	"github.com/open-apime/apime/internal/cluster"
 	"github.com/open-apime/apime/internal/config"
 	"github.com/open-apime/apime/internal/dashboard"
 	"github.com/open-apime/apime/internal/logger"
+	"github.com/open-apime/apime/internal/pkg/crypto"
+	"github.com/open-apime/apime/internal/pkg/fetch"
 	"github.com/open-apime/apime/internal/server"
 	"github.com/open-apime/apime/internal/service/api_token"
 	"github.com/open-apime/apime/internal/service/auth"
+	"github.com/open-apime/apime/internal/service/chat"
 	device_config "github.com/open-apime/apime/internal/service/device_config"
 	"github.com/open-apime/apime/internal/service/instance"
+	"github.com/open-apime/apime/internal/service/key_rotation"
 	"github.com/open-apime/apime/internal/service/message"
 	"github.com/open-apime/apime/internal/service/user"
+	"github.com/open-apime/apime/internal/session"
+	"github.com/open-apime/apime/internal/session/cloudapi"
+	"github.com/open-apime/apime/internal/session/sandbox"
 	"github.com/open-apime/apime/internal/session/whatsmeow"
 	whatsmeow_session "github.com/open-apime/apime/internal/session/whatsmeow"
 	"github.com/open-apime/apime/internal/storage"
@@ -52,6 +63,14 @@ func (a *instanceCheckerAdapter) IsMetaCompatible(ctx context.Context, instanceI
 	return inst.MetaCompatible
 }
 
+func (a *instanceCheckerAdapter) MediaDownloadMode(ctx context.Context, instanceID string) model.MediaDownloadMode {
+	inst, err := a.repo.GetByID(ctx, instanceID)
+	if err != nil {
+		return model.MediaDownloadEager
+	}
+	return inst.MediaDownload
+}
+
 func main() {
 	cfg := config.Load()
 
@@ -61,6 +80,10 @@ func main() {
 	}
 	defer logr.Sync()
 
+	if cfg.App.Env == "production" && cfg.WhatsApp.UsesDefaultSessionKey() {
+		log.Fatalf("config: WHATSAPP_SESSION_KEY_ENC usa um valor de exemplo; defina uma chave própria antes de iniciar em produção")
+	}
+
 	sessionDir := filepath.Join(cfg.Storage.DataDir, "sessions")
 	mediaDir := filepath.Join(cfg.Storage.DataDir, "media")
 
@@ -82,10 +105,64 @@ func main() {
 		pgConnString = cfg.DB.DSN()
 	}
 
-	sessionManager := whatsmeow.NewManager(logr, cfg.WhatsApp.SessionKeyEnc, cfg.Storage.Driver, sessionDir, pgConnString, repos.DeviceConfig, repos.Instance, repos.HistorySync, repos.Message)
+	keyring, err := crypto.NewKeyring(cfg.WhatsApp.SessionKeyEnc, cfg.WhatsApp.SessionKeysPrevious...)
+	if err != nil {
+		log.Fatalf("keyring: %v", err)
+	}
+	logr.Info("keyring de sessão carregado",
+		zap.String("current_key_id", keyring.CurrentKeyID()),
+		zap.Int("keys", keyring.Size()),
+	)
+	// A recifragem roda antes de restaurar as sessões, para não disputar a
+	// gravação das instâncias com o session manager.
+	if cfg.WhatsApp.SessionKeyRotateOnStart {
+		rotation := key_rotation.NewService(keyring, repos.Instance, repos.InstanceProxy, repos.CloudAPI, logr)
+		if _, err := rotation.Run(context.Background()); err != nil {
+			logr.Error("erro na recifragem com a chave atual", zap.Error(err))
+		}
+	}
+
+	sessionManager := whatsmeow.NewManager(logr, keyring, cfg.Storage.Driver, sessionDir, pgConnString, repos.DeviceConfig, repos.Instance, repos.HistorySync, repos.Message)
+	sessionManager.SetProxyRepository(repos.InstanceProxy)
+	sessionManager.SetLIDMappingRepository(repos.LIDMapping)
+	sessionManager.SetStateTransitionRepository(repos.InstanceState)
+	sessionManager.SetReconnectPolicy(whatsmeow.ReconnectPolicy{
+		BaseDelay:   time.Duration(cfg.WhatsApp.ReconnectBaseDelaySeconds) * time.Second,
+		MaxDelay:    time.Duration(cfg.WhatsApp.ReconnectMaxDelaySeconds) * time.Second,
+		MaxAttempts: cfg.WhatsApp.ReconnectMaxAttempts,
+	})
+	sessionManager.SetRestorePolicy(whatsmeow.RestorePolicy{
+		Concurrency: cfg.WhatsApp.RestoreConcurrency,
+		Jitter:      time.Duration(cfg.WhatsApp.RestoreJitterMS) * time.Millisecond,
+	})
+	// Instâncias com tráfego recente são restauradas primeiro.
+	sessionManager.SetRestorePriority(repos.Chat.LastActivityByInstance)
 
 	instanceService := instance.NewServiceWithSessionMessagesAndEventLogs(repos.Instance, repos.Message, repos.EventLog, sessionManager)
 
+	messengers := session.NewRegistry(repos.Instance)
+	messengers.Register(model.InstanceProviderWhatsmeow, whatsmeow.NewMessenger(sessionManager, repos.Contact, logr))
+
+	var cloudAPIManager *cloudapi.Manager
+	if cfg.CloudAPI.Enabled {
+		cloudAPIClient := cloudapi.NewClient(cfg.CloudAPI.BaseURL, cfg.CloudAPI.Version, time.Duration(cfg.CloudAPI.TimeoutSeconds)*time.Second)
+		cloudAPIManager = cloudapi.NewManager(repos.Instance, repos.CloudAPI, keyring, cloudAPIClient, cfg.App.BaseURL, logr)
+		messengers.Register(model.InstanceProviderCloudAPI, cloudAPIManager)
+		instanceService.SetCloudAPIManager(cloudAPIManager)
+		logr.Info("provedor Cloud API habilitado", zap.String("graph_version", cfg.CloudAPI.Version))
+	}
+
+	var sandboxManager *sandbox.Manager
+	if cfg.Sandbox.Enabled {
+		sandboxManager = sandbox.NewManager(repos.Instance, sandbox.Schedule{
+			DeliveredAfter: time.Duration(cfg.Sandbox.DeliveredDelayMS) * time.Millisecond,
+			ReadAfter:      time.Duration(cfg.Sandbox.ReadDelayMS) * time.Millisecond,
+		}, logr)
+		messengers.Register(model.InstanceProviderSandbox, sandboxManager)
+		instanceService.SetSandboxManager(sandboxManager)
+		logr.Info("provedor sandbox habilitado")
+	}
+
 	sessionManager.SetStatusChangeCallback(func(instanceID string, status string) {
 		ctx := context.Background()
 		var instanceStatus model.InstanceStatus
@@ -103,52 +180,184 @@ func main() {
 	})
 
 	mediaTTL := time.Duration(cfg.Storage.MediaTTLSeconds) * time.Second
-	mediaStorage, err := media.NewStorage(mediaDir, mediaTTL, logr)
-	if err != nil {
-		log.Fatalf("media storage: %v", err)
+	var mediaStorage media.Storage
+	switch cfg.Storage.MediaDriver {
+	case "s3":
+		s3Cfg := cfg.Storage.MediaS3
+		mediaStorage, err = media.NewS3Storage(media.S3Config{
+			Endpoint:  s3Cfg.Endpoint,
+			Region:    s3Cfg.Region,
+			Bucket:    s3Cfg.Bucket,
+			AccessKey: s3Cfg.AccessKey,
+			SecretKey: s3Cfg.SecretKey,
+			Prefix:    s3Cfg.Prefix,
+			PathStyle: s3Cfg.PathStyle,
+			PartSize:  s3Cfg.PartSizeMB << 20,
+			Lifecycle: s3Cfg.Lifecycle,
+		}, mediaTTL, logr)
+		if err != nil {
+			log.Fatalf("media storage: %v", err)
+		}
+		logr.Info("media storage inicializado", zap.String("bucket", s3Cfg.Bucket), zap.Duration("ttl", mediaTTL))
+	default:
+		mediaStorage, err = media.NewFileStorage(mediaDir, mediaTTL, logr)
+		if err != nil {
+			log.Fatalf("media storage: %v", err)
+		}
+		logr.Info("media storage inicializado", zap.String("dir", mediaDir), zap.Duration("ttl", mediaTTL))
 	}
-	logr.Info("media storage inicializado", zap.String("dir", mediaDir), zap.Duration("ttl", mediaTTL))
-
-	mediaHandler := handler.NewMediaHandler(mediaStorage)
+	mediaStorage.SetPolicyRepository(repos.MediaPolicy)
+	instanceService.SetMediaPolicies(repos.MediaPolicy, mediaStorage)
+
+	// Sem MEDIA_URL_SECRET, a chave das URLs é derivada do JWT_SECRET, que
+	// assina as sessões e não pode assinar também as URLs públicas.
+	mediaURLSecret := cfg.Storage.MediaURLSecret
+	if mediaURLSecret == "" {
+		mediaURLSecret = crypto.DeriveSecret(cfg.JWT.Secret, "media-url")
+	}
+	mediaURLSigner := media.NewURLSigner(mediaURLSecret, time.Duration(cfg.Storage.MediaURLTTLSeconds)*time.Second)
+	mediaHandler := handler.NewMediaHandler(mediaStorage, mediaURLSigner, repos.Instance, cfg.Storage.MediaPublicAccess)
+	mediaHandler.SetLazyFetcher(media.NewLazyFetcher(mediaStorage, repos.MediaRef, sessionManager, logr))
 
 	logr.Info("inicializando sistema de webhooks")
 	instanceWebhookChecker := &instanceCheckerAdapter{repo: repos.Instance}
-	eventHandler := webhook.NewEventHandler(repos.WebhookQueue, logr, mediaStorage, repos.Message, cfg.App.BaseURL, instanceWebhookChecker)
+	chatService := chat.NewService(repos.Message, repos.Chat, logr)
+	eventHandler := webhook.NewEventHandler(repos.WebhookQueue, logr, mediaStorage, repos.Message, repos.MessageStatus, chatService, cfg.App.BaseURL, instanceWebhookChecker)
+	if !cfg.Storage.MediaPublicAccess {
+		eventHandler.SetMediaURLSigner(mediaURLSigner)
+	}
+	eventHandler.SetLazyMedia(repos.MediaRef, int64(cfg.Storage.MediaEagerMaxSizeMB)<<20)
 	sessionManager.SetEventHandler(eventHandler)
+	sessionManager.SetHistoryRecorder(chatService)
+	if cloudAPIManager != nil {
+		cloudAPIManager.SetEventHandler(eventHandler)
+		cloudAPIManager.SetMediaStore(mediaStorage)
+	}
+	if sandboxManager != nil {
+		sandboxManager.SetEventHandler(eventHandler)
+		sandboxManager.SetMediaStore(mediaStorage)
+	}
 	logr.Info("event handler configurado")
 
+	alertService := alert.NewService(repos.AlertRule, repos.Instance, repos.RedisClient, logr, alert.Options{
+		CheckInterval:   time.Duration(cfg.Alert.CheckIntervalSeconds) * time.Second,
+		DefaultCooldown: time.Duration(cfg.Alert.DefaultCooldownSeconds) * time.Second,
+		SMTP: alert.SMTPConfig{
+			Host:     cfg.Alert.SMTPHost,
+			Port:     cfg.Alert.SMTPPort,
+			Username: cfg.Alert.SMTPUsername,
+			Password: cfg.Alert.SMTPPassword,
+			From:     cfg.Alert.SMTPFrom,
+		},
+	})
+
+	if cfg.Alert.Enabled {
+		sessionManager.SetStateListener(alertService.OnStateTransition)
+		alertService.SetStateSource(sessionManager)
+		alertService.Start(context.Background())
+	}
+
 	stuckDetector := whatsmeow_session.NewMessageStuckDetector(repos.Message, sessionManager, logr, 2*time.Minute)
+	if cfg.Alert.Enabled {
+		stuckDetector.SetOnStuck(alertService.OnStuckMessages)
+	}
 	stuckDetector.Start(context.Background(), 1*time.Minute)
 	logr.Info("detector de mensagens travadas (Stuck) iniciado")
 
 	webhookDelivery := delivery.NewDelivery(logr, 3)
 	webhookPool := webhook.NewPool(repos.WebhookQueue, repos.Instance, webhookDelivery, logr, cfg.Webhook.Workers)
+	if cfg.Alert.Enabled {
+		webhookPool.SetDeliveryObserver(alertService.OnWebhookDelivery)
+	}
 	go webhookPool.Start(context.Background())
 	logr.Info("webhook pool iniciada", zap.Int("workers", cfg.Webhook.Workers))
 
-	logr.Info("restaurando sessões...")
-	instances, err := instanceService.List(context.Background())
-	if err == nil {
-		var allInstanceIDs []string
-		for _, inst := range instances {
-			allInstanceIDs = append(allInstanceIDs, inst.ID)
+	var coordinator *cluster.Coordinator
+	// Os encaminhamentos entre nós exigem um segredo próprio: com o JWT_SECRET,
+	// o vazamento de um deles permitiria forjar o outro. Fora do cluster não
+	// há encaminhamento, e a chave derivada só preenche a configuração.
+	clusterSecret := cfg.Cluster.Secret
+	if clusterSecret == "" {
+		clusterSecret = crypto.DeriveSecret(cfg.JWT.Secret, "cluster-forward")
+	}
+	if cfg.Cluster.Enabled {
+		if repos.RedisClient == nil {
+			log.Fatalf("cluster: CLUSTER_ENABLED requer REDIS_ENABLED=true")
 		}
-		if len(allInstanceIDs) > 0 {
-			logr.Info("tentando restaurar sessões",
-				zap.Int("total", len(allInstanceIDs)),
-			)
-			sessionManager.RestoreAllSessions(context.Background(), allInstanceIDs)
-			time.Sleep(3 * time.Second)
-		} else {
-			logr.Info("nenhuma instância encontrada para restaurar")
+		if cfg.Cluster.Secret == "" {
+			log.Fatalf("cluster: CLUSTER_ENABLED requer CLUSTER_SECRET, diferente do JWT_SECRET")
+		}
+		if cfg.Cluster.Secret == cfg.JWT.Secret {
+			log.Fatalf("cluster: CLUSTER_SECRET não pode ser igual ao JWT_SECRET")
+		}
+		hostname, _ := os.Hostname()
+		nodeID := cfg.Cluster.NodeID
+		if nodeID == "" {
+			nodeID = hostname
 		}
+		advertiseURL := cfg.Cluster.AdvertiseURL
+		if advertiseURL == "" {
+			advertiseURL = fmt.Sprintf("http://%s:%s", hostname, cfg.App.Port)
+		}
+		listInstanceIDs := func(ctx context.Context) ([]string, error) {
+			instances, err := instanceService.List(ctx)
+			if err != nil {
+				return nil, err
+			}
+			ids := make([]string, 0, len(instances))
+			for _, inst := range instances {
+				if inst.Provider == model.InstanceProviderCloudAPI || inst.Provider == model.InstanceProviderSandbox {
+					continue
+				}
+				ids = append(ids, inst.ID)
+			}
+			return ids, nil
+		}
+		coordinator, err = cluster.NewCoordinator(repos.RedisClient, sessionManager, listInstanceIDs, cluster.Options{
+			NodeID:       nodeID,
+			AdvertiseURL: advertiseURL,
+			LeaseTTL:     time.Duration(cfg.Cluster.LeaseTTLSeconds) * time.Second,
+		}, logr)
+		if err != nil {
+			log.Fatalf("cluster: %v", err)
+		}
+		sessionManager.SetOwnershipChecker(coordinator.Owns)
+	}
+
+	if coordinator != nil {
+		logr.Info("modo cluster: sessões serão conectadas conforme a posse das instâncias")
+		coordinator.Start(context.Background())
 	} else {
-		logr.Warn("erro ao listar instâncias para restauração", zap.Error(err))
+		logr.Info("restaurando sessões...")
+		instances, err := instanceService.List(context.Background())
+		if err == nil {
+			var allInstanceIDs []string
+			for _, inst := range instances {
+				// Instâncias da Cloud API e sandbox não mantêm sessão.
+				if inst.Provider == model.InstanceProviderCloudAPI || inst.Provider == model.InstanceProviderSandbox {
+					continue
+				}
+				allInstanceIDs = append(allInstanceIDs, inst.ID)
+			}
+			if len(allInstanceIDs) > 0 {
+				logr.Info("tentando restaurar sessões",
+					zap.Int("total", len(allInstanceIDs)),
+				)
+				sessionManager.RestoreAllSessions(context.Background(), allInstanceIDs)
+			} else {
+				logr.Info("nenhuma instância encontrada para restaurar")
+			}
+		} else {
+			logr.Warn("erro ao listar instâncias para restauração", zap.Error(err))
+		}
 	}
 
 	logr.Debug("inicializando serviços")
-	messageService := message.NewServiceWithSession(repos.Message, sessionManager, repos.Instance, repos.Contact, repos.OutboxQueue, logr)
+	messageService := message.NewServiceWithSession(repos.Message, messengers, repos.Instance, repos.Contact, repos.Chat, repos.MessageStatus, repos.OutboxQueue, logr)
 	outboxWorker := message.NewOutboxWorker(messageService, repos.OutboxQueue, logr, cfg.App.OutboxWorkers)
+	if coordinator != nil {
+		outboxWorker.SetOwnershipChecker(coordinator.Owns)
+	}
 	outboxWorker.Start(context.Background())
 	logr.Info("outbox worker iniciado", zap.Int("workers", cfg.App.OutboxWorkers))
 	apiTokenService := api_token.NewService(repos.APIToken)
@@ -158,13 +367,33 @@ func main() {
 	logr.Debug("serviços inicializados")
 
 	instanceHandler := handler.NewInstanceHandlerWithSession(instanceService, logr, sessionManager)
-	messageHandler := handler.NewMessageHandler(messageService)
-	metaHandler := handler.NewMetaHandler(messageService)
-	whatsAppHandler := handler.NewWhatsAppHandler(sessionManager)
+	mediaFetcher := fetch.New(fetch.Config{
+		Timeout:        time.Duration(cfg.Storage.MediaFetch.TimeoutSeconds) * time.Second,
+		ConnectTimeout: time.Duration(cfg.Storage.MediaFetch.ConnectTimeoutSeconds) * time.Second,
+		MaxRedirects:   cfg.Storage.MediaFetch.MaxRedirects,
+		AllowPrivate:   cfg.Storage.MediaFetch.AllowPrivate,
+	})
+	messageHandler := handler.NewMessageHandler(messageService, mediaFetcher)
+	chatHandler := handler.NewChatHandler(chatService)
+	metaHandler := handler.NewMetaHandler(messageService, mediaFetcher)
+	whatsAppHandler := handler.NewWhatsAppHandler(sessionManager, messengers)
 	authHandler := handler.NewAuthHandler(authService)
 	apiTokenHandler := handler.NewAPITokenHandler(apiTokenService)
 	userHandler := handler.NewUserHandler(userService)
-	healthHandler := handler.NewHealthHandler()
+	alertHandler := handler.NewAlertHandler(alertService)
+	transferHandler := handler.NewSessionTransferHandler(instanceService, userService, logr)
+	var cloudAPIHandler *handler.CloudAPIWebhookHandler
+	if cloudAPIManager != nil {
+		cloudAPIHandler = handler.NewCloudAPIWebhookHandler(cloudAPIManager, logr)
+	}
+	var sandboxHandler *handler.SandboxHandler
+	if sandboxManager != nil {
+		sandboxHandler = handler.NewSandboxHandler(instanceService, sandboxManager, logr)
+	}
+	drainTimeout := time.Duration(cfg.App.DrainTimeoutSeconds) * time.Second
+	drainState := middleware.NewDrainState(drainTimeout)
+	healthHandler := handler.NewHealthHandlerWithDrain(drainState)
+	healthHandler.SetRestoreProgress(sessionManager)
 
 	rateLimitOpts := middleware.RateLimitOption{
 		Enabled:  cfg.RateLimit.Enabled,
@@ -181,6 +410,7 @@ func main() {
 		HTMLTemplate:    dashboard.HTMLTemplate(),
 		InstanceHandler: instanceHandler,
 		MessageHandler:  messageHandler,
+		ChatHandler:     chatHandler,
 		MetaHandler:     metaHandler,
 		WhatsAppHandler: whatsAppHandler,
 		AuthHandler:     authHandler,
@@ -190,8 +420,18 @@ func main() {
 		HealthHandler:   healthHandler,
 		UserHandler:     userHandler,
 		MediaHandler:    mediaHandler,
+		AlertHandler:    alertHandler,
+		TransferHandler: transferHandler,
+		CloudAPIHandler: cloudAPIHandler,
+		SandboxHandler:  sandboxHandler,
 		WebhookPool:     webhookPool,
 		RateLimit:       rateLimitOpts,
+		Forward: middleware.ForwardOption{
+			Coordinator: coordinator,
+			Secret:      clusterSecret,
+			Logger:      logr,
+		},
+		Drain: drainState,
 	})
 
 	if cfg.Dashboard.Enabled {
@@ -208,6 +448,8 @@ func main() {
 			Logger:              logr,
 			EnableDashboard:     true,
 			Timezone:            cfg.Dashboard.Timezone,
+			Cluster:             coordinator,
+			ClusterSecret:       clusterSecret,
 		})
 	} else {
 		logr.Info("dashboard desativado via configuração")
@@ -243,14 +485,41 @@ func main() {
 	}
 
 	logr.Info("iniciando shutdown graceful")
-	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
+	drainState.Start()
+	// A pool para de despachar já: as entregas em andamento terminam enquanto
+	// os envios são drenados, e o que está na fila fica para os outros nós.
+	webhookPool.StopDispatch()
+
+	// Cada etapa tem o próprio prazo, para que uma etapa lenta não consuma o
+	// tempo das seguintes.
+	outboxCtx, outboxCancel := context.WithTimeout(context.Background(), drainTimeout)
+	defer outboxCancel()
+	outboxWorker.Drain(outboxCtx)
+	logr.Info("outbox worker encerrado")
+
+	flushCtx, flushCancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer flushCancel()
+	sessionManager.Drain(flushCtx)
+	logr.Info("sessões gravadas e desconectadas")
 
-	webhookPool.Stop()
+	if coordinator != nil {
+		coordinator.Stop(flushCtx)
+		logr.Info("leases de instâncias liberados")
+	}
+
+	if sandboxManager != nil {
+		sandboxManager.Stop()
+	}
+
+	webhookCtx, webhookCancel := context.WithTimeout(context.Background(), drainTimeout)
+	defer webhookCancel()
+	webhookPool.Drain(webhookCtx)
 	logr.Info("webhook pool encerrada")
 
-	outboxWorker.Stop()
-	logr.Info("outbox worker encerrado")
+	alertService.Stop()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
 
 	if repos.RedisClient != nil {
 		if err := repos.RedisClient.Close(); err != nil {
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/open-apime/apime/internal/config"
+	"github.com/open-apime/apime/internal/logger"
+	"github.com/open-apime/apime/internal/pkg/crypto"
+	"github.com/open-apime/apime/internal/service/instance"
+	"github.com/open-apime/apime/internal/service/key_rotation"
+	"github.com/open-apime/apime/internal/session/whatsmeow"
+	"github.com/open-apime/apime/internal/storage"
+)
+
+const usage = `uso:
+  session export -instance <id> [-out arquivo.json] [-release]
+  session import -in arquivo.json [-owner <id ou e-mail>]
+  session rekey
+
+A senha de transferência é lida de -passphrase ou de APIME_TRANSFER_PASSPHRASE.
+rekey recifra os dados com WHATSAPP_SESSION_KEY_ENC, aceitando as chaves de
+WHATSAPP_SESSION_KEYS_PREVIOUS.
+Rode com a API parada: a CLI acessa o banco e o device store diretamente.`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	switch os.Args[1] {
+	case "export":
+		runExport(os.Args[2:])
+	case "import":
+		runImport(os.Args[2:])
+	case "rekey":
+		runRekey()
+	default:
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+}
+
+func runExport(args []string) {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	instanceID := fs.String("instance", "", "ID da instância")
+	out := fs.String("out", "", "Arquivo de saída (padrão: apime-session-<id>.json)")
+	passphrase := fs.String("passphrase", os.Getenv("APIME_TRANSFER_PASSPHRASE"), "Senha de transferência")
+	release := fs.Bool("release", false, "Descartar o device store local após exportar (sem logout)")
+	fs.Parse(args)
+
+	if *instanceID == "" {
+		log.Fatal("session: -instance é obrigatório")
+	}
+	if *out == "" {
+		*out = fmt.Sprintf("apime-session-%s.json", *instanceID)
+	}
+
+	svc, _ := newService()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	bundle, err := svc.ExportSession(ctx, *instanceID, instance.ExportOptions{
+		Passphrase: *passphrase,
+		Release:    *release,
+	})
+	if err != nil {
+		log.Fatalf("session: exportar: %v", err)
+	}
+
+	data, err := json.Marshal(bundle)
+	if err != nil {
+		log.Fatalf("session: codificar bundle: %v", err)
+	}
+	if err := os.WriteFile(*out, data, 0600); err != nil {
+		log.Fatalf("session: gravar %s: %v", *out, err)
+	}
+
+	log.Printf("session: instância %s exportada para %s", *instanceID, *out)
+	if !*release {
+		log.Println("session: o device store local foi mantido; não conecte a instância nos dois servidores ao mesmo tempo")
+	}
+}
+
+func runImport(args []string) {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	in := fs.String("in", "", "Arquivo do bundle")
+	passphrase := fs.String("passphrase", os.Getenv("APIME_TRANSFER_PASSPHRASE"), "Senha de transferência")
+	owner := fs.String("owner", "", "ID ou e-mail do usuário dono neste servidor (padrão: dono original)")
+	fs.Parse(args)
+
+	if *in == "" {
+		log.Fatal("session: -in é obrigatório")
+	}
+
+	data, err := os.ReadFile(*in)
+	if err != nil {
+		log.Fatalf("session: ler %s: %v", *in, err)
+	}
+	var bundle instance.SessionBundle
+	if err := json.Unmarshal(data, &bundle); err != nil {
+		log.Fatalf("session: bundle inválido: %v", err)
+	}
+
+	svc, repos := newService()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	ownerID := *owner
+	if strings.Contains(ownerID, "@") {
+		u, err := repos.User.GetByEmail(ctx, ownerID)
+		if err != nil {
+			log.Fatalf("session: usuário %s não encontrado: %v", ownerID, err)
+		}
+		ownerID = u.ID
+	}
+
+	inst, err := svc.ImportSession(ctx, bundle, instance.ImportOptions{
+		Passphrase:  *passphrase,
+		OwnerUserID: ownerID,
+	})
+	if err != nil {
+		log.Fatalf("session: importar: %v", err)
+	}
+
+	log.Printf("session: instância %s (%s) importada; a sessão será conectada no próximo início da API", inst.ID, inst.Name)
+}
+
+func runRekey() {
+	_, logr, repos, keyring := setup()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+	defer cancel()
+
+	res, err := key_rotation.NewService(keyring, repos.Instance, repos.InstanceProxy, repos.CloudAPI, logr).Run(ctx)
+	if err != nil {
+		log.Fatalf("session: recifrar: %v", err)
+	}
+
+	log.Printf("session: %d instâncias verificadas, %d session blobs, %d proxies e %d credenciais da Cloud API recifrados com a chave %s",
+		res.Instances, res.SessionBlobs, res.Proxies, res.CloudAPI, keyring.CurrentKeyID())
+	if res.Retries > 0 {
+		log.Printf("session: %d session blobs alterados durante a recifragem foram relidos", res.Retries)
+	}
+	if res.Failed > 0 {
+		log.Fatalf("session: %d registros não puderam ser decifrados com as chaves configuradas", res.Failed)
+	}
+}
+
+func setup() (config.Config, *zap.Logger, *storage.Repositories, *crypto.Keyring) {
+	cfg := config.Load()
+
+	logr, err := logger.New(cfg.App.Env, cfg.Log.Level)
+	if err != nil {
+		log.Fatalf("logger: %v", err)
+	}
+
+	repos, err := storage.NewRepositories(cfg, logr)
+	if err != nil {
+		log.Fatalf("storage: %v", err)
+	}
+
+	keyring, err := crypto.NewKeyring(cfg.WhatsApp.SessionKeyEnc, cfg.WhatsApp.SessionKeysPrevious...)
+	if err != nil {
+		log.Fatalf("keyring: %v", err)
+	}
+	return cfg, logr, repos, keyring
+}
+
+func newService() (*instance.Service, *storage.Repositories) {
+	cfg, logr, repos, keyring := setup()
+
+	pgConnString := ""
+	if cfg.Storage.Driver == "postgres" {
+		pgConnString = cfg.DB.DSN()
+	}
+
+	sessionDir := filepath.Join(cfg.Storage.DataDir, "sessions")
+	manager := whatsmeow.NewManager(logr, keyring, cfg.Storage.Driver, sessionDir, pgConnString, repos.DeviceConfig, repos.Instance, repos.HistorySync, repos.Message)
+	manager.SetProxyRepository(repos.InstanceProxy)
+
+	return instance.NewServiceWithSession(repos.Instance, manager), repos
+}
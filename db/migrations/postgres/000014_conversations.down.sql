@@ -0,0 +1,10 @@
+DROP TABLE IF EXISTS chats;
+
+DROP INDEX IF EXISTS idx_message_queue_chat;
+
+ALTER TABLE message_queue
+    DROP COLUMN IF EXISTS direction,
+    DROP COLUMN IF EXISTS sender,
+    DROP COLUMN IF EXISTS chat_jid,
+    DROP COLUMN IF EXISTS quoted_id,
+    DROP COLUMN IF EXISTS media_id;
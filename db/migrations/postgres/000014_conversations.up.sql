@@ -0,0 +1,23 @@
+-- Persistência de mensagens recebidas e conversas
+ALTER TABLE message_queue
+    ADD COLUMN IF NOT EXISTS direction TEXT NOT NULL DEFAULT 'outbound',
+    ADD COLUMN IF NOT EXISTS sender TEXT,
+    ADD COLUMN IF NOT EXISTS chat_jid TEXT,
+    ADD COLUMN IF NOT EXISTS quoted_id TEXT,
+    ADD COLUMN IF NOT EXISTS media_id TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_message_queue_chat ON message_queue(instance_id, chat_jid, created_at);
+
+CREATE TABLE IF NOT EXISTS chats (
+    instance_id UUID NOT NULL REFERENCES instances(id) ON DELETE CASCADE,
+    jid TEXT NOT NULL,
+    name TEXT,
+    last_message_id UUID,
+    last_message_at TIMESTAMPTZ,
+    unread_count INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (instance_id, jid)
+);
+
+CREATE INDEX IF NOT EXISTS idx_chats_last_message_at ON chats(instance_id, last_message_at DESC);
@@ -0,0 +1,2 @@
+ALTER TABLE whatsapp_history_syncs
+    DROP COLUMN IF EXISTS messages_imported;
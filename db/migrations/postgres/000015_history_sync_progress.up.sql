@@ -0,0 +1,3 @@
+-- Progresso da importação do histórico por payload
+ALTER TABLE whatsapp_history_syncs
+    ADD COLUMN IF NOT EXISTS messages_imported INTEGER NOT NULL DEFAULT 0;
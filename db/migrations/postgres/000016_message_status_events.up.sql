@@ -0,0 +1,14 @@
+-- Histórico de confirmações (entregue, lido, reproduzido) por destinatário
+CREATE TABLE IF NOT EXISTS message_status_events (
+    id UUID PRIMARY KEY,
+    instance_id UUID NOT NULL REFERENCES instances(id) ON DELETE CASCADE,
+    message_id TEXT NOT NULL,
+    chat_jid TEXT,
+    participant TEXT NOT NULL DEFAULT '',
+    status TEXT NOT NULL,
+    occurred_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (instance_id, message_id, participant, status)
+);
+
+CREATE INDEX IF NOT EXISTS idx_message_status_events_message ON message_status_events(instance_id, message_id, occurred_at);
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS instance_proxies;
@@ -0,0 +1,10 @@
+-- Proxy de saída por instância (credenciais criptografadas)
+CREATE TABLE IF NOT EXISTS instance_proxies (
+    instance_id UUID PRIMARY KEY REFERENCES instances(id) ON DELETE CASCADE,
+    type TEXT NOT NULL,
+    host TEXT NOT NULL,
+    port INTEGER NOT NULL,
+    credentials_enc BYTEA,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
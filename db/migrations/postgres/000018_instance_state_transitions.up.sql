@@ -0,0 +1,12 @@
+-- Histórico da máquina de estados de conexão das instâncias
+CREATE TABLE IF NOT EXISTS instance_state_transitions (
+    id UUID PRIMARY KEY,
+    instance_id UUID NOT NULL REFERENCES instances(id) ON DELETE CASCADE,
+    from_state TEXT NOT NULL DEFAULT '',
+    to_state TEXT NOT NULL,
+    reason TEXT NOT NULL DEFAULT '',
+    attempt INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_instance_state_transitions_instance ON instance_state_transitions(instance_id, created_at);
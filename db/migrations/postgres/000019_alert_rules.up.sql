@@ -0,0 +1,17 @@
+-- Regras de alerta do ciclo de vida das instâncias
+CREATE TABLE IF NOT EXISTS alert_rules (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    instance_id UUID REFERENCES instances(id) ON DELETE CASCADE,
+    type TEXT NOT NULL,
+    threshold INTEGER NOT NULL DEFAULT 0,
+    channel TEXT NOT NULL,
+    target TEXT NOT NULL,
+    cooldown_seconds INTEGER NOT NULL DEFAULT 900,
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_alert_rules_user_id ON alert_rules(user_id);
+CREATE INDEX IF NOT EXISTS idx_alert_rules_instance_id ON alert_rules(instance_id);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS instance_cloud_api;
+ALTER TABLE instances DROP COLUMN IF EXISTS provider;
@@ -0,0 +1,14 @@
+-- Backend de cada instância e credenciais da Cloud API (segredos criptografados)
+ALTER TABLE instances ADD COLUMN IF NOT EXISTS provider TEXT NOT NULL DEFAULT 'whatsmeow';
+
+CREATE TABLE IF NOT EXISTS instance_cloud_api (
+    instance_id UUID PRIMARY KEY REFERENCES instances(id) ON DELETE CASCADE,
+    phone_number_id TEXT NOT NULL,
+    business_account_id TEXT,
+    secrets_enc BYTEA NOT NULL,
+    verify_token TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_instance_cloud_api_phone_number ON instance_cloud_api(phone_number_id);
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS lid_mappings;
@@ -0,0 +1,12 @@
+-- Associação entre LID e número de telefone aprendida por cada instância
+CREATE TABLE IF NOT EXISTS lid_mappings (
+    instance_id UUID NOT NULL REFERENCES instances(id) ON DELETE CASCADE,
+    lid TEXT NOT NULL,
+    pn TEXT NOT NULL,
+    source TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (instance_id, lid)
+);
+
+CREATE INDEX IF NOT EXISTS idx_lid_mappings_pn ON lid_mappings(instance_id, pn);
@@ -0,0 +1,18 @@
+-- Modo de download de mídia por instância e chaves das mídias ainda não baixadas
+ALTER TABLE instances ADD COLUMN IF NOT EXISTS media_download TEXT NOT NULL DEFAULT 'lazy';
+
+CREATE TABLE IF NOT EXISTS media_refs (
+    instance_id UUID NOT NULL REFERENCES instances(id) ON DELETE CASCADE,
+    media_id TEXT NOT NULL,
+    message_id TEXT NOT NULL,
+    media_type TEXT NOT NULL,
+    direct_path TEXT NOT NULL,
+    media_key BYTEA NOT NULL,
+    file_enc_sha256 BYTEA NOT NULL,
+    file_sha256 BYTEA NOT NULL,
+    file_length BIGINT NOT NULL DEFAULT 0,
+    mimetype TEXT NOT NULL,
+    file_name TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (instance_id, media_id)
+);
@@ -0,0 +1,8 @@
+-- Retenção e cota de mídia por instância
+CREATE TABLE IF NOT EXISTS media_policies (
+    instance_id UUID PRIMARY KEY REFERENCES instances(id) ON DELETE CASCADE,
+    retention_seconds BIGINT NOT NULL DEFAULT 0,
+    quota_bytes BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS idx_message_queue_instance_status;
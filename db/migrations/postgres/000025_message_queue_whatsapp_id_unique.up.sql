@@ -0,0 +1,12 @@
+-- Remove as mensagens gravadas em duplicidade, mantendo a mais antiga
+DELETE FROM message_queue a
+USING message_queue b
+WHERE a.instance_id = b.instance_id
+    AND a.whatsapp_id = b.whatsapp_id
+    AND a.whatsapp_id <> ''
+    AND (a.created_at, a.id) > (b.created_at, b.id);
+
+-- Cada mensagem do WhatsApp é gravada uma única vez por instância
+CREATE UNIQUE INDEX IF NOT EXISTS idx_message_queue_instance_whatsapp_id
+    ON message_queue(instance_id, whatsapp_id)
+    WHERE whatsapp_id IS NOT NULL AND whatsapp_id <> '';
@@ -0,0 +1,23 @@
+-- Persistência de mensagens recebidas e conversas
+ALTER TABLE message_queue ADD COLUMN direction TEXT NOT NULL DEFAULT 'outbound';
+ALTER TABLE message_queue ADD COLUMN sender TEXT;
+ALTER TABLE message_queue ADD COLUMN chat_jid TEXT;
+ALTER TABLE message_queue ADD COLUMN quoted_id TEXT;
+ALTER TABLE message_queue ADD COLUMN media_id TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_message_queue_chat ON message_queue(instance_id, chat_jid, created_at);
+
+CREATE TABLE IF NOT EXISTS chats (
+    instance_id TEXT NOT NULL,
+    jid TEXT NOT NULL,
+    name TEXT,
+    last_message_id TEXT,
+    last_message_at TEXT,
+    unread_count INTEGER NOT NULL DEFAULT 0,
+    created_at TEXT NOT NULL DEFAULT (datetime('now')),
+    updated_at TEXT NOT NULL DEFAULT (datetime('now')),
+    PRIMARY KEY (instance_id, jid),
+    FOREIGN KEY (instance_id) REFERENCES instances(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_chats_last_message_at ON chats(instance_id, last_message_at);
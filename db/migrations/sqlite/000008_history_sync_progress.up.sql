@@ -0,0 +1,2 @@
+-- Progresso da importação do histórico por payload
+ALTER TABLE whatsapp_history_syncs ADD COLUMN messages_imported INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,15 @@
+-- Histórico de confirmações (entregue, lido, reproduzido) por destinatário
+CREATE TABLE IF NOT EXISTS message_status_events (
+    id TEXT PRIMARY KEY,
+    instance_id TEXT NOT NULL,
+    message_id TEXT NOT NULL,
+    chat_jid TEXT,
+    participant TEXT NOT NULL DEFAULT '',
+    status TEXT NOT NULL,
+    occurred_at TEXT NOT NULL,
+    created_at TEXT NOT NULL DEFAULT (datetime('now')),
+    UNIQUE (instance_id, message_id, participant, status),
+    FOREIGN KEY (instance_id) REFERENCES instances(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_message_status_events_message ON message_status_events(instance_id, message_id, occurred_at);
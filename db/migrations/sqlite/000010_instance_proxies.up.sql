@@ -0,0 +1,11 @@
+-- Proxy de saída por instância (credenciais criptografadas)
+CREATE TABLE IF NOT EXISTS instance_proxies (
+    instance_id TEXT PRIMARY KEY,
+    type TEXT NOT NULL,
+    host TEXT NOT NULL,
+    port INTEGER NOT NULL,
+    credentials_enc BLOB,
+    created_at TEXT NOT NULL DEFAULT (datetime('now')),
+    updated_at TEXT NOT NULL DEFAULT (datetime('now')),
+    FOREIGN KEY (instance_id) REFERENCES instances(id) ON DELETE CASCADE
+);
@@ -0,0 +1,13 @@
+-- Histórico da máquina de estados de conexão das instâncias
+CREATE TABLE IF NOT EXISTS instance_state_transitions (
+    id TEXT PRIMARY KEY,
+    instance_id TEXT NOT NULL,
+    from_state TEXT NOT NULL DEFAULT '',
+    to_state TEXT NOT NULL,
+    reason TEXT NOT NULL DEFAULT '',
+    attempt INTEGER NOT NULL DEFAULT 0,
+    created_at TEXT NOT NULL DEFAULT (datetime('now')),
+    FOREIGN KEY (instance_id) REFERENCES instances(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_instance_state_transitions_instance ON instance_state_transitions(instance_id, created_at);
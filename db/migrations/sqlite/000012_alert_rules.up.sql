@@ -0,0 +1,19 @@
+-- Regras de alerta do ciclo de vida das instâncias
+CREATE TABLE IF NOT EXISTS alert_rules (
+    id TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL,
+    instance_id TEXT,
+    type TEXT NOT NULL,
+    threshold INTEGER NOT NULL DEFAULT 0,
+    channel TEXT NOT NULL,
+    target TEXT NOT NULL,
+    cooldown_seconds INTEGER NOT NULL DEFAULT 900,
+    enabled INTEGER NOT NULL DEFAULT 1,
+    created_at TEXT NOT NULL DEFAULT (datetime('now')),
+    updated_at TEXT NOT NULL DEFAULT (datetime('now')),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (instance_id) REFERENCES instances(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_alert_rules_user_id ON alert_rules(user_id);
+CREATE INDEX IF NOT EXISTS idx_alert_rules_instance_id ON alert_rules(instance_id);
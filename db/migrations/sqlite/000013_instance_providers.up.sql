@@ -0,0 +1,15 @@
+-- Backend de cada instância e credenciais da Cloud API (segredos criptografados)
+ALTER TABLE instances ADD COLUMN provider TEXT NOT NULL DEFAULT 'whatsmeow';
+
+CREATE TABLE IF NOT EXISTS instance_cloud_api (
+    instance_id TEXT PRIMARY KEY,
+    phone_number_id TEXT NOT NULL,
+    business_account_id TEXT,
+    secrets_enc BLOB NOT NULL,
+    verify_token TEXT NOT NULL,
+    created_at TEXT NOT NULL DEFAULT (datetime('now')),
+    updated_at TEXT NOT NULL DEFAULT (datetime('now')),
+    FOREIGN KEY (instance_id) REFERENCES instances(id) ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_instance_cloud_api_phone_number ON instance_cloud_api(phone_number_id);
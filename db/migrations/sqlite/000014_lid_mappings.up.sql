@@ -0,0 +1,13 @@
+-- Associação entre LID e número de telefone aprendida por cada instância
+CREATE TABLE IF NOT EXISTS lid_mappings (
+    instance_id TEXT NOT NULL,
+    lid TEXT NOT NULL,
+    pn TEXT NOT NULL,
+    source TEXT NOT NULL,
+    created_at TEXT NOT NULL DEFAULT (datetime('now')),
+    updated_at TEXT NOT NULL DEFAULT (datetime('now')),
+    PRIMARY KEY (instance_id, lid),
+    FOREIGN KEY (instance_id) REFERENCES instances(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_lid_mappings_pn ON lid_mappings(instance_id, pn);
@@ -0,0 +1,19 @@
+-- Modo de download de mídia por instância e chaves das mídias ainda não baixadas
+ALTER TABLE instances ADD COLUMN media_download TEXT NOT NULL DEFAULT 'lazy';
+
+CREATE TABLE IF NOT EXISTS media_refs (
+    instance_id TEXT NOT NULL,
+    media_id TEXT NOT NULL,
+    message_id TEXT NOT NULL,
+    media_type TEXT NOT NULL,
+    direct_path TEXT NOT NULL,
+    media_key BLOB NOT NULL,
+    file_enc_sha256 BLOB NOT NULL,
+    file_sha256 BLOB NOT NULL,
+    file_length INTEGER NOT NULL DEFAULT 0,
+    mimetype TEXT NOT NULL,
+    file_name TEXT,
+    created_at TEXT NOT NULL DEFAULT (datetime('now')),
+    PRIMARY KEY (instance_id, media_id),
+    FOREIGN KEY (instance_id) REFERENCES instances(id) ON DELETE CASCADE
+);
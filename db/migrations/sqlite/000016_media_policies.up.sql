@@ -0,0 +1,9 @@
+-- Retenção e cota de mídia por instância
+CREATE TABLE IF NOT EXISTS media_policies (
+    instance_id TEXT PRIMARY KEY,
+    retention_seconds INTEGER NOT NULL DEFAULT 0,
+    quota_bytes INTEGER NOT NULL DEFAULT 0,
+    created_at TEXT NOT NULL DEFAULT (datetime('now')),
+    updated_at TEXT NOT NULL DEFAULT (datetime('now')),
+    FOREIGN KEY (instance_id) REFERENCES instances(id) ON DELETE CASCADE
+);
@@ -0,0 +1,2 @@
+-- Busca dos envios pendentes pelo detector de mensagens travadas
+CREATE INDEX IF NOT EXISTS idx_message_queue_instance_status ON message_queue(instance_id, status, created_at);
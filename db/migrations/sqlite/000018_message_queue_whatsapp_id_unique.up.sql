@@ -0,0 +1,15 @@
+-- Remove as mensagens gravadas em duplicidade, mantendo a mais antiga
+DELETE FROM message_queue
+WHERE whatsapp_id IS NOT NULL AND whatsapp_id <> ''
+    AND EXISTS (
+        SELECT 1 FROM message_queue b
+        WHERE b.instance_id = message_queue.instance_id
+            AND b.whatsapp_id = message_queue.whatsapp_id
+            AND (b.created_at < message_queue.created_at
+                OR (b.created_at = message_queue.created_at AND b.id < message_queue.id))
+    );
+
+-- Cada mensagem do WhatsApp é gravada uma única vez por instância
+CREATE UNIQUE INDEX IF NOT EXISTS idx_message_queue_instance_whatsapp_id
+    ON message_queue(instance_id, whatsapp_id)
+    WHERE whatsapp_id IS NOT NULL AND whatsapp_id <> '';
@@ -0,0 +1,6 @@
+-- Converte last_message_at para UTC: a coluna é comparada como texto, e
+-- datas com fusos diferentes ficavam fora de ordem
+UPDATE chats
+SET last_message_at = strftime('%Y-%m-%dT%H:%M:%SZ', last_message_at)
+WHERE last_message_at IS NOT NULL
+    AND strftime('%Y-%m-%dT%H:%M:%SZ', last_message_at) IS NOT NULL;
@@ -0,0 +1,112 @@
+package alert
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/open-apime/apime/internal/storage/model"
+	"github.com/open-apime/apime/internal/webhook/delivery"
+)
+
+// Alert é a notificação enviada pelos canais.
+type Alert struct {
+	RuleID       string          `json:"ruleId"`
+	Type         model.AlertType `json:"type"`
+	InstanceID   string          `json:"instanceId"`
+	InstanceName string          `json:"instanceName,omitempty"`
+	Title        string          `json:"title"`
+	Message      string          `json:"message"`
+	Test         bool            `json:"test,omitempty"`
+	TriggeredAt  time.Time       `json:"triggeredAt"`
+}
+
+// Notifier entrega um alerta ao destino configurado na regra.
+type Notifier interface {
+	Notify(ctx context.Context, target string, alert Alert) error
+}
+
+type SMTPConfig struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+}
+
+type emailNotifier struct {
+	cfg SMTPConfig
+}
+
+func NewEmailNotifier(cfg SMTPConfig) Notifier {
+	return &emailNotifier{cfg: cfg}
+}
+
+func (n *emailNotifier) Notify(_ context.Context, target string, alert Alert) error {
+	if n.cfg.Host == "" || n.cfg.From == "" {
+		return errors.New("alert: SMTP não configurado")
+	}
+
+	var body strings.Builder
+	fmt.Fprintf(&body, "From: %s\r\n", n.cfg.From)
+	fmt.Fprintf(&body, "To: %s\r\n", target)
+	fmt.Fprintf(&body, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", alert.Title))
+	fmt.Fprintf(&body, "Date: %s\r\n", alert.TriggeredAt.Format(time.RFC1123Z))
+	body.WriteString("MIME-Version: 1.0\r\n")
+	body.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	body.WriteString(alert.Message)
+	fmt.Fprintf(&body, "\r\n\r\nInstância: %s (%s)\r\n", alert.InstanceName, alert.InstanceID)
+
+	var auth smtp.Auth
+	if n.cfg.Username != "" {
+		auth = smtp.PlainAuth("", n.cfg.Username, n.cfg.Password, n.cfg.Host)
+	}
+
+	addr := net.JoinHostPort(n.cfg.Host, strconv.Itoa(n.cfg.Port))
+	if err := smtp.SendMail(addr, auth, n.cfg.From, []string{target}, []byte(body.String())); err != nil {
+		return fmt.Errorf("alert: enviar e-mail: %w", err)
+	}
+	return nil
+}
+
+// webhookNotifier envia o alerta como JSON, no mesmo formato de envelope dos
+// eventos de instância.
+type webhookNotifier struct {
+	delivery *delivery.Delivery
+}
+
+func NewWebhookNotifier(d *delivery.Delivery) Notifier {
+	return &webhookNotifier{delivery: d}
+}
+
+func (n *webhookNotifier) Notify(ctx context.Context, target string, alert Alert) error {
+	return n.delivery.Deliver(ctx, target, "", map[string]interface{}{
+		"instanceId": alert.InstanceID,
+		"type":       "alert." + string(alert.Type),
+		"payload":    alert,
+		"createdAt":  alert.TriggeredAt,
+	})
+}
+
+// slackNotifier usa o formato de incoming webhook do Slack, aceito também por
+// Mattermost, Rocket.Chat e Discord (/slack).
+type slackNotifier struct {
+	delivery *delivery.Delivery
+}
+
+func NewSlackNotifier(d *delivery.Delivery) Notifier {
+	return &slackNotifier{delivery: d}
+}
+
+func (n *slackNotifier) Notify(ctx context.Context, target string, alert Alert) error {
+	text := fmt.Sprintf("*%s*\n%s\nInstância: %s (`%s`)", alert.Title, alert.Message, alert.InstanceName, alert.InstanceID)
+	return n.delivery.Deliver(ctx, target, "", map[string]interface{}{
+		"text": text,
+	})
+}
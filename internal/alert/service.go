@@ -0,0 +1,555 @@
+package alert
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/mail"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/open-apime/apime/internal/storage"
+	"github.com/open-apime/apime/internal/storage/model"
+	storage_redis "github.com/open-apime/apime/internal/storage/redis"
+	"github.com/open-apime/apime/internal/webhook/delivery"
+)
+
+var (
+	ErrInvalidType      = errors.New("tipo de alerta inválido")
+	ErrInvalidChannel   = errors.New("canal de alerta inválido")
+	ErrInvalidTarget    = errors.New("destino do alerta inválido para o canal")
+	ErrInvalidThreshold = errors.New("threshold inválido")
+)
+
+// stuckWindow é a janela usada para a taxa de mensagens travadas.
+const stuckWindow = time.Hour
+
+// defaultThresholds é usado quando a regra é criada sem threshold.
+var defaultThresholds = map[model.AlertType]int{
+	model.AlertTypeDisconnected:    5,
+	model.AlertTypeStuckMessages:   5,
+	model.AlertTypeWebhookFailures: 5,
+}
+
+// StateSource fornece o estado de conexão das instâncias deste nó.
+type StateSource interface {
+	ConnectionStates() []model.InstanceConnectionState
+}
+
+type Options struct {
+	CheckInterval   time.Duration
+	DefaultCooldown time.Duration
+	SMTP            SMTPConfig
+}
+
+type RuleInput struct {
+	InstanceID      string
+	Type            model.AlertType
+	Threshold       int
+	Channel         model.AlertChannel
+	Target          string
+	CooldownSeconds int
+	Enabled         bool
+}
+
+type stuckSample struct {
+	at    time.Time
+	count int
+}
+
+// webhookStreak é uma sequência de falhas de webhook seguidas; since
+// identifica a sequência para a deduplicação.
+type webhookStreak struct {
+	count int
+	since time.Time
+}
+
+// Service avalia as regras de alerta e envia as notificações.
+//
+// Cada condição dispara uma vez por ocorrência (um logout, um período
+// desconectado, uma sequência de falhas de webhook) e, entre dois envios da
+// mesma regra para a mesma instância, respeita o cooldown da regra. Com Redis,
+// o cooldown é compartilhado entre os nós do cluster. Uma notificação que
+// falha não conta: a ocorrência e o cooldown são liberados para a próxima
+// avaliação tentar de novo.
+type Service struct {
+	rules     storage.AlertRuleRepository
+	instances storage.InstanceRepository
+	redis     *storage_redis.Client
+	notifiers map[model.AlertChannel]Notifier
+	opts      Options
+	log       *zap.Logger
+
+	states StateSource
+
+	mu              sync.Mutex
+	cooldowns       map[string]time.Time
+	stuck           map[string][]stuckSample
+	webhookFailures map[string]webhookStreak
+	// fired guarda, por regra e instância, a última ocorrência já alertada.
+	fired map[string]string
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func NewService(rules storage.AlertRuleRepository, instances storage.InstanceRepository, redisClient *storage_redis.Client, log *zap.Logger, opts Options) *Service {
+	if opts.CheckInterval <= 0 {
+		opts.CheckInterval = 30 * time.Second
+	}
+	if opts.DefaultCooldown <= 0 {
+		opts.DefaultCooldown = 15 * time.Minute
+	}
+
+	alertDelivery := delivery.NewDelivery(log, 2)
+	return &Service{
+		rules:     rules,
+		instances: instances,
+		redis:     redisClient,
+		notifiers: map[model.AlertChannel]Notifier{
+			model.AlertChannelEmail:   NewEmailNotifier(opts.SMTP),
+			model.AlertChannelWebhook: NewWebhookNotifier(alertDelivery),
+			model.AlertChannelSlack:   NewSlackNotifier(alertDelivery),
+		},
+		opts:            opts,
+		log:             log,
+		cooldowns:       make(map[string]time.Time),
+		stuck:           make(map[string][]stuckSample),
+		webhookFailures: make(map[string]webhookStreak),
+		fired:           make(map[string]string),
+	}
+}
+
+func (s *Service) SetStateSource(src StateSource) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.states = src
+}
+
+// Start inicia a verificação periódica das instâncias desconectadas.
+func (s *Service) Start(ctx context.Context) {
+	ctx, s.cancel = context.WithCancel(ctx)
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		ticker := time.NewTicker(s.opts.CheckInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.checkDisconnected(ctx)
+			}
+		}
+	}()
+
+	s.log.Info("alertas: avaliação iniciada", zap.Duration("interval", s.opts.CheckInterval))
+}
+
+func (s *Service) Stop() {
+	if s.cancel != nil {
+		s.cancel()
+	}
+	s.wg.Wait()
+}
+
+// OnStateTransition recebe as transições de estado de conexão das instâncias.
+func (s *Service) OnStateTransition(t model.InstanceStateTransition) {
+	var alertType model.AlertType
+	var title string
+	switch t.To {
+	case model.ConnectionStateLoggedOut:
+		alertType = model.AlertTypeLoggedOut
+		title = "Instância deslogada do WhatsApp"
+	case model.ConnectionStateBanned:
+		alertType = model.AlertTypeBanned
+		title = "Instância temporariamente banida pelo WhatsApp"
+	default:
+		return
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+
+		message := title + "."
+		if t.Reason != "" {
+			message = fmt.Sprintf("%s. Motivo: %s.", title, t.Reason)
+		}
+		s.evaluate(ctx, t.InstanceID, alertType, "", func(model.AlertRule) bool { return true }, title, message)
+	}()
+}
+
+// OnWebhookDelivery acompanha as falhas consecutivas de entrega de webhook.
+// Cada regra dispara uma vez por sequência, a partir da falha que atinge seu
+// threshold.
+func (s *Service) OnWebhookDelivery(instanceID string, err error) {
+	s.mu.Lock()
+	if err == nil {
+		delete(s.webhookFailures, instanceID)
+		s.mu.Unlock()
+		return
+	}
+	streak := s.webhookFailures[instanceID]
+	if streak.count == 0 {
+		streak.since = time.Now()
+	}
+	streak.count++
+	s.webhookFailures[instanceID] = streak
+	s.mu.Unlock()
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+
+		title := "Falhas consecutivas na entrega de webhook"
+		message := fmt.Sprintf("%d entregas de webhook seguidas falharam. Último erro: %s", streak.count, err.Error())
+		occurrence := streak.since.Format(time.RFC3339Nano)
+		s.evaluate(ctx, instanceID, model.AlertTypeWebhookFailures, occurrence, func(rule model.AlertRule) bool {
+			return streak.count >= rule.Threshold
+		}, title, message)
+	}()
+}
+
+// OnStuckMessages recebe as mensagens marcadas como travadas pelo detector.
+func (s *Service) OnStuckMessages(instanceID string, count int) {
+	now := time.Now()
+
+	s.mu.Lock()
+	samples := append(s.stuck[instanceID], stuckSample{at: now, count: count})
+	total := 0
+	kept := samples[:0]
+	for _, sample := range samples {
+		if now.Sub(sample.at) <= stuckWindow {
+			kept = append(kept, sample)
+			total += sample.count
+		}
+	}
+	s.stuck[instanceID] = kept
+	s.mu.Unlock()
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+
+		title := "Taxa alta de mensagens travadas"
+		message := fmt.Sprintf("%d mensagens travaram sem confirmação na última hora.", total)
+		s.evaluate(ctx, instanceID, model.AlertTypeStuckMessages, "", func(rule model.AlertRule) bool {
+			return total >= rule.Threshold
+		}, title, message)
+	}()
+}
+
+func (s *Service) checkDisconnected(ctx context.Context) {
+	s.mu.Lock()
+	src := s.states
+	s.mu.Unlock()
+	if src == nil {
+		return
+	}
+
+	for _, state := range src.ConnectionStates() {
+		if state.DisconnectedSince == nil {
+			continue
+		}
+		downSince := *state.DisconnectedSince
+		elapsed := time.Since(downSince)
+
+		title := "Instância desconectada"
+		message := fmt.Sprintf("A instância está sem conexão há %d minutos (estado atual: %s).", int(elapsed.Minutes()), state.State)
+		occurrence := downSince.Format(time.RFC3339Nano)
+		s.evaluate(ctx, state.InstanceID, model.AlertTypeDisconnected, occurrence, func(rule model.AlertRule) bool {
+			return elapsed >= time.Duration(rule.Threshold)*time.Minute
+		}, title, message)
+	}
+}
+
+// evaluate envia o alerta pelas regras habilitadas do tipo que se aplicam à
+// instância e cuja condição match aceita. Com occurrence preenchido, cada
+// regra notifica a ocorrência uma única vez; vazio, cada chamada é uma
+// ocorrência nova.
+func (s *Service) evaluate(ctx context.Context, instanceID string, alertType model.AlertType, occurrence string, match func(model.AlertRule) bool, title, message string) {
+	inst, err := s.instances.GetByID(ctx, instanceID)
+	if err != nil {
+		s.log.Warn("alertas: instância não encontrada",
+			zap.String("instance_id", instanceID),
+			zap.Error(err),
+		)
+		return
+	}
+
+	rules, err := s.rules.List(ctx)
+	if err != nil {
+		s.log.Error("alertas: erro ao listar regras", zap.Error(err))
+		return
+	}
+
+	for _, rule := range rules {
+		if !rule.Enabled || rule.Type != alertType || !appliesTo(rule, inst) || !match(rule) {
+			continue
+		}
+		firedKey := rule.ID + ":" + instanceID
+		if occurrence != "" && !s.claimOccurrence(firedKey, occurrence) {
+			continue
+		}
+		release, ok := s.acquireCooldown(ctx, rule, instanceID)
+		if !ok {
+			// A ocorrência continua pendente: é alertada na primeira
+			// avaliação depois do cooldown, se ainda estiver em curso.
+			if occurrence != "" {
+				s.releaseOccurrence(firedKey, occurrence)
+			}
+			s.log.Debug("alertas: regra em cooldown",
+				zap.String("rule_id", rule.ID),
+				zap.String("instance_id", instanceID),
+			)
+			continue
+		}
+
+		err := s.send(ctx, rule, Alert{
+			RuleID:       rule.ID,
+			Type:         alertType,
+			InstanceID:   instanceID,
+			InstanceName: inst.Name,
+			Title:        title,
+			Message:      message,
+			TriggeredAt:  time.Now(),
+		})
+		if err != nil {
+			release()
+			if occurrence != "" {
+				s.releaseOccurrence(firedKey, occurrence)
+			}
+		}
+	}
+}
+
+// claimOccurrence marca a ocorrência como alertada pela regra e instância da
+// chave. Retorna false se ela já tinha sido alertada.
+func (s *Service) claimOccurrence(key, occurrence string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.fired[key] == occurrence {
+		return false
+	}
+	s.fired[key] = occurrence
+	return true
+}
+
+// releaseOccurrence desfaz claimOccurrence depois de uma notificação que
+// falhou ou foi barrada pelo cooldown, se nenhuma ocorrência mais nova tiver
+// sido marcada.
+func (s *Service) releaseOccurrence(key, occurrence string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.fired[key] == occurrence {
+		delete(s.fired, key)
+	}
+}
+
+// appliesTo indica se a regra cobre a instância: regras com instância valem
+// só para ela, e as demais para todas as instâncias do dono da regra.
+func appliesTo(rule model.AlertRule, inst model.Instance) bool {
+	if rule.InstanceID != "" {
+		return rule.InstanceID == inst.ID
+	}
+	return rule.UserID == inst.OwnerUserID
+}
+
+// acquireCooldown inicia o cooldown da regra para a instância. Retorna false
+// se ele já está em curso; release desfaz o cooldown quando a notificação
+// falha.
+func (s *Service) acquireCooldown(ctx context.Context, rule model.AlertRule, instanceID string) (release func(), ok bool) {
+	cooldown := time.Duration(rule.CooldownSeconds) * time.Second
+	if cooldown <= 0 {
+		cooldown = s.opts.DefaultCooldown
+	}
+	key := fmt.Sprintf("apime:alert:cooldown:%s:%s", rule.ID, instanceID)
+
+	if s.redis != nil {
+		// Depois de um envio bem-sucedido o lock não é liberado: expira
+		// sozinho ao fim do cooldown.
+		lock := storage_redis.NewLock(s.redis, key, cooldown)
+		acquired, err := lock.Acquire(ctx)
+		if err == nil {
+			return func() {
+				releaseCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+				defer cancel()
+				if err := lock.Release(releaseCtx); err != nil {
+					s.log.Warn("alertas: erro ao liberar cooldown no redis", zap.Error(err))
+				}
+			}, acquired
+		}
+		s.log.Warn("alertas: erro ao consultar cooldown no redis, usando memória", zap.Error(err))
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if last, ok := s.cooldowns[key]; ok && time.Since(last) < cooldown {
+		return func() {}, false
+	}
+	now := time.Now()
+	s.cooldowns[key] = now
+	return func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.cooldowns[key].Equal(now) {
+			delete(s.cooldowns, key)
+		}
+	}, true
+}
+
+func (s *Service) send(ctx context.Context, rule model.AlertRule, alert Alert) error {
+	notifier, ok := s.notifiers[rule.Channel]
+	if !ok {
+		return ErrInvalidChannel
+	}
+
+	if err := notifier.Notify(ctx, rule.Target, alert); err != nil {
+		s.log.Error("alertas: falha ao enviar notificação",
+			zap.String("rule_id", rule.ID),
+			zap.String("instance_id", alert.InstanceID),
+			zap.String("channel", string(rule.Channel)),
+			zap.Error(err),
+		)
+		return err
+	}
+
+	s.log.Info("alertas: notificação enviada",
+		zap.String("rule_id", rule.ID),
+		zap.String("instance_id", alert.InstanceID),
+		zap.String("type", string(alert.Type)),
+		zap.String("channel", string(rule.Channel)),
+	)
+	return nil
+}
+
+func (s *Service) ListRules(ctx context.Context, userID, userRole string) ([]model.AlertRule, error) {
+	if userRole == "admin" {
+		return s.rules.List(ctx)
+	}
+	return s.rules.ListByUser(ctx, userID)
+}
+
+func (s *Service) GetRule(ctx context.Context, id, userID, userRole string) (model.AlertRule, error) {
+	rule, err := s.rules.GetByID(ctx, id)
+	if err != nil {
+		return model.AlertRule{}, err
+	}
+	if userRole != "admin" && rule.UserID != userID {
+		return model.AlertRule{}, storage.ErrNotFound
+	}
+	return rule, nil
+}
+
+func (s *Service) CreateRule(ctx context.Context, input RuleInput, userID, userRole string) (model.AlertRule, error) {
+	rule := model.AlertRule{UserID: userID}
+	if err := s.apply(ctx, &rule, input, userID, userRole); err != nil {
+		return model.AlertRule{}, err
+	}
+	return s.rules.Create(ctx, rule)
+}
+
+func (s *Service) UpdateRule(ctx context.Context, id string, input RuleInput, userID, userRole string) (model.AlertRule, error) {
+	rule, err := s.GetRule(ctx, id, userID, userRole)
+	if err != nil {
+		return model.AlertRule{}, err
+	}
+	if err := s.apply(ctx, &rule, input, userID, userRole); err != nil {
+		return model.AlertRule{}, err
+	}
+	return s.rules.Update(ctx, rule)
+}
+
+func (s *Service) DeleteRule(ctx context.Context, id, userID, userRole string) error {
+	if _, err := s.GetRule(ctx, id, userID, userRole); err != nil {
+		return err
+	}
+	return s.rules.Delete(ctx, id)
+}
+
+// TestRule envia uma notificação de teste pelo canal da regra, ignorando a
+// condição e o cooldown.
+func (s *Service) TestRule(ctx context.Context, id, userID, userRole string) error {
+	rule, err := s.GetRule(ctx, id, userID, userRole)
+	if err != nil {
+		return err
+	}
+
+	alert := Alert{
+		RuleID:      rule.ID,
+		Type:        rule.Type,
+		InstanceID:  rule.InstanceID,
+		Title:       "Teste de alerta ApiMe",
+		Message:     fmt.Sprintf("Notificação de teste da regra %s (%s).", rule.ID, rule.Type),
+		Test:        true,
+		TriggeredAt: time.Now(),
+	}
+	if rule.InstanceID != "" {
+		if inst, err := s.instances.GetByID(ctx, rule.InstanceID); err == nil {
+			alert.InstanceName = inst.Name
+		}
+	}
+	return s.send(ctx, rule, alert)
+}
+
+func (s *Service) apply(ctx context.Context, rule *model.AlertRule, input RuleInput, userID, userRole string) error {
+	switch input.Type {
+	case model.AlertTypeLoggedOut, model.AlertTypeBanned:
+		input.Threshold = 0
+	case model.AlertTypeDisconnected, model.AlertTypeStuckMessages, model.AlertTypeWebhookFailures:
+		if input.Threshold < 0 {
+			return ErrInvalidThreshold
+		}
+		if input.Threshold == 0 {
+			input.Threshold = defaultThresholds[input.Type]
+		}
+	default:
+		return ErrInvalidType
+	}
+
+	target := strings.TrimSpace(input.Target)
+	switch input.Channel {
+	case model.AlertChannelEmail:
+		if _, err := mail.ParseAddress(target); err != nil {
+			return ErrInvalidTarget
+		}
+	case model.AlertChannelWebhook, model.AlertChannelSlack:
+		u, err := url.Parse(target)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return ErrInvalidTarget
+		}
+	default:
+		return ErrInvalidChannel
+	}
+
+	if input.CooldownSeconds < 0 {
+		input.CooldownSeconds = 0
+	}
+
+	if input.InstanceID != "" {
+		inst, err := s.instances.GetByID(ctx, input.InstanceID)
+		if err != nil {
+			return err
+		}
+		if userRole != "admin" && inst.OwnerUserID != userID {
+			return storage.ErrNotFound
+		}
+	}
+
+	rule.InstanceID = input.InstanceID
+	rule.Type = input.Type
+	rule.Threshold = input.Threshold
+	rule.Channel = input.Channel
+	rule.Target = target
+	rule.CooldownSeconds = input.CooldownSeconds
+	rule.Enabled = input.Enabled
+	return nil
+}
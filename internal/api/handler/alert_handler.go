@@ -0,0 +1,182 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	alertSvc "github.com/open-apime/apime/internal/alert"
+	"github.com/open-apime/apime/internal/pkg/response"
+	"github.com/open-apime/apime/internal/storage/model"
+)
+
+type AlertHandler struct {
+	service *alertSvc.Service
+}
+
+func NewAlertHandler(service *alertSvc.Service) *AlertHandler {
+	return &AlertHandler{service: service}
+}
+
+func (h *AlertHandler) Register(r *gin.RouterGroup) {
+	rules := r.Group("/alerts/rules")
+	{
+		rules.GET("", h.list)
+		rules.POST("", h.create)
+		rules.GET("/:id", h.get)
+		rules.PUT("/:id", h.update)
+		rules.DELETE("/:id", h.delete)
+		rules.POST("/:id/test", h.test)
+	}
+}
+
+type alertRuleRequest struct {
+	InstanceID      string `json:"instanceId"`
+	Type            string `json:"type" binding:"required"`
+	Threshold       int    `json:"threshold"`
+	Channel         string `json:"channel" binding:"required"`
+	Target          string `json:"target" binding:"required"`
+	CooldownSeconds int    `json:"cooldownSeconds"`
+	Enabled         *bool  `json:"enabled"`
+}
+
+func (r alertRuleRequest) input() alertSvc.RuleInput {
+	enabled := true
+	if r.Enabled != nil {
+		enabled = *r.Enabled
+	}
+	return alertSvc.RuleInput{
+		InstanceID:      r.InstanceID,
+		Type:            model.AlertType(r.Type),
+		Threshold:       r.Threshold,
+		Channel:         model.AlertChannel(r.Channel),
+		Target:          r.Target,
+		CooldownSeconds: r.CooldownSeconds,
+		Enabled:         enabled,
+	}
+}
+
+// alertUser retorna o usuário autenticado. Tokens de instância não gerenciam
+// regras de alerta, que pertencem a usuários.
+func alertUser(c *gin.Context) (string, bool) {
+	userID := c.GetString("userID")
+	if userID == "" {
+		response.ErrorWithMessage(c, http.StatusForbidden, "endpoint disponível apenas para usuários autenticados")
+		return "", false
+	}
+	return userID, true
+}
+
+func (h *AlertHandler) list(c *gin.Context) {
+	userID, ok := alertUser(c)
+	if !ok {
+		return
+	}
+
+	rules, err := h.service.ListRules(c.Request.Context(), userID, c.GetString("userRole"))
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, err)
+		return
+	}
+	response.Success(c, http.StatusOK, rules)
+}
+
+func (h *AlertHandler) get(c *gin.Context) {
+	userID, ok := alertUser(c)
+	if !ok {
+		return
+	}
+
+	rule, err := h.service.GetRule(c.Request.Context(), c.Param("id"), userID, c.GetString("userRole"))
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+	response.Success(c, http.StatusOK, rule)
+}
+
+func (h *AlertHandler) create(c *gin.Context) {
+	userID, ok := alertUser(c)
+	if !ok {
+		return
+	}
+
+	var req alertRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, err)
+		return
+	}
+
+	rule, err := h.service.CreateRule(c.Request.Context(), req.input(), userID, c.GetString("userRole"))
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+	response.Success(c, http.StatusCreated, rule)
+}
+
+func (h *AlertHandler) update(c *gin.Context) {
+	userID, ok := alertUser(c)
+	if !ok {
+		return
+	}
+
+	var req alertRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, err)
+		return
+	}
+
+	rule, err := h.service.UpdateRule(c.Request.Context(), c.Param("id"), req.input(), userID, c.GetString("userRole"))
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+	response.Success(c, http.StatusOK, rule)
+}
+
+func (h *AlertHandler) delete(c *gin.Context) {
+	userID, ok := alertUser(c)
+	if !ok {
+		return
+	}
+
+	if err := h.service.DeleteRule(c.Request.Context(), c.Param("id"), userID, c.GetString("userRole")); err != nil {
+		h.handleError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (h *AlertHandler) test(c *gin.Context) {
+	userID, ok := alertUser(c)
+	if !ok {
+		return
+	}
+
+	if err := h.service.TestRule(c.Request.Context(), c.Param("id"), userID, c.GetString("userRole")); err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			response.ErrorWithMessage(c, http.StatusNotFound, "regra não encontrada")
+			return
+		}
+		response.Error(c, http.StatusBadGateway, err)
+		return
+	}
+	response.Success(c, http.StatusOK, gin.H{"message": "notificação de teste enviada"})
+}
+
+func (h *AlertHandler) handleError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, alertSvc.ErrInvalidType),
+		errors.Is(err, alertSvc.ErrInvalidChannel),
+		errors.Is(err, alertSvc.ErrInvalidTarget),
+		errors.Is(err, alertSvc.ErrInvalidThreshold):
+		response.Error(c, http.StatusBadRequest, err)
+	case strings.Contains(err.Error(), "not found"):
+		response.ErrorWithMessage(c, http.StatusNotFound, "regra ou instância não encontrada")
+	default:
+		response.Error(c, http.StatusInternalServerError, err)
+	}
+}
@@ -0,0 +1,88 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/open-apime/apime/internal/pkg/response"
+	chatSvc "github.com/open-apime/apime/internal/service/chat"
+)
+
+type ChatHandler struct {
+	service *chatSvc.Service
+}
+
+func NewChatHandler(service *chatSvc.Service) *ChatHandler {
+	return &ChatHandler{service: service}
+}
+
+func (h *ChatHandler) Register(r *gin.RouterGroup) {
+	r.GET("/instances/:id/chats", h.list)
+	r.GET("/instances/:id/chats/:jid/messages", h.listMessages)
+}
+
+func (h *ChatHandler) list(c *gin.Context) {
+	instanceID := c.Param("id")
+	if c.GetString("authType") != "instance_token" {
+		response.ErrorWithMessage(c, http.StatusForbidden, "endpoint disponível apenas com token de instância")
+		return
+	}
+	if c.GetString("instanceID") != instanceID {
+		response.ErrorWithMessage(c, http.StatusForbidden, "token inválido para esta instância")
+		return
+	}
+
+	chats, err := h.service.ListChats(c.Request.Context(), instanceID)
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, err)
+		return
+	}
+	response.Success(c, http.StatusOK, chats)
+}
+
+func (h *ChatHandler) listMessages(c *gin.Context) {
+	instanceID := c.Param("id")
+	if c.GetString("authType") != "instance_token" {
+		response.ErrorWithMessage(c, http.StatusForbidden, "endpoint disponível apenas com token de instância")
+		return
+	}
+	if c.GetString("instanceID") != instanceID {
+		response.ErrorWithMessage(c, http.StatusForbidden, "token inválido para esta instância")
+		return
+	}
+
+	limit := 0
+	if v := c.Query("limit"); v != "" {
+		parsed, err := strconv.Atoi(v)
+		if err != nil || parsed <= 0 {
+			response.ErrorWithMessage(c, http.StatusBadRequest, "limit inválido")
+			return
+		}
+		limit = parsed
+	}
+
+	var before *time.Time
+	if v := c.Query("before"); v != "" {
+		parsed, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			response.ErrorWithMessage(c, http.StatusBadRequest, "before deve estar no formato RFC3339")
+			return
+		}
+		before = &parsed
+	}
+
+	messages, err := h.service.ListMessages(c.Request.Context(), instanceID, c.Param("jid"), before, limit)
+	if err != nil {
+		if errors.Is(err, chatSvc.ErrInvalidChatJID) {
+			response.Error(c, http.StatusBadRequest, err)
+			return
+		}
+		response.Error(c, http.StatusInternalServerError, err)
+		return
+	}
+	response.Success(c, http.StatusOK, messages)
+}
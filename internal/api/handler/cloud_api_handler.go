@@ -0,0 +1,75 @@
+package handler
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/open-apime/apime/internal/pkg/response"
+	"github.com/open-apime/apime/internal/session/cloudapi"
+)
+
+// maxCloudAPIWebhookBody limita o corpo aceito no webhook da Meta.
+const maxCloudAPIWebhookBody = 4 << 20
+
+// CloudAPIWebhookHandler recebe o webhook da Meta das instâncias da Cloud API.
+// As rotas são públicas: a Meta é autenticada pelo verify token no cadastro e
+// pela assinatura X-Hub-Signature-256 nas entregas.
+type CloudAPIWebhookHandler struct {
+	manager *cloudapi.Manager
+	log     *zap.Logger
+}
+
+func NewCloudAPIWebhookHandler(manager *cloudapi.Manager, log *zap.Logger) *CloudAPIWebhookHandler {
+	return &CloudAPIWebhookHandler{manager: manager, log: log}
+}
+
+func (h *CloudAPIWebhookHandler) Register(r *gin.RouterGroup) {
+	r.GET("/cloud-api/webhook/:id", h.verify)
+	r.POST("/cloud-api/webhook/:id", h.receive)
+}
+
+func (h *CloudAPIWebhookHandler) verify(c *gin.Context) {
+	id := c.Param("id")
+
+	challenge, err := h.manager.VerifyWebhook(c.Request.Context(), id,
+		c.Query("hub.mode"), c.Query("hub.verify_token"), c.Query("hub.challenge"))
+	if err != nil {
+		h.log.Warn("verificação do webhook da Cloud API recusada", zap.String("instance_id", id), zap.Error(err))
+		if strings.Contains(err.Error(), "not found") {
+			response.ErrorWithMessage(c, http.StatusNotFound, "instância não encontrada")
+			return
+		}
+		response.ErrorWithMessage(c, http.StatusForbidden, "verify token inválido")
+		return
+	}
+	c.String(http.StatusOK, challenge)
+}
+
+func (h *CloudAPIWebhookHandler) receive(c *gin.Context) {
+	id := c.Param("id")
+
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxCloudAPIWebhookBody))
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := h.manager.HandleWebhook(c.Request.Context(), id, body, c.GetHeader("X-Hub-Signature-256")); err != nil {
+		h.log.Warn("erro ao processar webhook da Cloud API", zap.String("instance_id", id), zap.Error(err))
+		switch {
+		case errors.Is(err, cloudapi.ErrInvalidSignature):
+			response.ErrorWithMessage(c, http.StatusUnauthorized, err.Error())
+		case strings.Contains(err.Error(), "not found"):
+			response.ErrorWithMessage(c, http.StatusNotFound, "instância não encontrada")
+		default:
+			response.Error(c, http.StatusInternalServerError, err)
+		}
+		return
+	}
+	c.Status(http.StatusOK)
+}
@@ -5,15 +5,36 @@ import (
 
 	"github.com/gin-gonic/gin"
 
+	"github.com/open-apime/apime/internal/api/middleware"
 	"github.com/open-apime/apime/internal/config"
+	"github.com/open-apime/apime/internal/storage/model"
 )
 
-type HealthHandler struct{}
+// RestoreProgressSource informa o andamento da restauração das sessões feita
+// na inicialização do nó.
+type RestoreProgressSource interface {
+	RestoreProgress() model.SessionRestoreProgress
+}
+
+type HealthHandler struct {
+	drain   *middleware.DrainState
+	restore RestoreProgressSource
+}
 
 func NewHealthHandler() *HealthHandler {
 	return &HealthHandler{}
 }
 
+// NewHealthHandlerWithDrain faz o health check responder 503 enquanto o nó
+// drena, para que o balanceador deixe de enviar tráfego a ele.
+func NewHealthHandlerWithDrain(drain *middleware.DrainState) *HealthHandler {
+	return &HealthHandler{drain: drain}
+}
+
+func (h *HealthHandler) SetRestoreProgress(source RestoreProgressSource) {
+	h.restore = source
+}
+
 func (h *HealthHandler) Register(r *gin.RouterGroup) {
 	// Root endpoint with version info
 	r.Match([]string{"GET", "HEAD"}, "/", func(c *gin.Context) {
@@ -30,6 +51,17 @@ func (h *HealthHandler) Register(r *gin.RouterGroup) {
 
 	// Health check endpoint
 	r.Match([]string{"GET", "HEAD"}, "/healthz", func(c *gin.Context) {
+		if h.drain.IsDraining() {
+			if c.Request.Method == http.MethodHead {
+				c.Status(http.StatusServiceUnavailable)
+				return
+			}
+			c.JSON(http.StatusServiceUnavailable, gin.H{
+				"status":  "draining",
+				"version": config.Version,
+			})
+			return
+		}
 		if c.Request.Method == http.MethodHead {
 			c.Status(http.StatusOK)
 			return
@@ -39,4 +71,13 @@ func (h *HealthHandler) Register(r *gin.RouterGroup) {
 			"version": config.Version,
 		})
 	})
+
+	// Andamento da restauração das sessões na inicialização
+	r.GET("/healthz/restore", func(c *gin.Context) {
+		progress := model.SessionRestoreProgress{Status: model.SessionRestoreIdle}
+		if h.restore != nil {
+			progress = h.restore.RestoreProgress()
+		}
+		c.JSON(http.StatusOK, progress)
+	})
 }
@@ -1,8 +1,10 @@
 package handler
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/gin-gonic/gin"
@@ -12,6 +14,8 @@ import (
 
 	"github.com/open-apime/apime/internal/pkg/response"
 	instanceSvc "github.com/open-apime/apime/internal/service/instance"
+	"github.com/open-apime/apime/internal/session"
+	"github.com/open-apime/apime/internal/session/cloudapi"
 	"github.com/open-apime/apime/internal/storage/model"
 )
 
@@ -45,6 +49,17 @@ func (h *InstanceHandler) Register(r *gin.RouterGroup) {
 	r.DELETE("/instances/:id", h.delete)
 	r.POST("/instances/:id/token/rotate", h.rotateToken)
 	r.GET("/instances/:id/qr", h.getQR)
+	r.POST("/instances/:id/pair-code", h.getPairCode)
+	r.GET("/instances/:id/proxy", h.getProxy)
+	r.PUT("/instances/:id/proxy", h.setProxy)
+	r.DELETE("/instances/:id/proxy", h.removeProxy)
+	r.GET("/instances/:id/media/usage", h.getMediaUsage)
+	r.GET("/instances/:id/media/policy", h.getMediaPolicy)
+	r.PUT("/instances/:id/media/policy", h.setMediaPolicy)
+	r.DELETE("/instances/:id/media/policy", h.removeMediaPolicy)
+	r.GET("/instances/:id/cloud-api", h.getCloudAPI)
+	r.PUT("/instances/:id/cloud-api", h.setCloudAPI)
+	r.GET("/instances/:id/state", h.getConnectionState)
 	r.POST("/instances/:id/disconnect", h.disconnect)
 	r.GET("/instances/:id/info", h.getInstanceInfo)
 	r.GET("/instances/:id/profile/:jid", h.getProfile)
@@ -57,6 +72,8 @@ type createInstanceRequest struct {
 	WebhookURL     string `json:"webhook_url"`
 	WebhookSecret  string `json:"webhook_secret"`
 	MetaCompatible bool   `json:"meta_compatible"`
+	Provider       string `json:"provider" binding:"omitempty,oneof=whatsmeow cloud_api sandbox"`
+	MediaDownload  string `json:"media_download" binding:"omitempty,oneof=lazy eager"`
 }
 
 type updateInstanceRequest struct {
@@ -64,6 +81,7 @@ type updateInstanceRequest struct {
 	WebhookURL     string `json:"webhook_url"`
 	WebhookSecret  string `json:"webhook_secret"`
 	MetaCompatible bool   `json:"meta_compatible"`
+	MediaDownload  string `json:"media_download" binding:"omitempty,oneof=lazy eager"`
 }
 
 func (h *InstanceHandler) create(c *gin.Context) {
@@ -85,6 +103,8 @@ func (h *InstanceHandler) create(c *gin.Context) {
 		WebhookSecret:  req.WebhookSecret,
 		MetaCompatible: req.MetaCompatible,
 		OwnerUserID:    userID,
+		Provider:       model.InstanceProvider(req.Provider),
+		MediaDownload:  model.MediaDownloadMode(req.MediaDownload),
 	})
 	if err != nil {
 		response.Error(c, http.StatusBadRequest, err)
@@ -150,6 +170,7 @@ func (h *InstanceHandler) update(c *gin.Context) {
 		WebhookURL:     req.WebhookURL,
 		WebhookSecret:  req.WebhookSecret,
 		MetaCompatible: req.MetaCompatible,
+		MediaDownload:  model.MediaDownloadMode(req.MediaDownload),
 		OwnerUserID:    userRole, // Passamos o role para verificação de permissão
 	})
 	if err != nil {
@@ -225,7 +246,9 @@ func (h *InstanceHandler) getQR(c *gin.Context) {
 		statusCode := http.StatusInternalServerError
 		errorMsg := err.Error()
 
-		if strings.Contains(err.Error(), "timeout") {
+		if errors.Is(err, session.ErrNotSupported) {
+			statusCode = http.StatusNotImplemented
+		} else if strings.Contains(err.Error(), "timeout") {
 			statusCode = http.StatusRequestTimeout
 			errorMsg = "Timeout ao gerar QR code. Tente novamente."
 		} else if strings.Contains(err.Error(), "contexto cancelado") {
@@ -247,6 +270,429 @@ func (h *InstanceHandler) getQR(c *gin.Context) {
 	response.Success(c, http.StatusOK, gin.H{"qr": qr})
 }
 
+type pairCodeRequest struct {
+	Phone string `json:"phone" binding:"required"`
+}
+
+func (h *InstanceHandler) getPairCode(c *gin.Context) {
+	id := c.Param("id")
+
+	var req pairCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, err)
+		return
+	}
+
+	h.log.Info("solicitando código de pareamento", zap.String("instance_id", id))
+
+	var pairCode model.PairCode
+	var err error
+
+	if c.GetString("authType") == "instance_token" {
+		if c.GetString("instanceID") != id {
+			response.ErrorWithMessage(c, http.StatusForbidden, "token inválido para esta instância")
+			return
+		}
+		pairCode, err = h.service.GetPairCode(c.Request.Context(), id, req.Phone)
+	} else {
+		userID := c.GetString("userID")
+		userRole := c.GetString("userRole")
+		pairCode, err = h.service.GetPairCodeByUser(c.Request.Context(), id, req.Phone, userID, userRole)
+	}
+
+	if err != nil {
+		h.log.Error("erro ao gerar código de pareamento",
+			zap.String("instance_id", id),
+			zap.Error(err),
+			zap.String("error_type", getErrorType(err)))
+
+		statusCode := http.StatusInternalServerError
+		errorMsg := err.Error()
+
+		if errors.Is(err, session.ErrNotSupported) {
+			statusCode = http.StatusNotImplemented
+		} else if strings.Contains(err.Error(), "telefone inválido") {
+			statusCode = http.StatusBadRequest
+		} else if strings.Contains(err.Error(), "já conectada") {
+			statusCode = http.StatusConflict
+		} else if strings.Contains(err.Error(), "timeout") {
+			statusCode = http.StatusRequestTimeout
+			errorMsg = "Timeout ao gerar código de pareamento. Tente novamente."
+		} else if strings.Contains(err.Error(), "not found") {
+			statusCode = http.StatusNotFound
+			errorMsg = "Instância não encontrada."
+		}
+
+		response.ErrorWithMessage(c, statusCode, errorMsg)
+		return
+	}
+
+	h.log.Info("código de pareamento gerado com sucesso", zap.String("instance_id", id))
+	response.Success(c, http.StatusOK, pairCode)
+}
+
+type proxyRequest struct {
+	Type     string `json:"type" binding:"required,oneof=http socks5"`
+	Host     string `json:"host" binding:"required"`
+	Port     int    `json:"port" binding:"required,min=1,max=65535"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+func (h *InstanceHandler) getProxy(c *gin.Context) {
+	id := c.Param("id")
+
+	var proxy model.InstanceProxy
+	var err error
+
+	if c.GetString("authType") == "instance_token" {
+		if c.GetString("instanceID") != id {
+			response.ErrorWithMessage(c, http.StatusForbidden, "token inválido para esta instância")
+			return
+		}
+		proxy, err = h.service.GetProxy(c.Request.Context(), id)
+	} else {
+		proxy, err = h.service.GetProxyByUser(c.Request.Context(), id, c.GetString("userID"), c.GetString("userRole"))
+	}
+
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			response.ErrorWithMessage(c, http.StatusNotFound, "proxy não configurado")
+			return
+		}
+		response.Error(c, http.StatusInternalServerError, err)
+		return
+	}
+	response.Success(c, http.StatusOK, proxy)
+}
+
+func (h *InstanceHandler) setProxy(c *gin.Context) {
+	id := c.Param("id")
+
+	var req proxyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, err)
+		return
+	}
+
+	input := model.InstanceProxy{
+		InstanceID: id,
+		Type:       model.ProxyType(req.Type),
+		Host:       req.Host,
+		Port:       req.Port,
+		Username:   req.Username,
+		Password:   req.Password,
+	}
+
+	var proxy model.InstanceProxy
+	var err error
+
+	if c.GetString("authType") == "instance_token" {
+		if c.GetString("instanceID") != id {
+			response.ErrorWithMessage(c, http.StatusForbidden, "token inválido para esta instância")
+			return
+		}
+		proxy, err = h.service.SetProxy(c.Request.Context(), input)
+	} else {
+		proxy, err = h.service.SetProxyByUser(c.Request.Context(), input, c.GetString("userID"), c.GetString("userRole"))
+	}
+
+	if err != nil {
+		h.log.Error("erro ao configurar proxy", zap.String("instance_id", id), zap.Error(err))
+		if strings.Contains(err.Error(), "proxy inválida") {
+			response.Error(c, http.StatusBadRequest, err)
+			return
+		}
+		if strings.Contains(err.Error(), "not found") {
+			response.ErrorWithMessage(c, http.StatusNotFound, "instância não encontrada")
+			return
+		}
+		response.Error(c, http.StatusInternalServerError, err)
+		return
+	}
+	response.Success(c, http.StatusOK, proxy)
+}
+
+func (h *InstanceHandler) removeProxy(c *gin.Context) {
+	id := c.Param("id")
+
+	var err error
+	if c.GetString("authType") == "instance_token" {
+		if c.GetString("instanceID") != id {
+			response.ErrorWithMessage(c, http.StatusForbidden, "token inválido para esta instância")
+			return
+		}
+		err = h.service.RemoveProxy(c.Request.Context(), id)
+	} else {
+		err = h.service.RemoveProxyByUser(c.Request.Context(), id, c.GetString("userID"), c.GetString("userRole"))
+	}
+
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			response.ErrorWithMessage(c, http.StatusNotFound, "instância não encontrada")
+			return
+		}
+		response.Error(c, http.StatusInternalServerError, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+type mediaPolicyRequest struct {
+	RetentionSeconds int64 `json:"retention_seconds" binding:"min=0"`
+	QuotaBytes       int64 `json:"quota_bytes" binding:"min=0"`
+}
+
+func (h *InstanceHandler) getMediaUsage(c *gin.Context) {
+	id := c.Param("id")
+
+	var usage model.MediaUsage
+	var err error
+
+	if c.GetString("authType") == "instance_token" {
+		if c.GetString("instanceID") != id {
+			response.ErrorWithMessage(c, http.StatusForbidden, "token inválido para esta instância")
+			return
+		}
+		usage, err = h.service.GetMediaUsage(c.Request.Context(), id)
+	} else {
+		usage, err = h.service.GetMediaUsageByUser(c.Request.Context(), id, c.GetString("userID"), c.GetString("userRole"))
+	}
+
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			response.ErrorWithMessage(c, http.StatusNotFound, "instância não encontrada")
+			return
+		}
+		response.Error(c, http.StatusInternalServerError, err)
+		return
+	}
+	response.Success(c, http.StatusOK, usage)
+}
+
+func (h *InstanceHandler) getMediaPolicy(c *gin.Context) {
+	id := c.Param("id")
+
+	var policy model.MediaPolicy
+	var err error
+
+	if c.GetString("authType") == "instance_token" {
+		if c.GetString("instanceID") != id {
+			response.ErrorWithMessage(c, http.StatusForbidden, "token inválido para esta instância")
+			return
+		}
+		policy, err = h.service.GetMediaPolicy(c.Request.Context(), id)
+	} else {
+		policy, err = h.service.GetMediaPolicyByUser(c.Request.Context(), id, c.GetString("userID"), c.GetString("userRole"))
+	}
+
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			response.ErrorWithMessage(c, http.StatusNotFound, "política de mídia não configurada")
+			return
+		}
+		response.Error(c, http.StatusInternalServerError, err)
+		return
+	}
+	response.Success(c, http.StatusOK, policy)
+}
+
+func (h *InstanceHandler) setMediaPolicy(c *gin.Context) {
+	id := c.Param("id")
+
+	var req mediaPolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, err)
+		return
+	}
+
+	input := model.MediaPolicy{
+		InstanceID:       id,
+		RetentionSeconds: req.RetentionSeconds,
+		QuotaBytes:       req.QuotaBytes,
+	}
+
+	var policy model.MediaPolicy
+	var err error
+
+	if c.GetString("authType") == "instance_token" {
+		if c.GetString("instanceID") != id {
+			response.ErrorWithMessage(c, http.StatusForbidden, "token inválido para esta instância")
+			return
+		}
+		policy, err = h.service.SetMediaPolicy(c.Request.Context(), input)
+	} else {
+		policy, err = h.service.SetMediaPolicyByUser(c.Request.Context(), input, c.GetString("userID"), c.GetString("userRole"))
+	}
+
+	if err != nil {
+		h.log.Error("erro ao configurar política de mídia", zap.String("instance_id", id), zap.Error(err))
+		if errors.Is(err, instanceSvc.ErrInvalidPolicy) {
+			response.Error(c, http.StatusBadRequest, err)
+			return
+		}
+		if strings.Contains(err.Error(), "not found") {
+			response.ErrorWithMessage(c, http.StatusNotFound, "instância não encontrada")
+			return
+		}
+		response.Error(c, http.StatusInternalServerError, err)
+		return
+	}
+	response.Success(c, http.StatusOK, policy)
+}
+
+func (h *InstanceHandler) removeMediaPolicy(c *gin.Context) {
+	id := c.Param("id")
+
+	var err error
+	if c.GetString("authType") == "instance_token" {
+		if c.GetString("instanceID") != id {
+			response.ErrorWithMessage(c, http.StatusForbidden, "token inválido para esta instância")
+			return
+		}
+		err = h.service.RemoveMediaPolicy(c.Request.Context(), id)
+	} else {
+		err = h.service.RemoveMediaPolicyByUser(c.Request.Context(), id, c.GetString("userID"), c.GetString("userRole"))
+	}
+
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			response.ErrorWithMessage(c, http.StatusNotFound, "instância não encontrada")
+			return
+		}
+		response.Error(c, http.StatusInternalServerError, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (h *InstanceHandler) getConnectionState(c *gin.Context) {
+	id := c.Param("id")
+
+	limit := 0
+	if v := c.Query("limit"); v != "" {
+		parsed, err := strconv.Atoi(v)
+		if err != nil || parsed <= 0 {
+			response.ErrorWithMessage(c, http.StatusBadRequest, "limit inválido")
+			return
+		}
+		limit = parsed
+	}
+
+	var state model.InstanceConnectionState
+	var err error
+
+	if c.GetString("authType") == "instance_token" {
+		if c.GetString("instanceID") != id {
+			response.ErrorWithMessage(c, http.StatusForbidden, "token inválido para esta instância")
+			return
+		}
+		state, err = h.service.GetConnectionState(c.Request.Context(), id, limit)
+	} else {
+		state, err = h.service.GetConnectionStateByUser(c.Request.Context(), id, limit, c.GetString("userID"), c.GetString("userRole"))
+	}
+
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			response.ErrorWithMessage(c, http.StatusNotFound, "instância não encontrada")
+			return
+		}
+		response.Error(c, http.StatusInternalServerError, err)
+		return
+	}
+	response.Success(c, http.StatusOK, state)
+}
+
+type cloudAPIRequest struct {
+	PhoneNumberID     string `json:"phoneNumberId" binding:"required"`
+	BusinessAccountID string `json:"businessAccountId"`
+	AccessToken       string `json:"accessToken"`
+	AppSecret         string `json:"appSecret"`
+	VerifyToken       string `json:"verifyToken"`
+}
+
+func (h *InstanceHandler) getCloudAPI(c *gin.Context) {
+	id := c.Param("id")
+
+	var cfg model.InstanceCloudAPI
+	var err error
+
+	if c.GetString("authType") == "instance_token" {
+		if c.GetString("instanceID") != id {
+			response.ErrorWithMessage(c, http.StatusForbidden, "token inválido para esta instância")
+			return
+		}
+		cfg, err = h.service.GetCloudAPI(c.Request.Context(), id)
+	} else {
+		cfg, err = h.service.GetCloudAPIByUser(c.Request.Context(), id, c.GetString("userID"), c.GetString("userRole"))
+	}
+
+	if err != nil {
+		if errors.Is(err, session.ErrNotSupported) {
+			response.ErrorWithMessage(c, http.StatusNotImplemented, "instância não usa a Cloud API")
+			return
+		}
+		if strings.Contains(err.Error(), "not found") {
+			response.ErrorWithMessage(c, http.StatusNotFound, "Cloud API não configurada")
+			return
+		}
+		response.Error(c, http.StatusInternalServerError, err)
+		return
+	}
+	response.Success(c, http.StatusOK, cfg)
+}
+
+func (h *InstanceHandler) setCloudAPI(c *gin.Context) {
+	id := c.Param("id")
+
+	var req cloudAPIRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, err)
+		return
+	}
+
+	input := model.InstanceCloudAPI{
+		InstanceID:        id,
+		PhoneNumberID:     req.PhoneNumberID,
+		BusinessAccountID: req.BusinessAccountID,
+		AccessToken:       req.AccessToken,
+		AppSecret:         req.AppSecret,
+		VerifyToken:       req.VerifyToken,
+	}
+
+	var cfg model.InstanceCloudAPI
+	var err error
+
+	if c.GetString("authType") == "instance_token" {
+		if c.GetString("instanceID") != id {
+			response.ErrorWithMessage(c, http.StatusForbidden, "token inválido para esta instância")
+			return
+		}
+		cfg, err = h.service.ConfigureCloudAPI(c.Request.Context(), input)
+	} else {
+		cfg, err = h.service.ConfigureCloudAPIByUser(c.Request.Context(), input, c.GetString("userID"), c.GetString("userRole"))
+	}
+
+	if err != nil {
+		h.log.Error("erro ao configurar Cloud API", zap.String("instance_id", id), zap.Error(err))
+		if errors.Is(err, session.ErrNotSupported) {
+			response.ErrorWithMessage(c, http.StatusNotImplemented, "instância não usa a Cloud API")
+			return
+		}
+		if errors.Is(err, cloudapi.ErrInvalidConfig) {
+			response.Error(c, http.StatusBadRequest, err)
+			return
+		}
+		if strings.Contains(err.Error(), "not found") {
+			response.ErrorWithMessage(c, http.StatusNotFound, "instância não encontrada")
+			return
+		}
+		response.Error(c, http.StatusInternalServerError, err)
+		return
+	}
+	response.Success(c, http.StatusOK, cfg)
+}
+
 // getErrorType retorna o tipo de erro para logging
 func getErrorType(err error) string {
 	if err == nil {
@@ -312,6 +758,21 @@ func (h *InstanceHandler) getInstanceInfo(c *gin.Context) {
 		return
 	}
 
+	if instance.Provider == model.InstanceProviderCloudAPI || instance.Provider == model.InstanceProviderSandbox {
+		responseData := gin.H{
+			"id":        instance.ID,
+			"name":      instance.Name,
+			"status":    instance.Status,
+			"provider":  instance.Provider,
+			"connected": instance.Status == model.InstanceStatusActive,
+		}
+		if instance.WhatsAppJID != "" {
+			responseData["instanceJID"] = instance.WhatsAppJID
+		}
+		response.Success(c, http.StatusOK, responseData)
+		return
+	}
+
 	if h.sessionManager == nil {
 		response.Success(c, http.StatusOK, gin.H{
 			"id":        instance.ID,
@@ -378,6 +839,32 @@ func (h *InstanceHandler) getInstanceInfo(c *gin.Context) {
 	response.Success(c, http.StatusOK, responseData)
 }
 
+// sessionClient retorna o cliente whatsmeow da instância. Instâncias de outros
+// provedores recebem 501.
+func (h *InstanceHandler) sessionClient(c *gin.Context, instanceID string) (*whatsmeow.Client, bool) {
+	if h.sessionManager == nil {
+		response.ErrorWithMessage(c, http.StatusBadRequest, "session manager não configurado")
+		return nil, false
+	}
+
+	instance, err := h.service.Get(c.Request.Context(), instanceID)
+	if err != nil {
+		response.ErrorWithMessage(c, http.StatusNotFound, "instância não encontrada")
+		return nil, false
+	}
+	if instance.Provider == model.InstanceProviderCloudAPI || instance.Provider == model.InstanceProviderSandbox {
+		response.ErrorWithMessage(c, http.StatusNotImplemented, session.ErrNotSupported.Error())
+		return nil, false
+	}
+
+	client, err := h.sessionManager.GetClient(instanceID)
+	if err != nil {
+		response.ErrorWithMessage(c, http.StatusBadRequest, "instância não conectada")
+		return nil, false
+	}
+	return client, true
+}
+
 func (h *InstanceHandler) getProfile(c *gin.Context) {
 	instanceID := c.Param("id")
 	jidStr := c.Param("jid")
@@ -390,14 +877,8 @@ func (h *InstanceHandler) getProfile(c *gin.Context) {
 		return
 	}
 
-	if h.sessionManager == nil {
-		response.ErrorWithMessage(c, http.StatusBadRequest, "session manager não configurado")
-		return
-	}
-
-	client, err := h.sessionManager.GetClient(instanceID)
-	if err != nil {
-		response.ErrorWithMessage(c, http.StatusBadRequest, "instância não conectada")
+	client, ok := h.sessionClient(c, instanceID)
+	if !ok {
 		return
 	}
 
@@ -434,14 +915,8 @@ func (h *InstanceHandler) getBusinessProfile(c *gin.Context) {
 		return
 	}
 
-	if h.sessionManager == nil {
-		response.ErrorWithMessage(c, http.StatusBadRequest, "session manager não configurado")
-		return
-	}
-
-	client, err := h.sessionManager.GetClient(instanceID)
-	if err != nil {
-		response.ErrorWithMessage(c, http.StatusBadRequest, "instância não conectada")
+	client, ok := h.sessionClient(c, instanceID)
+	if !ok {
 		return
 	}
 
@@ -472,14 +947,8 @@ func (h *InstanceHandler) getProfilePicture(c *gin.Context) {
 		return
 	}
 
-	if h.sessionManager == nil {
-		response.ErrorWithMessage(c, http.StatusBadRequest, "session manager não configurado")
-		return
-	}
-
-	client, err := h.sessionManager.GetClient(instanceID)
-	if err != nil {
-		response.ErrorWithMessage(c, http.StatusBadRequest, "instância não conectada")
+	client, ok := h.sessionClient(c, instanceID)
+	if !ok {
 		return
 	}
 
@@ -1,25 +1,48 @@
 package handler
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"mime"
 	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 
+	"github.com/open-apime/apime/internal/session"
+	"github.com/open-apime/apime/internal/storage"
 	"github.com/open-apime/apime/internal/storage/media"
 )
 
 // MediaHandler lida com requisições de mídia
 type MediaHandler struct {
-	storage *media.Storage
+	storage       media.Storage
+	signer        *media.URLSigner
+	instanceRepo  storage.InstanceRepository
+	allowUnsigned bool
+	lazy          *media.LazyFetcher
 }
 
-// NewMediaHandler cria um novo handler de mídia
-func NewMediaHandler(storage *media.Storage) *MediaHandler {
+// NewMediaHandler cria um novo handler de mídia. O download exige a URL
+// assinada enviada no webhook ou o token da instância, a menos que
+// allowUnsigned libere o acesso sem autenticação.
+func NewMediaHandler(store media.Storage, signer *media.URLSigner, instanceRepo storage.InstanceRepository, allowUnsigned bool) *MediaHandler {
 	return &MediaHandler{
-		storage: storage,
+		storage:       store,
+		signer:        signer,
+		instanceRepo:  instanceRepo,
+		allowUnsigned: allowUnsigned,
 	}
 }
 
+// SetLazyFetcher faz as mídias guardadas só como referência serem baixadas
+// do WhatsApp no primeiro acesso.
+func (h *MediaHandler) SetLazyFetcher(f *media.LazyFetcher) {
+	h.lazy = f
+}
+
 // GetMedia serve uma mídia pelo ID
 // GET /api/media/:instanceId/:mediaId
 func (h *MediaHandler) GetMedia(c *gin.Context) {
@@ -31,67 +54,91 @@ func (h *MediaHandler) GetMedia(c *gin.Context) {
 		return
 	}
 
-	// Verificar se existe
-	if !h.storage.Exists(instanceID, mediaID) {
-		c.JSON(http.StatusNotFound, gin.H{"error": "mídia não encontrada ou expirada"})
+	if !h.authorize(c, instanceID, mediaID) {
 		return
 	}
 
-	// Obter dados
-	data, err := h.storage.Get(c.Request.Context(), instanceID, mediaID)
+	obj, err := h.storage.Open(c.Request.Context(), instanceID, mediaID)
+	if errors.Is(err, media.ErrNotFound) && h.lazy != nil {
+		if err = h.lazy.Fetch(c.Request.Context(), instanceID, mediaID); err == nil {
+			obj, err = h.storage.Open(c.Request.Context(), instanceID, mediaID)
+		}
+	}
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		switch {
+		case errors.Is(err, media.ErrNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "mídia não encontrada ou expirada"})
+		case errors.Is(err, session.ErrMediaExpired):
+			c.JSON(http.StatusGone, gin.H{"error": "mídia não está mais disponível no WhatsApp"})
+		case errors.Is(err, session.ErrNotConnected), errors.Is(err, session.ErrRestoring):
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "instância não conectada para baixar a mídia"})
+		case errors.Is(err, media.ErrQuotaExceeded):
+			c.JSON(http.StatusInsufficientStorage, gin.H{"error": "mídia maior que a cota da instância"})
+		case errors.Is(err, context.DeadlineExceeded), errors.Is(err, context.Canceled):
+			c.JSON(http.StatusGatewayTimeout, gin.H{"error": "tempo esgotado ao baixar a mídia"})
+		default:
+			c.JSON(http.StatusBadGateway, gin.H{"error": "falha ao obter a mídia"})
+		}
 		return
 	}
+	defer obj.Close()
 
-	// Detectar content-type pela extensão
-	contentType := getContentTypeFromFilename(mediaID)
+	name := obj.FileName
+	if name == "" {
+		name = mediaID
+	}
 
-	// Configurar headers
-	c.Header("Content-Type", contentType)
-	c.Header("Cache-Control", "public, max-age=3600")
-	c.Header("Access-Control-Allow-Origin", "*")
-	c.Header("Cross-Origin-Resource-Policy", "cross-origin")
+	// Content-Type vem dos metadados gravados; ETag e Range ficam a cargo do
+	// http.ServeContent, que também responde 304 e 206.
+	c.Header("Content-Type", obj.MimeType)
+	c.Header("Content-Disposition", mime.FormatMediaType("inline", map[string]string{"filename": name}))
+	if obj.ETag != "" {
+		c.Header("ETag", `"`+obj.ETag+`"`)
+	}
+	if h.allowUnsigned {
+		// Só mídias públicas são liberadas para leitura por outras origens;
+		// com acesso assinado valem as restrições padrão do navegador.
+		c.Header("Cache-Control", "public, max-age=3600")
+		c.Header("Access-Control-Allow-Origin", "*")
+		c.Header("Cross-Origin-Resource-Policy", "cross-origin")
+	} else {
+		c.Header("Cache-Control", "private, max-age=3600")
+	}
 
-	c.Data(http.StatusOK, contentType, data)
+	http.ServeContent(c.Writer, c.Request, name, obj.ModTime, obj.Content)
 }
 
-// getContentTypeFromFilename retorna o content-type baseado na extensão
-func getContentTypeFromFilename(filename string) string {
-	// Pegar últimos caracteres para verificar extensão
-	if len(filename) < 4 {
-		return "application/octet-stream"
+// authorize aceita a URL assinada (exp e sig) ou o token da instância dona da
+// mídia no header Authorization.
+func (h *MediaHandler) authorize(c *gin.Context, instanceID, mediaID string) bool {
+	if h.allowUnsigned {
+		return true
 	}
 
-	switch {
-	case endsWith(filename, ".jpg"), endsWith(filename, ".jpeg"):
-		return "image/jpeg"
-	case endsWith(filename, ".png"):
-		return "image/png"
-	case endsWith(filename, ".gif"):
-		return "image/gif"
-	case endsWith(filename, ".webp"):
-		return "image/webp"
-	case endsWith(filename, ".mp4"):
-		return "video/mp4"
-	case endsWith(filename, ".3gp"):
-		return "video/3gpp"
-	case endsWith(filename, ".ogg"):
-		return "audio/ogg"
-	case endsWith(filename, ".mp3"):
-		return "audio/mpeg"
-	case endsWith(filename, ".m4a"):
-		return "audio/mp4"
-	case endsWith(filename, ".pdf"):
-		return "application/pdf"
-	default:
-		return "application/octet-stream"
+	if sig := c.Query("sig"); sig != "" && h.signer != nil {
+		err := h.signer.Verify(instanceID, mediaID, c.Query("exp"), sig)
+		switch {
+		case err == nil:
+			return true
+		case errors.Is(err, media.ErrURLExpired):
+			c.JSON(http.StatusForbidden, gin.H{"error": "URL de mídia expirada"})
+		default:
+			c.JSON(http.StatusForbidden, gin.H{"error": "assinatura inválida"})
+		}
+		return false
 	}
-}
 
-func endsWith(s, suffix string) bool {
-	if len(s) < len(suffix) {
+	header := c.GetHeader("Authorization")
+	if strings.HasPrefix(header, "Bearer ") && h.instanceRepo != nil {
+		hashBytes := sha256.Sum256([]byte(strings.TrimPrefix(header, "Bearer ")))
+		inst, err := h.instanceRepo.GetByTokenHash(c.Request.Context(), hex.EncodeToString(hashBytes[:]))
+		if err == nil && inst.ID == instanceID {
+			return true
+		}
+		c.JSON(http.StatusForbidden, gin.H{"error": "token inválido para esta instância"})
 		return false
 	}
-	return s[len(s)-len(suffix):] == suffix
+
+	c.JSON(http.StatusUnauthorized, gin.H{"error": "URL assinada ou token da instância obrigatório"})
+	return false
 }
@@ -1,23 +1,29 @@
 package handler
 
 import (
+	"context"
 	"errors"
 	"io"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 
+	"github.com/open-apime/apime/internal/pkg/fetch"
+	"github.com/open-apime/apime/internal/pkg/mediatype"
 	"github.com/open-apime/apime/internal/pkg/response"
 	messageSvc "github.com/open-apime/apime/internal/service/message"
 )
 
 type MessageHandler struct {
 	service *messageSvc.Service
+	fetcher *fetch.Fetcher
 }
 
-func NewMessageHandler(service *messageSvc.Service) *MessageHandler {
-	return &MessageHandler{service: service}
+func NewMessageHandler(service *messageSvc.Service, fetcher *fetch.Fetcher) *MessageHandler {
+	return &MessageHandler{service: service, fetcher: fetcher}
 }
 
 func (h *MessageHandler) Register(r *gin.RouterGroup) {
@@ -27,6 +33,7 @@ func (h *MessageHandler) Register(r *gin.RouterGroup) {
 	r.POST("/instances/:id/messages/audio", h.sendAudio)
 	r.POST("/instances/:id/messages/document", h.sendDocument)
 	r.GET("/instances/:id/messages", h.list)
+	r.GET("/instances/:id/messages/:messageId/status", h.status)
 }
 
 type messageRequest struct {
@@ -96,7 +103,9 @@ func (h *MessageHandler) sendText(c *gin.Context) {
 		Quoted:     req.Quoted,
 	})
 	if err != nil {
-		if errors.Is(err, messageSvc.ErrInstanceNotConnected) {
+		if errors.Is(err, messageSvc.ErrInstanceRestoring) {
+			respondRestoring(c)
+		} else if errors.Is(err, messageSvc.ErrInstanceNotConnected) {
 			response.ErrorWithMessage(c, http.StatusBadRequest, "instância não conectada")
 		} else if errors.Is(err, messageSvc.ErrInvalidJID) {
 			response.Error(c, http.StatusBadRequest, err)
@@ -133,25 +142,15 @@ func (h *MessageHandler) sendMedia(c *gin.Context) {
 		return
 	}
 
-	// Obter arquivo
-	file, err := c.FormFile("file")
-	if err != nil {
-		response.ErrorWithMessage(c, http.StatusBadRequest, "arquivo não fornecido")
+	// Arquivo enviado no formulário ou baixado do campo "url"
+	fileData, contentType, _, ok := h.readMedia(c, mediaType)
+	if !ok {
 		return
 	}
 
-	// Abrir arquivo
-	src, err := file.Open()
+	thumb, err := readThumbnail(c)
 	if err != nil {
-		response.ErrorWithMessage(c, http.StatusInternalServerError, "erro ao abrir arquivo")
-		return
-	}
-	defer src.Close()
-
-	// Ler dados do arquivo
-	fileData, err := io.ReadAll(src)
-	if err != nil {
-		response.ErrorWithMessage(c, http.StatusInternalServerError, "erro ao ler arquivo")
+		response.ErrorWithMessage(c, http.StatusBadRequest, "erro ao ler miniatura")
 		return
 	}
 
@@ -162,12 +161,18 @@ func (h *MessageHandler) sendMedia(c *gin.Context) {
 		To:         to,
 		Type:       mediaType,
 		MediaData:  fileData,
-		MediaType:  file.Header.Get("Content-Type"),
+		MediaType:  contentType,
 		Caption:    caption,
 		Quoted:     c.PostForm("quoted"),
+		Thumbnail:  thumb,
 	})
 	if err != nil {
-		if errors.Is(err, messageSvc.ErrInstanceNotConnected) {
+		if respondMediaValidation(c, err) {
+			return
+		}
+		if errors.Is(err, messageSvc.ErrInstanceRestoring) {
+			respondRestoring(c)
+		} else if errors.Is(err, messageSvc.ErrInstanceNotConnected) {
 			response.ErrorWithMessage(c, http.StatusBadRequest, "instância não conectada")
 		} else {
 			response.Error(c, http.StatusInternalServerError, err)
@@ -195,25 +200,9 @@ func (h *MessageHandler) sendAudio(c *gin.Context) {
 		return
 	}
 
-	// Obter arquivo
-	file, err := c.FormFile("file")
-	if err != nil {
-		response.ErrorWithMessage(c, http.StatusBadRequest, "arquivo não fornecido")
-		return
-	}
-
-	// Abrir arquivo
-	src, err := file.Open()
-	if err != nil {
-		response.ErrorWithMessage(c, http.StatusInternalServerError, "erro ao abrir arquivo")
-		return
-	}
-	defer src.Close()
-
-	// Ler dados do arquivo
-	fileData, err := io.ReadAll(src)
-	if err != nil {
-		response.ErrorWithMessage(c, http.StatusInternalServerError, "erro ao ler arquivo")
+	// Arquivo enviado no formulário ou baixado do campo "url"
+	fileData, contentType, _, ok := h.readMedia(c, "audio")
+	if !ok {
 		return
 	}
 
@@ -227,20 +216,23 @@ func (h *MessageHandler) sendAudio(c *gin.Context) {
 	pttStr := c.PostForm("ptt")
 	ptt := pttStr == "true" || pttStr == "1"
 
-	mediaType := file.Header.Get("Content-Type")
-
 	msg, err := h.service.Send(c.Request.Context(), messageSvc.SendInput{
 		InstanceID: instanceID,
 		To:         to,
 		Type:       "audio",
 		MediaData:  fileData,
-		MediaType:  mediaType,
+		MediaType:  contentType,
 		Seconds:    seconds,
 		PTT:        ptt,
 		Quoted:     c.PostForm("quoted"),
 	})
 	if err != nil {
-		if errors.Is(err, messageSvc.ErrInstanceNotConnected) {
+		if respondMediaValidation(c, err) {
+			return
+		}
+		if errors.Is(err, messageSvc.ErrInstanceRestoring) {
+			respondRestoring(c)
+		} else if errors.Is(err, messageSvc.ErrInstanceNotConnected) {
 			response.ErrorWithMessage(c, http.StatusBadRequest, "instância não conectada")
 		} else {
 			response.Error(c, http.StatusInternalServerError, err)
@@ -270,30 +262,20 @@ func (h *MessageHandler) sendDocument(c *gin.Context) {
 		return
 	}
 
-	// Obter arquivo
-	file, err := c.FormFile("file")
-	if err != nil {
-		response.ErrorWithMessage(c, http.StatusBadRequest, "arquivo não fornecido")
+	// Arquivo enviado no formulário ou baixado do campo "url"
+	fileData, contentType, name, ok := h.readMedia(c, "document")
+	if !ok {
 		return
 	}
 
 	// Usar nome do arquivo enviado se não fornecido
 	if fileName == "" {
-		fileName = file.Filename
+		fileName = name
 	}
 
-	// Abrir arquivo
-	src, err := file.Open()
+	thumb, err := readThumbnail(c)
 	if err != nil {
-		response.ErrorWithMessage(c, http.StatusInternalServerError, "erro ao abrir arquivo")
-		return
-	}
-	defer src.Close()
-
-	// Ler dados do arquivo
-	fileData, err := io.ReadAll(src)
-	if err != nil {
-		response.ErrorWithMessage(c, http.StatusInternalServerError, "erro ao ler arquivo")
+		response.ErrorWithMessage(c, http.StatusBadRequest, "erro ao ler miniatura")
 		return
 	}
 
@@ -304,13 +286,19 @@ func (h *MessageHandler) sendDocument(c *gin.Context) {
 		To:         to,
 		Type:       "document",
 		MediaData:  fileData,
-		MediaType:  file.Header.Get("Content-Type"),
+		MediaType:  contentType,
 		FileName:   fileName,
 		Caption:    caption,
 		Quoted:     c.PostForm("quoted"),
+		Thumbnail:  thumb,
 	})
 	if err != nil {
-		if errors.Is(err, messageSvc.ErrInstanceNotConnected) {
+		if respondMediaValidation(c, err) {
+			return
+		}
+		if errors.Is(err, messageSvc.ErrInstanceRestoring) {
+			respondRestoring(c)
+		} else if errors.Is(err, messageSvc.ErrInstanceNotConnected) {
 			response.ErrorWithMessage(c, http.StatusBadRequest, "instância não conectada")
 		} else {
 			response.Error(c, http.StatusInternalServerError, err)
@@ -331,10 +319,140 @@ func (h *MessageHandler) list(c *gin.Context) {
 		response.ErrorWithMessage(c, http.StatusForbidden, "token inválido para esta instância")
 		return
 	}
-	list, err := h.service.List(c.Request.Context(), instanceID)
+
+	limit := 0
+	if v := c.Query("limit"); v != "" {
+		parsed, err := strconv.Atoi(v)
+		if err != nil || parsed <= 0 {
+			response.ErrorWithMessage(c, http.StatusBadRequest, "limit inválido")
+			return
+		}
+		limit = parsed
+	}
+
+	var before *time.Time
+	if v := c.Query("before"); v != "" {
+		parsed, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			response.ErrorWithMessage(c, http.StatusBadRequest, "before deve estar no formato RFC3339")
+			return
+		}
+		before = &parsed
+	}
+
+	list, err := h.service.List(c.Request.Context(), instanceID, before, limit)
 	if err != nil {
 		response.Error(c, http.StatusInternalServerError, err)
 		return
 	}
 	response.Success(c, http.StatusOK, list)
 }
+
+func (h *MessageHandler) status(c *gin.Context) {
+	instanceID := c.Param("id")
+	if c.GetString("authType") != "instance_token" {
+		response.ErrorWithMessage(c, http.StatusForbidden, "endpoint disponível apenas com token de instância")
+		return
+	}
+	if c.GetString("instanceID") != instanceID {
+		response.ErrorWithMessage(c, http.StatusForbidden, "token inválido para esta instância")
+		return
+	}
+	timeline, err := h.service.GetStatusTimeline(c.Request.Context(), instanceID, c.Param("messageId"))
+	if err != nil {
+		if errors.Is(err, messageSvc.ErrMessageNotFound) {
+			response.Error(c, http.StatusNotFound, err)
+			return
+		}
+		response.Error(c, http.StatusInternalServerError, err)
+		return
+	}
+	response.Success(c, http.StatusOK, timeline)
+}
+
+// respondRestoring recusa o pedido enquanto a sessão da instância aguarda a
+// restauração da inicialização, indicando quando tentar de novo.
+// readMedia lê a mídia do campo "file" ou, sem ele, baixa a do campo "url".
+// Retorna os dados, o tipo e o nome informados; em caso de erro, a resposta
+// já foi escrita.
+func (h *MessageHandler) readMedia(c *gin.Context, kind string) ([]byte, string, string, bool) {
+	file, err := c.FormFile("file")
+	if err == nil {
+		src, err := file.Open()
+		if err != nil {
+			response.ErrorWithMessage(c, http.StatusInternalServerError, "erro ao abrir arquivo")
+			return nil, "", "", false
+		}
+		defer src.Close()
+
+		data, err := io.ReadAll(src)
+		if err != nil {
+			response.ErrorWithMessage(c, http.StatusInternalServerError, "erro ao ler arquivo")
+			return nil, "", "", false
+		}
+		return data, file.Header.Get("Content-Type"), file.Filename, true
+	}
+
+	link := strings.TrimSpace(c.PostForm("url"))
+	if link == "" {
+		response.ErrorWithMessage(c, http.StatusBadRequest, "arquivo não fornecido: envie 'file' ou 'url'")
+		return nil, "", "", false
+	}
+	result, err := downloadMedia(c.Request.Context(), h.fetcher, link, kind)
+	if err != nil {
+		if !respondMediaValidation(c, err) {
+			response.ErrorWithMessage(c, http.StatusBadRequest, "falha ao baixar mídia do link: "+err.Error())
+		}
+		return nil, "", "", false
+	}
+	return result.Data, result.ContentType, result.FileName, true
+}
+
+// downloadMedia baixa a mídia do link, recusando endereços internos e
+// arquivos maiores que o limite do tipo de mensagem.
+func downloadMedia(ctx context.Context, fetcher *fetch.Fetcher, link string, kind string) (fetch.Result, error) {
+	maxSize := mediatype.MaxSize(kind)
+	result, err := fetcher.Fetch(ctx, link, maxSize)
+	var tooLarge *fetch.TooLargeError
+	if errors.As(err, &tooLarge) {
+		return fetch.Result{}, &mediatype.ValidationError{Kind: kind, Reason: mediatype.ReasonTooLarge, Size: tooLarge.Size, MaxSize: maxSize}
+	}
+	return result, err
+}
+
+func respondRestoring(c *gin.Context) {
+	c.Header("Retry-After", "5")
+	response.ErrorWithMessage(c, http.StatusServiceUnavailable, "sessão da instância em restauração")
+}
+
+// readThumbnail lê a miniatura opcional enviada no campo "thumbnail".
+func readThumbnail(c *gin.Context) ([]byte, error) {
+	file, err := c.FormFile("thumbnail")
+	if err != nil {
+		if errors.Is(err, http.ErrMissingFile) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	src, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+	return io.ReadAll(src)
+}
+
+// respondMediaValidation responde com os detalhes da mídia recusada, quando o
+// erro for de validação.
+func respondMediaValidation(c *gin.Context, err error) bool {
+	var verr *mediatype.ValidationError
+	if !errors.As(err, &verr) {
+		return false
+	}
+	status := http.StatusBadRequest
+	if verr.Reason == mediatype.ReasonTooLarge {
+		status = http.StatusRequestEntityTooLarge
+	}
+	c.JSON(status, gin.H{"error": verr.Error(), "details": verr})
+	return true
+}
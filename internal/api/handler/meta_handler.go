@@ -2,22 +2,23 @@ package handler
 
 import (
 	"errors"
-	"io"
 	"net/http"
-	"time"
 
 	"github.com/gin-gonic/gin"
 
+	"github.com/open-apime/apime/internal/pkg/fetch"
 	"github.com/open-apime/apime/internal/pkg/response"
 	messageSvc "github.com/open-apime/apime/internal/service/message"
+	"github.com/open-apime/apime/internal/session"
 )
 
 type MetaHandler struct {
 	service *messageSvc.Service
+	fetcher *fetch.Fetcher
 }
 
-func NewMetaHandler(service *messageSvc.Service) *MetaHandler {
-	return &MetaHandler{service: service}
+func NewMetaHandler(service *messageSvc.Service, fetcher *fetch.Fetcher) *MetaHandler {
+	return &MetaHandler{service: service, fetcher: fetcher}
 }
 
 func (h *MetaHandler) Register(r *gin.RouterGroup) {
@@ -127,21 +128,30 @@ func (h *MetaHandler) sendMessage(c *gin.Context) {
 			return
 		}
 
-		// Suporte apenas a Link por enquanto
-		if media.Link == "" {
-			response.ErrorWithMessage(c, http.StatusBadRequest, "apenas 'link' é suportado para mídia no momento (upload de ID não implementado)")
+		switch {
+		case media.ID != "":
+			// ID retornado pelo upload avulso: reaproveita a mídia já
+			// enviada ao WhatsApp.
+			input.MediaID = media.ID
+		case media.Link != "":
+			// Baixar a mídia do link
+			result, err := downloadMedia(c.Request.Context(), h.fetcher, media.Link, req.Type)
+			if err != nil {
+				if respondMediaValidation(c, err) {
+					return
+				}
+				response.ErrorWithMessage(c, http.StatusBadRequest, "falha ao baixar mídia do link: "+err.Error())
+				return
+			}
+
+			// O Content-Type remoto é só o tipo informado; o service confere
+			// com o conteúdo.
+			input.MediaData = result.Data
+			input.MediaType = result.ContentType
+		default:
+			response.ErrorWithMessage(c, http.StatusBadRequest, "informe 'id' ou 'link' da mídia")
 			return
 		}
-
-		// Baixar a mídia do link
-		data, contentType, err := h.downloadMedia(media.Link)
-		if err != nil {
-			response.ErrorWithMessage(c, http.StatusBadRequest, "falha ao baixar mídia do link: "+err.Error())
-			return
-		}
-
-		input.MediaData = data
-		input.MediaType = contentType // "image/jpeg", etc.
 		input.Caption = media.Caption
 		input.FileName = media.Filename
 
@@ -152,7 +162,16 @@ func (h *MetaHandler) sendMessage(c *gin.Context) {
 
 	msg, err := h.service.Send(c.Request.Context(), input)
 	if err != nil {
-		if errors.Is(err, messageSvc.ErrInstanceNotConnected) {
+		if respondMediaValidation(c, err) {
+			return
+		}
+		if errors.Is(err, session.ErrMediaExpired) {
+			response.ErrorWithMessage(c, http.StatusNotFound, "mídia não encontrada ou expirada; refaça o upload")
+			return
+		}
+		if errors.Is(err, messageSvc.ErrInstanceRestoring) {
+			respondRestoring(c)
+		} else if errors.Is(err, messageSvc.ErrInstanceNotConnected) {
 			response.ErrorWithMessage(c, http.StatusBadRequest, "instância não conectada")
 		} else {
 			response.Error(c, http.StatusInternalServerError, err)
@@ -176,28 +195,3 @@ func (h *MetaHandler) sendMessage(c *gin.Context) {
 		},
 	})
 }
-
-func (h *MetaHandler) downloadMedia(url string) ([]byte, string, error) {
-	client := &http.Client{
-		Timeout: 30 * time.Second,
-	}
-	resp, err := client.Get(url)
-	if err != nil {
-		return nil, "", err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, "", errors.New("status code diferente de 200")
-	}
-
-	// Limit to 20MB
-	const maxFileSize = 20 * 1024 * 1024
-	data, err := io.ReadAll(io.LimitReader(resp.Body, maxFileSize))
-	if err != nil {
-		return nil, "", err
-	}
-
-	contentType := resp.Header.Get("Content-Type")
-	return data, contentType, nil
-}
@@ -0,0 +1,183 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/open-apime/apime/internal/pkg/response"
+	instanceSvc "github.com/open-apime/apime/internal/service/instance"
+	"github.com/open-apime/apime/internal/session"
+	"github.com/open-apime/apime/internal/session/sandbox"
+	"github.com/open-apime/apime/internal/storage/model"
+)
+
+// SandboxHandler expõe os controles das instâncias simuladas: injeção de
+// mensagens recebidas, agendamento das confirmações e reconexão.
+type SandboxHandler struct {
+	service *instanceSvc.Service
+	sandbox *sandbox.Manager
+	log     *zap.Logger
+}
+
+func NewSandboxHandler(service *instanceSvc.Service, manager *sandbox.Manager, log *zap.Logger) *SandboxHandler {
+	return &SandboxHandler{service: service, sandbox: manager, log: log}
+}
+
+func (h *SandboxHandler) Register(r *gin.RouterGroup) {
+	r.POST("/instances/:id/sandbox/inbound", h.inbound)
+	r.GET("/instances/:id/sandbox/schedule", h.getSchedule)
+	r.PUT("/instances/:id/sandbox/schedule", h.setSchedule)
+	r.POST("/instances/:id/sandbox/connect", h.connect)
+}
+
+type sandboxInboundRequest struct {
+	ID        string    `json:"id"`
+	From      string    `json:"from" binding:"required"`
+	PushName  string    `json:"pushName"`
+	Text      string    `json:"text"`
+	Quoted    string    `json:"quoted"`
+	Media     []byte    `json:"media"`
+	MimeType  string    `json:"mimeType"`
+	Caption   string    `json:"caption"`
+	FileName  string    `json:"fileName"`
+	PTT       bool      `json:"ptt"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// sandboxSchedule é o Schedule em milissegundos; -1 desativa a confirmação.
+type sandboxSchedule struct {
+	DeliveredAfterMS *int64 `json:"deliveredAfterMs" binding:"required"`
+	ReadAfterMS      *int64 `json:"readAfterMs" binding:"required"`
+}
+
+// instance autoriza o acesso à instância e confirma que ela é sandbox.
+func (h *SandboxHandler) instance(c *gin.Context) (string, bool) {
+	id := c.Param("id")
+
+	var inst model.Instance
+	var err error
+	if c.GetString("authType") == "instance_token" {
+		if c.GetString("instanceID") != id {
+			response.ErrorWithMessage(c, http.StatusForbidden, "token inválido para esta instância")
+			return "", false
+		}
+		inst, err = h.service.Get(c.Request.Context(), id)
+	} else {
+		inst, err = h.service.GetByUser(c.Request.Context(), id, c.GetString("userID"), c.GetString("userRole"))
+	}
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			response.ErrorWithMessage(c, http.StatusNotFound, "instância não encontrada")
+			return "", false
+		}
+		response.Error(c, http.StatusInternalServerError, err)
+		return "", false
+	}
+
+	if inst.Provider != model.InstanceProviderSandbox {
+		response.ErrorWithMessage(c, http.StatusNotImplemented, "instância não usa o sandbox")
+		return "", false
+	}
+	return id, true
+}
+
+func (h *SandboxHandler) inbound(c *gin.Context) {
+	id, ok := h.instance(c)
+	if !ok {
+		return
+	}
+
+	var req sandboxInboundRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, err)
+		return
+	}
+
+	msgID, err := h.sandbox.Inject(c.Request.Context(), id, sandbox.Inbound{
+		ID:        req.ID,
+		From:      req.From,
+		PushName:  req.PushName,
+		Text:      req.Text,
+		Quoted:    req.Quoted,
+		Media:     req.Media,
+		MimeType:  req.MimeType,
+		Caption:   req.Caption,
+		FileName:  req.FileName,
+		PTT:       req.PTT,
+		Timestamp: req.Timestamp,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, sandbox.ErrInvalidInbound):
+			response.Error(c, http.StatusBadRequest, err)
+		case errors.Is(err, session.ErrNotConnected):
+			response.ErrorWithMessage(c, http.StatusBadRequest, "instância não conectada")
+		default:
+			h.log.Error("erro ao injetar mensagem sandbox", zap.String("instance_id", id), zap.Error(err))
+			response.Error(c, http.StatusInternalServerError, err)
+		}
+		return
+	}
+	response.Success(c, http.StatusCreated, gin.H{"id": msgID})
+}
+
+func (h *SandboxHandler) getSchedule(c *gin.Context) {
+	id, ok := h.instance(c)
+	if !ok {
+		return
+	}
+	response.Success(c, http.StatusOK, toSandboxSchedule(h.sandbox.Schedule(id)))
+}
+
+func (h *SandboxHandler) setSchedule(c *gin.Context) {
+	id, ok := h.instance(c)
+	if !ok {
+		return
+	}
+
+	var req sandboxSchedule
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, err)
+		return
+	}
+
+	schedule := sandbox.Schedule{
+		DeliveredAfter: time.Duration(*req.DeliveredAfterMS) * time.Millisecond,
+		ReadAfter:      time.Duration(*req.ReadAfterMS) * time.Millisecond,
+	}
+	h.sandbox.SetSchedule(id, schedule)
+	response.Success(c, http.StatusOK, toSandboxSchedule(schedule))
+}
+
+func (h *SandboxHandler) connect(c *gin.Context) {
+	id, ok := h.instance(c)
+	if !ok {
+		return
+	}
+	if err := h.sandbox.Connect(c.Request.Context(), id); err != nil {
+		h.log.Error("erro ao conectar instância sandbox", zap.String("instance_id", id), zap.Error(err))
+		response.Error(c, http.StatusInternalServerError, err)
+		return
+	}
+	response.Success(c, http.StatusOK, gin.H{"status": model.InstanceStatusActive})
+}
+
+func toSandboxSchedule(s sandbox.Schedule) sandboxSchedule {
+	delivered := durationMS(s.DeliveredAfter)
+	read := durationMS(s.ReadAfter)
+	return sandboxSchedule{DeliveredAfterMS: &delivered, ReadAfterMS: &read}
+}
+
+// durationMS converte o atraso em milissegundos, normalizando os negativos
+// (desativados) para -1.
+func durationMS(d time.Duration) int64 {
+	if d < 0 {
+		return -1
+	}
+	return d.Milliseconds()
+}
@@ -0,0 +1,133 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/open-apime/apime/internal/api/middleware"
+	"github.com/open-apime/apime/internal/pkg/response"
+	instanceSvc "github.com/open-apime/apime/internal/service/instance"
+	userSvc "github.com/open-apime/apime/internal/service/user"
+)
+
+// SessionTransferHandler expõe a exportação e a importação de sessões para
+// migrar instâncias entre servidores sem novo pareamento.
+type SessionTransferHandler struct {
+	service *instanceSvc.Service
+	users   *userSvc.Service
+	log     *zap.Logger
+}
+
+func NewSessionTransferHandler(service *instanceSvc.Service, users *userSvc.Service, log *zap.Logger) *SessionTransferHandler {
+	return &SessionTransferHandler{service: service, users: users, log: log}
+}
+
+// Register registra os endpoints, restritos a administradores.
+func (h *SessionTransferHandler) Register(r *gin.RouterGroup) {
+	admin := r.Group("")
+	admin.Use(middleware.RequireAdmin(h.users))
+
+	admin.POST("/instances/:id/export", h.export)
+	admin.POST("/instances/import", h.importSession)
+}
+
+type exportSessionRequest struct {
+	Passphrase string `json:"passphrase" binding:"required"`
+	Release    *bool  `json:"release"`
+}
+
+type importSessionRequest struct {
+	Bundle      instanceSvc.SessionBundle `json:"bundle" binding:"required"`
+	Passphrase  string                    `json:"passphrase" binding:"required"`
+	OwnerUserID string                    `json:"ownerUserId"`
+}
+
+func (h *SessionTransferHandler) export(c *gin.Context) {
+	id := c.Param("id")
+
+	var req exportSessionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, err)
+		return
+	}
+
+	release := true
+	if req.Release != nil {
+		release = *req.Release
+	}
+
+	bundle, err := h.service.ExportSession(c.Request.Context(), id, instanceSvc.ExportOptions{
+		Passphrase: req.Passphrase,
+		Release:    release,
+	})
+	if err != nil {
+		h.log.Error("erro ao exportar sessão", zap.String("instance_id", id), zap.Error(err))
+		h.handleError(c, err)
+		return
+	}
+
+	h.log.Info("sessão exportada",
+		zap.String("instance_id", id),
+		zap.Bool("release", release),
+		zap.String("user_id", c.GetString("userID")),
+	)
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="apime-session-%s.json"`, id))
+	c.JSON(http.StatusOK, bundle)
+}
+
+func (h *SessionTransferHandler) importSession(c *gin.Context) {
+	var req importSessionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, err)
+		return
+	}
+
+	owner := req.OwnerUserID
+	if owner == "" {
+		owner = c.GetString("userID")
+	} else if _, err := h.users.Get(c.Request.Context(), owner); err != nil {
+		response.ErrorWithMessage(c, http.StatusBadRequest, "ownerUserId não encontrado")
+		return
+	}
+
+	inst, err := h.service.ImportSession(c.Request.Context(), req.Bundle, instanceSvc.ImportOptions{
+		Passphrase:  req.Passphrase,
+		OwnerUserID: owner,
+		Activate:    true,
+	})
+	if err != nil {
+		h.log.Error("erro ao importar sessão", zap.String("instance_id", req.Bundle.InstanceID), zap.Error(err))
+		h.handleError(c, err)
+		return
+	}
+
+	h.log.Info("sessão importada",
+		zap.String("instance_id", inst.ID),
+		zap.String("owner_user_id", inst.OwnerUserID),
+	)
+	response.Success(c, http.StatusCreated, inst)
+}
+
+func (h *SessionTransferHandler) handleError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, instanceSvc.ErrPassphraseRequired),
+		errors.Is(err, instanceSvc.ErrInvalidPassphrase),
+		errors.Is(err, instanceSvc.ErrInvalidBundle),
+		errors.Is(err, instanceSvc.ErrUnsupportedBundle):
+		response.Error(c, http.StatusBadRequest, err)
+	case errors.Is(err, instanceSvc.ErrInstanceAlreadyExists),
+		strings.Contains(err.Error(), "ativa neste nó"):
+		response.Error(c, http.StatusConflict, err)
+	case strings.Contains(err.Error(), "not found"),
+		strings.Contains(err.Error(), "sessão não encontrada"):
+		response.ErrorWithMessage(c, http.StatusNotFound, "instância ou sessão não encontrada")
+	default:
+		response.Error(c, http.StatusInternalServerError, err)
+	}
+}
@@ -3,6 +3,7 @@ package handler
 import (
 	"context"
 	"encoding/base64"
+	"errors"
 	"net/http"
 	"strconv"
 	"strings"
@@ -12,19 +13,26 @@ import (
 	"go.mau.fi/whatsmeow"
 	"go.mau.fi/whatsmeow/types"
 
+	"github.com/open-apime/apime/internal/pkg/mediatype"
 	"github.com/open-apime/apime/internal/pkg/response"
+	"github.com/open-apime/apime/internal/session"
+	"github.com/open-apime/apime/internal/storage/model"
 )
 
+// WhatsAppHandler expõe operações do WhatsApp. As comuns aos provedores passam
+// pelo session.Messenger da instância; as demais exigem uma sessão whatsmeow.
 type WhatsAppHandler struct {
 	sessionManager WhatsAppSessionManager
+	messengers     *session.Registry
 }
 
 type WhatsAppSessionManager interface {
 	GetClient(instanceID string) (*whatsmeow.Client, error)
+	ResolveLID(ctx context.Context, instanceID, lid string) (model.LIDMapping, error)
 }
 
-func NewWhatsAppHandler(sessionManager WhatsAppSessionManager) *WhatsAppHandler {
-	return &WhatsAppHandler{sessionManager: sessionManager}
+func NewWhatsAppHandler(sessionManager WhatsAppSessionManager, messengers *session.Registry) *WhatsAppHandler {
+	return &WhatsAppHandler{sessionManager: sessionManager, messengers: messengers}
 }
 
 func (h *WhatsAppHandler) Register(r *gin.RouterGroup) {
@@ -34,6 +42,7 @@ func (h *WhatsAppHandler) Register(r *gin.RouterGroup) {
 	r.POST("/instances/:id/whatsapp/messages/delete", h.deleteForEveryone)
 	r.GET("/instances/:id/whatsapp/contacts", h.listContacts)
 	r.GET("/instances/:id/whatsapp/contacts/:jid", h.getContact)
+	r.GET("/instances/:id/whatsapp/lid/:lid", h.resolveLID)
 	r.GET("/instances/:id/whatsapp/userinfo/:jid", h.getUserInfo)
 	r.GET("/instances/:id/whatsapp/privacy", h.getPrivacySettings)
 	r.POST("/instances/:id/whatsapp/privacy", h.setPrivacySetting)
@@ -79,6 +88,59 @@ func (h *WhatsAppHandler) requireInstanceToken(c *gin.Context) (string, bool) {
 	return instanceID, true
 }
 
+// messenger retorna o session.Messenger do provedor da instância.
+func (h *WhatsAppHandler) messenger(c *gin.Context, instanceID string) (session.Messenger, bool) {
+	if h.messengers == nil {
+		response.ErrorWithMessage(c, http.StatusBadRequest, "session manager não configurado")
+		return nil, false
+	}
+	m, err := h.messengers.For(c.Request.Context(), instanceID)
+	if err != nil {
+		h.messengerError(c, err, http.StatusInternalServerError)
+		return nil, false
+	}
+	return m, true
+}
+
+// client retorna o cliente whatsmeow da instância. Instâncias de outros
+// provedores recebem 501.
+func (h *WhatsAppHandler) client(c *gin.Context, instanceID string) (*whatsmeow.Client, bool) {
+	if h.messengers != nil {
+		provider, err := h.messengers.Provider(c.Request.Context(), instanceID)
+		if err != nil {
+			h.messengerError(c, err, http.StatusInternalServerError)
+			return nil, false
+		}
+		if provider != model.InstanceProviderWhatsmeow {
+			response.ErrorWithMessage(c, http.StatusNotImplemented, session.ErrNotSupported.Error())
+			return nil, false
+		}
+	}
+	client, err := h.sessionManager.GetClient(instanceID)
+	if err != nil {
+		response.ErrorWithMessage(c, http.StatusBadRequest, "instância não conectada")
+		return nil, false
+	}
+	return client, true
+}
+
+// messengerError responde com o status correspondente aos erros de
+// session.Messenger, usando status nos demais.
+func (h *WhatsAppHandler) messengerError(c *gin.Context, err error, status int) {
+	switch {
+	case errors.Is(err, session.ErrNotSupported):
+		response.ErrorWithMessage(c, http.StatusNotImplemented, err.Error())
+	case errors.Is(err, session.ErrRestoring):
+		respondRestoring(c)
+	case errors.Is(err, session.ErrNotConnected):
+		response.ErrorWithMessage(c, http.StatusBadRequest, "instância não conectada")
+	case strings.Contains(err.Error(), "not found"):
+		response.ErrorWithMessage(c, http.StatusNotFound, "instância não encontrada")
+	default:
+		response.Error(c, status, err)
+	}
+}
+
 type checkIsWhatsAppRequest struct {
 	Phone string `json:"phone" binding:"required"`
 }
@@ -104,15 +166,14 @@ func (h *WhatsAppHandler) checkIsWhatsApp(c *gin.Context) {
 		phone = "+" + phone
 	}
 
-	client, err := h.sessionManager.GetClient(instanceID)
-	if err != nil {
-		response.ErrorWithMessage(c, http.StatusBadRequest, "instância não conectada")
+	messenger, ok := h.messenger(c, instanceID)
+	if !ok {
 		return
 	}
 
-	resp, err := client.IsOnWhatsApp(c.Request.Context(), []string{phone})
+	resp, err := messenger.CheckPhones(c.Request.Context(), instanceID, []string{phone})
 	if err != nil {
-		response.Error(c, http.StatusInternalServerError, err)
+		h.messengerError(c, err, http.StatusInternalServerError)
 		return
 	}
 
@@ -139,24 +200,23 @@ func (h *WhatsAppHandler) setPresence(c *gin.Context) {
 		return
 	}
 
-	client, err := h.sessionManager.GetClient(instanceID)
-	if err != nil {
-		response.ErrorWithMessage(c, http.StatusBadRequest, "instância não conectada")
+	messenger, ok := h.messenger(c, instanceID)
+	if !ok {
 		return
 	}
 
 	state := strings.ToLower(strings.TrimSpace(req.State))
 	switch state {
 	case "available", "online":
-		if err := client.SendPresence(c.Request.Context(), types.PresenceAvailable); err != nil {
-			response.Error(c, http.StatusInternalServerError, err)
+		if err := messenger.SendPresence(c.Request.Context(), instanceID, types.PresenceAvailable); err != nil {
+			h.messengerError(c, err, http.StatusInternalServerError)
 			return
 		}
 		response.Success(c, http.StatusOK, gin.H{"status": "ok"})
 		return
 	case "unavailable", "offline":
-		if err := client.SendPresence(c.Request.Context(), types.PresenceUnavailable); err != nil {
-			response.Error(c, http.StatusInternalServerError, err)
+		if err := messenger.SendPresence(c.Request.Context(), instanceID, types.PresenceUnavailable); err != nil {
+			h.messengerError(c, err, http.StatusInternalServerError)
 			return
 		}
 		response.Success(c, http.StatusOK, gin.H{"status": "ok"})
@@ -194,8 +254,8 @@ func (h *WhatsAppHandler) setPresence(c *gin.Context) {
 		chatState = types.ChatPresencePaused
 	}
 
-	if err := client.SendChatPresence(c.Request.Context(), toJID, chatState, media); err != nil {
-		response.Error(c, http.StatusInternalServerError, err)
+	if err := messenger.SendChatPresence(c.Request.Context(), instanceID, toJID, chatState, media); err != nil {
+		h.messengerError(c, err, http.StatusInternalServerError)
 		return
 	}
 	response.Success(c, http.StatusOK, gin.H{"status": "ok"})
@@ -216,9 +276,8 @@ func (h *WhatsAppHandler) createGroup(c *gin.Context) {
 		response.Error(c, http.StatusBadRequest, err)
 		return
 	}
-	client, err := h.sessionManager.GetClient(instanceID)
-	if err != nil {
-		response.ErrorWithMessage(c, http.StatusBadRequest, "instância não conectada")
+	messenger, ok := h.messenger(c, instanceID)
+	if !ok {
 		return
 	}
 
@@ -239,12 +298,9 @@ func (h *WhatsAppHandler) createGroup(c *gin.Context) {
 		participants = append(participants, jid)
 	}
 
-	info, err := client.CreateGroup(c.Request.Context(), whatsmeow.ReqCreateGroup{
-		Name:         strings.TrimSpace(req.Name),
-		Participants: participants,
-	})
+	info, err := messenger.CreateGroup(c.Request.Context(), instanceID, strings.TrimSpace(req.Name), participants)
 	if err != nil {
-		response.Error(c, http.StatusBadRequest, err)
+		h.messengerError(c, err, http.StatusBadRequest)
 		return
 	}
 	response.Success(c, http.StatusOK, info)
@@ -260,9 +316,8 @@ func (h *WhatsAppHandler) updateGroupParticipants(c *gin.Context) {
 	if !ok {
 		return
 	}
-	client, err := h.sessionManager.GetClient(instanceID)
-	if err != nil {
-		response.ErrorWithMessage(c, http.StatusBadRequest, "instância não conectada")
+	messenger, ok := h.messenger(c, instanceID)
+	if !ok {
 		return
 	}
 
@@ -282,16 +337,9 @@ func (h *WhatsAppHandler) updateGroupParticipants(c *gin.Context) {
 		return
 	}
 
-	var action whatsmeow.ParticipantChange
-	switch strings.ToLower(strings.TrimSpace(req.Action)) {
-	case "add":
-		action = whatsmeow.ParticipantChangeAdd
-	case "remove":
-		action = whatsmeow.ParticipantChangeRemove
-	case "promote":
-		action = whatsmeow.ParticipantChangePromote
-	case "demote":
-		action = whatsmeow.ParticipantChangeDemote
+	action := session.ParticipantAction(strings.ToLower(strings.TrimSpace(req.Action)))
+	switch action {
+	case session.ParticipantAdd, session.ParticipantRemove, session.ParticipantPromote, session.ParticipantDemote:
 	default:
 		response.ErrorWithMessage(c, http.StatusBadRequest, "action inválida")
 		return
@@ -314,9 +362,9 @@ func (h *WhatsAppHandler) updateGroupParticipants(c *gin.Context) {
 		participants = append(participants, jid)
 	}
 
-	res, err := client.UpdateGroupParticipants(c.Request.Context(), groupJID, participants, action)
+	res, err := messenger.UpdateGroupParticipants(c.Request.Context(), instanceID, groupJID, participants, action)
 	if err != nil {
-		response.Error(c, http.StatusBadRequest, err)
+		h.messengerError(c, err, http.StatusBadRequest)
 		return
 	}
 	response.Success(c, http.StatusOK, gin.H{"participants": res})
@@ -327,9 +375,8 @@ func (h *WhatsAppHandler) getStatusPrivacy(c *gin.Context) {
 	if !ok {
 		return
 	}
-	client, err := h.sessionManager.GetClient(instanceID)
-	if err != nil {
-		response.ErrorWithMessage(c, http.StatusBadRequest, "instância não conectada")
+	client, ok := h.client(c, instanceID)
+	if !ok {
 		return
 	}
 	privacy, err := client.GetStatusPrivacy(c.Request.Context())
@@ -345,9 +392,8 @@ func (h *WhatsAppHandler) newsletterSubscribeLiveUpdates(c *gin.Context) {
 	if !ok {
 		return
 	}
-	client, err := h.sessionManager.GetClient(instanceID)
-	if err != nil {
-		response.ErrorWithMessage(c, http.StatusBadRequest, "instância não conectada")
+	client, ok := h.client(c, instanceID)
+	if !ok {
 		return
 	}
 	jidStr := strings.TrimSpace(c.Param("jid"))
@@ -373,9 +419,8 @@ func (h *WhatsAppHandler) newsletterMarkViewed(c *gin.Context) {
 	if !ok {
 		return
 	}
-	client, err := h.sessionManager.GetClient(instanceID)
-	if err != nil {
-		response.ErrorWithMessage(c, http.StatusBadRequest, "instância não conectada")
+	client, ok := h.client(c, instanceID)
+	if !ok {
 		return
 	}
 	jidStr := strings.TrimSpace(c.Param("jid"))
@@ -420,9 +465,8 @@ func (h *WhatsAppHandler) newsletterSendReaction(c *gin.Context) {
 	if !ok {
 		return
 	}
-	client, err := h.sessionManager.GetClient(instanceID)
-	if err != nil {
-		response.ErrorWithMessage(c, http.StatusBadRequest, "instância não conectada")
+	client, ok := h.client(c, instanceID)
+	if !ok {
 		return
 	}
 	jidStr := strings.TrimSpace(c.Param("jid"))
@@ -464,9 +508,8 @@ func (h *WhatsAppHandler) getNewsletterMessageUpdates(c *gin.Context) {
 	if !ok {
 		return
 	}
-	client, err := h.sessionManager.GetClient(instanceID)
-	if err != nil {
-		response.ErrorWithMessage(c, http.StatusBadRequest, "instância não conectada")
+	client, ok := h.client(c, instanceID)
+	if !ok {
 		return
 	}
 	jidStr := strings.TrimSpace(c.Param("jid"))
@@ -494,9 +537,8 @@ func (h *WhatsAppHandler) uploadMedia(c *gin.Context) {
 	if !ok {
 		return
 	}
-	client, err := h.sessionManager.GetClient(instanceID)
-	if err != nil {
-		response.ErrorWithMessage(c, http.StatusBadRequest, "instância não conectada")
+	messenger, ok := h.messenger(c, instanceID)
+	if !ok {
 		return
 	}
 	var req uploadMediaRequest
@@ -509,23 +551,21 @@ func (h *WhatsAppHandler) uploadMedia(c *gin.Context) {
 		response.ErrorWithMessage(c, http.StatusBadRequest, "base64 inválido")
 		return
 	}
-	var mt whatsmeow.MediaType
-	switch strings.ToLower(strings.TrimSpace(req.MediaType)) {
-	case "image":
-		mt = whatsmeow.MediaImage
-	case "video":
-		mt = whatsmeow.MediaVideo
-	case "audio":
-		mt = whatsmeow.MediaAudio
-	case "document":
-		mt = whatsmeow.MediaDocument
+	kind := session.MediaKind(strings.ToLower(strings.TrimSpace(req.MediaType)))
+	switch kind {
+	case session.MediaImage, session.MediaVideo, session.MediaAudio, session.MediaDocument:
 	default:
 		response.ErrorWithMessage(c, http.StatusBadRequest, "media_type inválido")
 		return
 	}
-	resp, err := client.Upload(c.Request.Context(), data, mt)
+	mimeType, err := mediatype.Validate(string(kind), data, "", false)
 	if err != nil {
-		response.Error(c, http.StatusInternalServerError, err)
+		respondMediaValidation(c, err)
+		return
+	}
+	resp, err := messenger.Upload(c.Request.Context(), instanceID, data, mimeType, kind)
+	if err != nil {
+		h.messengerError(c, err, http.StatusInternalServerError)
 		return
 	}
 	response.Success(c, http.StatusOK, resp)
@@ -536,9 +576,8 @@ func (h *WhatsAppHandler) listGroupJoinRequests(c *gin.Context) {
 	if !ok {
 		return
 	}
-	client, err := h.sessionManager.GetClient(instanceID)
-	if err != nil {
-		response.ErrorWithMessage(c, http.StatusBadRequest, "instância não conectada")
+	client, ok := h.client(c, instanceID)
+	if !ok {
 		return
 	}
 
@@ -570,9 +609,8 @@ func (h *WhatsAppHandler) updateGroupJoinRequests(c *gin.Context) {
 	if !ok {
 		return
 	}
-	client, err := h.sessionManager.GetClient(instanceID)
-	if err != nil {
-		response.ErrorWithMessage(c, http.StatusBadRequest, "instância não conectada")
+	client, ok := h.client(c, instanceID)
+	if !ok {
 		return
 	}
 
@@ -633,9 +671,8 @@ func (h *WhatsAppHandler) getGroupInfo(c *gin.Context) {
 	if !ok {
 		return
 	}
-	client, err := h.sessionManager.GetClient(instanceID)
-	if err != nil {
-		response.ErrorWithMessage(c, http.StatusBadRequest, "instância não conectada")
+	messenger, ok := h.messenger(c, instanceID)
+	if !ok {
 		return
 	}
 
@@ -649,9 +686,9 @@ func (h *WhatsAppHandler) getGroupInfo(c *gin.Context) {
 		return
 	}
 
-	info, err := client.GetGroupInfo(c.Request.Context(), groupJID)
+	info, err := messenger.GetGroupInfo(c.Request.Context(), instanceID, groupJID)
 	if err != nil {
-		response.Error(c, http.StatusInternalServerError, err)
+		h.messengerError(c, err, http.StatusInternalServerError)
 		return
 	}
 	response.Success(c, http.StatusOK, info)
@@ -676,9 +713,8 @@ func (h *WhatsAppHandler) markRead(c *gin.Context) {
 		return
 	}
 
-	client, err := h.sessionManager.GetClient(instanceID)
-	if err != nil {
-		response.ErrorWithMessage(c, http.StatusBadRequest, "instância não conectada")
+	messenger, ok := h.messenger(c, instanceID)
+	if !ok {
 		return
 	}
 
@@ -705,13 +741,8 @@ func (h *WhatsAppHandler) markRead(c *gin.Context) {
 		}
 	}
 
-	receiptType := types.ReceiptTypeRead
-	if req.Played {
-		receiptType = types.ReceiptTypePlayed
-	}
-
-	if err := client.MarkRead(c.Request.Context(), []types.MessageID{types.MessageID(req.MessageID)}, time.Now(), chatJID, senderJID, receiptType); err != nil {
-		response.Error(c, http.StatusInternalServerError, err)
+	if err := messenger.MarkRead(c.Request.Context(), instanceID, chatJID, senderJID, []string{req.MessageID}, req.Played); err != nil {
+		h.messengerError(c, err, http.StatusInternalServerError)
 		return
 	}
 	response.Success(c, http.StatusOK, gin.H{"status": "ok"})
@@ -735,9 +766,8 @@ func (h *WhatsAppHandler) deleteForEveryone(c *gin.Context) {
 		return
 	}
 
-	client, err := h.sessionManager.GetClient(instanceID)
-	if err != nil {
-		response.ErrorWithMessage(c, http.StatusBadRequest, "instância não conectada")
+	messenger, ok := h.messenger(c, instanceID)
+	if !ok {
 		return
 	}
 
@@ -765,15 +795,10 @@ func (h *WhatsAppHandler) deleteForEveryone(c *gin.Context) {
 		}
 	}
 
-	resp, err := client.RevokeMessage(c.Request.Context(), chatJID, types.MessageID(req.MessageID))
+	resp, err := messenger.Revoke(c.Request.Context(), instanceID, chatJID, senderJID, req.MessageID)
 	if err != nil {
-		// fallback: usar BuildRevoke para suportar revoke admin quando sender for informado
-		msg := client.BuildRevoke(chatJID, senderJID, types.MessageID(req.MessageID))
-		resp, err = client.SendMessage(c.Request.Context(), chatJID, msg)
-		if err != nil {
-			response.Error(c, http.StatusInternalServerError, err)
-			return
-		}
+		h.messengerError(c, err, http.StatusInternalServerError)
+		return
 	}
 
 	response.Success(c, http.StatusOK, gin.H{
@@ -789,9 +814,8 @@ func (h *WhatsAppHandler) listContacts(c *gin.Context) {
 		return
 	}
 
-	client, err := h.sessionManager.GetClient(instanceID)
-	if err != nil {
-		response.ErrorWithMessage(c, http.StatusBadRequest, "instância não conectada")
+	client, ok := h.client(c, instanceID)
+	if !ok {
 		return
 	}
 	if client.Store == nil || client.Store.Contacts == nil {
@@ -823,9 +847,8 @@ func (h *WhatsAppHandler) getContact(c *gin.Context) {
 		return
 	}
 
-	client, err := h.sessionManager.GetClient(instanceID)
-	if err != nil {
-		response.ErrorWithMessage(c, http.StatusBadRequest, "instância não conectada")
+	client, ok := h.client(c, instanceID)
+	if !ok {
 		return
 	}
 	if client.Store == nil || client.Store.Contacts == nil {
@@ -840,14 +863,42 @@ func (h *WhatsAppHandler) getContact(c *gin.Context) {
 	response.Success(c, http.StatusOK, gin.H{"jid": jid.String(), "contact": contact})
 }
 
-func (h *WhatsAppHandler) getPrivacySettings(c *gin.Context) {
+// resolveLID retorna o número do contato identificado pelo LID, como
+// aprendido pela sessão em mensagens, grupos e history sync.
+func (h *WhatsAppHandler) resolveLID(c *gin.Context) {
 	instanceID, ok := h.requireInstanceToken(c)
 	if !ok {
 		return
 	}
-	client, err := h.sessionManager.GetClient(instanceID)
+
+	mapping, err := h.sessionManager.ResolveLID(c.Request.Context(), instanceID, c.Param("lid"))
 	if err != nil {
-		response.ErrorWithMessage(c, http.StatusBadRequest, "instância não conectada")
+		switch {
+		case errors.Is(err, session.ErrInvalidJID):
+			response.ErrorWithMessage(c, http.StatusBadRequest, "lid inválido")
+		case strings.Contains(err.Error(), "not found"):
+			response.ErrorWithMessage(c, http.StatusNotFound, "número do LID não conhecido")
+		default:
+			response.Error(c, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	response.Success(c, http.StatusOK, gin.H{
+		"lid":    mapping.LID,
+		"pn":     mapping.PN,
+		"phone":  strings.Split(mapping.PN, "@")[0],
+		"source": mapping.Source,
+	})
+}
+
+func (h *WhatsAppHandler) getPrivacySettings(c *gin.Context) {
+	instanceID, ok := h.requireInstanceToken(c)
+	if !ok {
+		return
+	}
+	client, ok := h.client(c, instanceID)
+	if !ok {
 		return
 	}
 	settings := client.GetPrivacySettings(c.Request.Context())
@@ -868,9 +919,8 @@ func (h *WhatsAppHandler) setStatusMessage(c *gin.Context) {
 		response.Error(c, http.StatusBadRequest, err)
 		return
 	}
-	client, err := h.sessionManager.GetClient(instanceID)
-	if err != nil {
-		response.ErrorWithMessage(c, http.StatusBadRequest, "instância não conectada")
+	client, ok := h.client(c, instanceID)
+	if !ok {
 		return
 	}
 	if err := client.SetStatusMessage(c.Request.Context(), strings.TrimSpace(req.Message)); err != nil {
@@ -894,9 +944,8 @@ func (h *WhatsAppHandler) setDefaultDisappearingTimer(c *gin.Context) {
 		response.Error(c, http.StatusBadRequest, err)
 		return
 	}
-	client, err := h.sessionManager.GetClient(instanceID)
-	if err != nil {
-		response.ErrorWithMessage(c, http.StatusBadRequest, "instância não conectada")
+	client, ok := h.client(c, instanceID)
+	if !ok {
 		return
 	}
 	if err := client.SetDefaultDisappearingTimer(c.Request.Context(), time.Duration(req.Seconds)*time.Second); err != nil {
@@ -924,9 +973,8 @@ func (h *WhatsAppHandler) getContactQRLink(c *gin.Context) {
 		_ = c.ShouldBindJSON(&req)
 		revoke = req.Revoke
 	}
-	client, err := h.sessionManager.GetClient(instanceID)
-	if err != nil {
-		response.ErrorWithMessage(c, http.StatusBadRequest, "instância não conectada")
+	client, ok := h.client(c, instanceID)
+	if !ok {
 		return
 	}
 	code, err := client.GetContactQRLink(c.Request.Context(), revoke)
@@ -951,9 +999,8 @@ func (h *WhatsAppHandler) resolveContactQRLink(c *gin.Context) {
 		response.Error(c, http.StatusBadRequest, err)
 		return
 	}
-	client, err := h.sessionManager.GetClient(instanceID)
-	if err != nil {
-		response.ErrorWithMessage(c, http.StatusBadRequest, "instância não conectada")
+	client, ok := h.client(c, instanceID)
+	if !ok {
 		return
 	}
 	target, err := client.ResolveContactQRLink(c.Request.Context(), strings.TrimSpace(req.Code))
@@ -969,9 +1016,8 @@ func (h *WhatsAppHandler) getGroupInviteLink(c *gin.Context) {
 	if !ok {
 		return
 	}
-	client, err := h.sessionManager.GetClient(instanceID)
-	if err != nil {
-		response.ErrorWithMessage(c, http.StatusBadRequest, "instância não conectada")
+	client, ok := h.client(c, instanceID)
+	if !ok {
 		return
 	}
 
@@ -1008,9 +1054,8 @@ func (h *WhatsAppHandler) getGroupInfoFromLink(c *gin.Context) {
 		response.Error(c, http.StatusBadRequest, err)
 		return
 	}
-	client, err := h.sessionManager.GetClient(instanceID)
-	if err != nil {
-		response.ErrorWithMessage(c, http.StatusBadRequest, "instância não conectada")
+	client, ok := h.client(c, instanceID)
+	if !ok {
 		return
 	}
 	info, err := client.GetGroupInfoFromLink(c.Request.Context(), strings.TrimSpace(req.Link))
@@ -1035,9 +1080,8 @@ func (h *WhatsAppHandler) joinGroupWithLink(c *gin.Context) {
 		response.Error(c, http.StatusBadRequest, err)
 		return
 	}
-	client, err := h.sessionManager.GetClient(instanceID)
-	if err != nil {
-		response.ErrorWithMessage(c, http.StatusBadRequest, "instância não conectada")
+	client, ok := h.client(c, instanceID)
+	if !ok {
 		return
 	}
 	jid, err := client.JoinGroupWithLink(c.Request.Context(), strings.TrimSpace(req.Link))
@@ -1053,9 +1097,8 @@ func (h *WhatsAppHandler) leaveGroup(c *gin.Context) {
 	if !ok {
 		return
 	}
-	client, err := h.sessionManager.GetClient(instanceID)
-	if err != nil {
-		response.ErrorWithMessage(c, http.StatusBadRequest, "instância não conectada")
+	messenger, ok := h.messenger(c, instanceID)
+	if !ok {
 		return
 	}
 
@@ -1069,8 +1112,8 @@ func (h *WhatsAppHandler) leaveGroup(c *gin.Context) {
 		return
 	}
 
-	if err := client.LeaveGroup(c.Request.Context(), groupJID); err != nil {
-		response.Error(c, http.StatusInternalServerError, err)
+	if err := messenger.LeaveGroup(c.Request.Context(), instanceID, groupJID); err != nil {
+		h.messengerError(c, err, http.StatusInternalServerError)
 		return
 	}
 	response.Success(c, http.StatusOK, gin.H{"status": "ok"})
@@ -1090,9 +1133,8 @@ func (h *WhatsAppHandler) resolveBusinessMessageLink(c *gin.Context) {
 		response.Error(c, http.StatusBadRequest, err)
 		return
 	}
-	client, err := h.sessionManager.GetClient(instanceID)
-	if err != nil {
-		response.ErrorWithMessage(c, http.StatusBadRequest, "instância não conectada")
+	client, ok := h.client(c, instanceID)
+	if !ok {
 		return
 	}
 	target, err := client.ResolveBusinessMessageLink(c.Request.Context(), strings.TrimSpace(req.Code))
@@ -1119,9 +1161,8 @@ func (h *WhatsAppHandler) getUserInfo(c *gin.Context) {
 		return
 	}
 
-	client, err := h.sessionManager.GetClient(instanceID)
-	if err != nil {
-		response.ErrorWithMessage(c, http.StatusBadRequest, "instância não conectada")
+	client, ok := h.client(c, instanceID)
+	if !ok {
 		return
 	}
 
@@ -1154,9 +1195,8 @@ func (h *WhatsAppHandler) getChatSettings(c *gin.Context) {
 		return
 	}
 
-	client, err := h.sessionManager.GetClient(instanceID)
-	if err != nil {
-		response.ErrorWithMessage(c, http.StatusBadRequest, "instância não conectada")
+	client, ok := h.client(c, instanceID)
+	if !ok {
 		return
 	}
 	if client.Store == nil || client.Store.ChatSettings == nil {
@@ -1199,9 +1239,8 @@ func (h *WhatsAppHandler) setChatSettings(c *gin.Context) {
 		return
 	}
 
-	client, err := h.sessionManager.GetClient(instanceID)
-	if err != nil {
-		response.ErrorWithMessage(c, http.StatusBadRequest, "instância não conectada")
+	client, ok := h.client(c, instanceID)
+	if !ok {
 		return
 	}
 	if client.Store == nil || client.Store.ChatSettings == nil {
@@ -1247,9 +1286,8 @@ func (h *WhatsAppHandler) setPrivacySetting(c *gin.Context) {
 	if !ok {
 		return
 	}
-	client, err := h.sessionManager.GetClient(instanceID)
-	if err != nil {
-		response.ErrorWithMessage(c, http.StatusBadRequest, "instância não conectada")
+	client, ok := h.client(c, instanceID)
+	if !ok {
 		return
 	}
 	var req setPrivacySettingRequest
@@ -1278,15 +1316,14 @@ func (h *WhatsAppHandler) listGroups(c *gin.Context) {
 		return
 	}
 
-	client, err := h.sessionManager.GetClient(instanceID)
-	if err != nil {
-		response.ErrorWithMessage(c, http.StatusBadRequest, "instância não conectada")
+	messenger, ok := h.messenger(c, instanceID)
+	if !ok {
 		return
 	}
 
-	groups, err := client.GetJoinedGroups(c.Request.Context())
+	groups, err := messenger.GetJoinedGroups(c.Request.Context(), instanceID)
 	if err != nil {
-		response.Error(c, http.StatusInternalServerError, err)
+		h.messengerError(c, err, http.StatusInternalServerError)
 		return
 	}
 
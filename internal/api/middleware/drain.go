@@ -0,0 +1,62 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DrainState indica que o nó está encerrando e não deve aceitar novos envios.
+type DrainState struct {
+	draining   atomic.Bool
+	retryAfter time.Duration
+}
+
+func NewDrainState(retryAfter time.Duration) *DrainState {
+	if retryAfter <= 0 {
+		retryAfter = 30 * time.Second
+	}
+	return &DrainState{retryAfter: retryAfter}
+}
+
+// Start coloca o nó em modo de dreno. É irreversível.
+func (d *DrainState) Start() {
+	d.draining.Store(true)
+}
+
+func (d *DrainState) IsDraining() bool {
+	return d != nil && d.draining.Load()
+}
+
+func (d *DrainState) RetryAfter() time.Duration {
+	return d.retryAfter
+}
+
+// Drain recusa com 503 as operações de escrita em instâncias enquanto o nó
+// drena. Leituras continuam sendo atendidas até o servidor HTTP fechar.
+func Drain(state *DrainState) gin.HandlerFunc {
+	if state == nil {
+		return func(c *gin.Context) { c.Next() }
+	}
+
+	return func(c *gin.Context) {
+		if !state.IsDraining() || !isInstanceRoute(c.FullPath()) {
+			c.Next()
+			return
+		}
+
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			c.Next()
+			return
+		}
+
+		c.Header("Retry-After", fmt.Sprintf("%d", int(state.RetryAfter().Seconds())))
+		c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{
+			"error": "servidor em manutenção, tente novamente em instantes",
+		})
+	}
+}
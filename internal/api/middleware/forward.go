@@ -0,0 +1,198 @@
+package middleware
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/open-apime/apime/internal/cluster"
+)
+
+// HeaderForwardedBy marca requisições já encaminhadas por outro nó, que são
+// sempre atendidas localmente para evitar laços de encaminhamento. A marca só
+// vale com a assinatura do nó de origem (HeaderForwardedAt e
+// HeaderForwardedSignature); sem ela, os cabeçalhos são descartados.
+const (
+	HeaderForwardedBy        = "X-Apime-Forwarded-By"
+	HeaderForwardedAt        = "X-Apime-Forwarded-At"
+	HeaderForwardedSignature = "X-Apime-Forwarded-Signature"
+)
+
+// forwardMaxSkew é a diferença máxima aceita entre o horário da assinatura e
+// o do nó que recebe a requisição encaminhada.
+const forwardMaxSkew = 30 * time.Second
+
+// instanceRoutePrefixes são as rotas cujo parâmetro :id (ou :instanceId, nas
+// mídias) é uma instância.
+var instanceRoutePrefixes = []string{
+	"/api/instances/:id",
+	"/api/meta/:id",
+	"/api/media/:instanceId",
+	"/dashboard/instances/:id",
+}
+
+// ForwardOption parametriza o encaminhamento de requisições ao nó dono.
+// Secret é o segredo compartilhado pelos nós, usado para assinar os
+// encaminhamentos.
+type ForwardOption struct {
+	Coordinator *cluster.Coordinator
+	Secret      string
+	Logger      *zap.Logger
+}
+
+// ForwardToOwner encaminha requisições de instâncias pertencentes a outro nó
+// do cluster. Instâncias sem dono são assumidas pelo nó que recebeu a
+// requisição.
+func ForwardToOwner(opts ForwardOption) gin.HandlerFunc {
+	if opts.Coordinator == nil {
+		return func(c *gin.Context) { c.Next() }
+	}
+
+	coord := opts.Coordinator
+	secret := []byte(opts.Secret)
+	var proxies sync.Map
+
+	return func(c *gin.Context) {
+		// Os cabeçalhos de encaminhamento vêm do cliente até que a
+		// assinatura prove o contrário; nunca seguem adiante.
+		forwarded := verifyForward(c.Request, secret)
+		c.Request.Header.Del(HeaderForwardedBy)
+		c.Request.Header.Del(HeaderForwardedAt)
+		c.Request.Header.Del(HeaderForwardedSignature)
+
+		instanceID := c.Param("id")
+		if instanceID == "" {
+			instanceID = c.Param("instanceId")
+		}
+		if instanceID == "" || !isInstanceRoute(c.FullPath()) || forwarded {
+			c.Next()
+			return
+		}
+
+		ctx := c.Request.Context()
+		owner, err := coord.Owner(ctx, instanceID)
+		if err == nil && owner.ID == "" {
+			var claimed bool
+			claimed, err = coord.Claim(ctx, instanceID)
+			if err == nil && !claimed {
+				owner, err = coord.Owner(ctx, instanceID)
+			} else if claimed {
+				owner.ID = coord.NodeID()
+			}
+		}
+		if err != nil {
+			if opts.Logger != nil {
+				opts.Logger.Warn("cluster: erro ao resolver dono da instância",
+					zap.String("instance_id", instanceID),
+					zap.Error(err),
+				)
+			}
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{
+				"error": "não foi possível localizar o nó da instância",
+			})
+			return
+		}
+
+		if owner.ID == "" || owner.ID == coord.NodeID() {
+			c.Next()
+			return
+		}
+
+		proxy, ok := proxies.Load(owner.AdvertiseURL)
+		if !ok {
+			target, err := url.Parse(owner.AdvertiseURL)
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusBadGateway, gin.H{
+					"error": "endereço inválido do nó da instância",
+				})
+				return
+			}
+			proxy, _ = proxies.LoadOrStore(owner.AdvertiseURL, newOwnerProxy(target, coord.NodeID(), secret, opts.Logger))
+		}
+
+		c.Header("X-Apime-Node", owner.ID)
+		proxy.(*httputil.ReverseProxy).ServeHTTP(c.Writer, c.Request)
+		c.Abort()
+	}
+}
+
+func newOwnerProxy(target *url.URL, nodeID string, secret []byte, log *zap.Logger) *httputil.ReverseProxy {
+	proxy := httputil.NewSingleHostReverseProxy(target)
+
+	director := proxy.Director
+	proxy.Director = func(req *http.Request) {
+		director(req)
+		signForward(req, nodeID, secret, time.Now())
+	}
+
+	proxy.ErrorHandler = func(w http.ResponseWriter, req *http.Request, err error) {
+		if log != nil {
+			log.Warn("cluster: falha ao encaminhar requisição ao nó dono",
+				zap.String("target", target.String()),
+				zap.String("path", req.URL.Path),
+				zap.Error(err),
+			)
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusBadGateway)
+		_, _ = w.Write([]byte(`{"error":"nó dono da instância indisponível"}`))
+	}
+
+	return proxy
+}
+
+// signForward assina o encaminhamento com HMAC-SHA256 sobre o nó de origem,
+// o horário, o método e o caminho da requisição.
+func signForward(req *http.Request, nodeID string, secret []byte, now time.Time) {
+	ts := strconv.FormatInt(now.Unix(), 10)
+	req.Header.Set(HeaderForwardedBy, nodeID)
+	req.Header.Set(HeaderForwardedAt, ts)
+	req.Header.Set(HeaderForwardedSignature, forwardSignature(secret, nodeID, ts, req.Method, req.URL.RequestURI()))
+}
+
+// verifyForward confere se a requisição foi encaminhada por um nó do
+// cluster, com assinatura válida e recente.
+func verifyForward(req *http.Request, secret []byte) bool {
+	nodeID := req.Header.Get(HeaderForwardedBy)
+	ts := req.Header.Get(HeaderForwardedAt)
+	sig := req.Header.Get(HeaderForwardedSignature)
+	if nodeID == "" || ts == "" || sig == "" || len(secret) == 0 {
+		return false
+	}
+
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return false
+	}
+	if skew := time.Since(time.Unix(unix, 0)); skew > forwardMaxSkew || skew < -forwardMaxSkew {
+		return false
+	}
+
+	expected := forwardSignature(secret, nodeID, ts, req.Method, req.URL.RequestURI())
+	return hmac.Equal([]byte(sig), []byte(expected))
+}
+
+func forwardSignature(secret []byte, nodeID, ts, method, uri string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(nodeID + "\n" + ts + "\n" + method + "\n" + uri))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func isInstanceRoute(path string) bool {
+	for _, prefix := range instanceRoutePrefixes {
+		if path == prefix || strings.HasPrefix(path, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,415 @@
+package cluster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	storage_redis "github.com/open-apime/apime/internal/storage/redis"
+)
+
+const (
+	nodeKeyPrefix  = "apime:cluster:node:"
+	leaseKeyPrefix = "apime:cluster:instance:"
+)
+
+// SessionController ativa e libera as sessões WhatsApp mantidas pelo nó local.
+type SessionController interface {
+	ActivateSession(ctx context.Context, instanceID string)
+	ReleaseSession(instanceID string)
+	HasPendingLogin(instanceID string) bool
+}
+
+// InstanceLister retorna os IDs de todas as instâncias cadastradas.
+type InstanceLister func(ctx context.Context) ([]string, error)
+
+type Options struct {
+	NodeID       string
+	AdvertiseURL string
+	LeaseTTL     time.Duration
+}
+
+// Node é uma réplica da API viva no cluster.
+type Node struct {
+	ID           string `json:"id"`
+	AdvertiseURL string `json:"advertiseUrl"`
+}
+
+// Coordinator garante que cada instância seja conectada por exatamente um nó.
+// A posse é um lease no Redis com o ID do nó como valor, renovado a cada
+// ciclo. O dono desejado de cada instância é escolhido por rendezvous hashing
+// entre os nós vivos, de modo que a entrada ou saída de um nó só move as
+// instâncias que eram dele ou que passam a ser dele.
+type Coordinator struct {
+	client        *storage_redis.Client
+	sessions      SessionController
+	listInstances InstanceLister
+	nodeID        string
+	advertiseURL  string
+	leaseTTL      time.Duration
+	log           *zap.Logger
+
+	mu     sync.RWMutex
+	leases map[string]*storage_redis.Lock
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func NewCoordinator(client *storage_redis.Client, sessions SessionController, listInstances InstanceLister, opts Options, log *zap.Logger) (*Coordinator, error) {
+	if client == nil {
+		return nil, errors.New("redis é obrigatório para coordenar instâncias")
+	}
+	if opts.NodeID == "" {
+		return nil, errors.New("ID do nó não definido")
+	}
+	if opts.AdvertiseURL == "" {
+		return nil, errors.New("URL anunciada do nó não definida")
+	}
+	if opts.LeaseTTL <= 0 {
+		opts.LeaseTTL = 30 * time.Second
+	}
+
+	return &Coordinator{
+		client:        client,
+		sessions:      sessions,
+		listInstances: listInstances,
+		nodeID:        opts.NodeID,
+		advertiseURL:  strings.TrimRight(opts.AdvertiseURL, "/"),
+		leaseTTL:      opts.LeaseTTL,
+		log:           log,
+		leases:        make(map[string]*storage_redis.Lock),
+	}, nil
+}
+
+func (c *Coordinator) NodeID() string {
+	return c.nodeID
+}
+
+// Start registra o nó, assume as instâncias que lhe cabem e mantém os leases
+// renovados em background.
+func (c *Coordinator) Start(ctx context.Context) {
+	ctx, c.cancel = context.WithCancel(ctx)
+
+	c.log.Info("cluster: iniciando coordenação de instâncias",
+		zap.String("node_id", c.nodeID),
+		zap.String("advertise_url", c.advertiseURL),
+		zap.Duration("lease_ttl", c.leaseTTL),
+	)
+
+	c.tick(ctx)
+
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		ticker := time.NewTicker(c.leaseTTL / 3)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				c.tick(ctx)
+			}
+		}
+	}()
+}
+
+// Stop interrompe a renovação, libera as sessões e os leases do nó e remove
+// seu registro, para que os demais nós assumam as instâncias sem esperar o TTL.
+func (c *Coordinator) Stop(ctx context.Context) {
+	if c.cancel != nil {
+		c.cancel()
+	}
+	c.wg.Wait()
+
+	c.mu.Lock()
+	leases := c.leases
+	c.leases = make(map[string]*storage_redis.Lock)
+	c.mu.Unlock()
+
+	for instanceID, lease := range leases {
+		c.sessions.ReleaseSession(instanceID)
+		if err := lease.Release(ctx); err != nil {
+			c.log.Warn("cluster: erro ao liberar lease",
+				zap.String("instance_id", instanceID),
+				zap.Error(err),
+			)
+		}
+	}
+
+	if err := c.client.RDB().Del(ctx, nodeKeyPrefix+c.nodeID).Err(); err != nil {
+		c.log.Warn("cluster: erro ao remover registro do nó", zap.Error(err))
+	}
+
+	c.log.Info("cluster: nó saiu do cluster",
+		zap.String("node_id", c.nodeID),
+		zap.Int("leases_released", len(leases)),
+	)
+}
+
+// Owns informa se o nó local detém o lease da instância.
+func (c *Coordinator) Owns(instanceID string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	_, ok := c.leases[instanceID]
+	return ok
+}
+
+// Owner retorna o nó que detém o lease da instância. Retorna um Node vazio
+// quando a instância ainda não tem dono.
+func (c *Coordinator) Owner(ctx context.Context, instanceID string) (Node, error) {
+	if c.Owns(instanceID) {
+		return Node{ID: c.nodeID, AdvertiseURL: c.advertiseURL}, nil
+	}
+
+	holder, err := c.leaseLock(instanceID).Holder(ctx)
+	if err != nil {
+		return Node{}, err
+	}
+	if holder == "" {
+		return Node{}, nil
+	}
+
+	addr, err := c.client.RDB().Get(ctx, nodeKeyPrefix+holder).Result()
+	if err != nil {
+		return Node{}, fmt.Errorf("cluster: nó %s sem endereço registrado: %w", holder, err)
+	}
+	return Node{ID: holder, AdvertiseURL: addr}, nil
+}
+
+// Claim assume a instância para o nó local quando ela ainda não tem dono,
+// como no caso de uma instância recém-criada. Retorna true se o nó local é o
+// dono ao final da chamada.
+func (c *Coordinator) Claim(ctx context.Context, instanceID string) (bool, error) {
+	if c.Owns(instanceID) {
+		return true, nil
+	}
+
+	lease := c.leaseLock(instanceID)
+	acquired, err := lease.Acquire(ctx)
+	if err != nil || !acquired {
+		return false, err
+	}
+
+	c.mu.Lock()
+	c.leases[instanceID] = lease
+	c.mu.Unlock()
+
+	c.log.Info("cluster: instância sem dono assumida sob demanda",
+		zap.String("instance_id", instanceID),
+		zap.String("node_id", c.nodeID),
+	)
+	return true, nil
+}
+
+// Nodes lista os nós vivos, isto é, com registro ainda dentro do TTL.
+func (c *Coordinator) Nodes(ctx context.Context) ([]Node, error) {
+	rdb := c.client.RDB()
+
+	var nodes []Node
+	iter := rdb.Scan(ctx, 0, nodeKeyPrefix+"*", 100).Iterator()
+	for iter.Next(ctx) {
+		key := iter.Val()
+		addr, err := rdb.Get(ctx, key).Result()
+		if err != nil {
+			continue
+		}
+		nodes = append(nodes, Node{ID: strings.TrimPrefix(key, nodeKeyPrefix), AdvertiseURL: addr})
+	}
+	if err := iter.Err(); err != nil {
+		return nil, fmt.Errorf("cluster: listar nós: %w", err)
+	}
+	return nodes, nil
+}
+
+func (c *Coordinator) tick(ctx context.Context) {
+	if err := c.client.RDB().Set(ctx, nodeKeyPrefix+c.nodeID, c.advertiseURL, c.leaseTTL).Err(); err != nil {
+		c.log.Error("cluster: erro ao registrar heartbeat do nó", zap.Error(err))
+		return
+	}
+
+	nodes, err := c.Nodes(ctx)
+	if err != nil {
+		c.log.Error("cluster: erro ao listar nós", zap.Error(err))
+		return
+	}
+	nodeIDs := make([]string, 0, len(nodes)+1)
+	nodeIDs = append(nodeIDs, c.nodeID)
+	for _, n := range nodes {
+		if n.ID != c.nodeID {
+			nodeIDs = append(nodeIDs, n.ID)
+		}
+	}
+
+	instanceIDs, err := c.listInstances(ctx)
+	if err != nil {
+		c.log.Error("cluster: erro ao listar instâncias", zap.Error(err))
+		c.refreshAll(ctx)
+		return
+	}
+
+	known := make(map[string]bool, len(instanceIDs))
+	for _, instanceID := range instanceIDs {
+		known[instanceID] = true
+		c.reconcile(ctx, instanceID, desiredOwner(nodeIDs, instanceID))
+	}
+
+	// Instâncias removidas não precisam mais de lease
+	c.mu.RLock()
+	var stale []string
+	for instanceID := range c.leases {
+		if !known[instanceID] {
+			stale = append(stale, instanceID)
+		}
+	}
+	c.mu.RUnlock()
+	for _, instanceID := range stale {
+		c.release(ctx, instanceID, "instância removida")
+	}
+}
+
+func (c *Coordinator) reconcile(ctx context.Context, instanceID, desired string) {
+	c.mu.RLock()
+	lease, held := c.leases[instanceID]
+	c.mu.RUnlock()
+
+	if held {
+		if !c.refresh(ctx, instanceID, lease) {
+			return
+		}
+		// Não interrompe um login em andamento; a entrega acontece no
+		// próximo ciclo após o pareamento.
+		if desired != c.nodeID && !c.sessions.HasPendingLogin(instanceID) {
+			c.release(ctx, instanceID, "rebalanceamento para "+desired)
+		}
+		return
+	}
+
+	if desired != c.nodeID {
+		return
+	}
+
+	lease = c.leaseLock(instanceID)
+	acquired, err := lease.Acquire(ctx)
+	if err != nil {
+		c.log.Warn("cluster: erro ao adquirir lease",
+			zap.String("instance_id", instanceID),
+			zap.Error(err),
+		)
+		return
+	}
+	if !acquired {
+		// Ainda pertence a outro nó, que vai liberá-la no próximo ciclo
+		// dele ou deixar o lease expirar se tiver caído.
+		return
+	}
+
+	c.mu.Lock()
+	c.leases[instanceID] = lease
+	c.mu.Unlock()
+
+	c.log.Info("cluster: instância assumida",
+		zap.String("instance_id", instanceID),
+		zap.String("node_id", c.nodeID),
+	)
+	c.sessions.ActivateSession(ctx, instanceID)
+}
+
+// refresh renova o lease e retorna false se o nó o perdeu.
+func (c *Coordinator) refresh(ctx context.Context, instanceID string, lease *storage_redis.Lock) bool {
+	ok, err := lease.Refresh(ctx)
+	if err != nil {
+		c.log.Warn("cluster: erro ao renovar lease",
+			zap.String("instance_id", instanceID),
+			zap.Error(err),
+		)
+		return true
+	}
+	if ok {
+		return true
+	}
+
+	// O lease expirou (ex.: pausa longa do processo). Se ninguém o assumiu,
+	// basta readquiri-lo; caso contrário a sessão local precisa ser largada.
+	if acquired, err := lease.Acquire(ctx); err == nil && acquired {
+		return true
+	}
+
+	c.mu.Lock()
+	delete(c.leases, instanceID)
+	c.mu.Unlock()
+
+	c.log.Warn("cluster: lease perdido para outro nó, liberando sessão local",
+		zap.String("instance_id", instanceID),
+	)
+	c.sessions.ReleaseSession(instanceID)
+	return false
+}
+
+func (c *Coordinator) refreshAll(ctx context.Context) {
+	c.mu.RLock()
+	leases := make(map[string]*storage_redis.Lock, len(c.leases))
+	for instanceID, lease := range c.leases {
+		leases[instanceID] = lease
+	}
+	c.mu.RUnlock()
+
+	for instanceID, lease := range leases {
+		c.refresh(ctx, instanceID, lease)
+	}
+}
+
+// release desconecta a sessão local antes de liberar o lease, para que o
+// próximo dono nunca conecte enquanto este nó ainda está conectado.
+func (c *Coordinator) release(ctx context.Context, instanceID, reason string) {
+	c.mu.Lock()
+	lease, held := c.leases[instanceID]
+	delete(c.leases, instanceID)
+	c.mu.Unlock()
+	if !held {
+		return
+	}
+
+	c.sessions.ReleaseSession(instanceID)
+	if err := lease.Release(ctx); err != nil {
+		c.log.Warn("cluster: erro ao liberar lease",
+			zap.String("instance_id", instanceID),
+			zap.Error(err),
+		)
+	}
+
+	c.log.Info("cluster: instância liberada",
+		zap.String("instance_id", instanceID),
+		zap.String("reason", reason),
+	)
+}
+
+func (c *Coordinator) leaseLock(instanceID string) *storage_redis.Lock {
+	return storage_redis.NewOwnedLock(c.client, leaseKeyPrefix+instanceID, c.nodeID, c.leaseTTL)
+}
+
+// desiredOwner escolhe o nó de maior peso para a instância (rendezvous
+// hashing). Todos os nós chegam ao mesmo resultado sem coordenação.
+func desiredOwner(nodeIDs []string, instanceID string) string {
+	var best string
+	var bestScore uint64
+	for _, nodeID := range nodeIDs {
+		h := fnv.New64a()
+		h.Write([]byte(nodeID))
+		h.Write([]byte{0})
+		h.Write([]byte(instanceID))
+		score := h.Sum64()
+		if best == "" || score > bestScore || (score == bestScore && nodeID < best) {
+			best, bestScore = nodeID, score
+		}
+	}
+	return best
+}
@@ -19,19 +19,57 @@ type Config struct {
 	WhatsApp    WhatsAppConfig
 	Webhook     WebhookConfig
 	Dashboard   DashboardConfig
+	Cluster     ClusterConfig
+	Alert       AlertConfig
+	CloudAPI    CloudAPIConfig
+	Sandbox     SandboxConfig
 }
 
 type StorageConfig struct {
-	Driver          string `env:"DB_DRIVER" envDefault:"sqlite"`
-	DataDir         string `env:"DATA_DIR" envDefault:"/app/data"`
-	MediaTTLSeconds int    `env:"MEDIA_TTL_SECONDS" envDefault:"7200"`
+	Driver             string `env:"DB_DRIVER" envDefault:"sqlite"`
+	DataDir            string `env:"DATA_DIR" envDefault:"/app/data"`
+	MediaTTLSeconds    int    `env:"MEDIA_TTL_SECONDS" envDefault:"7200"`
+	MediaDriver        string `env:"MEDIA_STORAGE_DRIVER" envDefault:"filesystem"`
+	MediaURLSecret     string `env:"MEDIA_URL_SECRET"`
+	MediaURLTTLSeconds int    `env:"MEDIA_URL_TTL_SECONDS" envDefault:"7200"`
+	MediaPublicAccess  bool   `env:"MEDIA_PUBLIC_ACCESS" envDefault:"false"`
+	// MediaEagerMaxSizeMB limita o download antecipado das instâncias com
+	// media_download=eager; mídias maiores ficam para o primeiro acesso.
+	MediaEagerMaxSizeMB int `env:"MEDIA_EAGER_MAX_SIZE_MB" envDefault:"16"`
+	MediaS3             MediaS3Config
+	MediaFetch          MediaFetchConfig
+}
+
+// MediaFetchConfig limita o download das mídias informadas por URL nos
+// envios. Endereços internos são recusados, a menos que AllowPrivate esteja
+// ligado.
+type MediaFetchConfig struct {
+	TimeoutSeconds        int  `env:"MEDIA_FETCH_TIMEOUT_SECONDS" envDefault:"30"`
+	ConnectTimeoutSeconds int  `env:"MEDIA_FETCH_CONNECT_TIMEOUT_SECONDS" envDefault:"10"`
+	MaxRedirects          int  `env:"MEDIA_FETCH_MAX_REDIRECTS" envDefault:"3"`
+	AllowPrivate          bool `env:"MEDIA_FETCH_ALLOW_PRIVATE" envDefault:"false"`
+}
+
+// MediaS3Config configura o armazenamento de mídia em um bucket compatível
+// com S3 (AWS S3, MinIO, R2), usado com MEDIA_STORAGE_DRIVER=s3.
+type MediaS3Config struct {
+	Endpoint   string `env:"MEDIA_S3_ENDPOINT"`
+	Region     string `env:"MEDIA_S3_REGION" envDefault:"us-east-1"`
+	Bucket     string `env:"MEDIA_S3_BUCKET"`
+	AccessKey  string `env:"MEDIA_S3_ACCESS_KEY"`
+	SecretKey  string `env:"MEDIA_S3_SECRET_KEY"`
+	Prefix     string `env:"MEDIA_S3_PREFIX" envDefault:"media/"`
+	PathStyle  bool   `env:"MEDIA_S3_PATH_STYLE" envDefault:"true"`
+	PartSizeMB int    `env:"MEDIA_S3_PART_SIZE_MB" envDefault:"8"`
+	Lifecycle  bool   `env:"MEDIA_S3_LIFECYCLE" envDefault:"false"`
 }
 
 type AppConfig struct {
-	Env           string `env:"APP_ENV" envDefault:"development"`
-	Port          string `env:"PORT" envDefault:"8080"`
-	BaseURL       string `env:"APP_BASE_URL" envDefault:"http://localhost:8080"`
-	OutboxWorkers int    `env:"OUTBOX_WORKERS" envDefault:"5"`
+	Env                 string `env:"APP_ENV" envDefault:"development"`
+	Port                string `env:"PORT" envDefault:"8080"`
+	BaseURL             string `env:"APP_BASE_URL" envDefault:"http://localhost:8080"`
+	OutboxWorkers       int    `env:"OUTBOX_WORKERS" envDefault:"5"`
+	DrainTimeoutSeconds int    `env:"DRAIN_TIMEOUT_SECONDS" envDefault:"20"`
 }
 
 type DatabaseConfig struct {
@@ -85,8 +123,22 @@ type LogConfig struct {
 	Level string `env:"LOG_LEVEL" envDefault:"debug"`
 }
 
+// DefaultSessionKey é o valor padrão de WHATSAPP_SESSION_KEY_ENC, recusado
+// quando APP_ENV=production.
+const DefaultSessionKey = "apime-session-key-change-in-production"
+
+// WhatsAppConfig configura as sessões do WhatsApp. SessionKeysPrevious lista
+// chaves antigas, usadas apenas para decifrar dados ainda não recifrados com
+// SessionKeyEnc.
 type WhatsAppConfig struct {
-	SessionKeyEnc string `env:"WHATSAPP_SESSION_KEY_ENC" envDefault:"apime-session-key-change-in-production"`
+	SessionKeyEnc             string   `env:"WHATSAPP_SESSION_KEY_ENC" envDefault:"apime-session-key-change-in-production"`
+	SessionKeysPrevious       []string `env:"WHATSAPP_SESSION_KEYS_PREVIOUS" envSeparator:","`
+	SessionKeyRotateOnStart   bool     `env:"WHATSAPP_SESSION_KEY_ROTATE_ON_START" envDefault:"true"`
+	ReconnectBaseDelaySeconds int      `env:"WHATSAPP_RECONNECT_BASE_DELAY_SECONDS" envDefault:"2"`
+	ReconnectMaxDelaySeconds  int      `env:"WHATSAPP_RECONNECT_MAX_DELAY_SECONDS" envDefault:"300"`
+	ReconnectMaxAttempts      int      `env:"WHATSAPP_RECONNECT_MAX_ATTEMPTS" envDefault:"10"`
+	RestoreConcurrency        int      `env:"WHATSAPP_RESTORE_CONCURRENCY" envDefault:"5"`
+	RestoreJitterMS           int      `env:"WHATSAPP_RESTORE_JITTER_MS" envDefault:"1500"`
 }
 
 type WebhookConfig struct {
@@ -98,6 +150,53 @@ type DashboardConfig struct {
 	Timezone string `env:"DASHBOARD_TIMEZONE" envDefault:""`
 }
 
+// ClusterConfig controla a divisão das instâncias entre réplicas da API.
+// Requer Redis habilitado.
+type ClusterConfig struct {
+	Enabled         bool   `env:"CLUSTER_ENABLED" envDefault:"false"`
+	NodeID          string `env:"CLUSTER_NODE_ID" envDefault:""`
+	AdvertiseURL    string `env:"CLUSTER_ADVERTISE_URL" envDefault:""`
+	LeaseTTLSeconds int    `env:"CLUSTER_LEASE_TTL_SECONDS" envDefault:"30"`
+	// Secret assina as requisições encaminhadas entre os nós. Obrigatório com
+	// o cluster habilitado, igual em todos os nós e diferente do JWT_SECRET.
+	Secret string `env:"CLUSTER_SECRET"`
+}
+
+// AlertConfig controla a avaliação das regras de alerta e o envio por e-mail.
+type AlertConfig struct {
+	Enabled                bool   `env:"ALERTS_ENABLED" envDefault:"true"`
+	CheckIntervalSeconds   int    `env:"ALERTS_CHECK_INTERVAL_SECONDS" envDefault:"30"`
+	DefaultCooldownSeconds int    `env:"ALERTS_DEFAULT_COOLDOWN_SECONDS" envDefault:"900"`
+	SMTPHost               string `env:"SMTP_HOST" envDefault:""`
+	SMTPPort               int    `env:"SMTP_PORT" envDefault:"587"`
+	SMTPUsername           string `env:"SMTP_USERNAME" envDefault:""`
+	SMTPPassword           string `env:"SMTP_PASSWORD" envDefault:""`
+	SMTPFrom               string `env:"SMTP_FROM" envDefault:""`
+}
+
+// CloudAPIConfig aponta para a Graph API usada pelas instâncias com provedor
+// cloud_api.
+type CloudAPIConfig struct {
+	Enabled        bool   `env:"CLOUD_API_ENABLED" envDefault:"true"`
+	BaseURL        string `env:"CLOUD_API_BASE_URL" envDefault:"https://graph.facebook.com"`
+	Version        string `env:"CLOUD_API_VERSION" envDefault:"v21.0"`
+	TimeoutSeconds int    `env:"CLOUD_API_TIMEOUT_SECONDS" envDefault:"30"`
+}
+
+// SandboxConfig habilita o provedor sandbox e define o atraso padrão das
+// confirmações simuladas. Atrasos negativos desativam a confirmação.
+type SandboxConfig struct {
+	Enabled          bool `env:"SANDBOX_ENABLED" envDefault:"false"`
+	DeliveredDelayMS int  `env:"SANDBOX_DELIVERED_DELAY_MS" envDefault:"1000"`
+	ReadDelayMS      int  `env:"SANDBOX_READ_DELAY_MS" envDefault:"3000"`
+}
+
+// UsesDefaultSessionKey informa se a chave de sessão é um valor de exemplo
+// conhecido, seja o padrão do código ou o do .env.example.
+func (c WhatsAppConfig) UsesDefaultSessionKey() bool {
+	return c.SessionKeyEnc == DefaultSessionKey || c.SessionKeyEnc == "change-me"
+}
+
 // Load carrega as configurações da aplicação.
 func Load() Config {
 	cfg := Config{}
@@ -5,6 +5,7 @@ import (
 	"embed"
 	"encoding/base64"
 	"errors"
+	"fmt"
 	"html/template"
 	"io/fs"
 	"net/http"
@@ -19,6 +20,7 @@ import (
 	"go.uber.org/zap"
 
 	"github.com/open-apime/apime/internal/api/middleware"
+	"github.com/open-apime/apime/internal/cluster"
 	"github.com/open-apime/apime/internal/config"
 	"github.com/open-apime/apime/internal/service/api_token"
 	"github.com/open-apime/apime/internal/service/auth"
@@ -51,6 +53,9 @@ type Options struct {
 	Logger              *zap.Logger
 	EnableDashboard     bool
 	Timezone            string
+	Cluster             *cluster.Coordinator
+	// ClusterSecret assina os encaminhamentos ao nó dono da instância.
+	ClusterSecret string
 }
 
 type Handler struct {
@@ -160,6 +165,11 @@ func Register(router *gin.Engine, opts Options) error {
 
 	group := router.Group("/dashboard")
 	group.Use(middleware.DashboardAuth(opts.JWTSecret, h.users))
+	group.Use(middleware.ForwardToOwner(middleware.ForwardOption{
+		Coordinator: opts.Cluster,
+		Secret:      opts.ClusterSecret,
+		Logger:      opts.Logger,
+	}))
 
 	group.GET("", h.overview)
 	group.GET("/instances", func(c *gin.Context) {
@@ -171,6 +181,9 @@ func Register(router *gin.Engine, opts Options) error {
 	group.GET("/instances/:id/qr", h.showInstanceQR)
 	group.GET("/instances/:id/qr/status", h.getInstanceQRStatus)
 	group.GET("/instances/:id/qr/image", h.getQRImage)
+	group.GET("/instances/:id/pair-code", h.showInstancePairCode)
+	group.POST("/instances/:id/pair-code", h.requestInstancePairCode)
+	group.GET("/instances/:id/pair-code/status", h.getInstancePairCodeStatus)
 	group.POST("/instances/:id/disconnect", h.disconnectInstance)
 	group.GET("/instances/:id/diagnostics", h.instanceDiagnostics)
 	group.POST("/instances/:id/delete", h.deleteInstance)
@@ -272,6 +285,24 @@ func templateFuncMap() template.FuncMap {
 			}
 			return valA / valB
 		},
+		"formatBytes": func(n int64) string {
+			const unit = 1024
+			if n < unit {
+				return fmt.Sprintf("%d B", n)
+			}
+			div, exp := int64(unit), 0
+			for m := n / unit; m >= unit; m /= unit {
+				div *= unit
+				exp++
+			}
+			return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGTPE"[exp])
+		},
+		"formatDuration": func(seconds int64) string {
+			if seconds <= 0 {
+				return "—"
+			}
+			return (time.Duration(seconds) * time.Second).String()
+		},
 	}
 }
 
@@ -519,6 +550,86 @@ func (h *Handler) getInstanceQRStatus(c *gin.Context) {
 	})
 }
 
+func (h *Handler) showInstancePairCode(c *gin.Context) {
+	id := c.Param("id")
+	userID := c.GetString("userID")
+	userRole := c.GetString("userRole")
+
+	if _, err := h.instances.GetByUser(c.Request.Context(), id, userID, userRole); err != nil {
+		redirectWithMessage(c, "/dashboard", "error", "Instância não encontrada.")
+		return
+	}
+
+	data := map[string]any{
+		"InstanceID": id,
+	}
+	if pairCode, ok := h.instances.CurrentPairCode(id); ok {
+		data["PairCode"] = pairCode
+	}
+	page := h.pageData(c, "", "instance_pair_code_content", data)
+	c.HTML(http.StatusOK, "layout", page)
+}
+
+func (h *Handler) requestInstancePairCode(c *gin.Context) {
+	id := c.Param("id")
+	userID := c.GetString("userID")
+	userRole := c.GetString("userRole")
+	phone := strings.TrimSpace(c.PostForm("phone"))
+
+	if phone == "" {
+		redirectWithMessage(c, "/dashboard/instances/"+id+"/pair-code", "error", "Informe o número de telefone.")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 60*time.Second)
+	defer cancel()
+
+	pairCode, err := h.instances.GetPairCodeByUser(ctx, id, phone, userID, userRole)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			h.logger.Warn("timeout ao gerar código de pareamento", zap.String("instance_id", id))
+			redirectWithMessage(c, "/dashboard/instances/"+id+"/pair-code", "error", "Timeout ao gerar código de pareamento.")
+			return
+		}
+
+		h.logger.Warn("erro ao gerar código de pareamento", zap.String("instance_id", id), zap.Error(err))
+		redirectWithMessage(c, "/dashboard/instances/"+id+"/pair-code", "error", "Falha ao gerar código de pareamento: "+err.Error())
+		return
+	}
+
+	data := map[string]any{
+		"InstanceID": id,
+		"PairCode":   pairCode,
+	}
+	page := h.pageData(c, "", "instance_pair_code_content", data)
+	c.HTML(http.StatusOK, "layout", page)
+}
+
+func (h *Handler) getInstancePairCodeStatus(c *gin.Context) {
+	id := c.Param("id")
+
+	userID := c.GetString("userID")
+	userRole := c.GetString("userRole")
+
+	instance, err := h.instances.GetByUser(c.Request.Context(), id, userID, userRole)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "instância não encontrada"})
+		return
+	}
+
+	pairCode, hasCode := h.instances.CurrentPairCode(id)
+	result := gin.H{
+		"status":    instance.Status,
+		"hasCode":   hasCode,
+		"connected": instance.Status == model.InstanceStatusActive,
+	}
+	if hasCode {
+		result["code"] = pairCode.Code
+		result["expiresAt"] = pairCode.ExpiresAt
+	}
+	c.JSON(http.StatusOK, result)
+}
+
 func (h *Handler) instanceDiagnostics(c *gin.Context) {
 	instanceID := c.Param("id")
 	ctx := c.Request.Context()
@@ -537,9 +648,17 @@ func (h *Handler) instanceDiagnostics(c *gin.Context) {
 		diagnostics = h.sessionManager.GetDiagnostics(instanceID)
 	}
 
+	var mediaUsage *model.MediaUsage
+	if usage, err := h.instances.GetMediaUsage(ctx, instanceID); err == nil {
+		mediaUsage = &usage
+	} else {
+		h.logger.Warn("erro ao obter uso de mídia", zap.String("instance_id", instanceID), zap.Error(err))
+	}
+
 	data := map[string]any{
 		"Instance":    inst,
 		"Diagnostics": diagnostics,
+		"MediaUsage":  mediaUsage,
 	}
 
 	page := h.pageData(c, "", "instance_diagnostics_content", data)
@@ -0,0 +1,39 @@
+package audio
+
+import (
+	"errors"
+	"time"
+)
+
+var adtsSampleRates = [...]int{96000, 88200, 64000, 48000, 44100, 32000, 24000, 22050, 16000, 12000, 11025, 8000, 7350}
+
+// parseADTS percorre os quadros ADTS de um AAC puro: cada bloco tem 1024
+// amostras e o nível é o tamanho do quadro.
+func parseADTS(data []byte) (time.Duration, []float64, error) {
+	off := id3Size(data)
+	var (
+		seconds float64
+		levels  []float64
+	)
+	for off+7 <= len(data) {
+		h := data[off:]
+		if h[0] != 0xff || h[1]&0xf6 != 0xf0 {
+			off++
+			continue
+		}
+		rateIdx := int(h[2]>>2) & 0x0f
+		length := int(h[3]&0x03)<<11 | int(h[4])<<3 | int(h[5]>>5)
+		blocks := int(h[6]&0x03) + 1
+		if rateIdx >= len(adtsSampleRates) || length < 7 {
+			off++
+			continue
+		}
+		seconds += float64(1024*blocks) / float64(adtsSampleRates[rateIdx])
+		levels = append(levels, float64(length)/float64(blocks))
+		off += length
+	}
+	if len(levels) == 0 {
+		return 0, nil, errors.New("aac sem quadros")
+	}
+	return time.Duration(seconds * float64(time.Second)), levels, nil
+}
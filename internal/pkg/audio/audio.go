@@ -0,0 +1,101 @@
+// Package audio calcula a duração e a forma de onda de arquivos de áudio
+// (OGG/Opus, MP3, M4A e AAC), sem decodificar o som e sem dependências fora
+// da biblioteca padrão.
+//
+// A forma de onda usa, de cada quadro, uma medida que acompanha o volume: o
+// tamanho dos pacotes Opus e AAC, codificados com taxa variável, e o ganho
+// global dos quadros MP3.
+package audio
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"time"
+)
+
+// WaveformSize é o número de amostras da forma de onda das mensagens de voz.
+const WaveformSize = 64
+
+// maxDuration é a maior duração aceita. Valores acima vêm de cabeçalhos
+// corrompidos e não devem substituir a duração informada pelo cliente.
+const maxDuration = 24 * time.Hour
+
+var ErrUnsupported = errors.New("formato de áudio não suportado")
+
+// Info é o resultado da análise do áudio.
+type Info struct {
+	Duration time.Duration
+	// Waveform tem WaveformSize valores de 0 a 100, como nas mensagens de
+	// voz do WhatsApp.
+	Waveform []byte
+}
+
+// Seconds retorna a duração arredondada, com no mínimo 1 segundo.
+func (i Info) Seconds() int {
+	return max(1, int(math.Round(i.Duration.Seconds())))
+}
+
+// Analyze calcula a duração e a forma de onda do áudio, identificado pelo
+// mimetype.
+func Analyze(data []byte, mimetype string) (Info, error) {
+	base, _, _ := strings.Cut(mimetype, ";")
+	var (
+		duration time.Duration
+		levels   []float64
+		err      error
+	)
+	switch strings.TrimSpace(strings.ToLower(base)) {
+	case "audio/ogg", "audio/opus":
+		duration, levels, err = parseOgg(data)
+	case "audio/mpeg", "audio/mp3":
+		duration, levels, err = parseMP3(data)
+	case "audio/mp4", "audio/x-m4a", "audio/m4a":
+		duration, levels, err = parseMP4(data)
+	case "audio/aac":
+		duration, levels, err = parseADTS(data)
+	default:
+		return Info{}, ErrUnsupported
+	}
+	if err != nil {
+		return Info{}, err
+	}
+	if duration <= 0 || len(levels) == 0 {
+		return Info{}, errors.New("áudio sem quadros")
+	}
+	if duration > maxDuration {
+		return Info{}, errors.New("duração do áudio inválida")
+	}
+	return Info{Duration: duration, Waveform: waveform(levels)}, nil
+}
+
+// waveform agrupa os níveis dos quadros em WaveformSize faixas e normaliza
+// para 0-100, do quadro mais baixo ao mais alto.
+func waveform(levels []float64) []byte {
+	bins := make([]float64, WaveformSize)
+	for i := range bins {
+		start := i * len(levels) / WaveformSize
+		end := max((i+1)*len(levels)/WaveformSize, start+1)
+		end = min(end, len(levels))
+		start = min(start, end-1)
+		var sum float64
+		for _, v := range levels[start:end] {
+			sum += v
+		}
+		bins[i] = sum / float64(end-start)
+	}
+
+	low, high := bins[0], bins[0]
+	for _, v := range bins {
+		low = min(low, v)
+		high = max(high, v)
+	}
+	out := make([]byte, WaveformSize)
+	if high == low {
+		return out
+	}
+	for i, v := range bins {
+		out[i] = byte(math.Round((v - low) / (high - low) * 100))
+	}
+	return out
+}
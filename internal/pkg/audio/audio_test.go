@@ -0,0 +1,278 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+)
+
+// bitWriter escreve campos de bits em ordem big-endian, o inverso de
+// bitReader.
+type bitWriter struct {
+	data []byte
+	pos  int
+}
+
+func (w *bitWriter) write(n int, v int) {
+	for i := n - 1; i >= 0; i-- {
+		if w.pos/8 >= len(w.data) {
+			w.data = append(w.data, 0)
+		}
+		if v>>i&1 == 1 {
+			w.data[w.pos/8] |= 1 << (7 - w.pos%8)
+		}
+		w.pos++
+	}
+}
+
+// oggPage monta uma página Ogg com os pacotes inteiros. O CRC não é
+// conferido pelo parser e fica zerado.
+func oggPage(serial uint32, granule int64, packets ...[]byte) []byte {
+	var table, body []byte
+	for _, p := range packets {
+		n := len(p)
+		for n >= 255 {
+			table = append(table, 255)
+			n -= 255
+		}
+		table = append(table, byte(n))
+		body = append(body, p...)
+	}
+	page := make([]byte, 27)
+	copy(page, "OggS")
+	binary.LittleEndian.PutUint64(page[6:], uint64(granule))
+	binary.LittleEndian.PutUint32(page[14:], serial)
+	page[26] = byte(len(table))
+	page = append(page, table...)
+	return append(page, body...)
+}
+
+// opusFile gera um OGG/Opus com pacotes de 20 ms cujo tamanho cresce ao
+// longo do arquivo.
+func opusFile(packets int) []byte {
+	const preSkip = 312
+	head := make([]byte, 19)
+	copy(head, "OpusHead")
+	head[8] = 1
+	head[9] = 1
+	binary.LittleEndian.PutUint16(head[10:], preSkip)
+	binary.LittleEndian.PutUint32(head[12:], 48000)
+
+	out := oggPage(1, 0, head)
+	out = append(out, oggPage(1, 0, []byte("OpusTags\x00\x00\x00\x00\x00\x00\x00\x00"))...)
+	for i := 0; i < packets; i += 10 {
+		var page [][]byte
+		for j := i; j < min(i+10, packets); j++ {
+			page = append(page, bytes.Repeat([]byte{0x55}, 20+j))
+		}
+		out = append(out, oggPage(1, int64(min(i+10, packets)*960+preSkip), page...)...)
+	}
+	return out
+}
+
+// mp3File gera um MP3 (MPEG-1 layer III, 128 kbps, 44,1 kHz, estéreo) com um
+// quadro Info no início e o ganho global crescendo ao longo dos quadros.
+func mp3File(frames int) []byte {
+	const frameLen = 417 // 144 * 128000 / 44100
+	header := []byte{0xff, 0xfb, 0x90, 0x00}
+
+	info := make([]byte, frameLen)
+	copy(info, header)
+	copy(info[36:], "Info")
+	out := append([]byte{}, info...)
+
+	for i := 0; i < frames; i++ {
+		w := bitWriter{data: append([]byte{}, header...), pos: 32}
+		w.write(9+3+8, 0) // main_data_begin, private_bits e scfsi
+		for gr := 0; gr < 2; gr++ {
+			for ch := 0; ch < 2; ch++ {
+				w.write(12, 0)
+				w.write(9, 1)         // big_values
+				w.write(8, 100+i%100) // global_gain
+				w.write(4+1+22+3, 0)
+			}
+		}
+		frame := make([]byte, frameLen)
+		copy(frame, w.data)
+		out = append(out, frame...)
+	}
+	return out
+}
+
+func mp4Box(kind string, content ...[]byte) []byte {
+	body := bytes.Join(content, nil)
+	box := make([]byte, 8, 8+len(body))
+	binary.BigEndian.PutUint32(box, uint32(8+len(body)))
+	copy(box[4:], kind)
+	return append(box, body...)
+}
+
+// m4aFile gera um M4A com uma trilha de vídeo antes da de áudio, para
+// conferir que a trilha é escolhida pelo hdlr.
+func m4aFile(timescale, duration uint32, samples int) []byte {
+	mdhd := make([]byte, 24)
+	binary.BigEndian.PutUint32(mdhd[12:], timescale)
+	binary.BigEndian.PutUint32(mdhd[16:], duration)
+
+	hdlr := func(kind string) []byte {
+		b := make([]byte, 24)
+		copy(b[8:], kind)
+		return b
+	}
+
+	stsz := make([]byte, 12+4*samples)
+	binary.BigEndian.PutUint32(stsz[8:], uint32(samples))
+	for i := 0; i < samples; i++ {
+		binary.BigEndian.PutUint32(stsz[12+4*i:], uint32(100+i))
+	}
+
+	video := mp4Box("trak", mp4Box("mdia", mp4Box("mdhd", mdhd), mp4Box("hdlr", hdlr("vide"))))
+	sound := mp4Box("trak", mp4Box("mdia",
+		mp4Box("mdhd", mdhd),
+		mp4Box("hdlr", hdlr("soun")),
+		mp4Box("minf", mp4Box("stbl", mp4Box("stsz", stsz))),
+	))
+	return append(mp4Box("ftyp", []byte("M4A \x00\x00\x00\x00")), mp4Box("moov", video, sound)...)
+}
+
+// adtsFile gera um AAC ADTS (44,1 kHz) com quadros que crescem de tamanho.
+func adtsFile(frames int) []byte {
+	var out []byte
+	// Lixo antes do primeiro quadro, como depois de um corte no meio.
+	out = append(out, 0x00, 0x12, 0x34)
+	for i := 0; i < frames; i++ {
+		length := 7 + 50 + i
+		h := []byte{
+			0xff, 0xf1,
+			1<<6 | 4<<2, // perfil LC, 44,1 kHz
+			2<<6 | byte(length>>11)&0x03,
+			byte(length >> 3),
+			byte(length&0x07)<<5 | 0x1f,
+			0xfc,
+		}
+		out = append(out, h...)
+		out = append(out, make([]byte, length-7)...)
+	}
+	return out
+}
+
+func TestAnalyze(t *testing.T) {
+	tests := []struct {
+		name     string
+		mimetype string
+		data     []byte
+		duration time.Duration
+		seconds  int
+	}{
+		{"ogg opus", "audio/ogg; codecs=opus", opusFile(150), 3 * time.Second, 3},
+		{"mp3", "audio/mpeg", mp3File(100), 100 * 1152 * time.Second / 44100, 3},
+		{"m4a", "audio/mp4", m4aFile(44100, 44100*5/2, 108), 2500 * time.Millisecond, 3},
+		{"aac", "audio/aac", adtsFile(86), 86 * 1024 * time.Second / 44100, 2},
+		{"curto arredonda para 1 segundo", "audio/ogg", opusFile(5), 100 * time.Millisecond, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := Analyze(tt.data, tt.mimetype)
+			if err != nil {
+				t.Fatalf("Analyze: %v", err)
+			}
+			if diff := info.Duration - tt.duration; diff < -time.Millisecond || diff > time.Millisecond {
+				t.Errorf("Duration = %v, esperado %v", info.Duration, tt.duration)
+			}
+			if info.Seconds() != tt.seconds {
+				t.Errorf("Seconds = %d, esperado %d", info.Seconds(), tt.seconds)
+			}
+			if len(info.Waveform) != WaveformSize {
+				t.Fatalf("Waveform com %d valores, esperado %d", len(info.Waveform), WaveformSize)
+			}
+			// Os níveis dos arquivos gerados crescem ao longo do áudio.
+			if info.Waveform[0] != 0 || info.Waveform[WaveformSize-1] != 100 {
+				t.Errorf("Waveform = %v, esperado de 0 a 100", info.Waveform)
+			}
+		})
+	}
+}
+
+func TestAnalyzeInvalid(t *testing.T) {
+	// Box com tamanho de 64 bits perto do máximo depois de um box de 8
+	// bytes: a soma com a posição dá a volta e não pode ser aceita.
+	wrap := mp4Box("free")
+	wrap = binary.BigEndian.AppendUint32(wrap, 1)
+	wrap = append(wrap, "moov"...)
+	wrap = binary.BigEndian.AppendUint64(wrap, ^uint64(0)-7)
+	wrap = append(wrap, make([]byte, 16)...)
+
+	truncatedOgg := opusFile(50)
+	truncatedOgg = truncatedOgg[:len(truncatedOgg)-100]
+
+	// Posição final perto do máximo de int64, que estourava a conversão
+	// para time.Duration e virava uma duração positiva qualquer.
+	hugeGranule := opusFile(0)
+	hugeGranule = append(hugeGranule, oggPage(1, 1<<50, bytes.Repeat([]byte{0x55}, 20))...)
+
+	tests := []struct {
+		name     string
+		mimetype string
+		data     []byte
+		wantErr  bool
+	}{
+		{"formato não suportado", "audio/wav", []byte("RIFF"), true},
+		{"ogg vazio", "audio/ogg", nil, true},
+		{"ogg sem opus", "audio/ogg", oggPage(1, 0, []byte("\x01vorbis")), true},
+		{"ogg lixo", "audio/ogg", []byte("OggSxxxxxxxxxxxxxxxxxxxxxxxxxxxxx"), true},
+		{"ogg truncado", "audio/ogg", truncatedOgg, false},
+		{"ogg com posição absurda", "audio/ogg", hugeGranule, true},
+		{"mp3 sem quadros", "audio/mpeg", bytes.Repeat([]byte{0xff}, 1000), true},
+		{"mp3 só com ID3", "audio/mpeg", append([]byte("ID3\x04\x00\x00\x7f\x7f\x7f\x7f"), make([]byte, 20)...), true},
+		{"mp3 truncado", "audio/mpeg", mp3File(10)[:2000], false},
+		{"mp4 sem moov", "audio/mp4", mp4Box("ftyp", []byte("M4A ")), true},
+		{"mp4 com tamanho de 64 bits que dá a volta", "audio/mp4", wrap, true},
+		{"mp4 com box maior que o arquivo", "audio/mp4", m4aFile(44100, 44100, 10)[:100], true},
+		{"mp4 com timescale zero", "audio/mp4", m4aFile(0, 44100, 10), true},
+		{"aac sem quadros", "audio/aac", make([]byte, 100), true},
+		{"aac com tamanho menor que o cabeçalho", "audio/aac", []byte{0xff, 0xf1, 0x50, 0x80, 0x00, 0x1f, 0xfc, 0x00}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Analyze(tt.data, tt.mimetype)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Analyze erro = %v, esperado erro: %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// FuzzAnalyze confere que nenhum dos parsers entra em pânico com entradas
+// arbitrárias e que um resultado sem erro é sempre utilizável.
+func FuzzAnalyze(f *testing.F) {
+	mimetypes := []string{"audio/ogg", "audio/mpeg", "audio/mp4", "audio/aac"}
+
+	wrap := mp4Box("free")
+	wrap = binary.BigEndian.AppendUint32(wrap, 1)
+	wrap = append(wrap, "moov"...)
+	wrap = binary.BigEndian.AppendUint64(wrap, ^uint64(0)-7)
+
+	for _, seed := range [][]byte{opusFile(20), mp3File(20), m4aFile(44100, 44100, 20), adtsFile(20), wrap} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		for _, mimetype := range mimetypes {
+			info, err := Analyze(data, mimetype)
+			if err != nil {
+				continue
+			}
+			if info.Duration <= 0 || len(info.Waveform) != WaveformSize {
+				t.Fatalf("%s: resultado inválido sem erro: %v, %d valores", mimetype, info.Duration, len(info.Waveform))
+			}
+			for _, v := range info.Waveform {
+				if v > 100 {
+					t.Fatalf("%s: valor da forma de onda acima de 100: %d", mimetype, v)
+				}
+			}
+		}
+	})
+}
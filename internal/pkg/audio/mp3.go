@@ -0,0 +1,201 @@
+package audio
+
+import (
+	"bytes"
+	"errors"
+	"time"
+)
+
+var (
+	// mp3Bitrates em kbps, por índice, para MPEG-1 e MPEG-2/2.5 layer III.
+	mp3Bitrates = [2][16]int{
+		{0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 0},
+		{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160, 0},
+	}
+	// mp3SampleRates em Hz, por versão (MPEG-1, 2 e 2.5) e índice.
+	mp3SampleRates = [3][3]int{
+		{44100, 48000, 32000},
+		{22050, 24000, 16000},
+		{11025, 12000, 8000},
+	}
+)
+
+// mp3Frame é o cabeçalho de um quadro MPEG layer III.
+type mp3Frame struct {
+	mpeg1      bool
+	mono       bool
+	crc        bool
+	sampleRate int
+	samples    int
+	length     int
+}
+
+// parseMP3Header interpreta os 4 bytes do cabeçalho, recusando o que não for
+// layer III válido.
+func parseMP3Header(h []byte) (mp3Frame, bool) {
+	if h[0] != 0xff || h[1]&0xe0 != 0xe0 {
+		return mp3Frame{}, false
+	}
+	version := (h[1] >> 3) & 0x03 // 0: 2.5, 2: 2, 3: 1
+	layer := (h[1] >> 1) & 0x03   // 1: layer III
+	bitrateIdx := h[2] >> 4
+	rateIdx := (h[2] >> 2) & 0x03
+	if version == 1 || layer != 1 || bitrateIdx == 0 || bitrateIdx == 15 || rateIdx == 3 {
+		return mp3Frame{}, false
+	}
+
+	f := mp3Frame{
+		mpeg1: version == 3,
+		mono:  h[3]>>6 == 3,
+		crc:   h[1]&0x01 == 0,
+	}
+	table, rates := 1, 1
+	switch version {
+	case 3:
+		table, rates = 0, 0
+	case 0:
+		rates = 2
+	}
+	bitrate := mp3Bitrates[table][bitrateIdx] * 1000
+	f.sampleRate = mp3SampleRates[rates][rateIdx]
+	padding := int(h[2]>>1) & 0x01
+	if f.mpeg1 {
+		f.samples = 1152
+		f.length = 144*bitrate/f.sampleRate + padding
+	} else {
+		f.samples = 576
+		f.length = 72*bitrate/f.sampleRate + padding
+	}
+	return f, true
+}
+
+// parseMP3 percorre os quadros depois da tag ID3v2. A duração soma as
+// amostras dos quadros e o nível de cada quadro é o ganho global médio dos
+// seus grânulos, que cresce com o volume.
+func parseMP3(data []byte) (time.Duration, []float64, error) {
+	off := id3Size(data)
+	var (
+		samples float64
+		levels  []float64
+	)
+	for off+4 <= len(data) {
+		f, ok := parseMP3Header(data[off:])
+		if !ok || f.length < 4 {
+			off++
+			continue
+		}
+		end := min(off+f.length, len(data))
+		frame := data[off:end]
+		off = end
+
+		// O quadro Xing/Info guarda só metadados do arquivo.
+		if head := frame[:min(len(frame), 64)]; len(levels) == 0 && (bytes.Contains(head, []byte("Xing")) || bytes.Contains(head, []byte("Info"))) {
+			continue
+		}
+		samples += float64(f.samples) / float64(f.sampleRate)
+		levels = append(levels, mp3Gain(f, frame))
+	}
+	if len(levels) == 0 {
+		return 0, nil, errors.New("mp3 sem quadros")
+	}
+	return time.Duration(samples * float64(time.Second)), levels, nil
+}
+
+// id3Size retorna o tamanho da tag ID3v2 no início do arquivo.
+func id3Size(data []byte) int {
+	if len(data) < 10 || !bytes.HasPrefix(data, []byte("ID3")) {
+		return 0
+	}
+	size := int(data[6]&0x7f)<<21 | int(data[7]&0x7f)<<14 | int(data[8]&0x7f)<<7 | int(data[9]&0x7f)
+	size += 10
+	if data[5]&0x10 != 0 {
+		size += 10
+	}
+	return min(size, len(data))
+}
+
+// mp3Gain lê o side info do quadro e retorna o ganho global médio dos
+// grânulos com áudio; grânulos sem coeficientes (silêncio) contam zero.
+func mp3Gain(f mp3Frame, frame []byte) float64 {
+	r := bitReader{data: frame, pos: 32}
+	if f.crc {
+		r.pos += 16
+	}
+	channels := 2
+	if f.mono {
+		channels = 1
+	}
+
+	granules := 1
+	if f.mpeg1 {
+		granules = 2
+		r.skip(9)
+		if f.mono {
+			r.skip(5)
+		} else {
+			r.skip(3)
+		}
+		r.skip(4 * channels)
+	} else {
+		r.skip(8)
+		r.skip(channels)
+	}
+
+	var sum float64
+	var n int
+	for gr := 0; gr < granules; gr++ {
+		for ch := 0; ch < channels; ch++ {
+			r.skip(12) // part2_3_length
+			bigValues := r.read(9)
+			gain := r.read(8)
+			if f.mpeg1 {
+				r.skip(4) // scalefac_compress
+			} else {
+				r.skip(9)
+			}
+			r.skip(1 + 22) // window_switching_flag e o bloco que ele seleciona
+			if f.mpeg1 {
+				r.skip(3)
+			} else {
+				r.skip(2)
+			}
+			if r.overflow {
+				return 0
+			}
+			if bigValues > 0 {
+				sum += float64(gain)
+			}
+			n++
+		}
+	}
+	if n == 0 {
+		return 0
+	}
+	return sum / float64(n)
+}
+
+// bitReader lê campos de bits em ordem big-endian.
+type bitReader struct {
+	data     []byte
+	pos      int
+	overflow bool
+}
+
+func (r *bitReader) read(n int) int {
+	var v int
+	for i := 0; i < n; i++ {
+		byteIdx := r.pos / 8
+		if byteIdx >= len(r.data) {
+			r.overflow = true
+			return 0
+		}
+		bit := (r.data[byteIdx] >> (7 - r.pos%8)) & 1
+		v = v<<1 | int(bit)
+		r.pos++
+	}
+	return v
+}
+
+func (r *bitReader) skip(n int) {
+	r.pos += n
+}
@@ -0,0 +1,117 @@
+package audio
+
+import (
+	"encoding/binary"
+	"errors"
+	"time"
+)
+
+// parseMP4 lê a trilha de áudio do M4A: a duração vem do mdhd e os níveis,
+// do tamanho das amostras AAC na tabela stsz.
+func parseMP4(data []byte) (time.Duration, []float64, error) {
+	moov, ok := findBox(data, "moov")
+	if !ok {
+		return 0, nil, errors.New("mp4 sem moov")
+	}
+	for _, trak := range boxes(moov, "trak") {
+		mdia, ok := findBox(trak, "mdia")
+		if !ok {
+			continue
+		}
+		hdlr, ok := findBox(mdia, "hdlr")
+		if !ok || len(hdlr) < 12 || string(hdlr[8:12]) != "soun" {
+			continue
+		}
+
+		mdhd, ok := findBox(mdia, "mdhd")
+		if !ok || len(mdhd) < 24 {
+			continue
+		}
+		var timescale, duration uint64
+		if mdhd[0] == 1 {
+			if len(mdhd) < 36 {
+				continue
+			}
+			timescale = uint64(binary.BigEndian.Uint32(mdhd[20:]))
+			duration = binary.BigEndian.Uint64(mdhd[24:])
+		} else {
+			timescale = uint64(binary.BigEndian.Uint32(mdhd[12:]))
+			duration = uint64(binary.BigEndian.Uint32(mdhd[16:]))
+		}
+		if timescale == 0 {
+			continue
+		}
+
+		var levels []float64
+		if minf, ok := findBox(mdia, "minf"); ok {
+			if stbl, ok := findBox(minf, "stbl"); ok {
+				if stsz, ok := findBox(stbl, "stsz"); ok {
+					levels = sampleSizes(stsz)
+				}
+			}
+		}
+		return time.Duration(float64(duration) / float64(timescale) * float64(time.Second)), levels, nil
+	}
+	return 0, nil, errors.New("mp4 sem trilha de áudio")
+}
+
+// sampleSizes lê a tabela stsz (versão, flags, tamanho fixo, quantidade e
+// os tamanhos).
+func sampleSizes(stsz []byte) []float64 {
+	if len(stsz) < 12 {
+		return nil
+	}
+	fixed := binary.BigEndian.Uint32(stsz[4:])
+	count := int(binary.BigEndian.Uint32(stsz[8:]))
+	if fixed != 0 {
+		levels := make([]float64, min(count, 1<<20))
+		for i := range levels {
+			levels[i] = float64(fixed)
+		}
+		return levels
+	}
+	count = min(count, (len(stsz)-12)/4)
+	levels := make([]float64, count)
+	for i := range levels {
+		levels[i] = float64(binary.BigEndian.Uint32(stsz[12+i*4:]))
+	}
+	return levels
+}
+
+// findBox retorna o conteúdo do primeiro box com o tipo pedido.
+func findBox(data []byte, kind string) ([]byte, bool) {
+	found := boxes(data, kind)
+	if len(found) == 0 {
+		return nil, false
+	}
+	return found[0], true
+}
+
+// boxes retorna o conteúdo dos boxes do nível atual com o tipo pedido.
+func boxes(data []byte, kind string) [][]byte {
+	var found [][]byte
+	for off := 0; off+8 <= len(data); {
+		size := uint64(binary.BigEndian.Uint32(data[off:]))
+		header := uint64(8)
+		switch size {
+		case 0:
+			size = uint64(len(data) - off)
+		case 1:
+			if off+16 > len(data) {
+				return found
+			}
+			size = binary.BigEndian.Uint64(data[off+8:])
+			header = 16
+		}
+		// Compara com o que resta em vez de somar a off: um tamanho de 64 bits
+		// perto do máximo daria a volta na soma.
+		if size < header || size > uint64(len(data)-off) {
+			return found
+		}
+		if string(data[off+4:off+8]) == kind {
+			found = append(found, data[off+int(header):off+int(size)])
+		}
+		off += int(size)
+	}
+	return found
+}
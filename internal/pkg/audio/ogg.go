@@ -0,0 +1,95 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"time"
+)
+
+// opusRate é a taxa das posições (granule) do Opus, qualquer que seja a taxa
+// original do áudio.
+const opusRate = 48000
+
+// maxOpusSamples limita as amostras convertidas em duração: acima disso a
+// multiplicação por time.Second estoura, e Analyze já recusa o resultado.
+const maxOpusSamples = int64(maxDuration/time.Second+1) * opusRate
+
+// parseOgg lê as páginas do primeiro fluxo Opus: a duração vem da última
+// posição menos o pre-skip do OpusHead, e os níveis, do tamanho dos pacotes.
+func parseOgg(data []byte) (time.Duration, []float64, error) {
+	var (
+		serial  uint32
+		started bool
+		packet  []byte
+		packets int
+		preSkip int64
+		granule int64
+		levels  []float64
+		isOpus  bool
+	)
+
+	for off := 0; off+27 <= len(data); {
+		if !bytes.Equal(data[off:off+4], []byte("OggS")) {
+			return 0, nil, errors.New("página ogg inválida")
+		}
+		pageGranule := int64(binary.LittleEndian.Uint64(data[off+6:]))
+		pageSerial := binary.LittleEndian.Uint32(data[off+14:])
+		segments := int(data[off+26])
+		body := off + 27 + segments
+		if body > len(data) {
+			break
+		}
+		table := data[off+27 : body]
+
+		if !started {
+			serial, started = pageSerial, true
+		}
+		if pageSerial != serial {
+			off = body + sumBytes(table)
+			continue
+		}
+
+		pos := body
+		for _, lacing := range table {
+			end := min(pos+int(lacing), len(data))
+			packet = append(packet, data[pos:end]...)
+			pos = end
+			if lacing == 255 {
+				continue
+			}
+			switch packets {
+			case 0:
+				if len(packet) < 19 || !bytes.HasPrefix(packet, []byte("OpusHead")) {
+					return 0, nil, errors.New("fluxo ogg sem opus")
+				}
+				isOpus = true
+				preSkip = int64(binary.LittleEndian.Uint16(packet[10:]))
+			case 1:
+				// OpusTags
+			default:
+				levels = append(levels, float64(len(packet)))
+			}
+			packets++
+			packet = packet[:0]
+		}
+		if pageGranule >= 0 && packets > 2 {
+			granule = pageGranule
+		}
+		off = pos
+	}
+
+	if !isOpus {
+		return 0, nil, errors.New("fluxo ogg sem opus")
+	}
+	samples := min(max(granule-preSkip, 0), maxOpusSamples)
+	return time.Duration(samples) * time.Second / opusRate, levels, nil
+}
+
+func sumBytes(table []byte) int {
+	var n int
+	for _, b := range table {
+		n += int(b)
+	}
+	return n
+}
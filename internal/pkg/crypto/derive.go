@@ -0,0 +1,17 @@
+package crypto
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// DeriveSecret deriva do segredo uma chave própria para o uso informado
+// (HMAC-SHA256 de purpose com secret), para que o mesmo segredo configurado
+// não assine dados de domínios diferentes e o vazamento da chave derivada
+// não revele o segredo de origem.
+func DeriveSecret(secret, purpose string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(purpose))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,189 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// Formato versionado: magic (4 bytes) + ID da chave (4 bytes) + nonce +
+// ciphertext. O cabeçalho entra como dado autenticado do GCM. Ciphertexts sem
+// o cabeçalho são do formato legado de Encrypt.
+var keyringMagic = []byte("AK2\x00")
+
+const keyIDSize = 4
+
+// keySalt é fixo porque a chave é derivada uma única vez por processo; o
+// Argon2id torna cara a busca por força bruta de segredos fracos.
+var keySalt = []byte("apime-session-key-v2")
+
+var ErrUnknownKey = errors.New("crypto: chave do ciphertext não está no keyring")
+
+type keyEntry struct {
+	id     [keyIDSize]byte
+	aead   cipher.AEAD
+	legacy cipher.AEAD
+}
+
+// Keyring cifra com a chave atual e decifra com qualquer chave conhecida,
+// permitindo trocar WHATSAPP_SESSION_KEY_ENC sem perder os dados gravados.
+type Keyring struct {
+	current keyEntry
+	keys    []keyEntry
+}
+
+// NewKeyring deriva as chaves com Argon2id. A primeira é a atual; as demais
+// são aceitas apenas para decifrar.
+func NewKeyring(current string, previous ...string) (*Keyring, error) {
+	if current == "" {
+		return nil, errors.New("crypto: chave atual vazia")
+	}
+
+	k := &Keyring{}
+	seen := make(map[string]bool)
+	for _, secret := range append([]string{current}, previous...) {
+		if secret == "" || seen[secret] {
+			continue
+		}
+		seen[secret] = true
+
+		entry, err := newKeyEntry(secret)
+		if err != nil {
+			return nil, err
+		}
+		k.keys = append(k.keys, entry)
+	}
+	k.current = k.keys[0]
+	return k, nil
+}
+
+func newKeyEntry(secret string) (keyEntry, error) {
+	derived := argon2.IDKey([]byte(secret), keySalt, 1, 64*1024, 4, 32)
+	aead, err := newGCM(derived)
+	if err != nil {
+		return keyEntry{}, err
+	}
+
+	legacyKey := sha256.Sum256([]byte(secret))
+	legacy, err := newGCM(legacyKey[:])
+	if err != nil {
+		return keyEntry{}, err
+	}
+
+	entry := keyEntry{aead: aead, legacy: legacy}
+	sum := sha256.Sum256(derived)
+	copy(entry.id[:], sum[:keyIDSize])
+	return entry, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("crypto: new cipher: %w", err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("crypto: new GCM: %w", err)
+	}
+	return gcm, nil
+}
+
+// CurrentKeyID identifica a chave usada nas novas cifragens.
+func (k *Keyring) CurrentKeyID() string {
+	return hex.EncodeToString(k.current.id[:])
+}
+
+// Size retorna a quantidade de chaves no keyring, incluindo a atual.
+func (k *Keyring) Size() int {
+	return len(k.keys)
+}
+
+func (k *Keyring) Encrypt(plaintext []byte) ([]byte, error) {
+	header := make([]byte, 0, len(keyringMagic)+keyIDSize)
+	header = append(header, keyringMagic...)
+	header = append(header, k.current.id[:]...)
+
+	nonce := make([]byte, k.current.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, fmt.Errorf("crypto: read nonce: %w", err)
+	}
+
+	out := append(header, nonce...)
+	return k.current.aead.Seal(out, nonce, plaintext, header), nil
+}
+
+// Decrypt aceita o formato versionado de qualquer chave do keyring e o
+// formato legado, derivado com SHA-256 simples.
+func (k *Keyring) Decrypt(ciphertext []byte) ([]byte, error) {
+	if id, ok := KeyID(ciphertext); ok {
+		for _, entry := range k.keys {
+			if hex.EncodeToString(entry.id[:]) != id {
+				continue
+			}
+			if plaintext, err := openVersioned(entry.aead, ciphertext); err == nil {
+				return plaintext, nil
+			}
+		}
+	}
+
+	for _, entry := range k.keys {
+		if plaintext, err := openLegacy(entry.legacy, ciphertext); err == nil {
+			return plaintext, nil
+		}
+	}
+
+	if _, ok := KeyID(ciphertext); ok {
+		return nil, ErrUnknownKey
+	}
+	return nil, errors.New("crypto: decrypt: nenhuma chave do keyring decifra o dado")
+}
+
+// NeedsRotation informa se o ciphertext não está no formato versionado com a
+// chave atual.
+func (k *Keyring) NeedsRotation(ciphertext []byte) bool {
+	id, ok := KeyID(ciphertext)
+	return !ok || id != k.CurrentKeyID()
+}
+
+// Reencrypt decifra com qualquer chave conhecida e cifra com a atual.
+func (k *Keyring) Reencrypt(ciphertext []byte) ([]byte, error) {
+	plaintext, err := k.Decrypt(ciphertext)
+	if err != nil {
+		return nil, err
+	}
+	return k.Encrypt(plaintext)
+}
+
+// KeyID retorna o ID da chave de um ciphertext versionado.
+func KeyID(ciphertext []byte) (string, bool) {
+	if len(ciphertext) < len(keyringMagic)+keyIDSize || !bytes.HasPrefix(ciphertext, keyringMagic) {
+		return "", false
+	}
+	return hex.EncodeToString(ciphertext[len(keyringMagic) : len(keyringMagic)+keyIDSize]), true
+}
+
+func openVersioned(aead cipher.AEAD, ciphertext []byte) ([]byte, error) {
+	headerSize := len(keyringMagic) + keyIDSize
+	if len(ciphertext) < headerSize+aead.NonceSize() {
+		return nil, errors.New("crypto: ciphertext too short")
+	}
+	header := ciphertext[:headerSize]
+	nonce := ciphertext[headerSize : headerSize+aead.NonceSize()]
+	return aead.Open(nil, nonce, ciphertext[headerSize+aead.NonceSize():], header)
+}
+
+func openLegacy(aead cipher.AEAD, ciphertext []byte) ([]byte, error) {
+	if len(ciphertext) < aead.NonceSize() {
+		return nil, errors.New("crypto: ciphertext too short")
+	}
+	nonce := ciphertext[:aead.NonceSize()]
+	return aead.Open(nil, nonce, ciphertext[aead.NonceSize():], nil)
+}
@@ -0,0 +1,73 @@
+package crypto
+
+import (
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// SaltSize é o tamanho do salt aleatório usado por EncryptWithPassphrase.
+const SaltSize = 16
+
+// Parâmetros do Argon2id para senhas escolhidas pelo usuário, que ficam
+// expostas a força bruta offline de quem obtém o arquivo cifrado.
+const (
+	passphraseTime    = 3
+	passphraseMemory  = 64 * 1024
+	passphraseThreads = 4
+)
+
+// NewSalt gera um salt aleatório de SaltSize bytes.
+func NewSalt() ([]byte, error) {
+	salt := make([]byte, SaltSize)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, fmt.Errorf("crypto: read salt: %w", err)
+	}
+	return salt, nil
+}
+
+// EncryptWithPassphrase cifra com AES-GCM usando a chave derivada da senha
+// com Argon2id e o salt informado, que deve ser novo a cada cifragem e
+// guardado junto do ciphertext. additionalData é autenticado mas não cifrado,
+// e deve ser informado igual na decifragem.
+func EncryptWithPassphrase(plaintext []byte, passphrase string, salt, additionalData []byte) ([]byte, error) {
+	aead, err := passphraseAEAD(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, fmt.Errorf("crypto: read nonce: %w", err)
+	}
+	return aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+// DecryptWithPassphrase decifra o resultado de EncryptWithPassphrase.
+func DecryptWithPassphrase(ciphertext []byte, passphrase string, salt, additionalData []byte) ([]byte, error) {
+	aead, err := passphraseAEAD(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ciphertext) < aead.NonceSize() {
+		return nil, errors.New("crypto: ciphertext too short")
+	}
+	nonce, ciphertext := ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, additionalData)
+	if err != nil {
+		return nil, fmt.Errorf("crypto: decrypt: %w", err)
+	}
+	return plaintext, nil
+}
+
+func passphraseAEAD(passphrase string, salt []byte) (cipher.AEAD, error) {
+	if len(salt) < SaltSize {
+		return nil, errors.New("crypto: salt too short")
+	}
+	return newGCM(argon2.IDKey([]byte(passphrase), salt, passphraseTime, passphraseMemory, passphraseThreads, 32))
+}
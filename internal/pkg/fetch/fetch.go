@@ -0,0 +1,237 @@
+// Package fetch baixa arquivos de URLs informadas pelos clientes da API sem
+// expor a rede interna: o destino é conferido depois da resolução DNS, a cada
+// conexão (inclusive nos redirecionamentos), e endereços privados, de
+// loopback e link-local são recusados.
+package fetch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"path"
+	"syscall"
+	"time"
+)
+
+var (
+	ErrInvalidURL       = errors.New("URL inválida: use http ou https")
+	ErrBlockedAddress   = errors.New("endereço de destino não permitido")
+	ErrTooManyRedirects = errors.New("redirecionamentos demais")
+)
+
+// blockedPrefixes são as faixas que não podem ser acessadas: redes privadas,
+// loopback, link-local (onde ficam os serviços de metadados das nuvens),
+// CGNAT, multicast e faixas reservadas. Os túneis 6to4 e Teredo e o NAT64 de
+// uso local também são recusados, porque levam a um IPv4 qualquer por um
+// relay fora do nosso controle.
+var blockedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("10.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("127.0.0.0/8"),
+	netip.MustParsePrefix("169.254.0.0/16"),
+	netip.MustParsePrefix("172.16.0.0/12"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("192.0.2.0/24"),
+	netip.MustParsePrefix("192.168.0.0/16"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("198.51.100.0/24"),
+	netip.MustParsePrefix("203.0.113.0/24"),
+	netip.MustParsePrefix("224.0.0.0/4"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("::/128"),
+	netip.MustParsePrefix("::1/128"),
+	netip.MustParsePrefix("64:ff9b:1::/48"),
+	netip.MustParsePrefix("100::/64"),
+	netip.MustParsePrefix("2001::/32"),
+	netip.MustParsePrefix("2001:db8::/32"),
+	netip.MustParsePrefix("2002::/16"),
+	netip.MustParsePrefix("fc00::/7"),
+	netip.MustParsePrefix("fe80::/10"),
+	netip.MustParsePrefix("ff00::/8"),
+}
+
+// TooLargeError indica um arquivo maior que o limite pedido. Size é o
+// tamanho informado pelo servidor ou, sem ele, o quanto foi lido até passar
+// do limite.
+type TooLargeError struct {
+	Size    int64
+	MaxSize int64
+}
+
+func (e *TooLargeError) Error() string {
+	return fmt.Sprintf("arquivo maior que o limite de %d bytes", e.MaxSize)
+}
+
+// Config define os limites do download. AllowPrivate desliga o bloqueio de
+// endereços internos, para instalações em que as mídias ficam na rede local.
+type Config struct {
+	Timeout        time.Duration
+	ConnectTimeout time.Duration
+	MaxRedirects   int
+	AllowPrivate   bool
+}
+
+// Result é o arquivo baixado. ContentType é o informado pelo servidor e
+// FileName, o nome do Content-Disposition ou do caminho da URL.
+type Result struct {
+	Data        []byte
+	ContentType string
+	FileName    string
+}
+
+type Fetcher struct {
+	client *http.Client
+}
+
+func New(cfg Config) *Fetcher {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 30 * time.Second
+	}
+	if cfg.ConnectTimeout <= 0 {
+		cfg.ConnectTimeout = 10 * time.Second
+	}
+	if cfg.MaxRedirects < 0 {
+		cfg.MaxRedirects = 0
+	}
+
+	dialer := &net.Dialer{Timeout: cfg.ConnectTimeout}
+	if !cfg.AllowPrivate {
+		dialer.Control = checkAddress
+	}
+	transport := &http.Transport{
+		// Sem proxy do ambiente: a conferência do destino precisa valer para
+		// a conexão de fato aberta.
+		Proxy:                 nil,
+		DialContext:           dialer.DialContext,
+		TLSHandshakeTimeout:   cfg.ConnectTimeout,
+		ResponseHeaderTimeout: cfg.Timeout,
+		MaxIdleConns:          10,
+		IdleConnTimeout:       90 * time.Second,
+	}
+
+	return &Fetcher{client: &http.Client{
+		Timeout:   cfg.Timeout,
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) > cfg.MaxRedirects {
+				return ErrTooManyRedirects
+			}
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return ErrInvalidURL
+			}
+			return nil
+		},
+	}}
+}
+
+// checkAddress recusa a conexão com endereços bloqueados. Roda depois da
+// resolução DNS, então um nome que aponta para a rede interna também é
+// recusado.
+func checkAddress(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrBlockedAddress, host)
+	}
+	if Blocked(addr) {
+		return fmt.Errorf("%w: %s", ErrBlockedAddress, addr)
+	}
+	return nil
+}
+
+// embeddedPrefixes são as faixas IPv6 que carregam um IPv4 nos últimos 32
+// bits: IPv4-compatível e o NAT64 do prefixo conhecido, usado pelo DNS64 em
+// redes só com IPv6. O destino de fato é o IPv4 embutido.
+var embeddedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("::/96"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+}
+
+// Blocked informa se o endereço está em uma das faixas recusadas. Endereços
+// IPv6 com um IPv4 embutido são conferidos pelo IPv4.
+func Blocked(addr netip.Addr) bool {
+	addr = addr.Unmap().WithZone("")
+	for _, prefix := range blockedPrefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	for _, prefix := range embeddedPrefixes {
+		if prefix.Contains(addr) {
+			b := addr.As16()
+			return Blocked(netip.AddrFrom4([4]byte(b[12:])))
+		}
+	}
+	return false
+}
+
+// Fetch baixa o arquivo da URL, lendo no máximo maxSize bytes.
+func (f *Fetcher) Fetch(ctx context.Context, rawURL string, maxSize int64) (Result, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return Result{}, ErrInvalidURL
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return Result{}, ErrInvalidURL
+	}
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return Result{}, unwrapURLError(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return Result{}, fmt.Errorf("servidor respondeu %d", resp.StatusCode)
+	}
+	if resp.ContentLength > maxSize {
+		return Result{}, &TooLargeError{Size: resp.ContentLength, MaxSize: maxSize}
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxSize+1))
+	if err != nil {
+		return Result{}, unwrapURLError(err)
+	}
+	if int64(len(data)) > maxSize {
+		return Result{}, &TooLargeError{Size: int64(len(data)), MaxSize: maxSize}
+	}
+
+	return Result{
+		Data:        data,
+		ContentType: resp.Header.Get("Content-Type"),
+		FileName:    fileName(resp),
+	}, nil
+}
+
+// unwrapURLError tira o *url.Error do cliente HTTP, que repete a URL inteira
+// na mensagem, mantendo o erro de origem.
+func unwrapURLError(err error) error {
+	var uerr *url.Error
+	if errors.As(err, &uerr) {
+		return uerr.Err
+	}
+	return err
+}
+
+// fileName retorna o nome do Content-Disposition ou, sem ele, o último
+// trecho do caminho da URL final.
+func fileName(resp *http.Response) string {
+	if _, params, err := mime.ParseMediaType(resp.Header.Get("Content-Disposition")); err == nil && params["filename"] != "" {
+		return path.Base(params["filename"])
+	}
+	if name := path.Base(resp.Request.URL.Path); name != "/" && name != "." {
+		return name
+	}
+	return ""
+}
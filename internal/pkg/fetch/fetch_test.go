@@ -0,0 +1,163 @@
+package fetch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"syscall"
+	"testing"
+)
+
+func TestBlocked(t *testing.T) {
+	tests := []struct {
+		addr    string
+		blocked bool
+	}{
+		{"8.8.8.8", false},
+		{"1.1.1.1", false},
+		{"2606:4700:4700::1111", false},
+		{"10.1.2.3", true},
+		{"127.0.0.1", true},
+		{"169.254.169.254", true},
+		{"172.16.0.1", true},
+		{"192.168.1.1", true},
+		{"100.64.0.1", true},
+		{"0.0.0.0", true},
+		{"224.0.0.1", true},
+		{"255.255.255.255", true},
+		{"::", true},
+		{"::1", true},
+		{"fe80::1%eth0", true},
+		{"fc00::1", true},
+		{"fd12:3456::1", true},
+		{"ff02::1", true},
+		{"2001:db8::1", true},
+		{"64:ff9b:1::a00:1", true},
+
+		// IPv4 mapeado e compatível: vale o IPv4.
+		{"::ffff:127.0.0.1", true},
+		{"::ffff:169.254.169.254", true},
+		{"::ffff:8.8.8.8", false},
+		{"::127.0.0.1", true},
+		{"::10.0.0.1", true},
+		{"::8.8.8.8", false},
+
+		// NAT64 do prefixo conhecido: vale o IPv4.
+		{"64:ff9b::127.0.0.1", true},
+		{"64:ff9b::a9fe:a9fe", true},
+		{"64:ff9b::8.8.8.8", false},
+
+		// 6to4 e Teredo são recusados com qualquer IPv4 embutido.
+		{"2002:7f00:1::1", true},
+		{"2002:a9fe:a9fe::1", true},
+		{"2002:808:808::1", true},
+		{"2001:0:4136:e378:8000:63bf:80ff:fffe", true},
+		{"2001:0:4136:e378:8000:63bf:f7f7:f7f7", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			if got := Blocked(netip.MustParseAddr(tt.addr)); got != tt.blocked {
+				t.Errorf("Blocked(%s) = %v, esperado %v", tt.addr, got, tt.blocked)
+			}
+		})
+	}
+}
+
+// newTestFetcher cria um Fetcher com o bloqueio ligado que deixa passar só
+// o servidor de teste em allowed, para conferir os redirecionamentos para a
+// rede interna.
+func newTestFetcher(maxRedirects int, allowed string) *Fetcher {
+	f := New(Config{MaxRedirects: maxRedirects})
+	dialer := &net.Dialer{Control: func(network, address string, c syscall.RawConn) error {
+		if address == allowed {
+			return nil
+		}
+		return checkAddress(network, address, c)
+	}}
+	f.client.Transport.(*http.Transport).DialContext = dialer.DialContext
+	return f
+}
+
+func TestFetchRedirects(t *testing.T) {
+	internal := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "interno")
+	}))
+	defer internal.Close()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/arquivo.pdf", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "conteúdo")
+	})
+	mux.HandleFunc("/salto/", func(w http.ResponseWriter, r *http.Request) {
+		var n int
+		fmt.Sscanf(r.URL.Path, "/salto/%d", &n)
+		if n == 0 {
+			http.Redirect(w, r, "/arquivo.pdf", http.StatusFound)
+			return
+		}
+		http.Redirect(w, r, fmt.Sprintf("/salto/%d", n-1), http.StatusFound)
+	})
+	mux.HandleFunc("/interno", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, internal.URL+"/", http.StatusFound)
+	})
+	mux.HandleFunc("/metadados", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "http://169.254.169.254/latest/meta-data/", http.StatusFound)
+	})
+	mux.HandleFunc("/ftp", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "ftp://example.com/arquivo.pdf", http.StatusFound)
+	})
+	public := httptest.NewServer(mux)
+	defer public.Close()
+
+	tests := []struct {
+		name     string
+		path     string
+		wantErr  error
+		wantName string
+	}{
+		{"sem redirecionamento", "/arquivo.pdf", nil, "arquivo.pdf"},
+		{"redirecionamentos dentro do limite", "/salto/2", nil, "arquivo.pdf"},
+		{"redirecionamentos demais", "/salto/3", ErrTooManyRedirects, ""},
+		{"redirecionamento para a rede interna", "/interno", ErrBlockedAddress, ""},
+		{"redirecionamento para link-local", "/metadados", ErrBlockedAddress, ""},
+		{"redirecionamento para outro esquema", "/ftp", ErrInvalidURL, ""},
+	}
+
+	f := newTestFetcher(3, public.Listener.Addr().String())
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := f.Fetch(context.Background(), public.URL+tt.path, 1024)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Fetch erro = %v, esperado %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Fetch: %v", err)
+			}
+			if string(res.Data) != "conteúdo" || res.FileName != tt.wantName {
+				t.Errorf("Fetch = %q, %q; esperado %q, %q", res.Data, res.FileName, "conteúdo", tt.wantName)
+			}
+		})
+	}
+}
+
+func TestFetchBlocksLoopback(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "interno")
+	}))
+	defer srv.Close()
+
+	if _, err := New(Config{}).Fetch(context.Background(), srv.URL, 1024); !errors.Is(err, ErrBlockedAddress) {
+		t.Fatalf("Fetch erro = %v, esperado %v", err, ErrBlockedAddress)
+	}
+	if _, err := New(Config{AllowPrivate: true}).Fetch(context.Background(), srv.URL, 1024); err != nil {
+		t.Fatalf("Fetch com AllowPrivate: %v", err)
+	}
+}
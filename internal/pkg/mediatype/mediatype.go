@@ -0,0 +1,197 @@
+// Package mediatype identifica o tipo das mídias enviadas pelo conteúdo e
+// aplica os formatos e tamanhos aceitos pelo WhatsApp para cada tipo de
+// mensagem.
+package mediatype
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/gabriel-vasile/mimetype"
+)
+
+// Motivos de recusa em ValidationError.Reason.
+const (
+	ReasonEmpty           = "empty"
+	ReasonUnsupportedKind = "unsupported_kind"
+	ReasonUnsupportedType = "unsupported_type"
+	ReasonTypeMismatch    = "type_mismatch"
+	ReasonTooLarge        = "too_large"
+	ReasonPTTRequiresOpus = "ptt_requires_opus"
+)
+
+// rule são os formatos e o tamanho máximo aceitos para um tipo de mensagem.
+// Sem allowed, qualquer formato é aceito.
+type rule struct {
+	allowed []string
+	maxSize int64
+	// canonical troca o tipo detectado pelo que é enviado ao WhatsApp.
+	canonical map[string]string
+}
+
+var rules = map[string]rule{
+	"image": {
+		allowed: []string{"image/jpeg", "image/png"},
+		maxSize: 5 << 20,
+	},
+	"video": {
+		allowed: []string{"video/mp4", "video/3gpp"},
+		maxSize: 16 << 20,
+	},
+	"audio": {
+		allowed: []string{"audio/aac", "audio/amr", "audio/mpeg", "audio/mp4", "audio/ogg"},
+		maxSize: 16 << 20,
+		canonical: map[string]string{
+			"audio/x-m4a": "audio/mp4",
+			"video/mp4":   "audio/mp4",
+		},
+	},
+	"document": {
+		maxSize: 100 << 20,
+	},
+}
+
+// ValidationError descreve a mídia recusada, com os formatos aceitos para o
+// tipo de mensagem.
+type ValidationError struct {
+	Kind     string   `json:"kind"`
+	Reason   string   `json:"reason"`
+	Detected string   `json:"detected,omitempty"`
+	Declared string   `json:"declared,omitempty"`
+	Allowed  []string `json:"allowed,omitempty"`
+	Size     int64    `json:"size"`
+	MaxSize  int64    `json:"maxSize,omitempty"`
+}
+
+func (e *ValidationError) Error() string {
+	switch e.Reason {
+	case ReasonEmpty:
+		return "mídia vazia"
+	case ReasonUnsupportedKind:
+		return fmt.Sprintf("tipo de mensagem não suporta mídia: %s", e.Kind)
+	case ReasonTooLarge:
+		return fmt.Sprintf("mídia de %d bytes excede o limite de %d bytes para %s", e.Size, e.MaxSize, e.Kind)
+	case ReasonTypeMismatch:
+		return fmt.Sprintf("conteúdo %s não corresponde ao tipo informado %s", e.Detected, e.Declared)
+	case ReasonPTTRequiresOpus:
+		return fmt.Sprintf("áudio de voz (ptt) deve ser ogg/opus, recebido %s", e.Detected)
+	default:
+		return fmt.Sprintf("formato %s não suportado para %s; aceitos: %s", e.Detected, e.Kind, strings.Join(e.Allowed, ", "))
+	}
+}
+
+// MaxSize retorna o tamanho máximo aceito para o tipo de mensagem, ou zero
+// se o tipo não leva mídia.
+func MaxSize(kind string) int64 {
+	return rules[kind].maxSize
+}
+
+// Validate identifica o formato da mídia pelo conteúdo e confere com o tipo
+// de mensagem, o mimetype informado pelo cliente (opcional) e o tamanho
+// máximo. Retorna o mimetype a enviar ao WhatsApp ou um *ValidationError.
+func Validate(kind string, data []byte, declared string, ptt bool) (string, error) {
+	r, ok := rules[kind]
+	if !ok {
+		return "", &ValidationError{Kind: kind, Reason: ReasonUnsupportedKind, Size: int64(len(data))}
+	}
+	verr := &ValidationError{
+		Kind:     kind,
+		Declared: declared,
+		Allowed:  r.allowed,
+		Size:     int64(len(data)),
+		MaxSize:  r.maxSize,
+	}
+	if len(data) == 0 {
+		verr.Reason = ReasonEmpty
+		return "", verr
+	}
+	if verr.Size > r.maxSize {
+		verr.Reason = ReasonTooLarge
+		return "", verr
+	}
+
+	detected := mimetype.Detect(data)
+	verr.Detected = baseType(detected.String())
+
+	result := verr.Detected
+	if canonical, ok := r.canonical[result]; ok {
+		result = canonical
+	}
+
+	if len(r.allowed) > 0 && !allowed(detected, result, r.allowed) {
+		verr.Reason = ReasonUnsupportedType
+		return "", verr
+	}
+	if !matches(detected, result, declared) {
+		verr.Reason = ReasonTypeMismatch
+		return "", verr
+	}
+
+	// Documentos que o conteúdo não identifica além de texto ou binário
+	// mantêm o tipo informado, mais específico.
+	if len(r.allowed) == 0 && !generic(declared) && (result == "application/octet-stream" || result == "text/plain") {
+		result = baseType(declared)
+	}
+
+	if kind == "audio" && result == "audio/ogg" {
+		if !isOpus(data) {
+			verr.Detected = "audio/ogg (sem opus)"
+			verr.Reason = ReasonUnsupportedType
+			return "", verr
+		}
+		result = "audio/ogg; codecs=opus"
+	}
+	if kind == "audio" && ptt && !strings.HasPrefix(result, "audio/ogg") {
+		verr.Reason = ReasonPTTRequiresOpus
+		return "", verr
+	}
+
+	return result, nil
+}
+
+func allowed(detected *mimetype.MIME, result string, list []string) bool {
+	for _, a := range list {
+		if result == a || detected.Is(a) {
+			return true
+		}
+	}
+	return false
+}
+
+// matches confere o tipo informado com o detectado, aceitando os tipos
+// genéricos, os ancestrais do detectado (zip para docx), subtipos de texto
+// que o conteúdo não distingue de text/plain e, em conteúdo binário não
+// identificado, tipos que a detecção não conhece.
+func matches(detected *mimetype.MIME, result, declared string) bool {
+	if generic(declared) || baseType(declared) == result {
+		return true
+	}
+	for m := detected; m != nil; m = m.Parent() {
+		if m.Is(declared) {
+			return true
+		}
+	}
+	if detected.Is("text/plain") && strings.HasPrefix(baseType(declared), "text/") {
+		return true
+	}
+	return detected.Is("application/octet-stream") && mimetype.Lookup(baseType(declared)) == nil
+}
+
+func generic(mime string) bool {
+	switch baseType(mime) {
+	case "", "application/octet-stream", "binary/octet-stream":
+		return true
+	}
+	return false
+}
+
+func baseType(mime string) string {
+	base, _, _ := strings.Cut(mime, ";")
+	return strings.ToLower(strings.TrimSpace(base))
+}
+
+// isOpus confere o codec no primeiro pacote do ogg.
+func isOpus(data []byte) bool {
+	return len(data) >= 36 && bytes.HasPrefix(data[28:], []byte("OpusHead"))
+}
@@ -0,0 +1,111 @@
+// Package thumbnail gera miniaturas JPEG de imagens, sem dependências fora
+// da biblioteca padrão.
+package thumbnail
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+
+	// Decoders registrados para image.Decode.
+	_ "image/gif"
+	_ "image/png"
+)
+
+// maxPixels limita o tamanho das imagens decodificadas: a imagem inteira
+// fica em memória durante a geração, e um PNG bem comprimido de poucos
+// megabytes pode ter dezenas de megapixels.
+const maxPixels = 16_000_000
+
+// maxConcurrent limita as miniaturas geradas ao mesmo tempo, para que várias
+// imagens grandes recebidas juntas não somem a memória de todas.
+const maxConcurrent = 2
+
+const quality = 75
+
+var ErrUnsupported = errors.New("imagem não suportada para miniatura")
+
+var slots = make(chan struct{}, maxConcurrent)
+
+// JPEG decodifica a imagem (JPEG, PNG ou GIF) e retorna uma miniatura JPEG
+// com o maior lado limitado a maxSide, mantendo a proporção. Imagens menores
+// não são ampliadas. Chamadas além de maxConcurrent esperam a vez.
+func JPEG(data []byte, maxSide int) ([]byte, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupported, err)
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width > maxPixels || cfg.Height > maxPixels ||
+		cfg.Width*cfg.Height > maxPixels {
+		return nil, fmt.Errorf("%w: %dx%d", ErrUnsupported, cfg.Width, cfg.Height)
+	}
+
+	slots <- struct{}{}
+	defer func() { <-slots }()
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupported, err)
+	}
+
+	dst := resize(src, maxSide)
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: quality}); err != nil {
+		return nil, fmt.Errorf("codificar miniatura: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// resize reduz a imagem pela média de cada bloco de pixels de origem, sobre
+// fundo branco para imagens com transparência. Os pixels são lidos direto da
+// origem, sem uma cópia do tamanho da imagem original.
+func resize(src image.Image, maxSide int) *image.RGBA {
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	dw, dh := w, h
+	if w > maxSide || h > maxSide {
+		if w >= h {
+			dw, dh = maxSide, max(1, h*maxSide/w)
+		} else {
+			dw, dh = max(1, w*maxSide/h), maxSide
+		}
+	}
+
+	// RGBA64At não aloca por pixel; todos os formatos da biblioteca padrão o
+	// implementam.
+	at := func(x, y int) (r, g, b, a uint32) { return src.At(x, y).RGBA() }
+	if fast, ok := src.(image.RGBA64Image); ok {
+		at = func(x, y int) (r, g, b, a uint32) {
+			c := fast.RGBA64At(x, y)
+			return uint32(c.R), uint32(c.G), uint32(c.B), uint32(c.A)
+		}
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		y0, y1 := y*h/dh, max((y+1)*h/dh, y*h/dh+1)
+		for x := 0; x < dw; x++ {
+			x0, x1 := x*w/dw, max((x+1)*w/dw, x*w/dw+1)
+			var r, g, bl, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					// Cores pré-multiplicadas: compor sobre o branco soma a
+					// parte transparente.
+					pr, pg, pb, pa := at(b.Min.X+sx, b.Min.Y+sy)
+					r += uint64(pr + 0xffff - pa)
+					g += uint64(pg + 0xffff - pa)
+					bl += uint64(pb + 0xffff - pa)
+					n++
+				}
+			}
+			i := y*dst.Stride + x*4
+			dst.Pix[i] = uint8(r / n >> 8)
+			dst.Pix[i+1] = uint8(g / n >> 8)
+			dst.Pix[i+2] = uint8(bl / n >> 8)
+			dst.Pix[i+3] = 0xff
+		}
+	}
+	return dst
+}
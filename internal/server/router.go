@@ -20,6 +20,7 @@ type Options struct {
 	HTMLTemplate    *template.Template
 	InstanceHandler *handler.InstanceHandler
 	MessageHandler  *handler.MessageHandler
+	ChatHandler     *handler.ChatHandler
 	MetaHandler     *handler.MetaHandler
 	WhatsAppHandler *handler.WhatsAppHandler
 	AuthHandler     *handler.AuthHandler
@@ -27,10 +28,16 @@ type Options struct {
 	HealthHandler   *handler.HealthHandler
 	UserHandler     *handler.UserHandler
 	MediaHandler    *handler.MediaHandler
+	AlertHandler    *handler.AlertHandler
+	TransferHandler *handler.SessionTransferHandler
+	CloudAPIHandler *handler.CloudAPIWebhookHandler
+	SandboxHandler  *handler.SandboxHandler
 	WebhookPool     *webhook.Pool
 	APITokenService interface{}
 	InstanceRepo    interface{}
 	RateLimit       middleware.RateLimitOption
+	Forward         middleware.ForwardOption
+	Drain           *middleware.DrainState
 }
 
 func NewRouter(opts Options) *gin.Engine {
@@ -55,8 +62,15 @@ func NewRouter(opts Options) *gin.Engine {
 	opts.HealthHandler.Register(api)
 	opts.AuthHandler.Register(api)
 
+	forward := middleware.ForwardToOwner(opts.Forward)
+
 	if opts.MediaHandler != nil {
-		api.GET("/media/:instanceId/:mediaId", opts.MediaHandler.GetMedia)
+		// A mídia é servida pelo nó dono da instância, o único que pode
+		// baixá-la do WhatsApp sob demanda. A autenticação fica com ele.
+		api.GET("/media/:instanceId/:mediaId", forward, opts.MediaHandler.GetMedia)
+	}
+	if opts.CloudAPIHandler != nil {
+		opts.CloudAPIHandler.Register(api)
 	}
 
 	protected := api.Group("")
@@ -82,8 +96,14 @@ func NewRouter(opts Options) *gin.Engine {
 		protected.Use(middleware.Auth(opts.AuthSecret))
 	}
 
+	protected.Use(forward)
+	protected.Use(middleware.Drain(opts.Drain))
+
 	opts.InstanceHandler.Register(protected)
 	opts.MessageHandler.Register(protected)
+	if opts.ChatHandler != nil {
+		opts.ChatHandler.Register(protected)
+	}
 	if opts.MetaHandler != nil {
 		opts.MetaHandler.Register(protected)
 	}
@@ -94,6 +114,15 @@ func NewRouter(opts Options) *gin.Engine {
 	if opts.UserHandler != nil {
 		opts.UserHandler.Register(protected)
 	}
+	if opts.AlertHandler != nil {
+		opts.AlertHandler.Register(protected)
+	}
+	if opts.TransferHandler != nil {
+		opts.TransferHandler.Register(protected)
+	}
+	if opts.SandboxHandler != nil {
+		opts.SandboxHandler.Register(protected)
+	}
 
 	return router
 }
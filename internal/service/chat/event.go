@@ -0,0 +1,133 @@
+package chat
+
+import (
+	"fmt"
+	"strings"
+
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+
+	"github.com/open-apime/apime/internal/storage/model"
+)
+
+// MessageFromEvent converte uma mensagem do WhatsMeow no modelo persistido.
+// Retorna false quando a mensagem não tem conteúdo exibível (protocolo,
+// reações etc.). O segundo retorno é o nome sugerido para a conversa.
+func MessageFromEvent(instanceID string, evt *events.Message, mediaID string) (model.Message, string, bool) {
+	msgType, text := describeMessage(evt.Message)
+	if msgType == "" {
+		return model.Message{}, "", false
+	}
+
+	chatJID := ResolveChatJID(evt.Info)
+	msg := model.Message{
+		InstanceID: instanceID,
+		WhatsAppID: evt.Info.ID,
+		ChatJID:    chatJID,
+		Sender:     ResolveSenderJID(evt.Info),
+		To:         chatJID,
+		Type:       msgType,
+		Payload:    text,
+		QuotedID:   quotedMessageID(evt.Message),
+		MediaID:    mediaID,
+		CreatedAt:  evt.Info.Timestamp,
+	}
+
+	chatName := ""
+	if evt.Info.IsFromMe {
+		msg.Direction = model.MessageDirectionOutbound
+		msg.Status = "synced"
+	} else {
+		msg.Direction = model.MessageDirectionInbound
+		msg.Status = "received"
+		if !evt.Info.IsGroup {
+			chatName = evt.Info.PushName
+		}
+	}
+
+	return msg, chatName, true
+}
+
+// ResolveSenderJID retorna o JID do remetente, preferindo o número real
+// (SenderAlt) quando o Sender for um LID.
+func ResolveSenderJID(info types.MessageInfo) string {
+	senderJID := info.Sender.String()
+	if strings.Contains(senderJID, "@lid") && !info.SenderAlt.IsEmpty() {
+		senderJID = info.SenderAlt.String()
+	}
+	return senderJID
+}
+
+// ResolveChatJID retorna o identificador estável da conversa, trocando LIDs
+// pelo número real quando disponível.
+func ResolveChatJID(info types.MessageInfo) string {
+	chatJID := info.Chat.String()
+	if !strings.Contains(chatJID, "@lid") {
+		return chatJID
+	}
+
+	// Para mensagens ENVIADAS (isFromMe=true), o número real do DESTINATÁRIO está em RecipientAlt
+	// Para mensagens RECEBIDAS (isFromMe=false), o número real do REMETENTE está em SenderAlt
+	if info.IsFromMe && !info.RecipientAlt.IsEmpty() && strings.Contains(info.RecipientAlt.String(), "@s.whatsapp.net") {
+		chatJID = info.RecipientAlt.String()
+	} else if !info.IsFromMe && !info.SenderAlt.IsEmpty() && strings.Contains(info.SenderAlt.String(), "@s.whatsapp.net") {
+		chatJID = info.SenderAlt.String()
+	}
+	return chatJID
+}
+
+// describeMessage retorna o tipo e o texto (ou legenda) da mensagem.
+// Tipo vazio indica mensagem sem conteúdo a ser persistido.
+func describeMessage(msg *waE2E.Message) (string, string) {
+	switch {
+	case msg.GetConversation() != "":
+		return "text", msg.GetConversation()
+	case msg.GetExtendedTextMessage() != nil:
+		return "text", msg.GetExtendedTextMessage().GetText()
+	case msg.GetImageMessage() != nil:
+		return "image", msg.GetImageMessage().GetCaption()
+	case msg.GetVideoMessage() != nil:
+		return "video", msg.GetVideoMessage().GetCaption()
+	case msg.GetDocumentMessage() != nil:
+		doc := msg.GetDocumentMessage()
+		if doc.GetCaption() != "" {
+			return "document", doc.GetCaption()
+		}
+		return "document", doc.GetFileName()
+	case msg.GetAudioMessage() != nil:
+		return "audio", ""
+	case msg.GetStickerMessage() != nil:
+		return "sticker", ""
+	case msg.GetLocationMessage() != nil:
+		loc := msg.GetLocationMessage()
+		return "location", fmt.Sprintf("%f,%f", loc.GetDegreesLatitude(), loc.GetDegreesLongitude())
+	case msg.GetContactMessage() != nil:
+		return "contact", msg.GetContactMessage().GetDisplayName()
+	}
+	return "", ""
+}
+
+// quotedMessageID retorna o ID da mensagem citada, se houver.
+func quotedMessageID(msg *waE2E.Message) string {
+	var ctxInfo *waE2E.ContextInfo
+	switch {
+	case msg.GetExtendedTextMessage() != nil:
+		ctxInfo = msg.GetExtendedTextMessage().GetContextInfo()
+	case msg.GetImageMessage() != nil:
+		ctxInfo = msg.GetImageMessage().GetContextInfo()
+	case msg.GetVideoMessage() != nil:
+		ctxInfo = msg.GetVideoMessage().GetContextInfo()
+	case msg.GetDocumentMessage() != nil:
+		ctxInfo = msg.GetDocumentMessage().GetContextInfo()
+	case msg.GetAudioMessage() != nil:
+		ctxInfo = msg.GetAudioMessage().GetContextInfo()
+	case msg.GetStickerMessage() != nil:
+		ctxInfo = msg.GetStickerMessage().GetContextInfo()
+	case msg.GetLocationMessage() != nil:
+		ctxInfo = msg.GetLocationMessage().GetContextInfo()
+	case msg.GetContactMessage() != nil:
+		ctxInfo = msg.GetContactMessage().GetContextInfo()
+	}
+	return ctxInfo.GetStanzaID()
+}
@@ -0,0 +1,134 @@
+package chat
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"go.mau.fi/whatsmeow/types/events"
+	"go.uber.org/zap"
+
+	"github.com/open-apime/apime/internal/storage"
+	"github.com/open-apime/apime/internal/storage/model"
+)
+
+const (
+	defaultMessagesLimit = 50
+	maxMessagesLimit     = 200
+)
+
+var ErrInvalidChatJID = errors.New("JID da conversa inválido")
+
+type Service struct {
+	messageRepo storage.MessageRepository
+	chatRepo    storage.ChatRepository
+	log         *zap.Logger
+}
+
+func NewService(messageRepo storage.MessageRepository, chatRepo storage.ChatRepository, log *zap.Logger) *Service {
+	return &Service{
+		messageRepo: messageRepo,
+		chatRepo:    chatRepo,
+		log:         log,
+	}
+}
+
+// RecordEvent persiste uma mensagem observada na sessão (recebida ou enviada
+// por outro dispositivo vinculado) e atualiza a conversa correspondente.
+// Retorna true quando a mensagem ainda não existia.
+func (s *Service) RecordEvent(ctx context.Context, instanceID string, evt *events.Message, mediaID string) (bool, error) {
+	msg, chatName, ok := MessageFromEvent(instanceID, evt, mediaID)
+	if !ok {
+		return false, nil
+	}
+	return s.record(ctx, msg, chatName, msg.Direction == model.MessageDirectionInbound)
+}
+
+// ImportEvent persiste uma mensagem vinda do histórico. Mensagens importadas
+// não contam como não lidas.
+func (s *Service) ImportEvent(ctx context.Context, instanceID string, evt *events.Message, chatName string) (bool, error) {
+	msg, pushName, ok := MessageFromEvent(instanceID, evt, "")
+	if !ok {
+		return false, nil
+	}
+	if chatName == "" {
+		chatName = pushName
+	}
+	return s.record(ctx, msg, chatName, false)
+}
+
+func (s *Service) record(ctx context.Context, msg model.Message, chatName string, countUnread bool) (bool, error) {
+	if msg.InstanceID == "" || msg.ChatJID == "" {
+		return false, ErrInvalidChatJID
+	}
+
+	stored, created, err := s.messageRepo.CreateIfNotExists(ctx, msg)
+	if err != nil {
+		return false, err
+	}
+	if !created {
+		return false, nil
+	}
+
+	unread := 0
+	if countUnread {
+		unread = 1
+	}
+	if err := s.chatRepo.UpsertLastMessage(ctx, stored.InstanceID, stored.ChatJID, chatName, stored, unread); err != nil {
+		return true, err
+	}
+
+	return true, nil
+}
+
+func (s *Service) MarkRead(ctx context.Context, instanceID, chatJID string) error {
+	return s.chatRepo.MarkRead(ctx, instanceID, chatJID)
+}
+
+func (s *Service) ListChats(ctx context.Context, instanceID string) ([]model.Chat, error) {
+	chats, err := s.chatRepo.ListByInstance(ctx, instanceID)
+	if err != nil {
+		return nil, err
+	}
+	if chats == nil {
+		chats = []model.Chat{}
+	}
+	return chats, nil
+}
+
+func (s *Service) ListMessages(ctx context.Context, instanceID, chatJID string, before *time.Time, limit int) ([]model.Message, error) {
+	chatJID = NormalizeJID(chatJID)
+	if chatJID == "" {
+		return nil, ErrInvalidChatJID
+	}
+	if limit <= 0 {
+		limit = defaultMessagesLimit
+	}
+	if limit > maxMessagesLimit {
+		limit = maxMessagesLimit
+	}
+
+	messages, err := s.messageRepo.ListByChat(ctx, instanceID, chatJID, before, limit)
+	if err != nil {
+		return nil, err
+	}
+	if messages == nil {
+		messages = []model.Message{}
+	}
+	return messages, nil
+}
+
+// NormalizeJID aceita tanto o JID completo quanto apenas o número e retorna o
+// JID usado como chave da conversa.
+func NormalizeJID(jid string) string {
+	jid = strings.TrimSpace(jid)
+	if jid == "" {
+		return ""
+	}
+	if strings.Contains(jid, "@") {
+		return jid
+	}
+	jid = strings.TrimPrefix(jid, "+")
+	return jid + "@s.whatsapp.net"
+}
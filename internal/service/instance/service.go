@@ -5,31 +5,83 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"errors"
+	"fmt"
 	"strings"
 	"time"
 
 	"github.com/google/uuid"
 
+	"github.com/open-apime/apime/internal/session"
 	"github.com/open-apime/apime/internal/storage"
 	"github.com/open-apime/apime/internal/storage/model"
 )
 
-var ErrInvalidName = errors.New("nome da instância inválido")
+var (
+	ErrInvalidName     = errors.New("nome da instância inválido")
+	ErrInvalidProvider = errors.New("provedor da instância inválido")
+	ErrInvalidPolicy   = errors.New("política de mídia inválida")
+)
 
 type Service struct {
 	repo         storage.InstanceRepository
 	messageRepo  storage.MessageRepository
 	eventLogRepo storage.EventLogRepository
 	session      SessionManager
+	cloudAPI     CloudAPIManager
+	sandbox      SandboxManager
+	mediaPolicy  storage.MediaPolicyRepository
+	mediaUsage   MediaUsageSource
 }
 
 type SessionManager interface {
 	CreateSession(ctx context.Context, instanceID string) (string, error)
 	GetQR(ctx context.Context, instanceID string) (string, error)
+	GetPairCode(ctx context.Context, instanceID, phone string) (model.PairCode, error)
+	CurrentPairCode(instanceID string) (model.PairCode, bool)
+	GetProxy(ctx context.Context, instanceID string) (model.InstanceProxy, error)
+	SetProxy(ctx context.Context, proxy model.InstanceProxy) (model.InstanceProxy, error)
+	RemoveProxy(ctx context.Context, instanceID string) error
+	GetConnectionState(ctx context.Context, instanceID string, limit int) (model.InstanceConnectionState, error)
 	RestoreSession(ctx context.Context, instanceID string, encryptedBlob []byte) error
 	Disconnect(instanceID string) error
 	DeleteSession(instanceID string) error
 	SaveSessionBlob(instanceID string) ([]byte, error)
+	ExportDeviceStore(ctx context.Context, instanceID string) (model.DeviceStoreDump, error)
+	ImportDeviceStore(ctx context.Context, instanceID string, dump model.DeviceStoreDump) error
+	DiscardDeviceStore(ctx context.Context, instanceID, jid string) error
+	ExportProxy(ctx context.Context, instanceID string) (*model.InstanceProxy, error)
+	ReleaseSession(instanceID string)
+	ActivateSession(ctx context.Context, instanceID string)
+}
+
+// CloudAPIManager gerencia as credenciais das instâncias com provedor
+// cloud_api. Implementado por cloudapi.Manager.
+type CloudAPIManager interface {
+	GetConfig(ctx context.Context, instanceID string) (model.InstanceCloudAPI, error)
+	Configure(ctx context.Context, cfg model.InstanceCloudAPI) (model.InstanceCloudAPI, error)
+	Disconnect(ctx context.Context, instanceID string) error
+	Remove(ctx context.Context, instanceID string) error
+}
+
+// SandboxManager controla as instâncias simuladas do provedor sandbox.
+// Implementado por sandbox.Manager.
+type SandboxManager interface {
+	Connect(ctx context.Context, instanceID string) error
+	Disconnect(ctx context.Context, instanceID string) error
+	Remove(instanceID string)
+}
+
+// MediaUsageSource informa o espaço ocupado pelas mídias de uma instância.
+// Implementado por media.Storage.
+type MediaUsageSource interface {
+	Usage(ctx context.Context, instanceID string) (model.MediaUsage, error)
+}
+
+// MediaRetentionLimiter é implementado pelos backends de mídia que removem as
+// mídias depois de um prazo fixo, qualquer que seja a política da instância.
+type MediaRetentionLimiter interface {
+	// MaxRetention retorna zero quando não há limite.
+	MaxRetention() time.Duration
 }
 
 func NewService(repo storage.InstanceRepository) *Service {
@@ -48,12 +100,27 @@ func NewServiceWithSessionMessagesAndEventLogs(repo storage.InstanceRepository,
 	return &Service{repo: repo, messageRepo: messageRepo, eventLogRepo: eventLogRepo, session: session}
 }
 
+func (s *Service) SetCloudAPIManager(m CloudAPIManager) {
+	s.cloudAPI = m
+}
+
+func (s *Service) SetSandboxManager(m SandboxManager) {
+	s.sandbox = m
+}
+
+func (s *Service) SetMediaPolicies(repo storage.MediaPolicyRepository, usage MediaUsageSource) {
+	s.mediaPolicy = repo
+	s.mediaUsage = usage
+}
+
 type CreateInput struct {
 	Name           string
 	WebhookURL     string
 	WebhookSecret  string
 	MetaCompatible bool
 	OwnerUserID    string
+	Provider       model.InstanceProvider
+	MediaDownload  model.MediaDownloadMode
 }
 
 type UpdateInput struct {
@@ -61,6 +128,7 @@ type UpdateInput struct {
 	WebhookURL     string
 	WebhookSecret  string
 	MetaCompatible bool
+	MediaDownload  model.MediaDownloadMode
 	OwnerUserID    string
 }
 
@@ -71,6 +139,21 @@ func (s *Service) Create(ctx context.Context, input CreateInput) (model.Instance
 	if strings.TrimSpace(input.WebhookURL) != "" && !strings.HasPrefix(strings.TrimSpace(input.WebhookURL), "http") {
 		return model.Instance{}, errors.New("webhook inválido")
 	}
+	switch input.Provider {
+	case "":
+		input.Provider = model.InstanceProviderWhatsmeow
+	case model.InstanceProviderWhatsmeow:
+	case model.InstanceProviderCloudAPI:
+		if s.cloudAPI == nil {
+			return model.Instance{}, fmt.Errorf("%w: Cloud API não habilitada", ErrInvalidProvider)
+		}
+	case model.InstanceProviderSandbox:
+		if s.sandbox == nil {
+			return model.Instance{}, fmt.Errorf("%w: sandbox não habilitado", ErrInvalidProvider)
+		}
+	default:
+		return model.Instance{}, ErrInvalidProvider
+	}
 
 	plainToken := uuid.NewString()
 	hashBytes := sha256.Sum256([]byte(plainToken))
@@ -84,6 +167,8 @@ func (s *Service) Create(ctx context.Context, input CreateInput) (model.Instance
 		WebhookURL:     strings.TrimSpace(input.WebhookURL),
 		WebhookSecret:  strings.TrimSpace(input.WebhookSecret),
 		MetaCompatible: input.MetaCompatible,
+		Provider:       input.Provider,
+		MediaDownload:  input.MediaDownload,
 		TokenHash:      hash,
 		TokenUpdatedAt: &now,
 		Status:         model.InstanceStatusPending,
@@ -93,6 +178,14 @@ func (s *Service) Create(ctx context.Context, input CreateInput) (model.Instance
 		return model.Instance{}, err
 	}
 
+	// Instâncias sandbox não têm pareamento e já nascem conectadas.
+	if created.Provider == model.InstanceProviderSandbox {
+		if err := s.sandbox.Connect(ctx, created.ID); err != nil {
+			return model.Instance{}, err
+		}
+		return s.repo.GetByID(ctx, created.ID)
+	}
+
 	return created, nil
 }
 
@@ -139,6 +232,9 @@ func (s *Service) Update(ctx context.Context, id string, input UpdateInput) (mod
 	inst.WebhookURL = strings.TrimSpace(input.WebhookURL)
 	inst.WebhookSecret = strings.TrimSpace(input.WebhookSecret)
 	inst.MetaCompatible = input.MetaCompatible
+	if input.MediaDownload != "" {
+		inst.MediaDownload = input.MediaDownload
+	}
 	return s.repo.Update(ctx, inst)
 }
 
@@ -162,6 +258,9 @@ func (s *Service) UpdateByUser(ctx context.Context, id string, input UpdateInput
 	inst.WebhookURL = strings.TrimSpace(input.WebhookURL)
 	inst.WebhookSecret = strings.TrimSpace(input.WebhookSecret)
 	inst.MetaCompatible = input.MetaCompatible
+	if input.MediaDownload != "" {
+		inst.MediaDownload = input.MediaDownload
+	}
 	return s.repo.Update(ctx, inst)
 }
 
@@ -179,10 +278,13 @@ func (s *Service) GetQR(ctx context.Context, id string) (string, error) {
 		return "", errors.New("session manager não configurado")
 	}
 
-	_, err := s.repo.GetByID(ctx, id)
+	inst, err := s.repo.GetByID(ctx, id)
 	if err != nil {
 		return "", err
 	}
+	if !usesSession(inst) {
+		return "", session.ErrNotSupported
+	}
 
 	return s.session.GetQR(ctx, id)
 }
@@ -195,14 +297,223 @@ func (s *Service) GetQRByUser(ctx context.Context, id string, userID string, use
 	return s.GetQR(ctx, id)
 }
 
+func (s *Service) GetPairCode(ctx context.Context, id, phone string) (model.PairCode, error) {
+	if s.session == nil {
+		return model.PairCode{}, errors.New("session manager não configurado")
+	}
+
+	inst, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return model.PairCode{}, err
+	}
+	if !usesSession(inst) {
+		return model.PairCode{}, session.ErrNotSupported
+	}
+
+	return s.session.GetPairCode(ctx, id, phone)
+}
+
+func (s *Service) GetPairCodeByUser(ctx context.Context, id, phone string, userID string, userRole string) (model.PairCode, error) {
+	_, err := s.GetByUser(ctx, id, userID, userRole)
+	if err != nil {
+		return model.PairCode{}, err
+	}
+	return s.GetPairCode(ctx, id, phone)
+}
+
+// CurrentPairCode retorna o código de pareamento ainda válido da instância, se houver.
+func (s *Service) CurrentPairCode(id string) (model.PairCode, bool) {
+	if s.session == nil {
+		return model.PairCode{}, false
+	}
+	return s.session.CurrentPairCode(id)
+}
+
+func (s *Service) GetProxy(ctx context.Context, id string) (model.InstanceProxy, error) {
+	if s.session == nil {
+		return model.InstanceProxy{}, errors.New("session manager não configurado")
+	}
+	if _, err := s.repo.GetByID(ctx, id); err != nil {
+		return model.InstanceProxy{}, err
+	}
+	return s.session.GetProxy(ctx, id)
+}
+
+func (s *Service) GetProxyByUser(ctx context.Context, id string, userID string, userRole string) (model.InstanceProxy, error) {
+	if _, err := s.GetByUser(ctx, id, userID, userRole); err != nil {
+		return model.InstanceProxy{}, err
+	}
+	return s.GetProxy(ctx, id)
+}
+
+func (s *Service) SetProxy(ctx context.Context, proxy model.InstanceProxy) (model.InstanceProxy, error) {
+	if s.session == nil {
+		return model.InstanceProxy{}, errors.New("session manager não configurado")
+	}
+	if _, err := s.repo.GetByID(ctx, proxy.InstanceID); err != nil {
+		return model.InstanceProxy{}, err
+	}
+	return s.session.SetProxy(ctx, proxy)
+}
+
+func (s *Service) SetProxyByUser(ctx context.Context, proxy model.InstanceProxy, userID string, userRole string) (model.InstanceProxy, error) {
+	if _, err := s.GetByUser(ctx, proxy.InstanceID, userID, userRole); err != nil {
+		return model.InstanceProxy{}, err
+	}
+	return s.SetProxy(ctx, proxy)
+}
+
+func (s *Service) RemoveProxy(ctx context.Context, id string) error {
+	if s.session == nil {
+		return errors.New("session manager não configurado")
+	}
+	if _, err := s.repo.GetByID(ctx, id); err != nil {
+		return err
+	}
+	return s.session.RemoveProxy(ctx, id)
+}
+
+func (s *Service) RemoveProxyByUser(ctx context.Context, id string, userID string, userRole string) error {
+	if _, err := s.GetByUser(ctx, id, userID, userRole); err != nil {
+		return err
+	}
+	return s.RemoveProxy(ctx, id)
+}
+
+func (s *Service) GetMediaPolicy(ctx context.Context, id string) (model.MediaPolicy, error) {
+	if s.mediaPolicy == nil {
+		return model.MediaPolicy{}, errors.New("política de mídia não configurada")
+	}
+	if _, err := s.repo.GetByID(ctx, id); err != nil {
+		return model.MediaPolicy{}, err
+	}
+	return s.mediaPolicy.Get(ctx, id)
+}
+
+func (s *Service) GetMediaPolicyByUser(ctx context.Context, id string, userID string, userRole string) (model.MediaPolicy, error) {
+	if _, err := s.GetByUser(ctx, id, userID, userRole); err != nil {
+		return model.MediaPolicy{}, err
+	}
+	return s.GetMediaPolicy(ctx, id)
+}
+
+// SetMediaPolicy define a retenção e a cota de mídia da instância. Zero em
+// um dos campos mantém o padrão: o TTL global ou nenhuma cota.
+func (s *Service) SetMediaPolicy(ctx context.Context, policy model.MediaPolicy) (model.MediaPolicy, error) {
+	if s.mediaPolicy == nil {
+		return model.MediaPolicy{}, errors.New("política de mídia não configurada")
+	}
+	if policy.RetentionSeconds < 0 || policy.QuotaBytes < 0 {
+		return model.MediaPolicy{}, ErrInvalidPolicy
+	}
+	// Uma retenção que o backend não cumpre seria aceita sem efeito.
+	if limiter, ok := s.mediaUsage.(MediaRetentionLimiter); ok {
+		if limit := limiter.MaxRetention(); limit > 0 && time.Duration(policy.RetentionSeconds)*time.Second > limit {
+			return model.MediaPolicy{}, fmt.Errorf("%w: retenção acima de %d segundos não é cumprida com a regra de expiração do bucket",
+				ErrInvalidPolicy, int64(limit/time.Second))
+		}
+	}
+	if _, err := s.repo.GetByID(ctx, policy.InstanceID); err != nil {
+		return model.MediaPolicy{}, err
+	}
+	if err := s.mediaPolicy.Upsert(ctx, policy); err != nil {
+		return model.MediaPolicy{}, err
+	}
+	return s.mediaPolicy.Get(ctx, policy.InstanceID)
+}
+
+func (s *Service) SetMediaPolicyByUser(ctx context.Context, policy model.MediaPolicy, userID string, userRole string) (model.MediaPolicy, error) {
+	if _, err := s.GetByUser(ctx, policy.InstanceID, userID, userRole); err != nil {
+		return model.MediaPolicy{}, err
+	}
+	return s.SetMediaPolicy(ctx, policy)
+}
+
+func (s *Service) RemoveMediaPolicy(ctx context.Context, id string) error {
+	if s.mediaPolicy == nil {
+		return errors.New("política de mídia não configurada")
+	}
+	if _, err := s.repo.GetByID(ctx, id); err != nil {
+		return err
+	}
+	return s.mediaPolicy.Delete(ctx, id)
+}
+
+func (s *Service) RemoveMediaPolicyByUser(ctx context.Context, id string, userID string, userRole string) error {
+	if _, err := s.GetByUser(ctx, id, userID, userRole); err != nil {
+		return err
+	}
+	return s.RemoveMediaPolicy(ctx, id)
+}
+
+// GetMediaUsage retorna o espaço ocupado pelas mídias guardadas da instância,
+// por tipo, com a retenção e a cota em vigor.
+func (s *Service) GetMediaUsage(ctx context.Context, id string) (model.MediaUsage, error) {
+	if s.mediaUsage == nil {
+		return model.MediaUsage{}, errors.New("armazenamento de mídia não configurado")
+	}
+	if _, err := s.repo.GetByID(ctx, id); err != nil {
+		return model.MediaUsage{}, err
+	}
+	return s.mediaUsage.Usage(ctx, id)
+}
+
+func (s *Service) GetMediaUsageByUser(ctx context.Context, id string, userID string, userRole string) (model.MediaUsage, error) {
+	if _, err := s.GetByUser(ctx, id, userID, userRole); err != nil {
+		return model.MediaUsage{}, err
+	}
+	return s.GetMediaUsage(ctx, id)
+}
+
+// GetConnectionState retorna o estado de conexão da instância e as últimas
+// transições, limitadas a 200 (padrão 50).
+func (s *Service) GetConnectionState(ctx context.Context, id string, limit int) (model.InstanceConnectionState, error) {
+	if s.session == nil {
+		return model.InstanceConnectionState{}, errors.New("session manager não configurado")
+	}
+	if _, err := s.repo.GetByID(ctx, id); err != nil {
+		return model.InstanceConnectionState{}, err
+	}
+	if limit <= 0 {
+		limit = 50
+	}
+	if limit > 200 {
+		limit = 200
+	}
+	return s.session.GetConnectionState(ctx, id, limit)
+}
+
+func (s *Service) GetConnectionStateByUser(ctx context.Context, id string, limit int, userID string, userRole string) (model.InstanceConnectionState, error) {
+	if _, err := s.GetByUser(ctx, id, userID, userRole); err != nil {
+		return model.InstanceConnectionState{}, err
+	}
+	return s.GetConnectionState(ctx, id, limit)
+}
+
 func (s *Service) Disconnect(ctx context.Context, id string) error {
+	inst, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if inst.Provider == model.InstanceProviderCloudAPI {
+		if s.cloudAPI == nil {
+			return errors.New("Cloud API não configurada")
+		}
+		return s.cloudAPI.Disconnect(ctx, id)
+	}
+	if inst.Provider == model.InstanceProviderSandbox {
+		if s.sandbox == nil {
+			return errors.New("sandbox não habilitado")
+		}
+		return s.sandbox.Disconnect(ctx, id)
+	}
 	if s.session == nil {
 		return errors.New("session manager não configurado")
 	}
 	if err := s.session.Disconnect(id); err != nil {
 		return err
 	}
-	_, err := s.UpdateStatus(ctx, id, model.InstanceStatusDisconnected)
+	_, err = s.UpdateStatus(ctx, id, model.InstanceStatusDisconnected)
 	return err
 }
 
@@ -215,15 +526,12 @@ func (s *Service) DisconnectByUser(ctx context.Context, id string, userID string
 }
 
 func (s *Service) Delete(ctx context.Context, id string) error {
-	_, err := s.repo.GetByID(ctx, id)
+	inst, err := s.repo.GetByID(ctx, id)
 	if err != nil {
 		return err
 	}
 
-	// Deletar sessão completamente (incluindo arquivo SQLite) se existir
-	if s.session != nil {
-		_ = s.session.DeleteSession(id)
-	}
+	s.deleteSession(ctx, inst)
 
 	if s.messageRepo != nil {
 		if err := s.messageRepo.DeleteByInstanceID(ctx, id); err != nil {
@@ -250,9 +558,7 @@ func (s *Service) DeleteByUser(ctx context.Context, id string, userID string, us
 		return storage.ErrNotFound
 	}
 
-	if s.session != nil {
-		_ = s.session.DeleteSession(id)
-	}
+	s.deleteSession(ctx, inst)
 
 	if s.messageRepo != nil {
 		if err := s.messageRepo.DeleteByInstanceID(ctx, id); err != nil {
@@ -269,6 +575,69 @@ func (s *Service) DeleteByUser(ctx context.Context, id string, userID string, us
 	return s.repo.Delete(ctx, id)
 }
 
+// deleteSession apaga a sessão whatsmeow (incluindo arquivo SQLite), as
+// credenciais da Cloud API ou o estado sandbox da instância, se existirem.
+func (s *Service) deleteSession(ctx context.Context, inst model.Instance) {
+	switch inst.Provider {
+	case model.InstanceProviderCloudAPI:
+		if s.cloudAPI != nil {
+			_ = s.cloudAPI.Remove(ctx, inst.ID)
+		}
+		return
+	case model.InstanceProviderSandbox:
+		if s.sandbox != nil {
+			s.sandbox.Remove(inst.ID)
+		}
+		return
+	}
+	if s.session != nil {
+		_ = s.session.DeleteSession(inst.ID)
+	}
+}
+
+func (s *Service) GetCloudAPI(ctx context.Context, id string) (model.InstanceCloudAPI, error) {
+	inst, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return model.InstanceCloudAPI{}, err
+	}
+	if inst.Provider != model.InstanceProviderCloudAPI || s.cloudAPI == nil {
+		return model.InstanceCloudAPI{}, session.ErrNotSupported
+	}
+	return s.cloudAPI.GetConfig(ctx, id)
+}
+
+func (s *Service) GetCloudAPIByUser(ctx context.Context, id string, userID string, userRole string) (model.InstanceCloudAPI, error) {
+	if _, err := s.GetByUser(ctx, id, userID, userRole); err != nil {
+		return model.InstanceCloudAPI{}, err
+	}
+	return s.GetCloudAPI(ctx, id)
+}
+
+// ConfigureCloudAPI grava as credenciais da Cloud API e ativa a instância.
+func (s *Service) ConfigureCloudAPI(ctx context.Context, cfg model.InstanceCloudAPI) (model.InstanceCloudAPI, error) {
+	inst, err := s.repo.GetByID(ctx, cfg.InstanceID)
+	if err != nil {
+		return model.InstanceCloudAPI{}, err
+	}
+	if inst.Provider != model.InstanceProviderCloudAPI || s.cloudAPI == nil {
+		return model.InstanceCloudAPI{}, session.ErrNotSupported
+	}
+	return s.cloudAPI.Configure(ctx, cfg)
+}
+
+func (s *Service) ConfigureCloudAPIByUser(ctx context.Context, cfg model.InstanceCloudAPI, userID string, userRole string) (model.InstanceCloudAPI, error) {
+	if _, err := s.GetByUser(ctx, cfg.InstanceID, userID, userRole); err != nil {
+		return model.InstanceCloudAPI{}, err
+	}
+	return s.ConfigureCloudAPI(ctx, cfg)
+}
+
+// usesSession informa se a instância é conectada por uma sessão whatsmeow.
+// Instâncias gravadas antes da coluna provider não têm provedor.
+func usesSession(inst model.Instance) bool {
+	return inst.Provider == "" || inst.Provider == model.InstanceProviderWhatsmeow
+}
+
 func (s *Service) RotateToken(ctx context.Context, id string) (string, error) {
 	inst, err := s.repo.GetByID(ctx, id)
 	if err != nil {
@@ -0,0 +1,303 @@
+package instance
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/open-apime/apime/internal/pkg/crypto"
+	"github.com/open-apime/apime/internal/session"
+	"github.com/open-apime/apime/internal/storage/model"
+)
+
+const (
+	SessionBundleFormat = "apime-session-bundle"
+	// SessionBundleVersion 2 deriva a chave da senha com Argon2id e um salt
+	// por bundle, e autentica o cabeçalho em claro (ver bundleHeader).
+	SessionBundleVersion = 2
+)
+
+var (
+	ErrPassphraseRequired    = errors.New("senha de transferência obrigatória")
+	ErrInvalidPassphrase     = errors.New("senha de transferência incorreta ou bundle corrompido")
+	ErrInvalidBundle         = errors.New("bundle de sessão inválido")
+	ErrUnsupportedBundle     = errors.New("versão do bundle de sessão não suportada")
+	ErrInstanceAlreadyExists = errors.New("instância já existe neste servidor")
+)
+
+// SessionBundle é o arquivo de migração de uma instância. Apenas os metadados
+// ficam em claro; instância, proxy e device store vão em Data, cifrados com a
+// chave derivada da senha de transferência informada na exportação e do Salt.
+// Format, Version, InstanceID e Salt são autenticados junto com Data.
+type SessionBundle struct {
+	Format       string    `json:"format"`
+	Version      int       `json:"version"`
+	InstanceID   string    `json:"instanceId"`
+	InstanceName string    `json:"instanceName"`
+	ExportedAt   time.Time `json:"exportedAt"`
+	Salt         []byte    `json:"salt,omitempty"`
+	Data         []byte    `json:"data"`
+}
+
+// bundleHeader são os campos em claro autenticados como dados adicionais do
+// AES-GCM, para que não possam ser alterados sem invalidar o bundle.
+type bundleHeader struct {
+	Format     string `json:"format"`
+	Version    int    `json:"version"`
+	InstanceID string `json:"instanceId"`
+	Salt       []byte `json:"salt"`
+}
+
+func (b SessionBundle) additionalData() ([]byte, error) {
+	return json.Marshal(bundleHeader{
+		Format:     b.Format,
+		Version:    b.Version,
+		InstanceID: b.InstanceID,
+		Salt:       b.Salt,
+	})
+}
+
+type bundlePayload struct {
+	Instance    bundleInstance        `json:"instance"`
+	Proxy       *bundleProxy          `json:"proxy,omitempty"`
+	DeviceStore model.DeviceStoreDump `json:"deviceStore"`
+}
+
+type bundleInstance struct {
+	ID             string     `json:"id"`
+	Name           string     `json:"name"`
+	OwnerUserID    string     `json:"ownerUserId"`
+	WhatsAppJID    string     `json:"whatsappJid"`
+	WebhookURL     string     `json:"webhookUrl,omitempty"`
+	WebhookSecret  string     `json:"webhookSecret,omitempty"`
+	TokenHash      string     `json:"tokenHash,omitempty"`
+	TokenUpdatedAt *time.Time `json:"tokenUpdatedAt,omitempty"`
+	MetaCompatible bool       `json:"metaCompatible"`
+	// MediaDownload vazio (bundles antigos) usa o modo padrão.
+	MediaDownload model.MediaDownloadMode `json:"mediaDownload,omitempty"`
+}
+
+type bundleProxy struct {
+	Type     model.ProxyType `json:"type"`
+	Host     string          `json:"host"`
+	Port     int             `json:"port"`
+	Username string          `json:"username,omitempty"`
+	Password string          `json:"password,omitempty"`
+}
+
+type ExportOptions struct {
+	Passphrase string
+	// Release desconecta a instância e descarta o device store local sem
+	// logout, para que apenas o servidor de destino use a sessão.
+	Release bool
+}
+
+type ImportOptions struct {
+	Passphrase string
+	// OwnerUserID substitui o dono gravado no bundle, que pode não existir no
+	// servidor de destino.
+	OwnerUserID string
+	// Activate conecta a sessão logo após a importação. A CLI não ativa, pois
+	// a conexão deve ser feita pelo servidor.
+	Activate bool
+}
+
+// ExportSession gera o bundle de migração da instância.
+func (s *Service) ExportSession(ctx context.Context, id string, opts ExportOptions) (SessionBundle, error) {
+	if s.session == nil {
+		return SessionBundle{}, errors.New("session manager não configurado")
+	}
+	if opts.Passphrase == "" {
+		return SessionBundle{}, ErrPassphraseRequired
+	}
+
+	inst, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return SessionBundle{}, err
+	}
+	if !usesSession(inst) {
+		return SessionBundle{}, session.ErrNotSupported
+	}
+
+	if opts.Release {
+		s.session.ReleaseSession(id)
+	}
+	bundle, dump, err := s.buildBundle(ctx, inst, opts.Passphrase)
+	if err != nil {
+		if opts.Release {
+			s.session.ActivateSession(ctx, id)
+		}
+		return SessionBundle{}, err
+	}
+	if !opts.Release {
+		return bundle, nil
+	}
+
+	if err := s.session.DiscardDeviceStore(ctx, id, dump.JID); err != nil {
+		return SessionBundle{}, fmt.Errorf("descartar device store local: %w", err)
+	}
+	inst.WhatsAppJID = ""
+	inst.Status = model.InstanceStatusDisconnected
+	if _, err := s.repo.Update(ctx, inst); err != nil {
+		return SessionBundle{}, err
+	}
+	return bundle, nil
+}
+
+func (s *Service) buildBundle(ctx context.Context, inst model.Instance, passphrase string) (SessionBundle, model.DeviceStoreDump, error) {
+	dump, err := s.session.ExportDeviceStore(ctx, inst.ID)
+	if err != nil {
+		return SessionBundle{}, model.DeviceStoreDump{}, err
+	}
+
+	payload := bundlePayload{
+		Instance: bundleInstance{
+			ID:             inst.ID,
+			Name:           inst.Name,
+			OwnerUserID:    inst.OwnerUserID,
+			WhatsAppJID:    dump.JID,
+			WebhookURL:     inst.WebhookURL,
+			WebhookSecret:  inst.WebhookSecret,
+			TokenHash:      inst.TokenHash,
+			TokenUpdatedAt: inst.TokenUpdatedAt,
+			MetaCompatible: inst.MetaCompatible,
+			MediaDownload:  inst.MediaDownload,
+		},
+		DeviceStore: dump,
+	}
+
+	p, err := s.session.ExportProxy(ctx, inst.ID)
+	if err != nil {
+		return SessionBundle{}, model.DeviceStoreDump{}, err
+	}
+	if p != nil {
+		payload.Proxy = &bundleProxy{Type: p.Type, Host: p.Host, Port: p.Port, Username: p.Username, Password: p.Password}
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return SessionBundle{}, model.DeviceStoreDump{}, err
+	}
+	salt, err := crypto.NewSalt()
+	if err != nil {
+		return SessionBundle{}, model.DeviceStoreDump{}, err
+	}
+
+	bundle := SessionBundle{
+		Format:       SessionBundleFormat,
+		Version:      SessionBundleVersion,
+		InstanceID:   inst.ID,
+		InstanceName: inst.Name,
+		ExportedAt:   time.Now().UTC(),
+		Salt:         salt,
+	}
+	ad, err := bundle.additionalData()
+	if err != nil {
+		return SessionBundle{}, model.DeviceStoreDump{}, err
+	}
+	bundle.Data, err = crypto.EncryptWithPassphrase(data, passphrase, salt, ad)
+	if err != nil {
+		return SessionBundle{}, model.DeviceStoreDump{}, err
+	}
+	return bundle, dump, nil
+}
+
+// ImportSession cria a instância do bundle neste servidor, mantendo ID e
+// token, e grava o device store no backend local. Credenciais do proxy são
+// cifradas de novo com a WHATSAPP_SESSION_KEY_ENC deste servidor.
+func (s *Service) ImportSession(ctx context.Context, bundle SessionBundle, opts ImportOptions) (model.Instance, error) {
+	if s.session == nil {
+		return model.Instance{}, errors.New("session manager não configurado")
+	}
+	if opts.Passphrase == "" {
+		return model.Instance{}, ErrPassphraseRequired
+	}
+	if bundle.Format != SessionBundleFormat || len(bundle.Data) == 0 {
+		return model.Instance{}, ErrInvalidBundle
+	}
+	data, err := decryptBundle(bundle, opts.Passphrase)
+	if err != nil {
+		return model.Instance{}, err
+	}
+	var payload bundlePayload
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return model.Instance{}, fmt.Errorf("%w: %v", ErrInvalidBundle, err)
+	}
+	if payload.Instance.ID != bundle.InstanceID || strings.TrimSpace(payload.Instance.Name) == "" || payload.DeviceStore.JID == "" {
+		return model.Instance{}, ErrInvalidBundle
+	}
+
+	if _, err := s.repo.GetByID(ctx, payload.Instance.ID); err == nil {
+		return model.Instance{}, ErrInstanceAlreadyExists
+	} else if !strings.Contains(err.Error(), "not found") {
+		return model.Instance{}, err
+	}
+
+	owner := payload.Instance.OwnerUserID
+	if opts.OwnerUserID != "" {
+		owner = opts.OwnerUserID
+	}
+
+	created, err := s.repo.Create(ctx, model.Instance{
+		ID:             payload.Instance.ID,
+		Name:           payload.Instance.Name,
+		OwnerUserID:    owner,
+		WhatsAppJID:    payload.DeviceStore.JID,
+		WebhookURL:     payload.Instance.WebhookURL,
+		WebhookSecret:  payload.Instance.WebhookSecret,
+		TokenHash:      payload.Instance.TokenHash,
+		TokenUpdatedAt: payload.Instance.TokenUpdatedAt,
+		MetaCompatible: payload.Instance.MetaCompatible,
+		MediaDownload:  payload.Instance.MediaDownload,
+		Status:         model.InstanceStatusPending,
+	})
+	if err != nil {
+		return model.Instance{}, err
+	}
+
+	if err := s.session.ImportDeviceStore(ctx, created.ID, payload.DeviceStore); err != nil {
+		_ = s.repo.Delete(ctx, created.ID)
+		return model.Instance{}, fmt.Errorf("importar device store: %w", err)
+	}
+
+	if p := payload.Proxy; p != nil {
+		if _, err := s.session.SetProxy(ctx, model.InstanceProxy{
+			InstanceID: created.ID,
+			Type:       p.Type,
+			Host:       p.Host,
+			Port:       p.Port,
+			Username:   p.Username,
+			Password:   p.Password,
+		}); err != nil {
+			_ = s.session.DiscardDeviceStore(ctx, created.ID, payload.DeviceStore.JID)
+			_ = s.repo.Delete(ctx, created.ID)
+			return model.Instance{}, fmt.Errorf("importar proxy: %w", err)
+		}
+	}
+
+	if opts.Activate {
+		s.session.ActivateSession(ctx, created.ID)
+	}
+	return created, nil
+}
+
+func decryptBundle(bundle SessionBundle, passphrase string) ([]byte, error) {
+	if bundle.Version != SessionBundleVersion {
+		return nil, fmt.Errorf("%w: %d", ErrUnsupportedBundle, bundle.Version)
+	}
+	if len(bundle.Salt) < crypto.SaltSize || bundle.InstanceID == "" {
+		return nil, ErrInvalidBundle
+	}
+	ad, err := bundle.additionalData()
+	if err != nil {
+		return nil, err
+	}
+	data, err := crypto.DecryptWithPassphrase(bundle.Data, passphrase, bundle.Salt, ad)
+	if err != nil {
+		return nil, ErrInvalidPassphrase
+	}
+	return data, nil
+}
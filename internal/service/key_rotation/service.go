@@ -0,0 +1,193 @@
+package key_rotation
+
+import (
+	"context"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/open-apime/apime/internal/pkg/crypto"
+	"github.com/open-apime/apime/internal/storage"
+)
+
+// Result resume uma execução da recifragem.
+type Result struct {
+	Instances    int `json:"instances"`
+	SessionBlobs int `json:"sessionBlobs"`
+	Proxies      int `json:"proxies"`
+	CloudAPI     int `json:"cloudApi"`
+	Failed       int `json:"failed"`
+	// Retries conta as trocas de SessionBlob perdidas para uma gravação
+	// concorrente, refeitas a partir do blob relido.
+	Retries int `json:"retries"`
+}
+
+// maxSwapAttempts limita as tentativas de troca do SessionBlob de uma
+// instância que segue sendo gravada por um nó ativo; o que sobrar fica para a
+// próxima execução.
+const maxSwapAttempts = 3
+
+// Service recifra com a chave atual do keyring os campos gravados com chaves
+// anteriores ou no formato legado: SessionBlob das instâncias, credenciais
+// dos proxies e segredos da Cloud API.
+type Service struct {
+	keyring   *crypto.Keyring
+	instances storage.InstanceRepository
+	proxies   storage.InstanceProxyRepository
+	cloudAPI  storage.InstanceCloudAPIRepository
+	log       *zap.Logger
+}
+
+func NewService(keyring *crypto.Keyring, instances storage.InstanceRepository, proxies storage.InstanceProxyRepository, cloudAPI storage.InstanceCloudAPIRepository, log *zap.Logger) *Service {
+	return &Service{keyring: keyring, instances: instances, proxies: proxies, cloudAPI: cloudAPI, log: log}
+}
+
+// Run percorre todas as instâncias. Dados que nenhuma chave do keyring decifra
+// são mantidos como estão e contados em Failed.
+func (s *Service) Run(ctx context.Context) (Result, error) {
+	var res Result
+
+	instances, err := s.instances.List(ctx)
+	if err != nil {
+		return res, err
+	}
+
+	for _, listed := range instances {
+		if err := ctx.Err(); err != nil {
+			return res, err
+		}
+		// List não carrega o SessionBlob.
+		inst, err := s.instances.GetByID(ctx, listed.ID)
+		if err != nil {
+			return res, err
+		}
+		res.Instances++
+
+		if err := s.rotateSessionBlob(ctx, inst.ID, inst.SessionBlob, &res); err != nil {
+			return res, err
+		}
+		if err := s.rotateProxy(ctx, inst.ID, &res); err != nil {
+			return res, err
+		}
+		if err := s.rotateCloudAPI(ctx, inst.ID, &res); err != nil {
+			return res, err
+		}
+	}
+
+	s.log.Info("recifragem com a chave atual concluída",
+		zap.String("key_id", s.keyring.CurrentKeyID()),
+		zap.Int("instances", res.Instances),
+		zap.Int("session_blobs", res.SessionBlobs),
+		zap.Int("proxies", res.Proxies),
+		zap.Int("cloud_api", res.CloudAPI),
+		zap.Int("failed", res.Failed),
+		zap.Int("retries", res.Retries),
+	)
+	return res, nil
+}
+
+// rotateSessionBlob grava o blob recifrado só se o gravado ainda for o lido:
+// a recifragem roda enquanto outros nós salvam sessões, e a escrita não pode
+// desfazer um blob novo. Quando o blob muda no meio, ele é relido e a
+// recifragem, refeita.
+func (s *Service) rotateSessionBlob(ctx context.Context, instanceID string, blob []byte, res *Result) error {
+	for attempt := 0; attempt < maxSwapAttempts; attempt++ {
+		if attempt > 0 {
+			inst, err := s.instances.GetByID(ctx, instanceID)
+			if err != nil {
+				return err
+			}
+			blob = inst.SessionBlob
+		}
+		if len(blob) == 0 || !s.keyring.NeedsRotation(blob) {
+			return nil
+		}
+
+		rotated, err := s.keyring.Reencrypt(blob)
+		if err != nil {
+			res.Failed++
+			s.log.Error("não foi possível recifrar session blob",
+				zap.String("instance_id", instanceID),
+				zap.Error(err),
+			)
+			return nil
+		}
+		swapped, err := s.instances.SwapSessionBlob(ctx, instanceID, blob, rotated)
+		if err != nil {
+			return err
+		}
+		if swapped {
+			res.SessionBlobs++
+			return nil
+		}
+		res.Retries++
+	}
+
+	s.log.Warn("session blob alterado durante a recifragem, fica para a próxima execução",
+		zap.String("instance_id", instanceID),
+		zap.Int("attempts", maxSwapAttempts),
+	)
+	return nil
+}
+
+func (s *Service) rotateProxy(ctx context.Context, instanceID string, res *Result) error {
+	if s.proxies == nil {
+		return nil
+	}
+	p, err := s.proxies.Get(ctx, instanceID)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			return nil
+		}
+		return err
+	}
+	if len(p.CredentialsEnc) == 0 || !s.keyring.NeedsRotation(p.CredentialsEnc) {
+		return nil
+	}
+	creds, err := s.keyring.Reencrypt(p.CredentialsEnc)
+	if err != nil {
+		res.Failed++
+		s.log.Error("não foi possível recifrar credenciais do proxy",
+			zap.String("instance_id", instanceID),
+			zap.Error(err),
+		)
+		return nil
+	}
+	p.CredentialsEnc = creds
+	if err := s.proxies.Upsert(ctx, p); err != nil {
+		return err
+	}
+	res.Proxies++
+	return nil
+}
+
+func (s *Service) rotateCloudAPI(ctx context.Context, instanceID string, res *Result) error {
+	if s.cloudAPI == nil {
+		return nil
+	}
+	cfg, err := s.cloudAPI.Get(ctx, instanceID)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			return nil
+		}
+		return err
+	}
+	if len(cfg.SecretsEnc) == 0 || !s.keyring.NeedsRotation(cfg.SecretsEnc) {
+		return nil
+	}
+	secrets, err := s.keyring.Reencrypt(cfg.SecretsEnc)
+	if err != nil {
+		res.Failed++
+		s.log.Error("não foi possível recifrar credenciais da Cloud API",
+			zap.String("instance_id", instanceID),
+			zap.Error(err),
+		)
+		return nil
+	}
+	cfg.SecretsEnc = secrets
+	if err := s.cloudAPI.Upsert(ctx, cfg); err != nil {
+		return err
+	}
+	res.CloudAPI++
+	return nil
+}
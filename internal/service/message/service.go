@@ -4,59 +4,43 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"math"
-	"math/rand"
 	"mime"
 	"strings"
 	"time"
 
 	"github.com/google/uuid"
-	"go.mau.fi/whatsmeow"
-	"go.mau.fi/whatsmeow/proto/waE2E"
-	"go.mau.fi/whatsmeow/types"
 	"go.uber.org/zap"
-	"google.golang.org/protobuf/proto"
-
-	"sync"
 
+	"github.com/open-apime/apime/internal/pkg/audio"
+	"github.com/open-apime/apime/internal/pkg/mediatype"
 	"github.com/open-apime/apime/internal/pkg/queue"
+	"github.com/open-apime/apime/internal/pkg/thumbnail"
+	"github.com/open-apime/apime/internal/session"
 	"github.com/open-apime/apime/internal/storage"
 	"github.com/open-apime/apime/internal/storage/model"
 )
 
-var (
-	jidCache sync.Map
-)
-
-type jidCacheEntry struct {
-	jid       types.JID
-	expiresAt time.Time
-}
-
 var (
 	ErrInvalidPayload       = errors.New("payload inválido")
-	ErrInstanceNotConnected = errors.New("instância não conectada")
-	ErrInvalidJID           = errors.New("JID inválido")
+	ErrInstanceNotConnected = session.ErrNotConnected
+	ErrInvalidJID           = session.ErrInvalidJID
 	ErrUnsupportedMediaType = errors.New("tipo de mídia não suportado")
+	ErrMessageNotFound      = errors.New("mensagem não encontrada")
+	ErrSendInterrupted      = session.ErrInterrupted
+	ErrInstanceRestoring    = session.ErrRestoring
 )
 
 type Service struct {
 	repo         storage.MessageRepository
-	sessionMgr   SessionManager
+	messengers   *session.Registry
 	instanceRepo storage.InstanceRepository
 	contactRepo  storage.ContactRepository
+	chatRepo     storage.ChatRepository
+	statusRepo   storage.MessageStatusEventRepository
 	queue        queue.Queue
 	log          *zap.Logger
 }
 
-type SessionManager interface {
-	GetClient(instanceID string) (*whatsmeow.Client, error)
-	IsSessionReady(instanceID string) bool
-	HasSession(instanceID string, jid types.JID) (bool, error)
-	GetPreKeyCount(instanceID string) (int, error)
-	GetConnectedAt(instanceID string) time.Time
-}
-
 func NewService(repo storage.MessageRepository, q queue.Queue, log *zap.Logger) *Service {
 	return &Service{
 		repo:  repo,
@@ -65,12 +49,14 @@ func NewService(repo storage.MessageRepository, q queue.Queue, log *zap.Logger)
 	}
 }
 
-func NewServiceWithSession(repo storage.MessageRepository, sessionMgr SessionManager, instanceRepo storage.InstanceRepository, contactRepo storage.ContactRepository, q queue.Queue, log *zap.Logger) *Service {
+func NewServiceWithSession(repo storage.MessageRepository, messengers *session.Registry, instanceRepo storage.InstanceRepository, contactRepo storage.ContactRepository, chatRepo storage.ChatRepository, statusRepo storage.MessageStatusEventRepository, q queue.Queue, log *zap.Logger) *Service {
 	return &Service{
 		repo:         repo,
-		sessionMgr:   sessionMgr,
+		messengers:   messengers,
 		instanceRepo: instanceRepo,
 		contactRepo:  contactRepo,
+		chatRepo:     chatRepo,
+		statusRepo:   statusRepo,
 		queue:        q,
 		log:          log,
 	}
@@ -133,10 +119,25 @@ type SendInput struct {
 	PTT        bool
 	MessageID  string
 	Quoted     string
+	// MediaID referencia uma mídia enviada antes pelo upload avulso; é
+	// usado quando MediaData está vazio.
+	MediaID string
+	// Thumbnail é uma imagem opcional usada como miniatura de vídeos e
+	// documentos. Imagens geram a própria miniatura.
+	Thumbnail []byte
 }
 
+const (
+	defaultListLimit = 50
+	maxListLimit     = 200
+)
+
+// thumbnailSize é o maior lado, em pixels, da miniatura enviada junto com a
+// mídia, exibida pelo destinatário antes do download.
+const thumbnailSize = 96
+
 func (s *Service) Send(ctx context.Context, input SendInput) (model.Message, error) {
-	if s.sessionMgr == nil {
+	if s.messengers == nil {
 		return model.Message{}, errors.New("session manager não configurado")
 	}
 
@@ -144,132 +145,88 @@ func (s *Service) Send(ctx context.Context, input SendInput) (model.Message, err
 		return model.Message{}, ErrInvalidPayload
 	}
 
-	instance, err := s.instanceRepo.GetByID(ctx, input.InstanceID)
-	if err != nil {
-		return model.Message{}, fmt.Errorf("instância não encontrada: %w", err)
+	// O formato da mídia vem do conteúdo, não do tipo informado pelo cliente.
+	// Mídias referenciadas por ID já foram conferidas no upload.
+	if isMediaType(input.Type) && (len(input.MediaData) > 0 || input.MediaID == "") {
+		mimeType, err := mediatype.Validate(input.Type, input.MediaData, input.MediaType, input.PTT)
+		if err != nil {
+			return model.Message{}, err
+		}
+		input.MediaType = mimeType
 	}
 
-	if instance.Status != model.InstanceStatusActive {
-		return model.Message{}, ErrInstanceNotConnected
+	// A duração e a forma de onda vêm do próprio arquivo; o valor informado
+	// pelo cliente só é usado quando o formato não pode ser analisado. A forma
+	// de onda só aparece nas mensagens de voz, mas segue para o provedor em
+	// todo áudio, para ficar guardada com o upload.
+	var waveform []byte
+	if input.Type == "audio" && len(input.MediaData) > 0 {
+		info, err := audio.Analyze(input.MediaData, input.MediaType)
+		if err != nil {
+			s.log.Debug("duração do áudio não calculada", zap.String("mimetype", input.MediaType), zap.Error(err))
+		} else {
+			input.Seconds = info.Seconds()
+			waveform = info.Waveform
+		}
 	}
 
-	client, err := s.sessionMgr.GetClient(input.InstanceID)
+	instance, err := s.instanceRepo.GetByID(ctx, input.InstanceID)
 	if err != nil {
-		ctxUpdate := context.Background()
-		if instToUpdate, fetchErr := s.instanceRepo.GetByID(ctxUpdate, input.InstanceID); fetchErr == nil {
-			instToUpdate.Status = model.InstanceStatusError
-			_, _ = s.instanceRepo.Update(ctxUpdate, instToUpdate)
-		}
-		return model.Message{}, fmt.Errorf("cliente não encontrado: %w", err)
+		return model.Message{}, fmt.Errorf("instância não encontrada: %w", err)
 	}
 
-	if !client.IsLoggedIn() {
-		ctxUpdate := context.Background()
-		if instToUpdate, fetchErr := s.instanceRepo.GetByID(ctxUpdate, input.InstanceID); fetchErr == nil {
-			instToUpdate.Status = model.InstanceStatusError
-			_, _ = s.instanceRepo.Update(ctxUpdate, instToUpdate)
-		}
-		return model.Message{}, ErrInstanceNotConnected
+	messenger, err := s.messengers.Get(instance.Provider)
+	if err != nil {
+		return model.Message{}, err
 	}
 
-	readyStart := time.Now()
-	isReady := false
-	poked := false
-
-	connectedAt := s.sessionMgr.GetConnectedAt(input.InstanceID)
-	isColdStart := time.Since(connectedAt) < 60*time.Second
-	minPreKeys := 5
-	if isColdStart {
-		minPreKeys = 20
-		s.log.Debug("Sessão em Cold Start detectada, aguardando estabilização maior",
-			zap.String("instance_id", input.InstanceID),
-			zap.Duration("since_connection", time.Since(connectedAt)))
+	// Durante a restauração da inicialização, o status gravado ainda não
+	// reflete a sessão.
+	if checker, ok := messenger.(session.RestoreChecker); ok && checker.IsRestoring(input.InstanceID) {
+		return model.Message{}, ErrInstanceRestoring
 	}
 
-	for time.Since(readyStart) < 30*time.Second {
-		preKeyCount, _ := s.sessionMgr.GetPreKeyCount(input.InstanceID)
-
-		if s.sessionMgr.IsSessionReady(input.InstanceID) && preKeyCount >= minPreKeys {
-			isReady = true
-			break
+	// Mídias referenciadas por ID usam o mimetype, a duração e a forma de
+	// onda guardados no upload.
+	if lookup, ok := messenger.(session.UploadLookup); ok && isMediaType(input.Type) &&
+		len(input.MediaData) == 0 && input.MediaID != "" {
+		info, found := lookup.LookupUpload(input.InstanceID, input.MediaID, session.MediaKind(input.Type))
+		if !found {
+			return model.Message{}, fmt.Errorf("%w: id %s", session.ErrMediaExpired, input.MediaID)
 		}
-
-		if time.Since(readyStart) > 2*time.Second && !poked {
-			s.log.Info("Sessão ainda não pronta, enviando presence de ativação...", zap.String("instance_id", input.InstanceID), zap.Int("prekeys", preKeyCount))
-			_ = client.SendPresence(ctx, types.PresenceAvailable)
-			poked = true
+		input.MediaType = info.MimeType
+		if info.Seconds > 0 {
+			input.Seconds = info.Seconds
 		}
-
-		time.Sleep(1 * time.Second)
+		waveform = info.Waveform
 	}
 
-	if !isReady {
-		return model.Message{}, fmt.Errorf("sessão indisponível para criptografia (pode levar alguns instantes após conectar), tente novamente")
+	if instance.Status != model.InstanceStatusActive {
+		return model.Message{}, ErrInstanceNotConnected
 	}
 
-	if isColdStart {
-		timeSinceConnect := time.Since(connectedAt)
-		safeThreshold := 60 * time.Second
-
-		if timeSinceConnect < safeThreshold {
-			remainingWait := safeThreshold - timeSinceConnect
-			jitter := time.Duration(rand.Intn(5000)) * time.Millisecond
-			finalWait := remainingWait + jitter
-
-			s.log.Info("Estabilizando sessão...",
-				zap.String("instance_id", input.InstanceID),
-				zap.Duration("connected_for", timeSinceConnect),
-				zap.Duration("wait_time", finalWait))
-
-			time.Sleep(finalWait)
+	if err := messenger.Ready(ctx, input.InstanceID); err != nil {
+		if ctx.Err() != nil {
+			return model.Message{}, interrupted(ctx, err)
+		}
+		if errors.Is(err, session.ErrNotConnected) {
+			ctxUpdate := context.Background()
+			if instToUpdate, fetchErr := s.instanceRepo.GetByID(ctxUpdate, input.InstanceID); fetchErr == nil {
+				instToUpdate.Status = model.InstanceStatusError
+				_, _ = s.instanceRepo.Update(ctxUpdate, instToUpdate)
+			}
 		}
+		return model.Message{}, err
 	}
 
-	time.Sleep(1500 * time.Millisecond)
-
-	toJID, err := s.resolveJID(ctx, client, input.To)
+	toJID, err := messenger.ResolveJID(ctx, input.InstanceID, input.To)
 	if err != nil {
-		return model.Message{}, fmt.Errorf("%w: %s", ErrInvalidJID, input.To)
-	}
-
-	if toJID.Server == types.DefaultUserServer || toJID.Server == types.HiddenUserServer {
-		hasSession, err := s.sessionMgr.HasSession(input.InstanceID, toJID)
-
-		_ = client.SendPresence(ctx, types.PresenceAvailable)
-
-		_ = client.SendChatPresence(ctx, toJID, types.ChatPresenceComposing, types.ChatPresenceMediaText)
-
-		s.log.Debug("buscando dispositivos do destinatário antes do envio",
-			zap.String("instance_id", input.InstanceID),
-			zap.String("to", toJID.String()))
-
-		devices, devErr := client.GetUserDevices(ctx, []types.JID{toJID})
-		if devErr != nil {
-			s.log.Warn("erro ao buscar dispositivos do destinatário",
-				zap.String("to", toJID.String()),
-				zap.Error(devErr))
-		} else {
-			s.log.Debug("dispositivos do destinatário atualizados",
-				zap.String("to", toJID.String()),
-				zap.Int("device_count", len(devices)))
-
-			time.Sleep(800 * time.Millisecond)
-		}
-
-		if err == nil && !hasSession {
-			s.log.Info("Nova sessão detectada...",
-				zap.String("instance_id", input.InstanceID),
-				zap.String("to", toJID.String()))
-
-			wait := 1500 + rand.Intn(1500)
-			time.Sleep(time.Duration(wait) * time.Millisecond)
-		} else {
-			wait := 500 + rand.Intn(700)
-			time.Sleep(time.Duration(wait) * time.Millisecond)
+		if ctx.Err() != nil {
+			return model.Message{}, interrupted(ctx, err)
 		}
+		return model.Message{}, fmt.Errorf("%w: %s", ErrInvalidJID, input.To)
 	}
 
-	var waMessage *waE2E.Message
 	var messageType string
 	var payload string
 
@@ -278,166 +235,28 @@ func (s *Service) Send(ctx context.Context, input SendInput) (model.Message, err
 		if input.Text == "" {
 			return model.Message{}, ErrInvalidPayload
 		}
-		if input.Quoted != "" {
-			waMessage = &waE2E.Message{
-				ExtendedTextMessage: &waE2E.ExtendedTextMessage{
-					Text: proto.String(input.Text),
-					ContextInfo: &waE2E.ContextInfo{
-						StanzaID: proto.String(input.Quoted),
-					},
-				},
-			}
-		} else {
-			waMessage = &waE2E.Message{
-				Conversation: proto.String(input.Text),
-			}
-		}
 		messageType = "text"
 		payload = input.Text
 
 	case "image", "video":
-		if len(input.MediaData) == 0 {
+		if len(input.MediaData) == 0 && input.MediaID == "" {
 			return model.Message{}, ErrInvalidPayload
 		}
-
-		var mediaType whatsmeow.MediaType
-		if input.Type == "image" {
-			mediaType = whatsmeow.MediaImage
-		} else {
-			mediaType = whatsmeow.MediaVideo
-		}
-
-		uploadResp, err := client.Upload(ctx, input.MediaData, mediaType)
-		if err != nil {
-			return model.Message{}, fmt.Errorf("erro ao fazer upload da mídia: %w", err)
-		}
-
-		if input.Type == "image" {
-			imageMsg := &waE2E.ImageMessage{
-				URL:           &uploadResp.URL,
-				DirectPath:    &uploadResp.DirectPath,
-				MediaKey:      uploadResp.MediaKey,
-				FileEncSHA256: uploadResp.FileEncSHA256,
-				FileSHA256:    uploadResp.FileSHA256,
-				FileLength:    &uploadResp.FileLength,
-				Mimetype:      proto.String(input.MediaType),
-			}
-			if input.Caption != "" {
-				imageMsg.Caption = proto.String(input.Caption)
-			}
-			if input.Quoted != "" {
-				imageMsg.ContextInfo = &waE2E.ContextInfo{
-					StanzaID: proto.String(input.Quoted),
-				}
-			}
-			waMessage = &waE2E.Message{
-				ImageMessage: imageMsg,
-			}
-		} else {
-			videoMsg := &waE2E.VideoMessage{
-				URL:           &uploadResp.URL,
-				DirectPath:    &uploadResp.DirectPath,
-				MediaKey:      uploadResp.MediaKey,
-				FileEncSHA256: uploadResp.FileEncSHA256,
-				FileSHA256:    uploadResp.FileSHA256,
-				FileLength:    &uploadResp.FileLength,
-				Mimetype:      proto.String(input.MediaType),
-			}
-			if input.Caption != "" {
-				videoMsg.Caption = proto.String(input.Caption)
-			}
-			if input.Quoted != "" {
-				videoMsg.ContextInfo = &waE2E.ContextInfo{
-					StanzaID: proto.String(input.Quoted),
-				}
-			}
-			waMessage = &waE2E.Message{
-				VideoMessage: videoMsg,
-			}
-		}
 		messageType = input.Type
 		payload = fmt.Sprintf("media:%s", input.MediaType)
 
 	case "audio":
-		if len(input.MediaData) == 0 {
+		if len(input.MediaData) == 0 && input.MediaID == "" {
 			return model.Message{}, ErrInvalidPayload
 		}
-
-		uploadResp, err := client.Upload(ctx, input.MediaData, whatsmeow.MediaAudio)
-		if err != nil {
-			return model.Message{}, fmt.Errorf("erro ao fazer upload do áudio: %w", err)
-		}
-		isPTT := input.PTT
-
-		var waveform []byte
-		var sidecar []byte
-
-		if isPTT {
-			waveform = make([]byte, 64)
-			for i := 0; i < 64; i++ {
-				distFromCenter := math.Abs(float64(i) - 31.5)
-				normalizedDist := distFromCenter / 32.0
-
-				envelope := 1.0 - (normalizedDist * normalizedDist)
-				if envelope < 0 {
-					envelope = 0
-				}
-
-				baseHeight := envelope * 50.0
-				noise := float64(rand.Intn(40))
-
-				finalVal := baseHeight + noise
-				if finalVal > 255 {
-					finalVal = 255
-				}
-
-				waveform[i] = byte(finalVal)
-			}
-
-			sidecar = make([]byte, 16)
-		}
-
-		finalMimeType := input.MediaType
-		if isPTT && strings.Contains(input.MediaType, "audio/ogg") {
-			finalMimeType = "audio/ogg; codecs=opus"
-		}
-
-		audioMsg := &waE2E.AudioMessage{
-			URL:           &uploadResp.URL,
-			DirectPath:    &uploadResp.DirectPath,
-			MediaKey:      uploadResp.MediaKey,
-			FileEncSHA256: uploadResp.FileEncSHA256,
-			FileSHA256:    uploadResp.FileSHA256,
-			FileLength:    &uploadResp.FileLength,
-			Mimetype:      proto.String(finalMimeType),
-			ContextInfo: &waE2E.ContextInfo{
-				Expiration: proto.Uint32(0),
-			},
-			PTT:               proto.Bool(isPTT),
-			Seconds:           proto.Uint32(uint32(input.Seconds)),
-			Waveform:          waveform,
-			StreamingSidecar:  sidecar,
-			MediaKeyTimestamp: proto.Int64(time.Now().Unix()),
-		}
-		if input.Quoted != "" {
-			audioMsg.ContextInfo.StanzaID = proto.String(input.Quoted)
-		}
-		waMessage = &waE2E.Message{
-			AudioMessage: audioMsg,
-		}
 		messageType = "audio"
 		payload = fmt.Sprintf("audio:%s", input.MediaType)
 
 	case "document":
-		if len(input.MediaData) == 0 {
+		if len(input.MediaData) == 0 && input.MediaID == "" {
 			return model.Message{}, ErrInvalidPayload
 		}
 
-		uploadResp, err := client.Upload(ctx, input.MediaData, whatsmeow.MediaDocument)
-		if err != nil {
-			return model.Message{}, fmt.Errorf("erro ao fazer upload do documento: %w", err)
-		}
-
 		fileName := input.FileName
 		if fileName == "" {
 			exts, _ := mime.ExtensionsByType(input.MediaType)
@@ -447,28 +266,7 @@ func (s *Service) Send(ctx context.Context, input SendInput) (model.Message, err
 				fileName = "document"
 			}
 		}
-
-		docMsg := &waE2E.DocumentMessage{
-			URL:           &uploadResp.URL,
-			DirectPath:    &uploadResp.DirectPath,
-			MediaKey:      uploadResp.MediaKey,
-			FileEncSHA256: uploadResp.FileEncSHA256,
-			FileSHA256:    uploadResp.FileSHA256,
-			FileLength:    &uploadResp.FileLength,
-			Mimetype:      proto.String(input.MediaType),
-			FileName:      proto.String(fileName),
-		}
-		if input.Caption != "" {
-			docMsg.Caption = proto.String(input.Caption)
-		}
-		if input.Quoted != "" {
-			docMsg.ContextInfo = &waE2E.ContextInfo{
-				StanzaID: proto.String(input.Quoted),
-			}
-		}
-		waMessage = &waE2E.Message{
-			DocumentMessage: docMsg,
-		}
+		input.FileName = fileName
 		messageType = "document"
 		payload = fmt.Sprintf("document:%s:%s", fileName, input.MediaType)
 
@@ -476,10 +274,20 @@ func (s *Service) Send(ctx context.Context, input SendInput) (model.Message, err
 		return model.Message{}, fmt.Errorf("%w: %s", ErrUnsupportedMediaType, input.Type)
 	}
 
+	if err := messenger.Prepare(ctx, input.InstanceID, toJID); err != nil {
+		return model.Message{}, interrupted(ctx, err)
+	}
+
+	// Daqui em diante a mensagem é gravada e entregue ao provedor: o
+	// cancelamento (ex.: encerramento do nó) não pode interromper o envio
+	// nem deixar o registro em "sending".
+	ctx = context.WithoutCancel(ctx)
+
 	var msg model.Message
 	if input.MessageID != "" {
 		msg.ID = input.MessageID
 		msg.InstanceID = input.InstanceID
+		msg.ChatJID = toJID.String()
 		msg.To = input.To
 		msg.Type = messageType
 		msg.Payload = payload
@@ -497,6 +305,7 @@ func (s *Service) Send(ctx context.Context, input SendInput) (model.Message, err
 		message := model.Message{
 			ID:         uuid.NewString(),
 			InstanceID: input.InstanceID,
+			ChatJID:    toJID.String(),
 			To:         input.To,
 			Type:       messageType,
 			Payload:    payload,
@@ -508,184 +317,104 @@ func (s *Service) Send(ctx context.Context, input SendInput) (model.Message, err
 		}
 	}
 
-	var resp whatsmeow.SendResponse
-	maxRetries := 3
-
-	for attempt := 0; attempt <= maxRetries; attempt++ {
-		if attempt > 0 {
-			backoff := time.Duration(math.Pow(2, float64(attempt))) * time.Second
-			s.log.Info("tentando reenvio de mensagem devido a erro anterior",
-				zap.Int("attempt", attempt),
-				zap.Duration("backoff", backoff),
-				zap.String("to", toJID.String()))
-			time.Sleep(backoff)
-
-			_ = client.SendPresence(ctx, types.PresenceAvailable)
-			_ = client.SendChatPresence(ctx, toJID, types.ChatPresenceComposing, types.ChatPresenceMediaText)
-
-			// Recalcular dispositivos no retry caso tenha sido um erro de criptografia
-			_, _ = client.GetUserDevices(ctx, []types.JID{toJID})
-		}
-
-		resp, err = client.SendMessage(ctx, toJID, waMessage)
-		if err == nil {
-			s.log.Info("mensagem enviada com sucesso",
-				zap.Int("attempt", attempt),
-				zap.String("to", toJID.String()),
-				zap.String("server_id", resp.ID),
-				zap.Int64("timestamp", resp.Timestamp.Unix()))
-			break
-		}
-
-		s.log.Warn("falha no envio da mensagem",
-			zap.Int("attempt", attempt),
-			zap.Error(err),
-			zap.String("to", toJID.String()))
-
-		if strings.Contains(err.Error(), "untrusted identity") {
-			s.log.Warn("erro de identidade não confiável detectado, limpando identidade e tentando novamente",
-				zap.String("to", toJID.String()))
-			client.Store.Identities.DeleteIdentity(ctx, toJID.SignalAddress().String())
-			continue
-		}
-
-		// Tratar Cold Start de Criptografia: no signal session
-		if strings.Contains(err.Error(), "no signal session") {
-			s.log.Warn("sessão de criptografia não estabelecida (cold start), tentando warmup e reenvio",
-				zap.String("to", toJID.String()))
-			_, _ = client.GetUserDevices(ctx, []types.JID{toJID})
-			continue
-		}
-
-		if strings.Contains(err.Error(), "not logged in") {
-			break
-		}
-	}
-
-	// Limpar o status "digitando" após o envio (sucesso ou falha final)
-	_ = client.SendChatPresence(ctx, toJID, types.ChatPresencePaused, types.ChatPresenceMediaText)
-
+	sent, err := messenger.Send(ctx, input.InstanceID, toJID, session.OutgoingMessage{
+		Type:      messageType,
+		Text:      input.Text,
+		Media:     input.MediaData,
+		MediaID:   input.MediaID,
+		MimeType:  input.MediaType,
+		Caption:   input.Caption,
+		FileName:  input.FileName,
+		Seconds:   input.Seconds,
+		PTT:       input.PTT,
+		Quoted:    input.Quoted,
+		Thumbnail: s.thumbnail(input),
+		Waveform:  waveform,
+	})
 	if err != nil {
-		jidCache.Delete(input.To)
-		s.log.Info("Removido do cache de JID devido a erro de envio", zap.String("phone", input.To))
-
 		msg.Status = "failed"
 		_ = s.repo.Update(ctx, msg)
-		return msg, fmt.Errorf("erro ao enviar mensagem após %d tentativas: %w", maxRetries, err)
+		return msg, err
 	}
 
 	msg.Status = "sent"
-	msg.WhatsAppID = resp.ID
+	msg.WhatsAppID = sent.ID
 	if err := s.repo.Update(ctx, msg); err != nil {
 		s.log.Warn("erro ao atualizar status enviado no banco", zap.Error(err))
 	}
 
-	_ = client.SendChatPresence(ctx, toJID, types.ChatPresencePaused, types.ChatPresenceMediaText)
+	if s.chatRepo != nil {
+		last := msg
+		if last.CreatedAt.IsZero() {
+			last.CreatedAt = time.Now()
+		}
+		if err := s.chatRepo.UpsertLastMessage(ctx, msg.InstanceID, msg.ChatJID, "", last, 0); err != nil {
+			s.log.Warn("erro ao atualizar conversa após envio", zap.Error(err))
+		}
+	}
 
 	return msg, nil
 }
 
-func (s *Service) List(ctx context.Context, instanceID string) ([]model.Message, error) {
-	return s.repo.ListByInstance(ctx, instanceID)
+// interrupted marca como ErrSendInterrupted o erro causado pelo
+// cancelamento do contexto antes da chamada ao provedor, quando a mensagem
+// ainda pode voltar à fila sem risco de envio duplicado.
+func interrupted(ctx context.Context, err error) error {
+	if ctx.Err() == nil || errors.Is(err, ErrSendInterrupted) {
+		return err
+	}
+	return fmt.Errorf("%w: %v", ErrSendInterrupted, err)
 }
 
-func (s *Service) resolveJID(ctx context.Context, client *whatsmeow.Client, phone string) (types.JID, error) {
-	phone = strings.TrimSpace(phone)
-
-	if phone == "" {
-		return types.EmptyJID, errors.New("telefone vazio")
+// isMediaType indica se o tipo de mensagem leva mídia.
+func isMediaType(messageType string) bool {
+	switch messageType {
+	case "image", "video", "audio", "document":
+		return true
 	}
+	return false
+}
 
-	if strings.Contains(phone, "@g.us") || strings.Contains(phone, "@broadcast") {
-		return types.ParseJID(phone)
+// thumbnail gera a miniatura JPEG da mídia enviada: a partir da própria
+// imagem ou da miniatura informada pelo cliente. Sem uma imagem decodificável,
+// a mensagem segue sem miniatura.
+func (s *Service) thumbnail(input SendInput) []byte {
+	if input.Type != "image" && input.Type != "video" && input.Type != "document" {
+		return nil
 	}
 
-	if !strings.Contains(phone, "@") {
-		phone = strings.Map(func(r rune) rune {
-			if r >= '0' && r <= '9' {
-				return r
-			}
-			return -1
-		}, phone)
+	source := input.Thumbnail
+	if len(source) == 0 && (input.Type == "image" || strings.HasPrefix(input.MediaType, "image/")) {
+		source = input.MediaData
 	}
-
-	phone = strings.TrimSuffix(phone, "@s.whatsapp.net")
-
-	if val, ok := jidCache.Load(phone); ok {
-		entry := val.(jidCacheEntry)
-		if time.Now().Before(entry.expiresAt) {
-			if entry.jid.IsEmpty() {
-				s.log.Debug("JID negativo (não está no WhatsApp) resolvido via cache", zap.String("phone", phone))
-				return types.EmptyJID, fmt.Errorf("%w: número não registrado no WhatsApp (cache)", ErrInvalidJID)
-			}
-			s.log.Debug("JID resolvido via cache em memória", zap.String("phone", phone), zap.String("jid", entry.jid.String()))
-			return entry.jid, nil
-		}
-		jidCache.Delete(phone)
+	if len(source) == 0 {
+		return nil
 	}
 
-	if s.contactRepo != nil {
-		if contact, err := s.contactRepo.GetByPhone(ctx, phone); err == nil {
-			jid, jerr := types.ParseJID(contact.JID)
-			if jerr == nil {
-				s.log.Debug("JID resolvido via banco de dados", zap.String("phone", phone), zap.String("jid", jid.String()))
-				jidCache.Store(phone, jidCacheEntry{jid: jid, expiresAt: time.Now().Add(24 * time.Hour)})
-				return jid, nil
-			}
-		}
+	thumb, err := thumbnail.JPEG(source, thumbnailSize)
+	if err != nil {
+		s.log.Debug("miniatura não gerada", zap.String("type", input.Type), zap.Error(err))
+		return nil
 	}
+	return thumb
+}
 
-	if !strings.HasPrefix(phone, "55") {
-		// Se não for BR, apenas tenta parsear sem validar (ou você pode validar se preferir)
-		return types.ParseJID(phone + "@s.whatsapp.net")
+// List pagina as mensagens da instância, das mais recentes para as mais
+// antigas.
+func (s *Service) List(ctx context.Context, instanceID string, before *time.Time, limit int) ([]model.Message, error) {
+	if limit <= 0 {
+		limit = defaultListLimit
 	}
-
-	// Simulando comportamento humano com delay aleatório antes da consulta real
-	// Isso evita padrões robóticos de consulta rápida
-	delay := 1000 + rand.Intn(2000) // 1s a 3s
-	s.log.Debug("Aplicando delay de segurança antes de IsOnWhatsApp", zap.Int("ms", delay))
-	time.Sleep(time.Duration(delay) * time.Millisecond)
-
-	candidates := []string{phone}
-
-	if len(phone) == 13 {
-		optionWithout9 := phone[:4] + phone[5:]
-		candidates = append(candidates, optionWithout9)
-	} else if len(phone) == 12 {
-		optionWith9 := phone[:4] + "9" + phone[4:]
-		candidates = append(candidates, optionWith9)
+	if limit > maxListLimit {
+		limit = maxListLimit
 	}
 
-	s.log.Debug("Candidatos gerados para validação", zap.String("original", phone), zap.Strings("candidates", candidates))
-
-	resp, err := client.IsOnWhatsApp(ctx, candidates)
+	messages, err := s.repo.ListByInstance(ctx, instanceID, before, limit)
 	if err != nil {
-		s.log.Warn("falha ao consultar IsOnWhatsApp, enviando original", zap.String("phone", phone), zap.Error(err))
-		return types.ParseJID(phone + "@s.whatsapp.net")
+		return nil, err
 	}
-
-	resolvedJID := types.EmptyJID
-	for _, item := range resp {
-		if item.JID.User != "" {
-			resolvedJID = item.JID
-			break
-		}
+	if messages == nil {
+		messages = []model.Message{}
 	}
-
-	if resolvedJID.IsEmpty() {
-		s.log.Warn("WhatsApp não encontrado - registrando em cache negativo por 24h", zap.String("original_phone", phone), zap.Any("candidates", candidates))
-		// Cache Negativo: Evita reconsultar números que sabemos que não existem
-		jidCache.Store(phone, jidCacheEntry{jid: types.EmptyJID, expiresAt: time.Now().Add(24 * time.Hour)})
-		return types.EmptyJID, fmt.Errorf("%w: número não registrado no WhatsApp", ErrInvalidJID)
-	}
-
-	jidCache.Store(phone, jidCacheEntry{jid: resolvedJID, expiresAt: time.Now().Add(24 * time.Hour)})
-	if s.contactRepo != nil {
-		_ = s.contactRepo.Upsert(ctx, model.Contact{
-			Phone: phone,
-			JID:   resolvedJID.String(),
-		})
-	}
-
-	return resolvedJID, nil
+	return messages, nil
 }
@@ -0,0 +1,96 @@
+package message
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/open-apime/apime/internal/storage/model"
+)
+
+// RecipientStatus resume as confirmações de um destinatário. Em grupos há um
+// item por participante que confirmou a mensagem.
+type RecipientStatus struct {
+	Participant string     `json:"participant,omitempty"`
+	DeliveredAt *time.Time `json:"deliveredAt,omitempty"`
+	ReadAt      *time.Time `json:"readAt,omitempty"`
+	PlayedAt    *time.Time `json:"playedAt,omitempty"`
+}
+
+// StatusTimeline reúne o status atual da mensagem e todas as confirmações recebidas.
+type StatusTimeline struct {
+	MessageID  string                     `json:"messageId"`
+	Status     string                     `json:"status,omitempty"`
+	Message    *model.Message             `json:"message,omitempty"`
+	Recipients []RecipientStatus          `json:"recipients"`
+	Events     []model.MessageStatusEvent `json:"events"`
+}
+
+// GetStatusTimeline retorna o histórico de confirmações de uma mensagem,
+// aceitando tanto o ID interno quanto o ID do WhatsApp.
+func (s *Service) GetStatusTimeline(ctx context.Context, instanceID, messageID string) (StatusTimeline, error) {
+	if s.statusRepo == nil {
+		return StatusTimeline{}, errors.New("histórico de status não disponível")
+	}
+
+	timeline := StatusTimeline{MessageID: messageID}
+
+	msg, err := s.repo.GetByInstanceAndID(ctx, instanceID, messageID)
+	switch {
+	case err == nil:
+		timeline.Message = &msg
+		timeline.Status = msg.Status
+		if msg.WhatsAppID != "" {
+			timeline.MessageID = msg.WhatsAppID
+		}
+	case err.Error() != "not found":
+		return StatusTimeline{}, err
+	}
+
+	events, err := s.statusRepo.ListByMessage(ctx, instanceID, timeline.MessageID)
+	if err != nil {
+		return StatusTimeline{}, err
+	}
+	if timeline.Message == nil && len(events) == 0 {
+		return StatusTimeline{}, ErrMessageNotFound
+	}
+	if events == nil {
+		events = []model.MessageStatusEvent{}
+	}
+	timeline.Events = events
+	timeline.Recipients = summarizeRecipients(events)
+
+	if timeline.Status == "" && len(events) > 0 {
+		timeline.Status = events[len(events)-1].Status
+	}
+
+	return timeline, nil
+}
+
+// summarizeRecipients agrupa os eventos por participante, mantendo a ordem
+// da primeira confirmação de cada um.
+func summarizeRecipients(events []model.MessageStatusEvent) []RecipientStatus {
+	recipients := []RecipientStatus{}
+	index := make(map[string]int)
+
+	for _, event := range events {
+		i, ok := index[event.Participant]
+		if !ok {
+			i = len(recipients)
+			index[event.Participant] = i
+			recipients = append(recipients, RecipientStatus{Participant: event.Participant})
+		}
+
+		at := event.OccurredAt
+		switch event.Status {
+		case "delivered":
+			recipients[i].DeliveredAt = &at
+		case "read":
+			recipients[i].ReadAt = &at
+		case "played":
+			recipients[i].PlayedAt = &at
+		}
+	}
+
+	return recipients
+}
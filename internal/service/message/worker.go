@@ -2,6 +2,7 @@ package message
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sync"
 	"time"
@@ -15,9 +16,12 @@ type OutboxWorker struct {
 	queue      queue.Queue
 	log        *zap.Logger
 	numWorkers int
+	owns       func(instanceID string) bool
 	wg         sync.WaitGroup
 	ctx        context.Context
 	cancel     context.CancelFunc
+	sendCtx    context.Context
+	sendCancel context.CancelFunc
 }
 
 func NewOutboxWorker(service *Service, q queue.Queue, log *zap.Logger, numWorkers int) *OutboxWorker {
@@ -32,8 +36,17 @@ func NewOutboxWorker(service *Service, q queue.Queue, log *zap.Logger, numWorker
 	}
 }
 
+// SetOwnershipChecker faz o worker processar apenas mensagens de instâncias
+// conectadas neste nó. As demais voltam para a fila compartilhada.
+func (w *OutboxWorker) SetOwnershipChecker(fn func(instanceID string) bool) {
+	w.owns = fn
+}
+
 func (w *OutboxWorker) Start(ctx context.Context) {
 	w.ctx, w.cancel = context.WithCancel(ctx)
+	// Os envios têm contexto próprio para que o dreno possa parar de consumir
+	// a fila sem abortar as mensagens que já estão em andamento.
+	w.sendCtx, w.sendCancel = context.WithCancel(ctx)
 	w.log.Info("outbox worker: iniciando", zap.Int("workers", w.numWorkers))
 
 	for i := 0; i < w.numWorkers; i++ {
@@ -49,10 +62,39 @@ func (w *OutboxWorker) Stop() {
 	w.log.Info("outbox worker: encerrando")
 	if w.cancel != nil {
 		w.cancel()
+		w.sendCancel()
 	}
 	w.wg.Wait()
 }
 
+// Drain para de consumir a fila e aguarda os envios em andamento até o prazo
+// de ctx. No prazo, os envios que ainda não chegaram ao provedor são
+// interrompidos e devolvidos à fila, para serem retomados por outro nó ou na
+// próxima inicialização; os que já chegaram terminam normalmente.
+func (w *OutboxWorker) Drain(ctx context.Context) {
+	if w.cancel == nil {
+		return
+	}
+	w.log.Info("outbox worker: drenando envios em andamento")
+	w.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		w.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		w.log.Info("outbox worker: envios em andamento concluídos")
+	case <-ctx.Done():
+		w.log.Warn("outbox worker: prazo de dreno esgotado, interrompendo envios")
+		w.sendCancel()
+		<-done
+	}
+	w.sendCancel()
+}
+
 func (w *OutboxWorker) runWorker(id int) {
 	defer w.wg.Done()
 	prefix := fmt.Sprintf("[outbox-worker %d]", id)
@@ -66,6 +108,9 @@ func (w *OutboxWorker) runWorker(id int) {
 		default:
 			event, err := w.queue.Dequeue(w.ctx, 1*time.Second)
 			if err != nil {
+				if w.ctx.Err() != nil {
+					continue
+				}
 				w.log.Error(prefix+": erro ao desenfileirar", zap.Error(err))
 				continue
 			}
@@ -80,6 +125,13 @@ func (w *OutboxWorker) runWorker(id int) {
 }
 
 func (w *OutboxWorker) processEvent(prefix string, event *queue.Event) {
+	if w.owns != nil && !w.owns(event.InstanceID) {
+		w.requeue(prefix, event)
+		// Evita girar em falso enquanto só houver mensagens de outros nós
+		time.Sleep(200 * time.Millisecond)
+		return
+	}
+
 	w.log.Info(prefix+": processando mensagem da fila",
 		zap.String("id", event.ID),
 		zap.String("instance_id", event.InstanceID))
@@ -96,7 +148,25 @@ func (w *OutboxWorker) processEvent(prefix string, event *queue.Event) {
 	}
 
 	// Aqui usamos o service.Send que já tem o loop de retentativa e o AUTO-TRUST
-	_, err := w.service.Send(w.ctx, input)
+	// O encerramento só devolve à fila envios interrompidos antes da chamada
+	// ao provedor; depois dela, a mensagem pode já ter sido aceita pelo
+	// WhatsApp e o reenvio a duplicaria.
+	_, err := w.service.Send(w.sendCtx, input)
+	if errors.Is(err, ErrSendInterrupted) {
+		w.log.Warn(prefix+": envio interrompido pelo encerramento, devolvendo à fila",
+			zap.String("id", event.ID))
+		w.requeue(prefix, event)
+		return
+	}
+	if errors.Is(err, ErrInstanceRestoring) {
+		w.log.Info(prefix+": sessão em restauração, devolvendo à fila",
+			zap.String("id", event.ID),
+			zap.String("instance_id", event.InstanceID))
+		w.requeue(prefix, event)
+		// Evita girar em falso até a vez da instância na restauração
+		time.Sleep(time.Second)
+		return
+	}
 	if err != nil {
 		w.log.Error(prefix+": falha final ao enviar mensagem",
 			zap.String("id", event.ID),
@@ -104,6 +174,19 @@ func (w *OutboxWorker) processEvent(prefix string, event *queue.Event) {
 	}
 }
 
+// requeue devolve o evento à fila. Usa um contexto próprio porque também é
+// chamado durante o encerramento, quando o contexto do worker já foi cancelado.
+func (w *OutboxWorker) requeue(prefix string, event *queue.Event) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := w.queue.Enqueue(ctx, *event); err != nil {
+		w.log.Error(prefix+": erro ao devolver mensagem à fila",
+			zap.String("id", event.ID),
+			zap.String("instance_id", event.InstanceID),
+			zap.Error(err))
+	}
+}
+
 func (w *OutboxWorker) runStuckRecovery() {
 	defer w.wg.Done()
 	ticker := time.NewTicker(30 * time.Second)
@@ -126,6 +209,9 @@ func (w *OutboxWorker) runStuckRecovery() {
 
 			w.log.Info("outbox recovery: recuperando mensagens pendentes do banco", zap.Int("count", len(messages)))
 			for _, msg := range messages {
+				if w.owns != nil && !w.owns(msg.InstanceID) {
+					continue
+				}
 				event := queue.Event{
 					ID:         msg.ID,
 					InstanceID: msg.InstanceID,
@@ -0,0 +1,225 @@
+package cloudapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+// maxMediaSize é o maior arquivo aceito pela Cloud API (documentos, 100 MB).
+const maxMediaSize = 100 << 20
+
+// APIError é o erro retornado pela Graph API.
+type APIError struct {
+	Status    int
+	Code      int    `json:"code"`
+	Subcode   int    `json:"error_subcode"`
+	Type      string `json:"type"`
+	Message   string `json:"message"`
+	FBTraceID string `json:"fbtrace_id"`
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("cloudapi: status %d, código %d: %s", e.Status, e.Code, e.Message)
+}
+
+// PhoneNumberInfo descreve o número configurado na conta do WhatsApp Business.
+type PhoneNumberInfo struct {
+	ID                 string `json:"id"`
+	DisplayPhoneNumber string `json:"display_phone_number"`
+	VerifiedName       string `json:"verified_name"`
+}
+
+// Client fala com a Graph API. Cada chamada recebe o access token da
+// instância, então um único Client atende todos os números.
+type Client struct {
+	http    *http.Client
+	baseURL string
+	version string
+}
+
+func NewClient(baseURL, version string, timeout time.Duration) *Client {
+	return &Client{
+		http:    &http.Client{Timeout: timeout},
+		baseURL: strings.TrimRight(baseURL, "/"),
+		version: version,
+	}
+}
+
+func (c *Client) url(path string) string {
+	return fmt.Sprintf("%s/%s/%s", c.baseURL, c.version, strings.TrimLeft(path, "/"))
+}
+
+// SendMessage envia o corpo de /messages e retorna o ID (wamid) da mensagem.
+func (c *Client) SendMessage(ctx context.Context, token, phoneNumberID string, body map[string]any) (string, error) {
+	body["messaging_product"] = "whatsapp"
+
+	var resp struct {
+		Messages []struct {
+			ID string `json:"id"`
+		} `json:"messages"`
+	}
+	if err := c.doJSON(ctx, http.MethodPost, c.url(phoneNumberID+"/messages"), token, body, &resp); err != nil {
+		return "", err
+	}
+	if len(resp.Messages) == 0 {
+		return "", fmt.Errorf("cloudapi: resposta sem ID da mensagem")
+	}
+	return resp.Messages[0].ID, nil
+}
+
+// MarkRead marca a mensagem recebida como lida.
+func (c *Client) MarkRead(ctx context.Context, token, phoneNumberID, messageID string) error {
+	body := map[string]any{
+		"messaging_product": "whatsapp",
+		"status":            "read",
+		"message_id":        messageID,
+	}
+	return c.doJSON(ctx, http.MethodPost, c.url(phoneNumberID+"/messages"), token, body, nil)
+}
+
+// UploadMedia envia o arquivo para /media e retorna o ID usado no envio.
+func (c *Client) UploadMedia(ctx context.Context, token, phoneNumberID string, data []byte, mimeType string) (string, error) {
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	if err := w.WriteField("messaging_product", "whatsapp"); err != nil {
+		return "", err
+	}
+	if err := w.WriteField("type", mimeType); err != nil {
+		return "", err
+	}
+	h := make(textproto.MIMEHeader)
+	h.Set("Content-Disposition", `form-data; name="file"; filename="file"`)
+	h.Set("Content-Type", mimeType)
+	part, err := w.CreatePart(h)
+	if err != nil {
+		return "", err
+	}
+	if _, err := part.Write(data); err != nil {
+		return "", err
+	}
+	if err := w.Close(); err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url(phoneNumberID+"/media"), &buf)
+	if err != nil {
+		return "", fmt.Errorf("cloudapi: new request: %w", err)
+	}
+	req.Header.Set("Content-Type", w.FormDataContentType())
+
+	var resp struct {
+		ID string `json:"id"`
+	}
+	if err := c.do(req, token, &resp); err != nil {
+		return "", err
+	}
+	return resp.ID, nil
+}
+
+// PhoneNumber consulta o número, validando o access token e o phone number ID.
+func (c *Client) PhoneNumber(ctx context.Context, token, phoneNumberID string) (PhoneNumberInfo, error) {
+	var info PhoneNumberInfo
+	err := c.doJSON(ctx, http.MethodGet, c.url(phoneNumberID+"?fields=id,display_phone_number,verified_name"), token, nil, &info)
+	return info, err
+}
+
+// DownloadMedia busca a URL temporária da mídia e baixa o arquivo.
+func (c *Client) DownloadMedia(ctx context.Context, token, mediaID string) ([]byte, string, error) {
+	var meta struct {
+		URL      string `json:"url"`
+		MimeType string `json:"mime_type"`
+	}
+	if err := c.doJSON(ctx, http.MethodGet, c.url(mediaID), token, nil, &meta); err != nil {
+		return nil, "", err
+	}
+	if meta.URL == "" {
+		return nil, "", fmt.Errorf("cloudapi: mídia %s sem URL", mediaID)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, meta.URL, nil)
+	if err != nil {
+		return nil, "", fmt.Errorf("cloudapi: new request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, "", fmt.Errorf("cloudapi: download: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("cloudapi: download: status %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxMediaSize+1))
+	if err != nil {
+		return nil, "", fmt.Errorf("cloudapi: download: %w", err)
+	}
+	if len(data) > maxMediaSize {
+		return nil, "", fmt.Errorf("cloudapi: mídia %s excede %d bytes", mediaID, maxMediaSize)
+	}
+	return data, meta.MimeType, nil
+}
+
+func (c *Client) doJSON(ctx context.Context, method, url, token string, body any, out any) error {
+	var reader io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("cloudapi: marshal: %w", err)
+		}
+		reader = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, reader)
+	if err != nil {
+		return fmt.Errorf("cloudapi: new request: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return c.do(req, token, out)
+}
+
+func (c *Client) do(req *http.Request, token string, out any) error {
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("User-Agent", "ApiMe/1.0")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("cloudapi: request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return fmt.Errorf("cloudapi: read body: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		var envelope struct {
+			Error *APIError `json:"error"`
+		}
+		if json.Unmarshal(data, &envelope) == nil && envelope.Error != nil {
+			envelope.Error.Status = resp.StatusCode
+			return envelope.Error
+		}
+		return &APIError{Status: resp.StatusCode, Message: strings.TrimSpace(string(data))}
+	}
+
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("cloudapi: decode: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,383 @@
+package cloudapi
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mau.fi/whatsmeow/types"
+	"go.uber.org/zap"
+
+	"github.com/open-apime/apime/internal/pkg/crypto"
+	"github.com/open-apime/apime/internal/session"
+	"github.com/open-apime/apime/internal/storage"
+	"github.com/open-apime/apime/internal/storage/model"
+)
+
+var ErrInvalidConfig = errors.New("configuração da Cloud API inválida")
+
+// EventHandler recebe os eventos convertidos para o formato do whatsmeow, com
+// a mídia já salva. Implementado por webhook.EventHandler.
+type EventHandler interface {
+	HandleWithMedia(ctx context.Context, instanceID string, instanceJID string, evt any, mediaID string)
+}
+
+// MediaStore guarda a mídia recebida para servi-la em /api/media.
+type MediaStore interface {
+	Save(ctx context.Context, instanceID string, messageID string, data []byte, mimetype string, fileName string) (string, error)
+}
+
+type secrets struct {
+	AccessToken string `json:"accessToken"`
+	AppSecret   string `json:"appSecret,omitempty"`
+}
+
+// Manager atende as instâncias com provedor cloud_api: guarda as credenciais
+// criptografadas, envia pela Graph API e recebe o webhook da Meta. Implementa
+// session.Messenger.
+type Manager struct {
+	instances  storage.InstanceRepository
+	configs    storage.InstanceCloudAPIRepository
+	keyring    *crypto.Keyring
+	client     *Client
+	apiBaseURL string
+	log        *zap.Logger
+
+	mu     sync.RWMutex
+	events EventHandler
+	media  MediaStore
+}
+
+func NewManager(instances storage.InstanceRepository, configs storage.InstanceCloudAPIRepository, keyring *crypto.Keyring, client *Client, apiBaseURL string, log *zap.Logger) *Manager {
+	return &Manager{
+		instances:  instances,
+		configs:    configs,
+		keyring:    keyring,
+		client:     client,
+		apiBaseURL: strings.TrimRight(apiBaseURL, "/"),
+		log:        log,
+	}
+}
+
+func (m *Manager) SetEventHandler(h EventHandler) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.events = h
+}
+
+func (m *Manager) SetMediaStore(s MediaStore) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.media = s
+}
+
+// WebhookURL é o endereço a cadastrar no app da Meta para a instância.
+func (m *Manager) WebhookURL(instanceID string) string {
+	return fmt.Sprintf("%s/api/cloud-api/webhook/%s", m.apiBaseURL, instanceID)
+}
+
+// GetConfig retorna a configuração da instância sem os segredos.
+func (m *Manager) GetConfig(ctx context.Context, instanceID string) (model.InstanceCloudAPI, error) {
+	cfg, err := m.loadConfig(ctx, instanceID)
+	if err != nil {
+		return model.InstanceCloudAPI{}, err
+	}
+	return m.redact(cfg), nil
+}
+
+// Configure grava as credenciais, valida o número na Graph API e marca a
+// instância como ativa. Access token e app secret vazios mantêm os valores já
+// gravados.
+func (m *Manager) Configure(ctx context.Context, cfg model.InstanceCloudAPI) (model.InstanceCloudAPI, error) {
+	cfg.PhoneNumberID = strings.TrimSpace(cfg.PhoneNumberID)
+	cfg.BusinessAccountID = strings.TrimSpace(cfg.BusinessAccountID)
+	cfg.AccessToken = strings.TrimSpace(cfg.AccessToken)
+	cfg.AppSecret = strings.TrimSpace(cfg.AppSecret)
+	cfg.VerifyToken = strings.TrimSpace(cfg.VerifyToken)
+
+	if cfg.PhoneNumberID == "" {
+		return model.InstanceCloudAPI{}, fmt.Errorf("%w: phoneNumberId é obrigatório", ErrInvalidConfig)
+	}
+
+	if current, err := m.loadConfig(ctx, cfg.InstanceID); err == nil {
+		if cfg.AccessToken == "" {
+			cfg.AccessToken = current.AccessToken
+		}
+		if cfg.AppSecret == "" {
+			cfg.AppSecret = current.AppSecret
+		}
+		if cfg.VerifyToken == "" {
+			cfg.VerifyToken = current.VerifyToken
+		}
+	} else if !strings.Contains(err.Error(), "not found") {
+		return model.InstanceCloudAPI{}, err
+	}
+
+	if cfg.AccessToken == "" {
+		return model.InstanceCloudAPI{}, fmt.Errorf("%w: accessToken é obrigatório", ErrInvalidConfig)
+	}
+	if cfg.AppSecret == "" {
+		return model.InstanceCloudAPI{}, fmt.Errorf("%w: appSecret é obrigatório", ErrInvalidConfig)
+	}
+	if cfg.VerifyToken == "" {
+		token := make([]byte, 16)
+		if _, err := rand.Read(token); err != nil {
+			return model.InstanceCloudAPI{}, err
+		}
+		cfg.VerifyToken = hex.EncodeToString(token)
+	}
+
+	info, err := m.client.PhoneNumber(ctx, cfg.AccessToken, cfg.PhoneNumberID)
+	if err != nil {
+		return model.InstanceCloudAPI{}, fmt.Errorf("%w: %v", ErrInvalidConfig, err)
+	}
+
+	data, err := json.Marshal(secrets{AccessToken: cfg.AccessToken, AppSecret: cfg.AppSecret})
+	if err != nil {
+		return model.InstanceCloudAPI{}, err
+	}
+	cfg.SecretsEnc, err = m.keyring.Encrypt(data)
+	if err != nil {
+		return model.InstanceCloudAPI{}, fmt.Errorf("criptografar credenciais da Cloud API: %w", err)
+	}
+
+	if err := m.configs.Upsert(ctx, cfg); err != nil {
+		return model.InstanceCloudAPI{}, err
+	}
+
+	inst, err := m.instances.GetByID(ctx, cfg.InstanceID)
+	if err != nil {
+		return model.InstanceCloudAPI{}, err
+	}
+	inst.Provider = model.InstanceProviderCloudAPI
+	inst.Status = model.InstanceStatusActive
+	if phone := digits(info.DisplayPhoneNumber); phone != "" {
+		inst.WhatsAppJID = types.NewJID(phone, types.DefaultUserServer).String()
+	}
+	if _, err := m.instances.Update(ctx, inst); err != nil {
+		return model.InstanceCloudAPI{}, err
+	}
+
+	m.log.Info("instância da Cloud API configurada",
+		zap.String("instance_id", cfg.InstanceID),
+		zap.String("phone_number_id", cfg.PhoneNumberID),
+		zap.String("verified_name", info.VerifiedName),
+	)
+
+	return m.GetConfig(ctx, cfg.InstanceID)
+}
+
+// Disconnect mantém as credenciais, mas deixa a instância desconectada: envios
+// são recusados e o webhook da Meta é ignorado até a próxima configuração.
+func (m *Manager) Disconnect(ctx context.Context, instanceID string) error {
+	inst, err := m.instances.GetByID(ctx, instanceID)
+	if err != nil {
+		return err
+	}
+	inst.Status = model.InstanceStatusDisconnected
+	_, err = m.instances.Update(ctx, inst)
+	return err
+}
+
+// Remove apaga as credenciais da instância.
+func (m *Manager) Remove(ctx context.Context, instanceID string) error {
+	return m.configs.Delete(ctx, instanceID)
+}
+
+func (m *Manager) redact(cfg model.InstanceCloudAPI) model.InstanceCloudAPI {
+	cfg.HasAppSecret = cfg.AppSecret != ""
+	cfg.AccessToken = ""
+	cfg.AppSecret = ""
+	cfg.SecretsEnc = nil
+	cfg.WebhookURL = m.WebhookURL(cfg.InstanceID)
+	return cfg
+}
+
+// loadConfig busca e descriptografa as credenciais da instância.
+func (m *Manager) loadConfig(ctx context.Context, instanceID string) (model.InstanceCloudAPI, error) {
+	cfg, err := m.configs.Get(ctx, instanceID)
+	if err != nil {
+		return model.InstanceCloudAPI{}, err
+	}
+
+	data, err := m.keyring.Decrypt(cfg.SecretsEnc)
+	if err != nil {
+		return model.InstanceCloudAPI{}, fmt.Errorf("descriptografar credenciais da Cloud API: %w", err)
+	}
+	var s secrets
+	if err := json.Unmarshal(data, &s); err != nil {
+		return model.InstanceCloudAPI{}, fmt.Errorf("decodificar credenciais da Cloud API: %w", err)
+	}
+	cfg.AccessToken = s.AccessToken
+	cfg.AppSecret = s.AppSecret
+	return cfg, nil
+}
+
+func (m *Manager) connectedConfig(ctx context.Context, instanceID string) (model.InstanceCloudAPI, error) {
+	cfg, err := m.loadConfig(ctx, instanceID)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			return model.InstanceCloudAPI{}, fmt.Errorf("%w: credenciais da Cloud API não configuradas", session.ErrNotConnected)
+		}
+		return model.InstanceCloudAPI{}, err
+	}
+	return cfg, nil
+}
+
+func (m *Manager) Ready(ctx context.Context, instanceID string) error {
+	_, err := m.connectedConfig(ctx, instanceID)
+	return err
+}
+
+// ResolveJID aceita apenas números: a Cloud API não envia para grupos e
+// dispensa a consulta prévia do número.
+func (m *Manager) ResolveJID(ctx context.Context, instanceID, phone string) (types.JID, error) {
+	phone = strings.TrimSpace(phone)
+	if strings.Contains(phone, "@") {
+		jid, err := types.ParseJID(phone)
+		if err != nil {
+			return types.EmptyJID, err
+		}
+		if jid.Server != types.DefaultUserServer {
+			return types.EmptyJID, fmt.Errorf("%w: destino %s", session.ErrNotSupported, jid.Server)
+		}
+		phone = jid.User
+	}
+	phone = digits(phone)
+	if phone == "" {
+		return types.EmptyJID, errors.New("telefone vazio")
+	}
+	return types.NewJID(phone, types.DefaultUserServer), nil
+}
+
+func (m *Manager) Prepare(ctx context.Context, instanceID string, to types.JID) error {
+	return nil
+}
+
+func (m *Manager) Send(ctx context.Context, instanceID string, to types.JID, msg session.OutgoingMessage) (session.SentMessage, error) {
+	cfg, err := m.connectedConfig(ctx, instanceID)
+	if err != nil {
+		return session.SentMessage{}, err
+	}
+
+	body := map[string]any{
+		"recipient_type": "individual",
+		"to":             to.User,
+		"type":           msg.Type,
+	}
+	if msg.Quoted != "" {
+		body["context"] = map[string]any{"message_id": msg.Quoted}
+	}
+
+	switch msg.Type {
+	case "text":
+		body["text"] = map[string]any{"body": msg.Text, "preview_url": false}
+	case string(session.MediaImage), string(session.MediaVideo), string(session.MediaAudio), string(session.MediaDocument):
+		mediaID := msg.MediaID
+		if len(msg.Media) > 0 {
+			mediaID, err = m.client.UploadMedia(ctx, cfg.AccessToken, cfg.PhoneNumberID, msg.Media, msg.MimeType)
+			if err != nil {
+				return session.SentMessage{}, fmt.Errorf("erro ao fazer upload da mídia: %w", err)
+			}
+		}
+		media := map[string]any{"id": mediaID}
+		if msg.Caption != "" && msg.Type != string(session.MediaAudio) {
+			media["caption"] = msg.Caption
+		}
+		if msg.Type == string(session.MediaDocument) && msg.FileName != "" {
+			media["filename"] = msg.FileName
+		}
+		body[msg.Type] = media
+	default:
+		return session.SentMessage{}, fmt.Errorf("%w: tipo %s", session.ErrNotSupported, msg.Type)
+	}
+
+	id, err := m.client.SendMessage(ctx, cfg.AccessToken, cfg.PhoneNumberID, body)
+	if err != nil {
+		return session.SentMessage{}, err
+	}
+
+	m.log.Info("mensagem enviada pela Cloud API",
+		zap.String("instance_id", instanceID),
+		zap.String("to", to.String()),
+		zap.String("server_id", id))
+	return session.SentMessage{ID: id, Timestamp: time.Now()}, nil
+}
+
+func (m *Manager) Upload(ctx context.Context, instanceID string, data []byte, mimeType string, kind session.MediaKind) (session.UploadedMedia, error) {
+	cfg, err := m.connectedConfig(ctx, instanceID)
+	if err != nil {
+		return session.UploadedMedia{}, err
+	}
+	id, err := m.client.UploadMedia(ctx, cfg.AccessToken, cfg.PhoneNumberID, data, mimeType)
+	if err != nil {
+		return session.UploadedMedia{}, err
+	}
+	return session.UploadedMedia{ID: id}, nil
+}
+
+// MarkRead marca as mensagens como lidas. A Cloud API não distingue áudio
+// reproduzido de lido.
+func (m *Manager) MarkRead(ctx context.Context, instanceID string, chat, sender types.JID, ids []string, played bool) error {
+	cfg, err := m.connectedConfig(ctx, instanceID)
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if err := m.client.MarkRead(ctx, cfg.AccessToken, cfg.PhoneNumberID, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *Manager) CheckPhones(ctx context.Context, instanceID string, phones []string) ([]types.IsOnWhatsAppResponse, error) {
+	return nil, session.ErrNotSupported
+}
+
+func (m *Manager) Revoke(ctx context.Context, instanceID string, chat, sender types.JID, id string) (session.SentMessage, error) {
+	return session.SentMessage{}, session.ErrNotSupported
+}
+
+func (m *Manager) SendPresence(ctx context.Context, instanceID string, presence types.Presence) error {
+	return session.ErrNotSupported
+}
+
+func (m *Manager) SendChatPresence(ctx context.Context, instanceID string, chat types.JID, state types.ChatPresence, media types.ChatPresenceMedia) error {
+	return session.ErrNotSupported
+}
+
+func (m *Manager) GetJoinedGroups(ctx context.Context, instanceID string) ([]*types.GroupInfo, error) {
+	return nil, session.ErrNotSupported
+}
+
+func (m *Manager) GetGroupInfo(ctx context.Context, instanceID string, group types.JID) (*types.GroupInfo, error) {
+	return nil, session.ErrNotSupported
+}
+
+func (m *Manager) CreateGroup(ctx context.Context, instanceID, name string, participants []types.JID) (*types.GroupInfo, error) {
+	return nil, session.ErrNotSupported
+}
+
+func (m *Manager) UpdateGroupParticipants(ctx context.Context, instanceID string, group types.JID, participants []types.JID, action session.ParticipantAction) ([]types.GroupParticipant, error) {
+	return nil, session.ErrNotSupported
+}
+
+func (m *Manager) LeaveGroup(ctx context.Context, instanceID string, group types.JID) error {
+	return session.ErrNotSupported
+}
+
+func digits(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, s)
+}
@@ -0,0 +1,403 @@
+package cloudapi
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mau.fi/whatsmeow/proto/waCommon"
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/open-apime/apime/internal/storage/model"
+)
+
+var (
+	ErrInvalidSignature   = errors.New("assinatura do webhook inválida")
+	ErrInvalidVerifyToken = errors.New("verify token inválido")
+)
+
+type webhookPayload struct {
+	Object string `json:"object"`
+	Entry  []struct {
+		ID      string `json:"id"`
+		Changes []struct {
+			Field string       `json:"field"`
+			Value webhookValue `json:"value"`
+		} `json:"changes"`
+	} `json:"entry"`
+}
+
+type webhookValue struct {
+	Metadata struct {
+		DisplayPhoneNumber string `json:"display_phone_number"`
+		PhoneNumberID      string `json:"phone_number_id"`
+	} `json:"metadata"`
+	Contacts []struct {
+		WaID    string `json:"wa_id"`
+		Profile struct {
+			Name string `json:"name"`
+		} `json:"profile"`
+	} `json:"contacts"`
+	Messages []inboundMessage `json:"messages"`
+	Statuses []struct {
+		ID          string `json:"id"`
+		Status      string `json:"status"`
+		Timestamp   string `json:"timestamp"`
+		RecipientID string `json:"recipient_id"`
+		Errors      []struct {
+			Code  int    `json:"code"`
+			Title string `json:"title"`
+		} `json:"errors"`
+	} `json:"statuses"`
+}
+
+type inboundMedia struct {
+	ID       string `json:"id"`
+	MimeType string `json:"mime_type"`
+	Caption  string `json:"caption"`
+	Filename string `json:"filename"`
+	Voice    bool   `json:"voice"`
+	Animated bool   `json:"animated"`
+}
+
+type inboundMessage struct {
+	From      string `json:"from"`
+	ID        string `json:"id"`
+	Timestamp string `json:"timestamp"`
+	Type      string `json:"type"`
+	Context   *struct {
+		From string `json:"from"`
+		ID   string `json:"id"`
+	} `json:"context"`
+	Text *struct {
+		Body string `json:"body"`
+	} `json:"text"`
+	Image    *inboundMedia `json:"image"`
+	Video    *inboundMedia `json:"video"`
+	Audio    *inboundMedia `json:"audio"`
+	Document *inboundMedia `json:"document"`
+	Sticker  *inboundMedia `json:"sticker"`
+	Location *struct {
+		Latitude  float64 `json:"latitude"`
+		Longitude float64 `json:"longitude"`
+		Name      string  `json:"name"`
+		Address   string  `json:"address"`
+	} `json:"location"`
+	Reaction *struct {
+		MessageID string `json:"message_id"`
+		Emoji     string `json:"emoji"`
+	} `json:"reaction"`
+	Button *struct {
+		Text    string `json:"text"`
+		Payload string `json:"payload"`
+	} `json:"button"`
+	Interactive *struct {
+		Type        string `json:"type"`
+		ButtonReply *struct {
+			ID    string `json:"id"`
+			Title string `json:"title"`
+		} `json:"button_reply"`
+		ListReply *struct {
+			ID    string `json:"id"`
+			Title string `json:"title"`
+		} `json:"list_reply"`
+	} `json:"interactive"`
+}
+
+// VerifyWebhook responde ao desafio de cadastro do webhook no app da Meta.
+func (m *Manager) VerifyWebhook(ctx context.Context, instanceID, mode, token, challenge string) (string, error) {
+	cfg, err := m.configs.Get(ctx, instanceID)
+	if err != nil {
+		return "", err
+	}
+	if mode != "subscribe" || !hmac.Equal([]byte(token), []byte(cfg.VerifyToken)) {
+		return "", ErrInvalidVerifyToken
+	}
+	return challenge, nil
+}
+
+// HandleWebhook valida a assinatura e entrega as mensagens e confirmações ao
+// mesmo pipeline das instâncias whatsmeow, como events.Message e
+// events.Receipt. Sem app secret gravado (configurações anteriores à sua
+// obrigatoriedade), todas as entregas são recusadas, já que a rota é pública.
+func (m *Manager) HandleWebhook(ctx context.Context, instanceID string, body []byte, signature string) error {
+	cfg, err := m.loadConfig(ctx, instanceID)
+	if err != nil {
+		return err
+	}
+	if cfg.AppSecret == "" {
+		return fmt.Errorf("%w: app secret não configurado", ErrInvalidSignature)
+	}
+	if !validSignature(body, signature, cfg.AppSecret) {
+		return ErrInvalidSignature
+	}
+
+	inst, err := m.instances.GetByID(ctx, instanceID)
+	if err != nil {
+		return err
+	}
+	if inst.Status != model.InstanceStatusActive {
+		m.log.Info("webhook da Cloud API ignorado: instância não ativa",
+			zap.String("instance_id", instanceID),
+			zap.String("status", string(inst.Status)))
+		return nil
+	}
+
+	var payload webhookPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return fmt.Errorf("payload inválido: %w", err)
+	}
+
+	m.mu.RLock()
+	handler := m.events
+	m.mu.RUnlock()
+	if handler == nil {
+		return nil
+	}
+
+	for _, entry := range payload.Entry {
+		for _, change := range entry.Changes {
+			if change.Field != "messages" {
+				continue
+			}
+			value := change.Value
+			if value.Metadata.PhoneNumberID != "" && value.Metadata.PhoneNumberID != cfg.PhoneNumberID {
+				m.log.Warn("webhook da Cloud API para outro número ignorado",
+					zap.String("instance_id", instanceID),
+					zap.String("phone_number_id", value.Metadata.PhoneNumberID))
+				continue
+			}
+
+			names := make(map[string]string, len(value.Contacts))
+			for _, c := range value.Contacts {
+				names[c.WaID] = c.Profile.Name
+			}
+
+			for _, msg := range value.Messages {
+				evt := m.toMessageEvent(msg, names[msg.From])
+				if evt == nil {
+					m.log.Debug("tipo de mensagem da Cloud API não suportado",
+						zap.String("instance_id", instanceID),
+						zap.String("type", msg.Type))
+					continue
+				}
+				mediaID := m.saveMedia(ctx, cfg, msg)
+				handler.HandleWithMedia(ctx, instanceID, inst.WhatsAppJID, evt, mediaID)
+			}
+
+			for _, st := range value.Statuses {
+				var receiptType types.ReceiptType
+				switch st.Status {
+				case "delivered":
+					receiptType = types.ReceiptTypeDelivered
+				case "read":
+					receiptType = types.ReceiptTypeRead
+				case "failed":
+					fields := []zap.Field{zap.String("instance_id", instanceID), zap.String("msg_id", st.ID)}
+					if len(st.Errors) > 0 {
+						fields = append(fields, zap.Int("code", st.Errors[0].Code), zap.String("error", st.Errors[0].Title))
+					}
+					m.log.Warn("Cloud API reportou falha na entrega", fields...)
+					continue
+				default:
+					continue
+				}
+
+				jid := types.NewJID(st.RecipientID, types.DefaultUserServer)
+				handler.HandleWithMedia(ctx, instanceID, inst.WhatsAppJID, &events.Receipt{
+					MessageSource: types.MessageSource{Chat: jid, Sender: jid},
+					MessageIDs:    []types.MessageID{st.ID},
+					Timestamp:     parseTimestamp(st.Timestamp),
+					Type:          receiptType,
+				}, "")
+			}
+		}
+	}
+	return nil
+}
+
+// toMessageEvent converte a mensagem recebida no evento do whatsmeow. Retorna
+// nil para tipos sem equivalente.
+func (m *Manager) toMessageEvent(msg inboundMessage, pushName string) *events.Message {
+	from := types.NewJID(msg.From, types.DefaultUserServer)
+
+	var contextInfo *waE2E.ContextInfo
+	if msg.Context != nil && msg.Context.ID != "" {
+		contextInfo = &waE2E.ContextInfo{StanzaID: proto.String(msg.Context.ID)}
+		if msg.Context.From != "" {
+			contextInfo.Participant = proto.String(types.NewJID(msg.Context.From, types.DefaultUserServer).String())
+		}
+	}
+
+	message := &waE2E.Message{}
+	infoType := "media"
+	switch {
+	case msg.Text != nil:
+		infoType = "text"
+		if contextInfo != nil {
+			message.ExtendedTextMessage = &waE2E.ExtendedTextMessage{
+				Text:        proto.String(msg.Text.Body),
+				ContextInfo: contextInfo,
+			}
+		} else {
+			message.Conversation = proto.String(msg.Text.Body)
+		}
+	case msg.Image != nil:
+		message.ImageMessage = &waE2E.ImageMessage{
+			Mimetype:    proto.String(msg.Image.MimeType),
+			Caption:     optional(msg.Image.Caption),
+			ContextInfo: contextInfo,
+		}
+	case msg.Video != nil:
+		message.VideoMessage = &waE2E.VideoMessage{
+			Mimetype:    proto.String(msg.Video.MimeType),
+			Caption:     optional(msg.Video.Caption),
+			ContextInfo: contextInfo,
+		}
+	case msg.Audio != nil:
+		message.AudioMessage = &waE2E.AudioMessage{
+			Mimetype:    proto.String(msg.Audio.MimeType),
+			PTT:         proto.Bool(msg.Audio.Voice),
+			ContextInfo: contextInfo,
+		}
+	case msg.Document != nil:
+		message.DocumentMessage = &waE2E.DocumentMessage{
+			Mimetype:    proto.String(msg.Document.MimeType),
+			FileName:    optional(msg.Document.Filename),
+			Title:       optional(msg.Document.Filename),
+			Caption:     optional(msg.Document.Caption),
+			ContextInfo: contextInfo,
+		}
+	case msg.Sticker != nil:
+		message.StickerMessage = &waE2E.StickerMessage{
+			Mimetype:    proto.String(msg.Sticker.MimeType),
+			IsAnimated:  proto.Bool(msg.Sticker.Animated),
+			ContextInfo: contextInfo,
+		}
+	case msg.Location != nil:
+		message.LocationMessage = &waE2E.LocationMessage{
+			DegreesLatitude:  proto.Float64(msg.Location.Latitude),
+			DegreesLongitude: proto.Float64(msg.Location.Longitude),
+			Name:             optional(msg.Location.Name),
+			Address:          optional(msg.Location.Address),
+			ContextInfo:      contextInfo,
+		}
+	case msg.Reaction != nil:
+		infoType = "reaction"
+		message.ReactionMessage = &waE2E.ReactionMessage{
+			Key: &waCommon.MessageKey{
+				RemoteJID: proto.String(from.String()),
+				ID:        proto.String(msg.Reaction.MessageID),
+			},
+			Text:              proto.String(msg.Reaction.Emoji),
+			SenderTimestampMS: proto.Int64(parseTimestamp(msg.Timestamp).UnixMilli()),
+		}
+	case msg.Button != nil:
+		infoType = "text"
+		message.Conversation = proto.String(msg.Button.Text)
+	case msg.Interactive != nil && msg.Interactive.ButtonReply != nil:
+		infoType = "text"
+		message.Conversation = proto.String(msg.Interactive.ButtonReply.Title)
+	case msg.Interactive != nil && msg.Interactive.ListReply != nil:
+		infoType = "text"
+		message.Conversation = proto.String(msg.Interactive.ListReply.Title)
+	default:
+		return nil
+	}
+
+	return &events.Message{
+		Info: types.MessageInfo{
+			MessageSource: types.MessageSource{
+				Chat:   from,
+				Sender: from,
+			},
+			ID:        msg.ID,
+			Type:      infoType,
+			PushName:  pushName,
+			Timestamp: parseTimestamp(msg.Timestamp),
+		},
+		Message: message,
+	}
+}
+
+// saveMedia baixa a mídia da mensagem pela Graph API e salva no storage.
+// Retorna o ID da mídia salva ou string vazia.
+func (m *Manager) saveMedia(ctx context.Context, cfg model.InstanceCloudAPI, msg inboundMessage) string {
+	var media *inboundMedia
+	for _, candidate := range []*inboundMedia{msg.Image, msg.Video, msg.Audio, msg.Document, msg.Sticker} {
+		if candidate != nil {
+			media = candidate
+			break
+		}
+	}
+
+	m.mu.RLock()
+	store := m.media
+	m.mu.RUnlock()
+	if media == nil || media.ID == "" || store == nil {
+		return ""
+	}
+
+	data, mimeType, err := m.client.DownloadMedia(ctx, cfg.AccessToken, media.ID)
+	if err != nil {
+		m.log.Error("erro ao baixar mídia da Cloud API",
+			zap.String("instance_id", cfg.InstanceID),
+			zap.String("message_id", msg.ID),
+			zap.Error(err))
+		return ""
+	}
+	if mimeType == "" {
+		mimeType = media.MimeType
+	}
+
+	mediaID, err := store.Save(ctx, cfg.InstanceID, msg.ID, data, mimeType, media.Filename)
+	if err != nil {
+		m.log.Error("erro ao salvar mídia",
+			zap.String("instance_id", cfg.InstanceID),
+			zap.String("message_id", msg.ID),
+			zap.Error(err))
+		return ""
+	}
+	return mediaID
+}
+
+// validSignature confere o cabeçalho X-Hub-Signature-256 (sha256=<hex>).
+func validSignature(body []byte, header, secret string) bool {
+	sig, ok := strings.CutPrefix(header, "sha256=")
+	if !ok {
+		return false
+	}
+	expected, err := hex.DecodeString(sig)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(mac.Sum(nil), expected)
+}
+
+func parseTimestamp(s string) time.Time {
+	sec, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return time.Now()
+	}
+	return time.Unix(sec, 0)
+}
+
+func optional(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return proto.String(s)
+}
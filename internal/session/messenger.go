@@ -0,0 +1,194 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.mau.fi/whatsmeow/types"
+
+	"github.com/open-apime/apime/internal/storage"
+	"github.com/open-apime/apime/internal/storage/model"
+)
+
+var (
+	ErrNotSupported = errors.New("operação não suportada pelo provedor da instância")
+	ErrNotConnected = errors.New("instância não conectada")
+	ErrInvalidJID   = errors.New("JID inválido")
+	// ErrInterrupted indica que o envio foi abandonado durante as esperas de
+	// aquecimento, antes de a mensagem sair para o WhatsApp.
+	ErrInterrupted = errors.New("envio interrompido antes de chegar ao WhatsApp")
+	// ErrRestoring indica que a sessão ainda aguarda a restauração feita na
+	// inicialização do nó; o envio pode ser repetido em instantes.
+	ErrRestoring = errors.New("sessão da instância em restauração")
+	// ErrMediaExpired indica que o WhatsApp não tem mais a mídia pedida.
+	ErrMediaExpired = errors.New("mídia não está mais disponível no WhatsApp")
+)
+
+// MediaKind classifica a mídia enviada; cada provedor tem formatos e limites
+// próprios por tipo.
+type MediaKind string
+
+const (
+	MediaImage    MediaKind = "image"
+	MediaVideo    MediaKind = "video"
+	MediaAudio    MediaKind = "audio"
+	MediaDocument MediaKind = "document"
+)
+
+// OutgoingMessage é uma mensagem a enviar. Type é "text" ou um MediaKind;
+// Media é usado apenas nos tipos de mídia.
+type OutgoingMessage struct {
+	Type     string
+	Text     string
+	Media    []byte
+	MimeType string
+	Caption  string
+	FileName string
+	Seconds  int
+	PTT      bool
+	Quoted   string
+	// Thumbnail é a miniatura JPEG exibida antes do download da mídia.
+	Thumbnail []byte
+	// Waveform é a forma de onda das mensagens de voz, com 64 valores de 0 a
+	// 100.
+	Waveform []byte
+	// MediaID referencia uma mídia enviada antes por Upload; é usado quando
+	// Media está vazio.
+	MediaID string
+}
+
+// SentMessage identifica a mensagem aceita pelo provedor. ID é o mesmo que
+// chega depois nas confirmações de entrega e leitura.
+type SentMessage struct {
+	ID        string
+	Timestamp time.Time
+}
+
+// UploadedMedia é a referência da mídia enviada ao provedor. ID pode ser
+// usado como OutgoingMessage.MediaID; instâncias whatsmeow preenchem também
+// URL e DirectPath.
+type UploadedMedia struct {
+	ID         string `json:"id,omitempty"`
+	URL        string `json:"url,omitempty"`
+	DirectPath string `json:"direct_path,omitempty"`
+	Handle     string `json:"handle,omitempty"`
+	ObjectID   string `json:"object_id,omitempty"`
+}
+
+// ParticipantAction é a alteração aplicada aos participantes de um grupo.
+type ParticipantAction string
+
+const (
+	ParticipantAdd     ParticipantAction = "add"
+	ParticipantRemove  ParticipantAction = "remove"
+	ParticipantPromote ParticipantAction = "promote"
+	ParticipantDemote  ParticipantAction = "demote"
+)
+
+// Messenger é a interface de mensagens comum aos provedores de sessão. Os JIDs
+// seguem o formato do WhatsApp (telefone@s.whatsapp.net, grupo@g.us) em todos
+// os provedores, para que mensagens, conversas e webhooks sejam os mesmos.
+// Operações sem equivalente no provedor retornam ErrNotSupported.
+type Messenger interface {
+	// Ready aguarda até a sessão conseguir enviar. Erros de sessão
+	// indisponível envolvem ErrNotConnected ou ErrRestoring.
+	Ready(ctx context.Context, instanceID string) error
+	ResolveJID(ctx context.Context, instanceID, phone string) (types.JID, error)
+	CheckPhones(ctx context.Context, instanceID string, phones []string) ([]types.IsOnWhatsAppResponse, error)
+	// Prepare aquece o envio para o destinatário. As esperas feitas aqui
+	// podem ser abandonadas sem efeitos colaterais.
+	Prepare(ctx context.Context, instanceID string, to types.JID) error
+	Send(ctx context.Context, instanceID string, to types.JID, msg OutgoingMessage) (SentMessage, error)
+	Upload(ctx context.Context, instanceID string, data []byte, mimeType string, kind MediaKind) (UploadedMedia, error)
+	MarkRead(ctx context.Context, instanceID string, chat, sender types.JID, ids []string, played bool) error
+	Revoke(ctx context.Context, instanceID string, chat, sender types.JID, id string) (SentMessage, error)
+	SendPresence(ctx context.Context, instanceID string, presence types.Presence) error
+	SendChatPresence(ctx context.Context, instanceID string, chat types.JID, state types.ChatPresence, media types.ChatPresenceMedia) error
+
+	GetJoinedGroups(ctx context.Context, instanceID string) ([]*types.GroupInfo, error)
+	GetGroupInfo(ctx context.Context, instanceID string, group types.JID) (*types.GroupInfo, error)
+	CreateGroup(ctx context.Context, instanceID, name string, participants []types.JID) (*types.GroupInfo, error)
+	UpdateGroupParticipants(ctx context.Context, instanceID string, group types.JID, participants []types.JID, action ParticipantAction) ([]types.GroupParticipant, error)
+	LeaveGroup(ctx context.Context, instanceID string, group types.JID) error
+}
+
+// RestoreChecker é implementado pelos Messengers cujas sessões são
+// restauradas em fila na inicialização.
+type RestoreChecker interface {
+	IsRestoring(instanceID string) bool
+}
+
+// UploadInfo são os dados da mídia guardados junto com o upload. Seconds e
+// Waveform são preenchidos apenas para áudios.
+type UploadInfo struct {
+	MimeType string
+	Seconds  int
+	Waveform []byte
+}
+
+// UploadLookup é implementado pelos Messengers que guardam os uploads, para
+// que os envios por MediaID usem os dados da mídia conferidos no upload. ok é
+// false quando o upload não está mais disponível.
+type UploadLookup interface {
+	LookupUpload(instanceID, mediaID string, kind MediaKind) (info UploadInfo, ok bool)
+}
+
+// Registry escolhe o Messenger de cada instância pelo provedor gravado nela.
+type Registry struct {
+	instances storage.InstanceRepository
+
+	mu         sync.RWMutex
+	messengers map[model.InstanceProvider]Messenger
+}
+
+func NewRegistry(instances storage.InstanceRepository) *Registry {
+	return &Registry{
+		instances:  instances,
+		messengers: make(map[model.InstanceProvider]Messenger),
+	}
+}
+
+func (r *Registry) Register(provider model.InstanceProvider, m Messenger) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.messengers[provider] = m
+}
+
+// Get retorna o Messenger do provedor. Instâncias gravadas antes da coluna
+// provider são whatsmeow.
+func (r *Registry) Get(provider model.InstanceProvider) (Messenger, error) {
+	if provider == "" {
+		provider = model.InstanceProviderWhatsmeow
+	}
+	r.mu.RLock()
+	m, ok := r.messengers[provider]
+	r.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: provedor %s não habilitado", ErrNotSupported, provider)
+	}
+	return m, nil
+}
+
+// For busca a instância e retorna o Messenger do seu provedor.
+func (r *Registry) For(ctx context.Context, instanceID string) (Messenger, error) {
+	provider, err := r.Provider(ctx, instanceID)
+	if err != nil {
+		return nil, err
+	}
+	return r.Get(provider)
+}
+
+// Provider retorna o provedor da instância.
+func (r *Registry) Provider(ctx context.Context, instanceID string) (model.InstanceProvider, error) {
+	inst, err := r.instances.GetByID(ctx, instanceID)
+	if err != nil {
+		return "", err
+	}
+	if inst.Provider == "" {
+		return model.InstanceProviderWhatsmeow, nil
+	}
+	return inst.Provider, nil
+}
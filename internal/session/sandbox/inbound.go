@@ -0,0 +1,173 @@
+package sandbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/open-apime/apime/internal/session"
+	"github.com/open-apime/apime/internal/storage/model"
+)
+
+var ErrInvalidInbound = errors.New("mensagem simulada inválida")
+
+// Inbound é uma mensagem recebida simulada. Sem Media, Text é obrigatório; com
+// Media, o tipo é escolhido pelo MimeType, detectado pelo conteúdo se vazio.
+type Inbound struct {
+	ID        string
+	From      string
+	PushName  string
+	Text      string
+	Quoted    string
+	Media     []byte
+	MimeType  string
+	Caption   string
+	FileName  string
+	PTT       bool
+	Timestamp time.Time
+}
+
+// Inject entrega a mensagem ao pipeline de eventos como se tivesse chegado do
+// WhatsApp e retorna o ID usado.
+func (m *Manager) Inject(ctx context.Context, instanceID string, in Inbound) (string, error) {
+	inst, err := m.instances.GetByID(ctx, instanceID)
+	if err != nil {
+		return "", err
+	}
+	if inst.Provider != model.InstanceProviderSandbox {
+		return "", session.ErrNotSupported
+	}
+	if inst.Status != model.InstanceStatusActive {
+		return "", fmt.Errorf("%w: instância sandbox %s", session.ErrNotConnected, inst.Status)
+	}
+
+	from, err := m.ResolveJID(ctx, instanceID, in.From)
+	if err != nil {
+		return "", fmt.Errorf("%w: from: %v", ErrInvalidInbound, err)
+	}
+	if len(in.Media) == 0 && strings.TrimSpace(in.Text) == "" {
+		return "", fmt.Errorf("%w: text ou media é obrigatório", ErrInvalidInbound)
+	}
+	if len(in.Media) > 0 && in.MimeType == "" {
+		in.MimeType = http.DetectContentType(in.Media)
+	}
+	if in.ID == "" {
+		in.ID = newMessageID()
+	}
+	if in.Timestamp.IsZero() {
+		in.Timestamp = time.Now()
+	}
+
+	evt := &events.Message{
+		Info: types.MessageInfo{
+			MessageSource: types.MessageSource{Chat: from, Sender: from},
+			ID:            in.ID,
+			Type:          "text",
+			PushName:      in.PushName,
+			Timestamp:     in.Timestamp,
+		},
+		Message: buildMessage(in),
+	}
+
+	var mediaID string
+	if len(in.Media) > 0 {
+		evt.Info.Type = "media"
+		mediaID = m.saveMedia(ctx, instanceID, in)
+	}
+
+	m.log.Info("mensagem sandbox injetada",
+		zap.String("instance_id", instanceID),
+		zap.String("from", from.String()),
+		zap.String("msg_id", in.ID),
+		zap.String("type", evt.Info.Type))
+	m.dispatch(ctx, instanceID, inst.WhatsAppJID, evt, mediaID)
+	return in.ID, nil
+}
+
+func buildMessage(in Inbound) *waE2E.Message {
+	var contextInfo *waE2E.ContextInfo
+	if in.Quoted != "" {
+		contextInfo = &waE2E.ContextInfo{StanzaID: proto.String(in.Quoted)}
+	}
+
+	if len(in.Media) == 0 {
+		if contextInfo != nil {
+			return &waE2E.Message{ExtendedTextMessage: &waE2E.ExtendedTextMessage{
+				Text:        proto.String(in.Text),
+				ContextInfo: contextInfo,
+			}}
+		}
+		return &waE2E.Message{Conversation: proto.String(in.Text)}
+	}
+
+	size := proto.Uint64(uint64(len(in.Media)))
+	switch {
+	case strings.HasPrefix(in.MimeType, "image/"):
+		return &waE2E.Message{ImageMessage: &waE2E.ImageMessage{
+			Mimetype:    proto.String(in.MimeType),
+			Caption:     optional(in.Caption),
+			FileLength:  size,
+			ContextInfo: contextInfo,
+		}}
+	case strings.HasPrefix(in.MimeType, "video/"):
+		return &waE2E.Message{VideoMessage: &waE2E.VideoMessage{
+			Mimetype:    proto.String(in.MimeType),
+			Caption:     optional(in.Caption),
+			FileLength:  size,
+			ContextInfo: contextInfo,
+		}}
+	case strings.HasPrefix(in.MimeType, "audio/"):
+		return &waE2E.Message{AudioMessage: &waE2E.AudioMessage{
+			Mimetype:    proto.String(in.MimeType),
+			PTT:         proto.Bool(in.PTT),
+			FileLength:  size,
+			ContextInfo: contextInfo,
+		}}
+	default:
+		return &waE2E.Message{DocumentMessage: &waE2E.DocumentMessage{
+			Mimetype:    proto.String(in.MimeType),
+			FileName:    optional(in.FileName),
+			Title:       optional(in.FileName),
+			Caption:     optional(in.Caption),
+			FileLength:  size,
+			ContextInfo: contextInfo,
+		}}
+	}
+}
+
+// saveMedia salva a mídia da mensagem no storage. Retorna o ID da mídia salva
+// ou string vazia.
+func (m *Manager) saveMedia(ctx context.Context, instanceID string, in Inbound) string {
+	m.mu.Lock()
+	store := m.media
+	m.mu.Unlock()
+	if store == nil {
+		return ""
+	}
+
+	mediaID, err := store.Save(ctx, instanceID, in.ID, in.Media, in.MimeType, in.FileName)
+	if err != nil {
+		m.log.Error("erro ao salvar mídia",
+			zap.String("instance_id", instanceID),
+			zap.String("message_id", in.ID),
+			zap.Error(err))
+		return ""
+	}
+	return mediaID
+}
+
+func optional(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return proto.String(s)
+}
@@ -0,0 +1,374 @@
+package sandbox
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+	"go.uber.org/zap"
+
+	"github.com/open-apime/apime/internal/session"
+	"github.com/open-apime/apime/internal/storage"
+	"github.com/open-apime/apime/internal/storage/model"
+)
+
+// EventHandler recebe os eventos simulados no formato do whatsmeow. Implementado
+// por webhook.EventHandler.
+type EventHandler interface {
+	HandleWithMedia(ctx context.Context, instanceID string, instanceJID string, evt any, mediaID string)
+}
+
+// MediaStore guarda a mídia das mensagens injetadas para servi-la em /api/media.
+type MediaStore interface {
+	Save(ctx context.Context, instanceID string, messageID string, data []byte, mimetype string, fileName string) (string, error)
+}
+
+// Schedule define quanto tempo depois do envio chegam as confirmações
+// simuladas. Um atraso negativo desativa a confirmação; a leitura nunca chega
+// antes da entrega.
+type Schedule struct {
+	DeliveredAfter time.Duration
+	ReadAfter      time.Duration
+}
+
+// Manager atende as instâncias com provedor sandbox: aceita os envios sem
+// falar com o WhatsApp, gera as confirmações de entrega e leitura conforme o
+// Schedule e injeta mensagens recebidas no mesmo pipeline de eventos das
+// demais instâncias. Implementa session.Messenger.
+type Manager struct {
+	instances storage.InstanceRepository
+	defaults  Schedule
+	log       *zap.Logger
+
+	mu        sync.Mutex
+	events    EventHandler
+	media     MediaStore
+	schedules map[string]Schedule
+	pending   map[*time.Timer]string
+	stopped   bool
+}
+
+func NewManager(instances storage.InstanceRepository, defaults Schedule, log *zap.Logger) *Manager {
+	return &Manager{
+		instances: instances,
+		defaults:  defaults,
+		log:       log,
+		schedules: make(map[string]Schedule),
+		pending:   make(map[*time.Timer]string),
+	}
+}
+
+func (m *Manager) SetEventHandler(h EventHandler) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.events = h
+}
+
+func (m *Manager) SetMediaStore(s MediaStore) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.media = s
+}
+
+// Schedule retorna o agendamento das confirmações da instância.
+func (m *Manager) Schedule(instanceID string) Schedule {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if s, ok := m.schedules[instanceID]; ok {
+		return s
+	}
+	return m.defaults
+}
+
+// SetSchedule troca o agendamento da instância. Vale para os próximos envios
+// e fica só em memória: ao reiniciar, a instância volta ao padrão.
+func (m *Manager) SetSchedule(instanceID string, s Schedule) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.schedules[instanceID] = s
+}
+
+// Connect marca a instância como ativa, atribuindo um número fictício na
+// primeira conexão, e emite events.Connected.
+func (m *Manager) Connect(ctx context.Context, instanceID string) error {
+	inst, err := m.instances.GetByID(ctx, instanceID)
+	if err != nil {
+		return err
+	}
+	if inst.Provider != model.InstanceProviderSandbox {
+		return session.ErrNotSupported
+	}
+	if inst.WhatsAppJID == "" {
+		inst.WhatsAppJID = types.NewJID(fakePhone(instanceID), types.DefaultUserServer).String()
+	}
+	inst.Status = model.InstanceStatusActive
+	if _, err := m.instances.Update(ctx, inst); err != nil {
+		return err
+	}
+
+	m.log.Info("instância sandbox conectada",
+		zap.String("instance_id", instanceID),
+		zap.String("jid", inst.WhatsAppJID))
+	m.dispatch(ctx, instanceID, inst.WhatsAppJID, &events.Connected{}, "")
+	return nil
+}
+
+// Disconnect descarta as confirmações pendentes, marca a instância como
+// desconectada e emite events.Disconnected.
+func (m *Manager) Disconnect(ctx context.Context, instanceID string) error {
+	m.cancel(instanceID)
+
+	inst, err := m.instances.GetByID(ctx, instanceID)
+	if err != nil {
+		return err
+	}
+	inst.Status = model.InstanceStatusDisconnected
+	if _, err := m.instances.Update(ctx, inst); err != nil {
+		return err
+	}
+
+	m.log.Info("instância sandbox desconectada", zap.String("instance_id", instanceID))
+	m.dispatch(ctx, instanceID, inst.WhatsAppJID, &events.Disconnected{}, "")
+	return nil
+}
+
+// Remove descarta o estado em memória da instância.
+func (m *Manager) Remove(instanceID string) {
+	m.cancel(instanceID)
+	m.mu.Lock()
+	delete(m.schedules, instanceID)
+	m.mu.Unlock()
+}
+
+// Stop descarta as confirmações pendentes de todas as instâncias. Usado no
+// encerramento, antes de drenar a fila de webhooks.
+func (m *Manager) Stop() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.stopped = true
+	for timer := range m.pending {
+		timer.Stop()
+	}
+	if n := len(m.pending); n > 0 {
+		m.log.Info("confirmações sandbox pendentes descartadas", zap.Int("total", n))
+	}
+	m.pending = make(map[*time.Timer]string)
+}
+
+func (m *Manager) instance(ctx context.Context, instanceID string) (model.Instance, error) {
+	inst, err := m.instances.GetByID(ctx, instanceID)
+	if err != nil {
+		return model.Instance{}, err
+	}
+	if inst.Status != model.InstanceStatusActive {
+		return model.Instance{}, fmt.Errorf("%w: instância sandbox %s", session.ErrNotConnected, inst.Status)
+	}
+	return inst, nil
+}
+
+func (m *Manager) Ready(ctx context.Context, instanceID string) error {
+	_, err := m.instance(ctx, instanceID)
+	return err
+}
+
+// ResolveJID aceita apenas números; grupos não são simulados.
+func (m *Manager) ResolveJID(ctx context.Context, instanceID, phone string) (types.JID, error) {
+	phone = strings.TrimSpace(phone)
+	if strings.Contains(phone, "@") {
+		jid, err := types.ParseJID(phone)
+		if err != nil {
+			return types.EmptyJID, err
+		}
+		if jid.Server != types.DefaultUserServer {
+			return types.EmptyJID, fmt.Errorf("%w: destino %s", session.ErrNotSupported, jid.Server)
+		}
+		phone = jid.User
+	}
+	phone = digits(phone)
+	if phone == "" {
+		return types.EmptyJID, errors.New("telefone vazio")
+	}
+	return types.NewJID(phone, types.DefaultUserServer), nil
+}
+
+// CheckPhones considera que todo número válido está no WhatsApp.
+func (m *Manager) CheckPhones(ctx context.Context, instanceID string, phones []string) ([]types.IsOnWhatsAppResponse, error) {
+	if _, err := m.instance(ctx, instanceID); err != nil {
+		return nil, err
+	}
+	results := make([]types.IsOnWhatsAppResponse, 0, len(phones))
+	for _, phone := range phones {
+		number := digits(phone)
+		result := types.IsOnWhatsAppResponse{Query: phone, IsIn: number != ""}
+		if result.IsIn {
+			result.JID = types.NewJID(number, types.DefaultUserServer)
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+func (m *Manager) Prepare(ctx context.Context, instanceID string, to types.JID) error {
+	return nil
+}
+
+// Send aceita a mensagem e agenda as confirmações de entrega e leitura.
+func (m *Manager) Send(ctx context.Context, instanceID string, to types.JID, msg session.OutgoingMessage) (session.SentMessage, error) {
+	inst, err := m.instance(ctx, instanceID)
+	if err != nil {
+		return session.SentMessage{}, err
+	}
+
+	id := newMessageID()
+	m.scheduleReceipts(inst, to, id)
+
+	m.log.Info("mensagem aceita pelo sandbox",
+		zap.String("instance_id", instanceID),
+		zap.String("to", to.String()),
+		zap.String("type", msg.Type),
+		zap.String("server_id", id))
+	return session.SentMessage{ID: id, Timestamp: time.Now()}, nil
+}
+
+func (m *Manager) Upload(ctx context.Context, instanceID string, data []byte, mimeType string, kind session.MediaKind) (session.UploadedMedia, error) {
+	if _, err := m.instance(ctx, instanceID); err != nil {
+		return session.UploadedMedia{}, err
+	}
+	sum := sha256.Sum256(data)
+	return session.UploadedMedia{ID: hex.EncodeToString(sum[:16])}, nil
+}
+
+func (m *Manager) MarkRead(ctx context.Context, instanceID string, chat, sender types.JID, ids []string, played bool) error {
+	_, err := m.instance(ctx, instanceID)
+	return err
+}
+
+func (m *Manager) Revoke(ctx context.Context, instanceID string, chat, sender types.JID, id string) (session.SentMessage, error) {
+	if _, err := m.instance(ctx, instanceID); err != nil {
+		return session.SentMessage{}, err
+	}
+	return session.SentMessage{ID: newMessageID(), Timestamp: time.Now()}, nil
+}
+
+func (m *Manager) SendPresence(ctx context.Context, instanceID string, presence types.Presence) error {
+	_, err := m.instance(ctx, instanceID)
+	return err
+}
+
+func (m *Manager) SendChatPresence(ctx context.Context, instanceID string, chat types.JID, state types.ChatPresence, media types.ChatPresenceMedia) error {
+	_, err := m.instance(ctx, instanceID)
+	return err
+}
+
+func (m *Manager) GetJoinedGroups(ctx context.Context, instanceID string) ([]*types.GroupInfo, error) {
+	return nil, session.ErrNotSupported
+}
+
+func (m *Manager) GetGroupInfo(ctx context.Context, instanceID string, group types.JID) (*types.GroupInfo, error) {
+	return nil, session.ErrNotSupported
+}
+
+func (m *Manager) CreateGroup(ctx context.Context, instanceID, name string, participants []types.JID) (*types.GroupInfo, error) {
+	return nil, session.ErrNotSupported
+}
+
+func (m *Manager) UpdateGroupParticipants(ctx context.Context, instanceID string, group types.JID, participants []types.JID, action session.ParticipantAction) ([]types.GroupParticipant, error) {
+	return nil, session.ErrNotSupported
+}
+
+func (m *Manager) LeaveGroup(ctx context.Context, instanceID string, group types.JID) error {
+	return session.ErrNotSupported
+}
+
+// scheduleReceipts agenda as confirmações da mensagem enviada conforme o
+// Schedule da instância.
+func (m *Manager) scheduleReceipts(inst model.Instance, to types.JID, messageID string) {
+	schedule := m.Schedule(inst.ID)
+	if schedule.DeliveredAfter >= 0 {
+		m.after(inst, schedule.DeliveredAfter, to, messageID, types.ReceiptTypeDelivered)
+	}
+	if schedule.ReadAfter >= 0 {
+		m.after(inst, max(schedule.ReadAfter, schedule.DeliveredAfter), to, messageID, types.ReceiptTypeRead)
+	}
+}
+
+func (m *Manager) after(inst model.Instance, delay time.Duration, to types.JID, messageID string, receiptType types.ReceiptType) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.stopped {
+		return
+	}
+
+	var timer *time.Timer
+	timer = time.AfterFunc(delay, func() {
+		m.mu.Lock()
+		_, ok := m.pending[timer]
+		delete(m.pending, timer)
+		m.mu.Unlock()
+		if !ok {
+			return
+		}
+
+		m.dispatch(context.Background(), inst.ID, inst.WhatsAppJID, &events.Receipt{
+			MessageSource: types.MessageSource{Chat: to, Sender: to},
+			MessageIDs:    []types.MessageID{messageID},
+			Timestamp:     time.Now(),
+			Type:          receiptType,
+		}, "")
+	})
+	m.pending[timer] = inst.ID
+}
+
+// cancel descarta as confirmações pendentes da instância.
+func (m *Manager) cancel(instanceID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for timer, id := range m.pending {
+		if id == instanceID {
+			timer.Stop()
+			delete(m.pending, timer)
+		}
+	}
+}
+
+func (m *Manager) dispatch(ctx context.Context, instanceID, instanceJID string, evt any, mediaID string) {
+	m.mu.Lock()
+	handler := m.events
+	m.mu.Unlock()
+	if handler == nil {
+		return
+	}
+	handler.HandleWithMedia(ctx, instanceID, instanceJID, evt, mediaID)
+}
+
+// newMessageID gera um ID no formato usado pelo WhatsApp Web (3EB0 + hex).
+func newMessageID() string {
+	b := make([]byte, 9)
+	_, _ = rand.Read(b)
+	return "3EB0" + strings.ToUpper(hex.EncodeToString(b))
+}
+
+// fakePhone deriva da instância um número fictício e estável, no DDI 99,
+// que não é atribuído a nenhum país.
+func fakePhone(instanceID string) string {
+	sum := sha256.Sum256([]byte(instanceID))
+	return fmt.Sprintf("99%010d", binary.BigEndian.Uint64(sum[:8])%10_000_000_000)
+}
+
+func digits(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, s)
+}
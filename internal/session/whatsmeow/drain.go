@@ -0,0 +1,67 @@
+package whatsmeow
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+)
+
+// Drain prepara o encerramento do nó: impede novas conexões, grava o blob de
+// sessão de cada instância conectada e desconecta os clientes sem logout,
+// deixando o device store intacto para o próximo nó ou a próxima
+// inicialização. Retorna a quantidade de sessões liberadas.
+func (m *Manager) Drain(ctx context.Context) int {
+	m.mu.Lock()
+	m.draining = true
+	instanceIDs := make([]string, 0, len(m.clients))
+	for instanceID := range m.clients {
+		instanceIDs = append(instanceIDs, instanceID)
+	}
+	m.mu.Unlock()
+
+	for _, instanceID := range instanceIDs {
+		if ctx.Err() != nil {
+			m.log.Warn("prazo de dreno esgotado, liberando sessões sem gravar blob",
+				zap.String("instance_id", instanceID),
+			)
+		} else {
+			m.flushSessionBlob(ctx, instanceID)
+		}
+		m.ReleaseSession(instanceID)
+	}
+
+	m.log.Info("sessões drenadas", zap.Int("total", len(instanceIDs)))
+	return len(instanceIDs)
+}
+
+func (m *Manager) flushSessionBlob(ctx context.Context, instanceID string) {
+	if m.instanceRepo == nil {
+		return
+	}
+
+	blob, err := m.SaveSessionBlob(instanceID)
+	if err != nil {
+		m.log.Warn("erro ao gerar blob de sessão no dreno",
+			zap.String("instance_id", instanceID),
+			zap.Error(err),
+		)
+		return
+	}
+
+	inst, err := m.instanceRepo.GetByID(ctx, instanceID)
+	if err != nil {
+		m.log.Warn("erro ao buscar instância no dreno",
+			zap.String("instance_id", instanceID),
+			zap.Error(err),
+		)
+		return
+	}
+
+	inst.SessionBlob = blob
+	if _, err := m.instanceRepo.Update(ctx, inst); err != nil {
+		m.log.Warn("erro ao gravar blob de sessão no dreno",
+			zap.String("instance_id", instanceID),
+			zap.Error(err),
+		)
+	}
+}
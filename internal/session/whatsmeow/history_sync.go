@@ -2,25 +2,63 @@ package whatsmeow
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"time"
 
 	"github.com/google/uuid"
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
 	"go.uber.org/zap"
+	"google.golang.org/protobuf/proto"
 
 	"github.com/open-apime/apime/internal/storage/model"
 )
 
-func (m *Manager) initHistorySyncCycle(instanceID string) {
+const (
+	historySyncPollInterval = 2 * time.Second
+	// historySyncIdleTimeout encerra o ciclo quando nenhum novo chunk chega
+	// depois do último processado.
+	historySyncIdleTimeout = 90 * time.Second
+	// historySyncStartTimeout encerra o ciclo quando o celular não envia nenhum chunk.
+	historySyncStartTimeout = 5 * time.Minute
+)
+
+var errHistorySyncNoClient = errors.New("cliente não disponível para history sync")
+
+// HistoryRecorder importa mensagens vindas do history sync para o histórico
+// de conversas.
+type HistoryRecorder interface {
+	ImportEvent(ctx context.Context, instanceID string, evt *events.Message, chatName string) (bool, error)
+}
+
+// HistorySyncCompleted é entregue ao EventHandler quando um ciclo de history
+// sync é finalizado.
+type HistorySyncCompleted struct {
+	Status     model.HistorySyncStatus   `json:"status"`
+	Progress   model.HistorySyncProgress `json:"progress"`
+	StartedAt  time.Time                 `json:"startedAt"`
+	FinishedAt time.Time                 `json:"finishedAt"`
+}
+
+func (m *Manager) SetHistoryRecorder(recorder HistoryRecorder) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.historyRecorder = recorder
+}
+
+// initHistorySyncCycle inicia um ciclo de history sync para a instância e
+// retorna seu ID. Se já houver um ciclo em andamento, ele é reaproveitado.
+func (m *Manager) initHistorySyncCycle(instanceID string) string {
 	ctx := context.Background()
-	
+
 	if m.instanceRepo == nil || m.historySyncRepo == nil {
 		m.log.Warn("repositórios não disponíveis para history sync", zap.String("instance_id", instanceID))
-		return
+		return ""
 	}
 
-	cycleID := uuid.New().String()
-	now := time.Now()
+	m.historySyncMu.Lock()
+	defer m.historySyncMu.Unlock()
 
 	inst, err := m.instanceRepo.GetByID(ctx, instanceID)
 	if err != nil {
@@ -28,9 +66,19 @@ func (m *Manager) initHistorySyncCycle(instanceID string) {
 			zap.String("instance_id", instanceID),
 			zap.Error(err),
 		)
-		return
+		return ""
+	}
+
+	m.mu.RLock()
+	_, workerRunning := m.syncWorkers[instanceID]
+	m.mu.RUnlock()
+	if workerRunning && inst.HistorySyncStatus == model.HistorySyncStatusRunning && inst.HistorySyncCycleID != "" {
+		return inst.HistorySyncCycleID
 	}
 
+	cycleID := uuid.New().String()
+	now := time.Now()
+
 	inst.HistorySyncCycleID = cycleID
 	inst.HistorySyncStatus = model.HistorySyncStatusRunning
 	inst.HistorySyncUpdatedAt = &now
@@ -41,7 +89,7 @@ func (m *Manager) initHistorySyncCycle(instanceID string) {
 			zap.String("cycle_id", cycleID),
 			zap.Error(err),
 		)
-		return
+		return ""
 	}
 
 	m.log.Info("ciclo de history sync iniciado",
@@ -49,6 +97,58 @@ func (m *Manager) initHistorySyncCycle(instanceID string) {
 		zap.String("cycle_id", cycleID),
 	)
 
+	m.startHistorySyncWorker(instanceID, cycleID)
+	return cycleID
+}
+
+// resumeHistorySyncCycle retoma, na reconexão, o ciclo interrompido pela
+// desconexão ou deixado em andamento sem worker (ex.: após reiniciar o nó),
+// processando os chunks que ficaram pendentes.
+func (m *Manager) resumeHistorySyncCycle(instanceID string) {
+	ctx := context.Background()
+
+	if m.instanceRepo == nil || m.historySyncRepo == nil {
+		return
+	}
+
+	m.historySyncMu.Lock()
+	defer m.historySyncMu.Unlock()
+
+	m.mu.RLock()
+	_, workerRunning := m.syncWorkers[instanceID]
+	m.mu.RUnlock()
+	if workerRunning {
+		return
+	}
+
+	inst, err := m.instanceRepo.GetByID(ctx, instanceID)
+	if err != nil || inst.HistorySyncCycleID == "" {
+		return
+	}
+	if inst.HistorySyncStatus != model.HistorySyncStatusInterrupted && inst.HistorySyncStatus != model.HistorySyncStatusRunning {
+		return
+	}
+
+	now := time.Now()
+	inst.HistorySyncStatus = model.HistorySyncStatusRunning
+	inst.HistorySyncUpdatedAt = &now
+	if _, err := m.instanceRepo.Update(ctx, inst); err != nil {
+		m.log.Error("erro ao retomar ciclo de history sync",
+			zap.String("instance_id", instanceID),
+			zap.String("cycle_id", inst.HistorySyncCycleID),
+			zap.Error(err),
+		)
+		return
+	}
+
+	m.log.Info("ciclo de history sync retomado",
+		zap.String("instance_id", instanceID),
+		zap.String("cycle_id", inst.HistorySyncCycleID),
+	)
+	m.startHistorySyncWorker(instanceID, inst.HistorySyncCycleID)
+}
+
+func (m *Manager) startHistorySyncWorker(instanceID, cycleID string) {
 	workerCtx, workerCancel := context.WithCancel(context.Background())
 	m.mu.Lock()
 	if oldCancel, exists := m.syncWorkers[instanceID]; exists {
@@ -60,24 +160,23 @@ func (m *Manager) initHistorySyncCycle(instanceID string) {
 	go m.runHistorySyncWorker(workerCtx, instanceID, cycleID)
 }
 
-func (m *Manager) persistHistorySyncNotification(instanceID string, notif any) {
+// persistHistorySyncNotification grava a notificação recebida do celular para
+// ser baixada e importada pelo worker do ciclo atual. Notificações que chegam
+// fora de um ciclo ativo (ex.: após restaurar a sessão) abrem um novo ciclo.
+func (m *Manager) persistHistorySyncNotification(instanceID string, notif *waE2E.HistorySyncNotification) {
 	ctx := context.Background()
 
-	if m.historySyncRepo == nil {
+	if m.historySyncRepo == nil || m.instanceRepo == nil {
 		m.log.Warn("history sync repo não disponível", zap.String("instance_id", instanceID))
 		return
 	}
 
-	inst, err := m.instanceRepo.GetByID(ctx, instanceID)
-	if err != nil {
-		m.log.Error("erro ao buscar instância para persistir notification",
-			zap.String("instance_id", instanceID),
-			zap.Error(err),
-		)
+	cycleID := m.initHistorySyncCycle(instanceID)
+	if cycleID == "" {
 		return
 	}
 
-	payloadBytes, err := json.Marshal(notif)
+	payloadBytes, err := proto.Marshal(notif)
 	if err != nil {
 		m.log.Error("erro ao serializar notification de history sync",
 			zap.String("instance_id", instanceID),
@@ -90,7 +189,7 @@ func (m *Manager) persistHistorySyncNotification(instanceID string, notif any) {
 		InstanceID:  instanceID,
 		PayloadType: "HistorySyncNotification",
 		Payload:     payloadBytes,
-		CycleID:     inst.HistorySyncCycleID,
+		CycleID:     cycleID,
 		Status:      model.HistorySyncPayloadPending,
 		CreatedAt:   time.Now(),
 	}
@@ -105,71 +204,265 @@ func (m *Manager) persistHistorySyncNotification(instanceID string, notif any) {
 
 	m.log.Info("notification de history sync persistido",
 		zap.String("instance_id", instanceID),
-		zap.String("cycle_id", inst.HistorySyncCycleID),
+		zap.String("cycle_id", cycleID),
+		zap.String("sync_type", notif.GetSyncType().String()),
+		zap.Uint32("chunk_order", notif.GetChunkOrder()),
+		zap.Uint32("progress", notif.GetProgress()),
 	)
 }
 
+// runHistorySyncWorker processa os chunks do ciclo conforme chegam e finaliza
+// o ciclo quando o celular para de enviar novos chunks.
 func (m *Manager) runHistorySyncWorker(ctx context.Context, instanceID, cycleID string) {
 	m.log.Info("worker de history sync iniciado",
 		zap.String("instance_id", instanceID),
 		zap.String("cycle_id", cycleID),
 	)
 
-	// Com ManualHistorySyncDownload ativado, o histórico não é baixado automaticamente
-	// O worker apenas marca o ciclo como completo após um tempo
-	time.Sleep(10 * time.Second)
+	startedAt := time.Now()
+	lastActivity := startedAt
+	receivedAny := false
+
+	ticker := time.NewTicker(historySyncPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			m.log.Info("worker de history sync interrompido",
+				zap.String("instance_id", instanceID),
+				zap.String("cycle_id", cycleID),
+			)
+			return
+		case <-ticker.C:
+		}
+
+		payloads, err := m.historySyncRepo.ListPendingByCycle(ctx, instanceID, cycleID)
+		if err != nil {
+			m.log.Error("erro ao listar chunks pendentes de history sync",
+				zap.String("instance_id", instanceID),
+				zap.String("cycle_id", cycleID),
+				zap.Error(err),
+			)
+			continue
+		}
+
+		deferred := false
+		for _, payload := range payloads {
+			if payload.Status == model.HistorySyncPayloadError {
+				continue
+			}
+			receivedAny = true
+
+			if !m.handleHistorySyncPayload(ctx, instanceID, payload) {
+				deferred = true
+				break
+			}
+			lastActivity = time.Now()
+
+			if progress, err := m.historySyncRepo.GetCycleProgress(ctx, instanceID, cycleID); err == nil {
+				m.log.Info("progresso do history sync",
+					zap.String("instance_id", instanceID),
+					zap.String("cycle_id", cycleID),
+					zap.Int("chunks_done", progress.ChunksDone),
+					zap.Int("chunks_total", progress.ChunksTotal),
+					zap.Int("messages_imported", progress.MessagesImported),
+				)
+			}
+		}
+
+		// Sem cliente, os chunks não podem ser baixados: o ciclo fica
+		// interrompido e é retomado na reconexão.
+		if deferred {
+			m.interruptHistorySyncCycle(instanceID, cycleID)
+			return
+		}
+
+		idle := time.Since(lastActivity)
+		if (receivedAny && idle >= historySyncIdleTimeout) || (!receivedAny && idle >= historySyncStartTimeout) {
+			progress, err := m.historySyncRepo.GetCycleProgress(ctx, instanceID, cycleID)
+			if err != nil {
+				continue
+			}
+			// Chunks ainda sem resultado mantêm o ciclo aberto.
+			if progress.ChunksTotal > progress.ChunksDone+progress.ChunksFailed {
+				continue
+			}
+			status := model.HistorySyncStatusCompleted
+			if progress.ChunksFailed > 0 && progress.ChunksDone == 0 {
+				status = model.HistorySyncStatusFailed
+			}
+			m.finalizeHistorySyncCycle(instanceID, cycleID, status, startedAt)
+			return
+		}
+	}
+}
 
-	m.log.Info("finalizando ciclo de history sync (modo manual ativado)",
-		zap.String("instance_id", instanceID),
-		zap.String("cycle_id", cycleID),
-	)
-	
-	m.finalizeHistorySyncCycle(instanceID, model.HistorySyncStatusCompleted)
+// handleHistorySyncPayload processa um chunk e registra o resultado. Retorna
+// false quando o processamento deve ser adiado (ex.: cliente desconectado).
+func (m *Manager) handleHistorySyncPayload(ctx context.Context, instanceID string, payload model.WhatsappHistorySync) bool {
+	if err := m.historySyncRepo.UpdateStatus(ctx, payload.ID, model.HistorySyncPayloadProcessing, nil); err != nil {
+		m.log.Warn("erro ao marcar chunk de history sync em processamento",
+			zap.String("payload_id", payload.ID),
+			zap.Error(err),
+		)
+	}
+
+	imported, err := m.processHistorySyncPayload(ctx, instanceID, payload)
+	now := time.Now()
+	if errors.Is(err, errHistorySyncNoClient) {
+		_ = m.historySyncRepo.UpdateStatus(ctx, payload.ID, model.HistorySyncPayloadPending, nil)
+		return false
+	}
+	if err != nil {
+		m.log.Error("erro ao processar chunk de history sync",
+			zap.String("instance_id", instanceID),
+			zap.String("payload_id", payload.ID),
+			zap.Error(err),
+		)
+		_ = m.historySyncRepo.UpdateResult(ctx, payload.ID, model.HistorySyncPayloadError, &now, imported)
+		return true
+	}
+
+	if err := m.historySyncRepo.UpdateResult(ctx, payload.ID, model.HistorySyncPayloadDone, &now, imported); err != nil {
+		m.log.Warn("erro ao registrar resultado do chunk de history sync",
+			zap.String("payload_id", payload.ID),
+			zap.Error(err),
+		)
+	}
+	return true
 }
 
-func (m *Manager) processHistorySyncPayload(ctx context.Context, instanceID string, payload model.WhatsappHistorySync) error {
+// processHistorySyncPayload baixa o blob do chunk, decodifica as conversas e
+// importa as mensagens. Retorna a quantidade de mensagens novas importadas.
+func (m *Manager) processHistorySyncPayload(ctx context.Context, instanceID string, payload model.WhatsappHistorySync) (int, error) {
 	m.mu.RLock()
 	client, exists := m.clients[instanceID]
+	recorder := m.historyRecorder
 	m.mu.RUnlock()
 
-	if !exists || client == nil {
-		return nil
+	if !exists || client == nil || !client.IsConnected() {
+		return 0, errHistorySyncNoClient
+	}
+
+	var notif waE2E.HistorySyncNotification
+	if err := proto.Unmarshal(payload.Payload, &notif); err != nil {
+		return 0, err
 	}
 
-	// Deserializar a notificação original
-	var notif struct {
-		FileSHA256 []byte `json:"fileSHA256"`
-		FileLength uint64 `json:"fileLength"`
-		MediaKey   []byte `json:"mediaKey"`
-		FileEncSHA256 []byte `json:"fileEncSHA256"`
-		DirectPath string `json:"directPath"`
-		SyncType   int32  `json:"syncType"`
-		ChunkOrder uint32 `json:"chunkOrder"`
+	data, err := client.DownloadHistorySync(ctx, &notif, true)
+	if err != nil {
+		return 0, err
+	}
+
+	m.log.Info("processando history sync",
+		zap.String("instance_id", instanceID),
+		zap.String("payload_id", payload.ID),
+		zap.String("sync_type", data.GetSyncType().String()),
+		zap.Uint32("chunk_order", data.GetChunkOrder()),
+		zap.Int("conversations", len(data.GetConversations())),
+	)
+
+	for _, mapping := range data.GetPhoneNumberToLidMappings() {
+		lid, lidErr := types.ParseJID(mapping.GetLidJID())
+		pn, pnErr := types.ParseJID(mapping.GetPnJID())
+		if lidErr == nil && pnErr == nil {
+			m.learnLIDPair(ctx, instanceID, lid, pn, model.LIDMappingSourceHistorySync)
+		}
+	}
+	for _, conv := range data.GetConversations() {
+		lid, lidErr := types.ParseJID(conv.GetLidJID())
+		pn, pnErr := types.ParseJID(conv.GetPnJID())
+		if lidErr == nil && pnErr == nil {
+			m.learnLIDPair(ctx, instanceID, lid, pn, model.LIDMappingSourceHistorySync)
+		}
+	}
+
+	if recorder == nil {
+		return 0, nil
+	}
+
+	imported := 0
+	for _, conv := range data.GetConversations() {
+		chatJID, err := types.ParseJID(conv.GetID())
+		if err != nil {
+			m.log.Debug("JID de conversa inválido no history sync",
+				zap.String("instance_id", instanceID),
+				zap.String("jid", conv.GetID()),
+			)
+			continue
+		}
+
+		chatName := conv.GetName()
+		if chatName == "" {
+			chatName = conv.GetDisplayName()
+		}
+
+		for _, histMsg := range conv.GetMessages() {
+			webMsg := histMsg.GetMessage()
+			if webMsg == nil {
+				continue
+			}
+
+			evt, err := client.ParseWebMessage(chatJID, webMsg)
+			if err != nil {
+				continue
+			}
+			m.learnLIDs(ctx, instanceID, &evt.Info.MessageSource, model.LIDMappingSourceHistorySync)
+
+			created, err := recorder.ImportEvent(ctx, instanceID, evt, chatName)
+			if err != nil {
+				return imported, err
+			}
+			if created {
+				imported++
+			}
+		}
+	}
+
+	return imported, nil
+}
+
+// interruptHistorySyncCycle marca o ciclo como interrompido e encerra o
+// worker, preservando os chunks pendentes para a retomada.
+func (m *Manager) interruptHistorySyncCycle(instanceID, cycleID string) {
+	ctx := context.Background()
+
+	m.historySyncMu.Lock()
+	defer m.historySyncMu.Unlock()
+
+	inst, err := m.instanceRepo.GetByID(ctx, instanceID)
+	if err != nil || inst.HistorySyncCycleID != cycleID {
+		return
 	}
-	
-	if err := json.Unmarshal(payload.Payload, &notif); err != nil {
-		m.log.Error("erro ao deserializar notification",
+
+	now := time.Now()
+	inst.HistorySyncStatus = model.HistorySyncStatusInterrupted
+	inst.HistorySyncUpdatedAt = &now
+	if _, err := m.instanceRepo.Update(ctx, inst); err != nil {
+		m.log.Error("erro ao marcar ciclo de history sync como interrompido",
 			zap.String("instance_id", instanceID),
-			zap.String("payload_id", payload.ID),
+			zap.String("cycle_id", cycleID),
 			zap.Error(err),
 		)
-		return err
 	}
 
-	m.log.Info("processando history sync notification",
+	m.log.Info("ciclo de history sync interrompido pela desconexão",
 		zap.String("instance_id", instanceID),
-		zap.String("payload_id", payload.ID),
-		zap.Int32("sync_type", notif.SyncType),
+		zap.String("cycle_id", cycleID),
 	)
 
-	// Marcar como processado sem fazer download real para evitar erros
-	// O histórico já foi baixado automaticamente pelo WhatsMeow antes de ativarmos ManualHistorySyncDownload
-	return nil
+	m.mu.Lock()
+	if cancel, exists := m.syncWorkers[instanceID]; exists {
+		cancel()
+		delete(m.syncWorkers, instanceID)
+	}
+	m.mu.Unlock()
 }
 
-func (m *Manager) finalizeHistorySyncCycle(instanceID string, status model.HistorySyncStatus) {
+func (m *Manager) finalizeHistorySyncCycle(instanceID, cycleID string, status model.HistorySyncStatus, startedAt time.Time) {
 	ctx := context.Background()
-	
+
 	if m.instanceRepo == nil {
 		return
 	}
@@ -183,6 +476,11 @@ func (m *Manager) finalizeHistorySyncCycle(instanceID string, status model.Histo
 		return
 	}
 
+	if inst.HistorySyncCycleID != cycleID {
+		// Um novo ciclo já substituiu este; não sobrescrever o estado dele
+		return
+	}
+
 	now := time.Now()
 	inst.HistorySyncStatus = status
 	inst.HistorySyncUpdatedAt = &now
@@ -195,9 +493,18 @@ func (m *Manager) finalizeHistorySyncCycle(instanceID string, status model.Histo
 		return
 	}
 
+	progress := model.HistorySyncProgress{CycleID: cycleID}
+	if p, err := m.historySyncRepo.GetCycleProgress(ctx, instanceID, cycleID); err == nil {
+		progress = p
+	}
+
 	m.log.Info("ciclo de history sync finalizado",
 		zap.String("instance_id", instanceID),
+		zap.String("cycle_id", cycleID),
 		zap.String("status", string(status)),
+		zap.Int("chunks_done", progress.ChunksDone),
+		zap.Int("chunks_total", progress.ChunksTotal),
+		zap.Int("messages_imported", progress.MessagesImported),
 	)
 
 	m.mu.Lock()
@@ -205,5 +512,33 @@ func (m *Manager) finalizeHistorySyncCycle(instanceID string, status model.Histo
 		cancel()
 		delete(m.syncWorkers, instanceID)
 	}
+	handler := m.eventHandler
+	client := m.clients[instanceID]
 	m.mu.Unlock()
+
+	if handler != nil {
+		go handler.Handle(context.Background(), instanceID, inst.WhatsAppJID, client, &HistorySyncCompleted{
+			Status:     status,
+			Progress:   progress,
+			StartedAt:  startedAt,
+			FinishedAt: now,
+		})
+	}
+}
+
+// GetHistorySyncProgress retorna o progresso do ciclo de history sync atual da instância.
+func (m *Manager) GetHistorySyncProgress(instanceID string) (model.HistorySyncProgress, error) {
+	if m.instanceRepo == nil || m.historySyncRepo == nil {
+		return model.HistorySyncProgress{}, errors.New("history sync não disponível")
+	}
+
+	ctx := context.Background()
+	inst, err := m.instanceRepo.GetByID(ctx, instanceID)
+	if err != nil {
+		return model.HistorySyncProgress{}, err
+	}
+	if inst.HistorySyncCycleID == "" {
+		return model.HistorySyncProgress{}, nil
+	}
+	return m.historySyncRepo.GetCycleProgress(ctx, instanceID, inst.HistorySyncCycleID)
 }
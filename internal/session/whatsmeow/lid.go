@@ -0,0 +1,201 @@
+package whatsmeow
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"go.mau.fi/whatsmeow/types"
+	"go.uber.org/zap"
+
+	"github.com/open-apime/apime/internal/session"
+	"github.com/open-apime/apime/internal/storage"
+	"github.com/open-apime/apime/internal/storage/model"
+)
+
+func (m *Manager) SetLIDMappingRepository(repo storage.LIDMappingRepository) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.lidRepo = repo
+}
+
+func (m *Manager) getLIDRepo() storage.LIDMappingRepository {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.lidRepo
+}
+
+// ResolveLID retorna o número associado ao LID. Quando o repositório ainda não
+// conhece o LID, consulta o store da sessão e grava o resultado.
+func (m *Manager) ResolveLID(ctx context.Context, instanceID, lid string) (model.LIDMapping, error) {
+	lid = strings.TrimSpace(lid)
+	if !strings.Contains(lid, "@") {
+		lid += "@" + types.HiddenUserServer
+	}
+	jid, err := types.ParseJID(lid)
+	if err != nil || jid.Server != types.HiddenUserServer {
+		return model.LIDMapping{}, fmt.Errorf("%w: %s", session.ErrInvalidJID, lid)
+	}
+	jid = jid.ToNonAD()
+
+	if repo := m.getLIDRepo(); repo != nil {
+		mapping, err := repo.GetByLID(ctx, instanceID, jid.String())
+		if err == nil {
+			return mapping, nil
+		}
+		if !strings.Contains(err.Error(), "not found") {
+			return model.LIDMapping{}, err
+		}
+	}
+
+	pn, ok := m.sessionPNForLID(ctx, instanceID, jid)
+	if !ok {
+		return model.LIDMapping{}, storage.ErrNotFound
+	}
+	m.rememberLID(ctx, instanceID, jid, pn, model.LIDMappingSourceSession)
+	return model.LIDMapping{
+		InstanceID: instanceID,
+		LID:        jid.String(),
+		PN:         pn.String(),
+		Source:     model.LIDMappingSourceSession,
+	}, nil
+}
+
+// learnLIDs grava as associações presentes na origem da mensagem e completa
+// SenderAlt e RecipientAlt com o número conhecido quando o WhatsApp só enviou
+// o LID, para que os webhooks e o histórico usem sempre o número.
+func (m *Manager) learnLIDs(ctx context.Context, instanceID string, src *types.MessageSource, source model.LIDMappingSource) {
+	m.learnLIDPair(ctx, instanceID, src.Sender, src.SenderAlt, source)
+	if !src.IsGroup {
+		m.learnLIDPair(ctx, instanceID, src.Chat, src.RecipientAlt, source)
+	}
+
+	if src.Sender.Server == types.HiddenUserServer && src.SenderAlt.IsEmpty() {
+		if pn, ok := m.phoneForLID(ctx, instanceID, src.Sender); ok {
+			src.SenderAlt = pn
+		}
+	}
+	if src.IsFromMe && !src.IsGroup && src.Chat.Server == types.HiddenUserServer && src.RecipientAlt.IsEmpty() {
+		if pn, ok := m.phoneForLID(ctx, instanceID, src.Chat); ok {
+			src.RecipientAlt = pn
+		}
+	}
+}
+
+// learnGroupParticipants grava as associações dos participantes de grupos
+// que trazem LID e número.
+func (m *Manager) learnGroupParticipants(ctx context.Context, instanceID string, participants []types.GroupParticipant) {
+	for _, p := range participants {
+		m.learnLIDPair(ctx, instanceID, p.LID, p.PhoneNumber, model.LIDMappingSourceGroup)
+	}
+}
+
+// learnLIDPair grava a associação quando um dos JIDs é LID e o outro é número,
+// em qualquer ordem.
+func (m *Manager) learnLIDPair(ctx context.Context, instanceID string, a, b types.JID, source model.LIDMappingSource) {
+	switch {
+	case a.Server == types.HiddenUserServer && b.Server == types.DefaultUserServer:
+		m.rememberLID(ctx, instanceID, a, b, source)
+	case a.Server == types.DefaultUserServer && b.Server == types.HiddenUserServer:
+		m.rememberLID(ctx, instanceID, b, a, source)
+	}
+}
+
+func (m *Manager) rememberLID(ctx context.Context, instanceID string, lid, pn types.JID, source model.LIDMappingSource) {
+	lid, pn = lid.ToNonAD(), pn.ToNonAD()
+	if lid.User == "" || pn.User == "" {
+		return
+	}
+
+	key := instanceID + "|" + lid.String()
+	m.lidMu.Lock()
+	known := m.lidCache[key] == pn.String()
+	m.lidMu.Unlock()
+	if known {
+		return
+	}
+
+	if repo := m.getLIDRepo(); repo != nil {
+		err := repo.Upsert(ctx, model.LIDMapping{
+			InstanceID: instanceID,
+			LID:        lid.String(),
+			PN:         pn.String(),
+			Source:     source,
+		})
+		if err != nil {
+			m.log.Warn("erro ao gravar associação LID/número",
+				zap.String("instance_id", instanceID),
+				zap.String("lid", lid.String()),
+				zap.Error(err),
+			)
+			return
+		}
+	}
+
+	m.lidMu.Lock()
+	m.lidCache[key] = pn.String()
+	m.lidMu.Unlock()
+}
+
+// phoneForLID busca o número do LID no cache, no repositório e, por último,
+// no store da sessão.
+func (m *Manager) phoneForLID(ctx context.Context, instanceID string, lid types.JID) (types.JID, bool) {
+	lid = lid.ToNonAD()
+	key := instanceID + "|" + lid.String()
+
+	m.lidMu.Lock()
+	cached, ok := m.lidCache[key]
+	m.lidMu.Unlock()
+	if ok {
+		if pn, err := types.ParseJID(cached); err == nil {
+			return pn, true
+		}
+	}
+
+	if repo := m.getLIDRepo(); repo != nil {
+		if mapping, err := repo.GetByLID(ctx, instanceID, lid.String()); err == nil {
+			if pn, err := types.ParseJID(mapping.PN); err == nil {
+				m.lidMu.Lock()
+				m.lidCache[key] = mapping.PN
+				m.lidMu.Unlock()
+				return pn, true
+			}
+		}
+	}
+
+	pn, ok := m.sessionPNForLID(ctx, instanceID, lid)
+	if !ok {
+		return types.JID{}, false
+	}
+	m.rememberLID(ctx, instanceID, lid, pn, model.LIDMappingSourceSession)
+	return pn, true
+}
+
+// sessionPNForLID consulta as associações que o próprio whatsmeow mantém no
+// store da sessão.
+func (m *Manager) sessionPNForLID(ctx context.Context, instanceID string, lid types.JID) (types.JID, bool) {
+	m.mu.RLock()
+	client, exists := m.clients[instanceID]
+	m.mu.RUnlock()
+	if !exists || client == nil || client.Store == nil || client.Store.LIDs == nil {
+		return types.JID{}, false
+	}
+
+	pn, err := client.Store.LIDs.GetPNForLID(ctx, lid)
+	if err != nil || pn.IsEmpty() {
+		return types.JID{}, false
+	}
+	return pn.ToNonAD(), true
+}
+
+// forgetLIDs descarta o cache da instância.
+func (m *Manager) forgetLIDs(instanceID string) {
+	prefix := instanceID + "|"
+	m.lidMu.Lock()
+	defer m.lidMu.Unlock()
+	for key := range m.lidCache {
+		if strings.HasPrefix(key, prefix) {
+			delete(m.lidCache, key)
+		}
+	}
+}
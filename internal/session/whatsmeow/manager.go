@@ -59,11 +59,14 @@ type Manager struct {
 	clients            map[string]*whatsmeow.Client
 	currentQRs         map[string]string
 	qrContexts         map[string]context.CancelFunc
+	qrDone             map[string]chan struct{}
+	qrStartedAt        map[string]time.Time
+	pairCodes          map[string]model.PairCode
 	pairingSuccess     map[string]time.Time
 	sessionReady       map[string]bool
 	mu                 sync.RWMutex
 	log                *zap.Logger
-	encKey             string
+	keyring            *crypto.Keyring
 	storageDriver      string
 	baseDir            string
 	pgConnString       string
@@ -72,15 +75,41 @@ type Manager struct {
 	historySyncRepo    storage.HistorySyncRepository
 	onStatusChange     func(instanceID string, status string)
 	eventHandler       EventHandler
+	historyRecorder    HistoryRecorder
+	proxyRepo          storage.InstanceProxyRepository
+	proxyHealth        map[string]ProxyHealth
+	lidRepo            storage.LIDMappingRepository
+	lidMu              sync.Mutex
+	lidCache           map[string]string
+	ownershipChecker   func(instanceID string) bool
+	draining           bool
+	historySyncMu      sync.Mutex
 	syncWorkers        map[string]context.CancelFunc
 	disconnectDebounce map[string]*time.Timer
 	expectedDisconnect map[string]bool
 	connectedAt        map[string]time.Time
 	messageRepo        storage.MessageRepository
 	sharedContainer    *sqlstore.Container
+	stateMu            sync.Mutex
+	connStates         map[string]*connectionState
+	reconnectPolicy    ReconnectPolicy
+	stateRepo          storage.InstanceStateTransitionRepository
+	stateListener      func(transition model.InstanceStateTransition)
+	restoreMu          sync.Mutex
+	restorePolicy      RestorePolicy
+	restoring          map[string]bool
+	restoreProgress    model.SessionRestoreProgress
+	// restoreQueue é a fila única de restauração do nó, ordenada pela
+	// atividade recente; restoreCond acorda os workers.
+	restoreQueue          []restoreItem
+	restoreCond           *sync.Cond
+	restoreWorkersStarted bool
+	restoreActivitySource func(ctx context.Context) (map[string]time.Time, error)
+	restoreActivity       map[string]time.Time
+	restoreActivityAt     time.Time
 }
 
-func NewManager(log *zap.Logger, encKey, storageDriver, baseDir, pgConnString string, deviceConfigRepo storage.DeviceConfigRepository, instanceRepo storage.InstanceRepository, historySyncRepo storage.HistorySyncRepository, messageRepo storage.MessageRepository) *Manager {
+func NewManager(log *zap.Logger, keyring *crypto.Keyring, storageDriver, baseDir, pgConnString string, deviceConfigRepo storage.DeviceConfigRepository, instanceRepo storage.InstanceRepository, historySyncRepo storage.HistorySyncRepository, messageRepo storage.MessageRepository) *Manager {
 	var sharedContainer *sqlstore.Container
 
 	if storageDriver != "postgres" {
@@ -102,14 +131,17 @@ func NewManager(log *zap.Logger, encKey, storageDriver, baseDir, pgConnString st
 		}
 	}
 
-	return &Manager{
+	m := &Manager{
 		clients:            make(map[string]*whatsmeow.Client),
 		currentQRs:         make(map[string]string),
 		qrContexts:         make(map[string]context.CancelFunc),
+		qrDone:             make(map[string]chan struct{}),
+		qrStartedAt:        make(map[string]time.Time),
+		pairCodes:          make(map[string]model.PairCode),
 		pairingSuccess:     make(map[string]time.Time),
 		sessionReady:       make(map[string]bool),
 		log:                log,
-		encKey:             encKey,
+		keyring:            keyring,
 		storageDriver:      storageDriver,
 		baseDir:            baseDir,
 		pgConnString:       pgConnString,
@@ -119,10 +151,19 @@ func NewManager(log *zap.Logger, encKey, storageDriver, baseDir, pgConnString st
 		syncWorkers:        make(map[string]context.CancelFunc),
 		disconnectDebounce: make(map[string]*time.Timer),
 		expectedDisconnect: make(map[string]bool),
+		connStates:         make(map[string]*connectionState),
+		reconnectPolicy:    DefaultReconnectPolicy(),
+		restorePolicy:      DefaultRestorePolicy(),
+		restoring:          make(map[string]bool),
+		restoreProgress:    model.SessionRestoreProgress{Status: model.SessionRestoreIdle},
 		connectedAt:        make(map[string]time.Time),
+		lidCache:           make(map[string]string),
+		proxyHealth:        make(map[string]ProxyHealth),
 		messageRepo:        messageRepo,
 		sharedContainer:    sharedContainer,
 	}
+	m.restoreCond = sync.NewCond(&m.restoreMu)
+	return m
 }
 
 func (m *Manager) SetStatusChangeCallback(fn func(instanceID string, status string)) {
@@ -208,6 +249,11 @@ func (m *Manager) CreateSession(ctx context.Context, instanceID string) (string,
 func (m *Manager) createSession(ctx context.Context, instanceID string, forceRecreate bool) (string, error) {
 	m.mu.Lock()
 
+	if !m.ownsInstance(instanceID) {
+		m.mu.Unlock()
+		return "", ErrNotInstanceOwner
+	}
+
 	if existingClient, exists := m.clients[instanceID]; exists {
 		if !forceRecreate {
 			m.mu.Unlock()
@@ -311,7 +357,8 @@ func (m *Manager) createSession(ctx context.Context, instanceID string, forceRec
 	}
 
 	client := whatsmeow.NewClient(deviceStore, clientLog)
-	client.EnableAutoReconnect = true
+	// Reconexões seguem a ReconnectPolicy do manager (ver state.go).
+	client.EnableAutoReconnect = false
 	client.ManualHistorySyncDownload = true
 
 	// Configurar callback para recuperar mensagens para retry via banco de dados
@@ -321,6 +368,11 @@ func (m *Manager) createSession(ctx context.Context, instanceID string, forceRec
 		m.handleEvent(instanceID, evt)
 	})
 
+	if err := m.applyInstanceProxy(ctx, m.getProxyRepo(), instanceID, client); err != nil {
+		m.log.Error("erro ao configurar proxy do cliente", zap.String("instance_id", instanceID), zap.Error(err))
+		return "", fmt.Errorf("whatsmeow: %w", err)
+	}
+
 	qrCtx, qrCancel := context.WithCancel(context.Background())
 	qrChan, err := client.GetQRChannel(qrCtx)
 	if err != nil {
@@ -339,10 +391,15 @@ func (m *Manager) createSession(ctx context.Context, instanceID string, forceRec
 
 	m.mu.Lock()
 	m.clients[instanceID] = client
+	qrDone := make(chan struct{})
 	m.qrContexts[instanceID] = qrCancel
+	m.qrDone[instanceID] = qrDone
+	m.qrStartedAt[instanceID] = time.Now()
 	m.mu.Unlock()
 
-	go m.monitorQRChannel(instanceID, client, qrChan, qrCancel)
+	m.transition(instanceID, model.ConnectionStatePairing, "aguardando leitura do QR code")
+
+	go m.monitorQRChannel(instanceID, client, qrChan, qrCancel, qrDone)
 
 	m.log.Info("cliente conectado, aguardando QR code", zap.String("instance_id", instanceID))
 
@@ -387,15 +444,20 @@ func (m *Manager) createSession(ctx context.Context, instanceID string, forceRec
 	}
 }
 
-func (m *Manager) monitorQRChannel(instanceID string, client *whatsmeow.Client, qrChan <-chan whatsmeow.QRChannelItem, cancel context.CancelFunc) {
+func (m *Manager) monitorQRChannel(instanceID string, client *whatsmeow.Client, qrChan <-chan whatsmeow.QRChannelItem, cancel context.CancelFunc, done chan struct{}) {
 	pairingSucceeded := false
 
+	// Sinaliza o fim da limpeza para quem reinicia a janela de pareamento
+	defer close(done)
 	defer func() {
 		cancel()
 		// Limpar estado do QR quando o canal fecha (expiração ou sucesso)
 		m.mu.Lock()
 		delete(m.qrContexts, instanceID)
+		delete(m.qrDone, instanceID)
 		delete(m.currentQRs, instanceID)
+		delete(m.qrStartedAt, instanceID)
+		delete(m.pairCodes, instanceID)
 		m.mu.Unlock()
 
 		// Se o pareamento não teve sucesso, limpar cliente e device store parcial
@@ -581,6 +643,7 @@ func (m *Manager) GetQR(ctx context.Context, instanceID string) (string, error)
 		m.mu.Lock()
 		delete(m.clients, instanceID)
 		delete(m.currentQRs, instanceID)
+		delete(m.pairCodes, instanceID)
 		delete(m.pairingSuccess, instanceID)
 		if cancel, exists := m.qrContexts[instanceID]; exists {
 			cancel()
@@ -623,7 +686,11 @@ func (m *Manager) RestoreSession(ctx context.Context, instanceID string, encrypt
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	_, err := crypto.Decrypt(encryptedBlob, m.encKey)
+	if !m.ownsInstance(instanceID) {
+		return ErrNotInstanceOwner
+	}
+
+	_, err := m.keyring.Decrypt(encryptedBlob)
 	if err != nil {
 		return fmt.Errorf("whatsmeow: descriptografar: %w", err)
 	}
@@ -641,9 +708,15 @@ func (m *Manager) RestoreSession(ctx context.Context, instanceID string, encrypt
 	}
 
 	client := whatsmeow.NewClient(deviceStore, clientLog)
+	client.EnableAutoReconnect = false
+	if err := m.applyInstanceProxy(ctx, m.proxyRepo, instanceID, client); err != nil {
+		return fmt.Errorf("whatsmeow: %w", err)
+	}
 
+	m.transition(instanceID, model.ConnectionStateConnecting, "restaurando sessão")
 	err = client.Connect()
 	if err != nil {
+		m.transition(instanceID, model.ConnectionStateDisconnected, "falha ao restaurar sessão")
 		return fmt.Errorf("whatsmeow: conectar: %w", err)
 	}
 
@@ -689,8 +762,12 @@ func (m *Manager) DeleteSession(instanceID string) error {
 	delete(m.currentQRs, instanceID)
 	delete(m.sessionReady, instanceID)
 	delete(m.pairingSuccess, instanceID)
+	delete(m.proxyHealth, instanceID)
 	m.mu.Unlock()
 
+	m.forgetState(instanceID, "sessão encerrada")
+	m.forgetLIDs(instanceID)
+
 	if client == nil {
 		m.log.Debug("cliente não encontrado em memória, tentando restaurar para logout", zap.String("instance_id", instanceID))
 		if restoredClient, err := m.restoreSessionIfExists(context.Background(), instanceID); err == nil && restoredClient != nil {
@@ -874,6 +951,10 @@ func (m *Manager) restoreSessionIfExists(ctx context.Context, instanceID string)
 		return client, nil
 	}
 
+	if !m.ownsInstance(instanceID) {
+		return nil, ErrNotInstanceOwner
+	}
+
 	clientLog := &zapLogger{log: m.log, module: "whatsmeow"}
 	var container *sqlstore.Container
 	var deviceStore *store.Device
@@ -1002,6 +1083,8 @@ func (m *Manager) restoreSessionIfExists(ctx context.Context, instanceID string)
 	}
 
 	client := whatsmeow.NewClient(deviceStore, clientLog)
+	client.EnableAutoReconnect = false
+	client.ManualHistorySyncDownload = true
 
 	// Configurar callback para recuperar mensagens para retry via banco de dados
 	client.GetMessageForRetry = m.getMessageForRetryCallback(instanceID)
@@ -1010,7 +1093,13 @@ func (m *Manager) restoreSessionIfExists(ctx context.Context, instanceID string)
 		m.handleEvent(instanceID, evt)
 	})
 
+	if err := m.applyInstanceProxy(ctx, m.proxyRepo, instanceID, client); err != nil {
+		m.log.Error("erro ao configurar proxy do cliente restaurado", zap.String("instance_id", instanceID), zap.Error(err))
+		return nil, fmt.Errorf("whatsmeow: %w", err)
+	}
+
 	m.log.Debug("conectando cliente restaurado", zap.String("instance_id", instanceID))
+	m.transition(instanceID, model.ConnectionStateConnecting, "restaurando sessão")
 	var connectErr error
 	for i := 0; i < 3; i++ {
 		connectErr = client.Connect()
@@ -1032,6 +1121,7 @@ func (m *Manager) restoreSessionIfExists(ctx context.Context, instanceID string)
 			zap.String("instance_id", instanceID),
 			zap.Error(connectErr),
 		)
+		m.transition(instanceID, model.ConnectionStateDisconnected, "falha ao restaurar sessão")
 		return nil, fmt.Errorf("erro ao restaurar sessão após 3 tentativas: %w", connectErr)
 	}
 
@@ -1109,78 +1199,28 @@ func (m *Manager) restoreSessionIfExists(ctx context.Context, instanceID string)
 	return client, nil
 }
 
-func (m *Manager) RestoreAllSessions(ctx context.Context, instanceIDs []string) {
-	m.log.Info("iniciando restauração de todas as sessões",
-		zap.Int("total_instances", len(instanceIDs)),
-		zap.String("storage", m.storageDriver),
-	)
-
-	for _, instanceID := range instanceIDs {
-
-		m.mu.RLock()
-		client, exists := m.clients[instanceID]
-		m.mu.RUnlock()
-
-		if exists && client != nil && client.IsLoggedIn() {
-			m.log.Debug("sessão já está em memória e logada, pulando",
-				zap.String("instance_id", instanceID),
-			)
-			continue
-		}
-
-		if m.storageDriver != "postgres" {
-			dbPath := filepath.Join(m.baseDir, instanceID+".db")
-			if _, err := os.Stat(dbPath); os.IsNotExist(err) {
-				m.log.Debug("arquivo SQLite não existe, pulando",
-					zap.String("instance_id", instanceID),
-				)
-				continue
-			}
-		}
-
-		go func(id string) {
-			client, err := m.restoreSessionIfExists(ctx, id)
-			if err != nil {
-				m.log.Debug("não foi possível restaurar sessão na inicialização",
-					zap.String("instance_id", id),
-					zap.Error(err),
-				)
-
-				return
-			}
-
-			if client != nil && client.IsLoggedIn() {
-				m.log.Info("sessão restaurada com sucesso na inicialização",
-					zap.String("instance_id", id),
-				)
-
-			}
-		}(instanceID)
-	}
-
-	m.log.Info("restauração de sessões iniciada em background")
-}
-
 type DiagnosticsInfo struct {
-	InstanceID           string     `json:"instanceId"`
-	HasClientInMemory    bool       `json:"hasClientInMemory"`
-	IsLoggedIn           bool       `json:"isLoggedIn"`
-	HasSQLiteFile        bool       `json:"hasSQLiteFile"`
-	SQLiteFilePath       string     `json:"sqliteFilePath"`
-	SQLiteFileSize       int64      `json:"sqliteFileSize"`
-	SQLiteFileModTime    time.Time  `json:"sqliteFileModTime"`
-	StorageType          string     `json:"storageType"`
-	StorageLocation      string     `json:"storageLocation"`
-	HasDeviceStore       bool       `json:"hasDeviceStore"`
-	DeviceJID            string     `json:"deviceJid"`
-	DevicePushName       string     `json:"devicePushName"`
-	HasQRCode            bool       `json:"hasQRCode"`
-	LastError            string     `json:"lastError,omitempty"`
-	ClientConnected      bool       `json:"clientConnected"`
-	HistorySyncStatus    string     `json:"historySyncStatus,omitempty"`
-	HistorySyncCycleID   string     `json:"historySyncCycleId,omitempty"`
-	HistorySyncUpdatedAt *time.Time `json:"historySyncUpdatedAt,omitempty"`
-	PendingPayloads      int        `json:"pendingPayloads"`
+	InstanceID           string                     `json:"instanceId"`
+	HasClientInMemory    bool                       `json:"hasClientInMemory"`
+	IsLoggedIn           bool                       `json:"isLoggedIn"`
+	HasSQLiteFile        bool                       `json:"hasSQLiteFile"`
+	SQLiteFilePath       string                     `json:"sqliteFilePath"`
+	SQLiteFileSize       int64                      `json:"sqliteFileSize"`
+	SQLiteFileModTime    time.Time                  `json:"sqliteFileModTime"`
+	StorageType          string                     `json:"storageType"`
+	StorageLocation      string                     `json:"storageLocation"`
+	HasDeviceStore       bool                       `json:"hasDeviceStore"`
+	DeviceJID            string                     `json:"deviceJid"`
+	DevicePushName       string                     `json:"devicePushName"`
+	HasQRCode            bool                       `json:"hasQRCode"`
+	LastError            string                     `json:"lastError,omitempty"`
+	ClientConnected      bool                       `json:"clientConnected"`
+	HistorySyncStatus    string                     `json:"historySyncStatus,omitempty"`
+	HistorySyncCycleID   string                     `json:"historySyncCycleId,omitempty"`
+	HistorySyncUpdatedAt *time.Time                 `json:"historySyncUpdatedAt,omitempty"`
+	HistorySyncProgress  *model.HistorySyncProgress `json:"historySyncProgress,omitempty"`
+	PendingPayloads      int                        `json:"pendingPayloads"`
+	Proxy                *ProxyHealth               `json:"proxy,omitempty"`
 }
 
 func (m *Manager) GetDiagnostics(instanceID string) interface{} {
@@ -1261,8 +1301,15 @@ func (m *Manager) GetDiagnostics(instanceID string) interface{} {
 		if payloads, err := m.historySyncRepo.ListPendingByInstance(ctx, instanceID); err == nil {
 			diag.PendingPayloads = len(payloads)
 		}
+		if inst != nil && inst.HistorySyncCycleID != "" {
+			if progress, err := m.historySyncRepo.GetCycleProgress(ctx, instanceID, inst.HistorySyncCycleID); err == nil {
+				diag.HistorySyncProgress = &progress
+			}
+		}
 	}
 
+	diag.Proxy = m.CheckProxyHealth(context.Background(), instanceID)
+
 	return diag
 }
 
@@ -1277,7 +1324,7 @@ func (m *Manager) SaveSessionBlob(instanceID string) ([]byte, error) {
 
 	data := []byte(fmt.Sprintf("session:%s", instanceID))
 
-	encrypted, err := crypto.Encrypt(data, m.encKey)
+	encrypted, err := m.keyring.Encrypt(data)
 	if err != nil {
 		return nil, fmt.Errorf("whatsmeow: criptografar: %w", err)
 	}
@@ -1380,6 +1427,29 @@ func (m *Manager) handleEvent(instanceID string, evt any) {
 		instanceJID = client.Store.ID.String()
 	}
 
+	// Antes de entregar o evento, troca os LIDs pelo número quando conhecido
+	switch v := evt.(type) {
+	case *events.Message:
+		m.learnLIDs(context.Background(), instanceID, &v.Info.MessageSource, model.LIDMappingSourceMessage)
+	case *events.Receipt:
+		m.learnLIDs(context.Background(), instanceID, &v.MessageSource, model.LIDMappingSourceMessage)
+		// As conversas são gravadas pelo número: a leitura em outro
+		// dispositivo precisa apontar para a mesma conversa.
+		if v.Type == types.ReceiptTypeReadSelf && v.Chat.Server == types.HiddenUserServer {
+			if pn, ok := m.phoneForLID(context.Background(), instanceID, v.Chat); ok {
+				v.Chat = pn
+			}
+		}
+	case *events.Presence:
+		if v.From.Server == types.HiddenUserServer {
+			if pn, ok := m.phoneForLID(context.Background(), instanceID, v.From); ok {
+				v.From = pn
+			}
+		}
+	case *events.JoinedGroup:
+		m.learnGroupParticipants(context.Background(), instanceID, v.Participants)
+	}
+
 	if handler != nil {
 
 		switch evt.(type) {
@@ -1411,6 +1481,10 @@ func (m *Manager) handleEvent(instanceID string, evt any) {
 	}
 
 	switch v := evt.(type) {
+	case *events.Message:
+		if notif := v.Message.GetProtocolMessage().GetHistorySyncNotification(); notif != nil {
+			m.persistHistorySyncNotification(instanceID, notif)
+		}
 	case *events.Connected:
 		m.log.Info("instância conectada - dispositivo liberado, sincronizando dados essenciais em background",
 			zap.String("instance_id", instanceID))
@@ -1425,9 +1499,12 @@ func (m *Manager) handleEvent(instanceID string, evt any) {
 		m.connectedAt[instanceID] = time.Now()
 		m.mu.Unlock()
 
+		m.transition(instanceID, model.ConnectionStateConnected, "conexão estabelecida")
+
 		if handler != nil {
 			go handler.Handle(context.Background(), instanceID, instanceJID, client, v)
 		}
+		go m.resumeHistorySyncCycle(instanceID)
 
 		m.mu.RLock()
 		client, exists := m.clients[instanceID]
@@ -1484,6 +1561,7 @@ func (m *Manager) handleEvent(instanceID string, evt any) {
 			zap.String("instance_id", instanceID),
 			zap.String("user_jid", v.ID.String()),
 		)
+		m.transition(instanceID, model.ConnectionStateConnecting, "pareamento concluído")
 		if callback != nil {
 			callback(instanceID, "active")
 		}
@@ -1521,6 +1599,8 @@ func (m *Manager) handleEvent(instanceID string, evt any) {
 			})
 			m.disconnectDebounce[instanceID] = timer
 			m.mu.Unlock()
+
+			m.scheduleReconnect(instanceID, client, "conexão perdida")
 			return
 		}
 
@@ -1543,6 +1623,7 @@ func (m *Manager) handleEvent(instanceID string, evt any) {
 			zap.String("instance_id", instanceID),
 			zap.String("reason", v.Reason.String()),
 		)
+		m.transition(instanceID, model.ConnectionStateLoggedOut, v.Reason.String())
 
 		if handler != nil {
 			go handler.Handle(context.Background(), instanceID, instanceJID, client, v)
@@ -1563,6 +1644,10 @@ func (m *Manager) handleEvent(instanceID string, evt any) {
 			zap.String("code", v.Code.String()),
 			zap.Duration("expire", v.Expire),
 		)
+		m.transition(instanceID, model.ConnectionStateBanned, fmt.Sprintf("banimento temporário: %s", v.Code.String()))
+		if v.Expire > 0 && client != nil {
+			m.retryAfter(instanceID, client, v.Expire)
+		}
 		m.updateInstanceStatus(instanceID, model.InstanceStatusError)
 		if callback != nil {
 			callback(instanceID, "error")
@@ -1573,6 +1658,11 @@ func (m *Manager) handleEvent(instanceID string, evt any) {
 			zap.String("reason", v.Reason.String()),
 			zap.String("message", v.Message),
 		)
+		if v.Reason.IsLoggedOut() {
+			m.transition(instanceID, model.ConnectionStateLoggedOut, v.Reason.String())
+		} else {
+			m.scheduleReconnect(instanceID, client, "falha de conexão: "+v.Reason.String())
+		}
 		m.updateInstanceStatus(instanceID, model.InstanceStatusError)
 		if callback != nil {
 			callback(instanceID, "error")
@@ -1599,11 +1689,13 @@ func (m *Manager) handleEvent(instanceID string, evt any) {
 		m.log.Error("cliente WhatsMeow desatualizado",
 			zap.String("instance_id", instanceID),
 		)
+		m.transition(instanceID, model.ConnectionStateOutdated, "versão do cliente recusada pelo WhatsApp")
 		m.updateInstanceStatus(instanceID, model.InstanceStatusError)
 		if callback != nil {
 			callback(instanceID, "error")
 		}
 	case *events.HistorySync:
+		// Com ManualHistorySyncDownload ativo o download é feito pelo worker do ciclo
 		m.log.Info("history sync recebido",
 			zap.String("instance_id", instanceID),
 			zap.Int("conversations", len(v.Data.GetConversations())),
@@ -1635,6 +1727,7 @@ func (m *Manager) handleEvent(instanceID string, evt any) {
 	case *events.StreamReplaced:
 		m.log.Warn("stream substituído pelo servidor - limpando cache de dispositivos",
 			zap.String("instance_id", instanceID))
+		m.transition(instanceID, model.ConnectionStateDisconnected, "sessão assumida por outra conexão")
 
 		if client != nil {
 
@@ -1650,6 +1743,15 @@ func (m *Manager) handleEvent(instanceID string, evt any) {
 		m.log.Warn("keepalive timeout detectado - possível reconexão iminente",
 			zap.String("instance_id", instanceID),
 			zap.Int("error_count", v.ErrorCount))
+
+		// Sem o auto-reconnect do whatsmeow, a reconexão forçada por keepalive
+		// fica a cargo do manager.
+		if client != nil && time.Since(v.LastSuccess) > whatsmeow.KeepAliveMaxFailTime {
+			go func() {
+				client.Disconnect()
+				m.scheduleReconnect(instanceID, client, "keepalive sem resposta")
+			}()
+		}
 	case *events.KeepAliveRestored:
 		m.log.Info("keepalive restaurado - limpando cache de dispositivos",
 			zap.String("instance_id", instanceID))
@@ -0,0 +1,37 @@
+package whatsmeow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.mau.fi/whatsmeow"
+
+	"github.com/open-apime/apime/internal/session"
+	"github.com/open-apime/apime/internal/storage/model"
+)
+
+// DownloadMediaRef baixa e descriptografa a mídia de uma mensagem recebida no
+// modo lazy, a partir das chaves guardadas ao recebê-la.
+func (m *Manager) DownloadMediaRef(ctx context.Context, ref model.MediaRef) ([]byte, error) {
+	if m.IsRestoring(ref.InstanceID) {
+		return nil, session.ErrRestoring
+	}
+	client, err := m.GetClient(ref.InstanceID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", session.ErrNotConnected, err)
+	}
+	if !client.IsConnected() {
+		return nil, session.ErrNotConnected
+	}
+
+	data, err := client.DownloadMediaWithPath(ctx, ref.DirectPath, ref.FileEncSHA256, ref.FileSHA256, ref.MediaKey,
+		int(ref.FileLength), whatsmeow.MediaType(ref.MediaType), "")
+	if err != nil {
+		if errors.Is(err, whatsmeow.ErrMediaDownloadFailedWith404) || errors.Is(err, whatsmeow.ErrMediaDownloadFailedWith410) {
+			return nil, fmt.Errorf("%w: %v", session.ErrMediaExpired, err)
+		}
+		return nil, err
+	}
+	return data, nil
+}
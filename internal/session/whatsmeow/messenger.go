@@ -0,0 +1,795 @@
+package whatsmeow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"mime"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/open-apime/apime/internal/pkg/audio"
+	"github.com/open-apime/apime/internal/session"
+	"github.com/open-apime/apime/internal/storage"
+	"github.com/open-apime/apime/internal/storage/model"
+)
+
+type jidCacheEntry struct {
+	jid       types.JID
+	expiresAt time.Time
+}
+
+// Messenger implementa session.Messenger sobre as sessões do Manager, com o
+// aquecimento de sessão e as novas tentativas que o whatsmeow exige para
+// entregar mensagens de forma confiável.
+type Messenger struct {
+	manager     *Manager
+	contactRepo storage.ContactRepository
+	log         *zap.Logger
+	jidCache    sync.Map
+	uploads     *uploadCache
+}
+
+func NewMessenger(manager *Manager, contactRepo storage.ContactRepository, log *zap.Logger) *Messenger {
+	return &Messenger{manager: manager, contactRepo: contactRepo, log: log, uploads: newUploadCache()}
+}
+
+func (m *Messenger) client(instanceID string) (*whatsmeow.Client, error) {
+	client, err := m.manager.GetClient(instanceID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: cliente não encontrado: %v", session.ErrNotConnected, err)
+	}
+	return client, nil
+}
+
+func (m *Messenger) IsRestoring(instanceID string) bool {
+	return m.manager.IsRestoring(instanceID)
+}
+
+func (m *Messenger) Ready(ctx context.Context, instanceID string) error {
+	if m.manager.IsRestoring(instanceID) {
+		return session.ErrRestoring
+	}
+	client, err := m.client(instanceID)
+	if err != nil {
+		return err
+	}
+	if !client.IsLoggedIn() {
+		return session.ErrNotConnected
+	}
+
+	readyStart := time.Now()
+	isReady := false
+	poked := false
+
+	connectedAt := m.manager.GetConnectedAt(instanceID)
+	isColdStart := time.Since(connectedAt) < 60*time.Second
+	minPreKeys := 5
+	if isColdStart {
+		minPreKeys = 20
+		m.log.Debug("Sessão em Cold Start detectada, aguardando estabilização maior",
+			zap.String("instance_id", instanceID),
+			zap.Duration("since_connection", time.Since(connectedAt)))
+	}
+
+	for time.Since(readyStart) < 30*time.Second {
+		preKeyCount, _ := m.manager.GetPreKeyCount(instanceID)
+
+		if m.manager.IsSessionReady(instanceID) && preKeyCount >= minPreKeys {
+			isReady = true
+			break
+		}
+
+		if time.Since(readyStart) > 2*time.Second && !poked {
+			m.log.Info("Sessão ainda não pronta, enviando presence de ativação...", zap.String("instance_id", instanceID), zap.Int("prekeys", preKeyCount))
+			_ = client.SendPresence(ctx, types.PresenceAvailable)
+			poked = true
+		}
+
+		if err := sleepContext(ctx, 1*time.Second); err != nil {
+			return fmt.Errorf("%w: %v", session.ErrInterrupted, err)
+		}
+	}
+
+	if !isReady {
+		return fmt.Errorf("sessão indisponível para criptografia (pode levar alguns instantes após conectar), tente novamente")
+	}
+
+	if isColdStart {
+		timeSinceConnect := time.Since(connectedAt)
+		safeThreshold := 60 * time.Second
+
+		if timeSinceConnect < safeThreshold {
+			remainingWait := safeThreshold - timeSinceConnect
+			jitter := time.Duration(rand.Intn(5000)) * time.Millisecond
+			finalWait := remainingWait + jitter
+
+			m.log.Info("Estabilizando sessão...",
+				zap.String("instance_id", instanceID),
+				zap.Duration("connected_for", timeSinceConnect),
+				zap.Duration("wait_time", finalWait))
+
+			if err := sleepContext(ctx, finalWait); err != nil {
+				return fmt.Errorf("%w: %v", session.ErrInterrupted, err)
+			}
+		}
+	}
+
+	if err := sleepContext(ctx, 1500*time.Millisecond); err != nil {
+		return fmt.Errorf("%w: %v", session.ErrInterrupted, err)
+	}
+	return nil
+}
+
+func (m *Messenger) ResolveJID(ctx context.Context, instanceID, phone string) (types.JID, error) {
+	phone = strings.TrimSpace(phone)
+
+	if phone == "" {
+		return types.EmptyJID, errors.New("telefone vazio")
+	}
+
+	if strings.Contains(phone, "@g.us") || strings.Contains(phone, "@broadcast") {
+		return types.ParseJID(phone)
+	}
+
+	if !strings.Contains(phone, "@") {
+		phone = strings.Map(func(r rune) rune {
+			if r >= '0' && r <= '9' {
+				return r
+			}
+			return -1
+		}, phone)
+	}
+
+	phone = strings.TrimSuffix(phone, "@s.whatsapp.net")
+
+	if val, ok := m.jidCache.Load(phone); ok {
+		entry := val.(jidCacheEntry)
+		if time.Now().Before(entry.expiresAt) {
+			if entry.jid.IsEmpty() {
+				m.log.Debug("JID negativo (não está no WhatsApp) resolvido via cache", zap.String("phone", phone))
+				return types.EmptyJID, fmt.Errorf("%w: número não registrado no WhatsApp (cache)", session.ErrInvalidJID)
+			}
+			m.log.Debug("JID resolvido via cache em memória", zap.String("phone", phone), zap.String("jid", entry.jid.String()))
+			return entry.jid, nil
+		}
+		m.jidCache.Delete(phone)
+	}
+
+	if m.contactRepo != nil {
+		if contact, err := m.contactRepo.GetByPhone(ctx, phone); err == nil {
+			jid, jerr := types.ParseJID(contact.JID)
+			if jerr == nil {
+				m.log.Debug("JID resolvido via banco de dados", zap.String("phone", phone), zap.String("jid", jid.String()))
+				m.jidCache.Store(phone, jidCacheEntry{jid: jid, expiresAt: time.Now().Add(24 * time.Hour)})
+				return jid, nil
+			}
+		}
+	}
+
+	if !strings.HasPrefix(phone, "55") {
+		// Se não for BR, apenas tenta parsear sem validar (ou você pode validar se preferir)
+		return types.ParseJID(phone + "@s.whatsapp.net")
+	}
+
+	client, err := m.client(instanceID)
+	if err != nil {
+		return types.EmptyJID, err
+	}
+
+	// Simulando comportamento humano com delay aleatório antes da consulta real
+	// Isso evita padrões robóticos de consulta rápida
+	delay := 1000 + rand.Intn(2000) // 1s a 3s
+	m.log.Debug("Aplicando delay de segurança antes de IsOnWhatsApp", zap.Int("ms", delay))
+	time.Sleep(time.Duration(delay) * time.Millisecond)
+
+	candidates := []string{phone}
+
+	if len(phone) == 13 {
+		optionWithout9 := phone[:4] + phone[5:]
+		candidates = append(candidates, optionWithout9)
+	} else if len(phone) == 12 {
+		optionWith9 := phone[:4] + "9" + phone[4:]
+		candidates = append(candidates, optionWith9)
+	}
+
+	m.log.Debug("Candidatos gerados para validação", zap.String("original", phone), zap.Strings("candidates", candidates))
+
+	resp, err := client.IsOnWhatsApp(ctx, candidates)
+	if err != nil {
+		m.log.Warn("falha ao consultar IsOnWhatsApp, enviando original", zap.String("phone", phone), zap.Error(err))
+		return types.ParseJID(phone + "@s.whatsapp.net")
+	}
+
+	resolvedJID := types.EmptyJID
+	for _, item := range resp {
+		if item.JID.User != "" {
+			resolvedJID = item.JID
+			break
+		}
+	}
+
+	if resolvedJID.IsEmpty() {
+		m.log.Warn("WhatsApp não encontrado - registrando em cache negativo por 24h", zap.String("original_phone", phone), zap.Any("candidates", candidates))
+		// Cache Negativo: Evita reconsultar números que sabemos que não existem
+		m.jidCache.Store(phone, jidCacheEntry{jid: types.EmptyJID, expiresAt: time.Now().Add(24 * time.Hour)})
+		return types.EmptyJID, fmt.Errorf("%w: número não registrado no WhatsApp", session.ErrInvalidJID)
+	}
+
+	m.jidCache.Store(phone, jidCacheEntry{jid: resolvedJID, expiresAt: time.Now().Add(24 * time.Hour)})
+	if m.contactRepo != nil {
+		_ = m.contactRepo.Upsert(ctx, model.Contact{
+			Phone: phone,
+			JID:   resolvedJID.String(),
+		})
+	}
+
+	return resolvedJID, nil
+}
+
+// forgetJID remove do cache os telefones resolvidos para jid, para que o
+// próximo envio consulte o WhatsApp de novo.
+func (m *Messenger) forgetJID(jid types.JID) {
+	m.jidCache.Range(func(key, val any) bool {
+		if val.(jidCacheEntry).jid == jid {
+			m.jidCache.Delete(key)
+			m.log.Info("Removido do cache de JID devido a erro de envio", zap.Any("phone", key))
+		}
+		return true
+	})
+}
+
+func (m *Messenger) CheckPhones(ctx context.Context, instanceID string, phones []string) ([]types.IsOnWhatsAppResponse, error) {
+	client, err := m.client(instanceID)
+	if err != nil {
+		return nil, err
+	}
+	return client.IsOnWhatsApp(ctx, phones)
+}
+
+func (m *Messenger) Prepare(ctx context.Context, instanceID string, toJID types.JID) error {
+	if toJID.Server != types.DefaultUserServer && toJID.Server != types.HiddenUserServer {
+		return nil
+	}
+	client, err := m.client(instanceID)
+	if err != nil {
+		return err
+	}
+
+	hasSession, err := m.manager.HasSession(instanceID, toJID)
+
+	_ = client.SendPresence(ctx, types.PresenceAvailable)
+
+	_ = client.SendChatPresence(ctx, toJID, types.ChatPresenceComposing, types.ChatPresenceMediaText)
+
+	m.log.Debug("buscando dispositivos do destinatário antes do envio",
+		zap.String("instance_id", instanceID),
+		zap.String("to", toJID.String()))
+
+	devices, devErr := client.GetUserDevices(ctx, []types.JID{toJID})
+	if devErr != nil {
+		m.log.Warn("erro ao buscar dispositivos do destinatário",
+			zap.String("to", toJID.String()),
+			zap.Error(devErr))
+	} else {
+		m.log.Debug("dispositivos do destinatário atualizados",
+			zap.String("to", toJID.String()),
+			zap.Int("device_count", len(devices)))
+
+		if sleepErr := sleepContext(ctx, 800*time.Millisecond); sleepErr != nil {
+			return fmt.Errorf("%w: %v", session.ErrInterrupted, sleepErr)
+		}
+	}
+
+	if err == nil && !hasSession {
+		m.log.Info("Nova sessão detectada...",
+			zap.String("instance_id", instanceID),
+			zap.String("to", toJID.String()))
+
+		wait := 1500 + rand.Intn(1500)
+		if sleepErr := sleepContext(ctx, time.Duration(wait)*time.Millisecond); sleepErr != nil {
+			return fmt.Errorf("%w: %v", session.ErrInterrupted, sleepErr)
+		}
+	} else {
+		wait := 500 + rand.Intn(700)
+		if sleepErr := sleepContext(ctx, time.Duration(wait)*time.Millisecond); sleepErr != nil {
+			return fmt.Errorf("%w: %v", session.ErrInterrupted, sleepErr)
+		}
+	}
+	return nil
+}
+
+func (m *Messenger) Send(ctx context.Context, instanceID string, toJID types.JID, input session.OutgoingMessage) (session.SentMessage, error) {
+	client, err := m.client(instanceID)
+	if err != nil {
+		return session.SentMessage{}, err
+	}
+
+	waMessage, err := m.buildMessage(ctx, client, instanceID, input)
+	if err != nil {
+		return session.SentMessage{}, err
+	}
+
+	var resp whatsmeow.SendResponse
+	maxRetries := 3
+
+	for attempt := 0; attempt <= maxRetries; attempt++ {
+		if attempt > 0 {
+			backoff := time.Duration(math.Pow(2, float64(attempt))) * time.Second
+			m.log.Info("tentando reenvio de mensagem devido a erro anterior",
+				zap.Int("attempt", attempt),
+				zap.Duration("backoff", backoff),
+				zap.String("to", toJID.String()))
+			time.Sleep(backoff)
+
+			_ = client.SendPresence(ctx, types.PresenceAvailable)
+			_ = client.SendChatPresence(ctx, toJID, types.ChatPresenceComposing, types.ChatPresenceMediaText)
+
+			// Recalcular dispositivos no retry caso tenha sido um erro de criptografia
+			_, _ = client.GetUserDevices(ctx, []types.JID{toJID})
+		}
+
+		resp, err = client.SendMessage(ctx, toJID, waMessage)
+		if err == nil {
+			m.log.Info("mensagem enviada com sucesso",
+				zap.Int("attempt", attempt),
+				zap.String("to", toJID.String()),
+				zap.String("server_id", resp.ID),
+				zap.Int64("timestamp", resp.Timestamp.Unix()))
+			break
+		}
+
+		m.log.Warn("falha no envio da mensagem",
+			zap.Int("attempt", attempt),
+			zap.Error(err),
+			zap.String("to", toJID.String()))
+
+		if strings.Contains(err.Error(), "untrusted identity") {
+			m.log.Warn("erro de identidade não confiável detectado, limpando identidade e tentando novamente",
+				zap.String("to", toJID.String()))
+			client.Store.Identities.DeleteIdentity(ctx, toJID.SignalAddress().String())
+			continue
+		}
+
+		// Tratar Cold Start de Criptografia: no signal session
+		if strings.Contains(err.Error(), "no signal session") {
+			m.log.Warn("sessão de criptografia não estabelecida (cold start), tentando warmup e reenvio",
+				zap.String("to", toJID.String()))
+			_, _ = client.GetUserDevices(ctx, []types.JID{toJID})
+			continue
+		}
+
+		if strings.Contains(err.Error(), "not logged in") {
+			break
+		}
+	}
+
+	// Limpar o status "digitando" após o envio (sucesso ou falha final)
+	_ = client.SendChatPresence(ctx, toJID, types.ChatPresencePaused, types.ChatPresenceMediaText)
+
+	if err != nil {
+		m.forgetJID(toJID)
+		return session.SentMessage{}, fmt.Errorf("erro ao enviar mensagem após %d tentativas: %w", maxRetries, err)
+	}
+
+	return session.SentMessage{ID: resp.ID, Timestamp: resp.Timestamp}, nil
+}
+
+// buildMessage faz o upload da mídia, quando houver, e monta o protobuf da
+// mensagem.
+func (m *Messenger) buildMessage(ctx context.Context, client *whatsmeow.Client, instanceID string, input session.OutgoingMessage) (*waE2E.Message, error) {
+	var contextInfo *waE2E.ContextInfo
+	if input.Quoted != "" {
+		contextInfo = &waE2E.ContextInfo{
+			StanzaID: proto.String(input.Quoted),
+		}
+	}
+
+	switch input.Type {
+	case "text":
+		if input.Quoted != "" {
+			return &waE2E.Message{
+				ExtendedTextMessage: &waE2E.ExtendedTextMessage{
+					Text:        proto.String(input.Text),
+					ContextInfo: contextInfo,
+				},
+			}, nil
+		}
+		return &waE2E.Message{
+			Conversation: proto.String(input.Text),
+		}, nil
+
+	case string(session.MediaImage):
+		uploadResp, err := m.upload(ctx, client, instanceID, &input, whatsmeow.MediaImage)
+		if err != nil {
+			return nil, fmt.Errorf("erro ao fazer upload da mídia: %w", err)
+		}
+		imageMsg := &waE2E.ImageMessage{
+			URL:           &uploadResp.URL,
+			DirectPath:    &uploadResp.DirectPath,
+			MediaKey:      uploadResp.MediaKey,
+			FileEncSHA256: uploadResp.FileEncSHA256,
+			FileSHA256:    uploadResp.FileSHA256,
+			FileLength:    &uploadResp.FileLength,
+			Mimetype:      proto.String(input.MimeType),
+			ContextInfo:   contextInfo,
+		}
+		if input.Caption != "" {
+			imageMsg.Caption = proto.String(input.Caption)
+		}
+		if len(input.Thumbnail) > 0 {
+			imageMsg.JPEGThumbnail = input.Thumbnail
+		}
+		return &waE2E.Message{ImageMessage: imageMsg}, nil
+
+	case string(session.MediaVideo):
+		uploadResp, err := m.upload(ctx, client, instanceID, &input, whatsmeow.MediaVideo)
+		if err != nil {
+			return nil, fmt.Errorf("erro ao fazer upload da mídia: %w", err)
+		}
+		videoMsg := &waE2E.VideoMessage{
+			URL:           &uploadResp.URL,
+			DirectPath:    &uploadResp.DirectPath,
+			MediaKey:      uploadResp.MediaKey,
+			FileEncSHA256: uploadResp.FileEncSHA256,
+			FileSHA256:    uploadResp.FileSHA256,
+			FileLength:    &uploadResp.FileLength,
+			Mimetype:      proto.String(input.MimeType),
+			ContextInfo:   contextInfo,
+		}
+		if input.Caption != "" {
+			videoMsg.Caption = proto.String(input.Caption)
+		}
+		if len(input.Thumbnail) > 0 {
+			videoMsg.JPEGThumbnail = input.Thumbnail
+		}
+		return &waE2E.Message{VideoMessage: videoMsg}, nil
+
+	case string(session.MediaAudio):
+		uploadResp, err := m.upload(ctx, client, instanceID, &input, whatsmeow.MediaAudio)
+		if err != nil {
+			return nil, fmt.Errorf("erro ao fazer upload do áudio: %w", err)
+		}
+		isPTT := input.PTT
+
+		var waveform []byte
+		if isPTT {
+			waveform = input.Waveform
+		}
+
+		finalMimeType := input.MimeType
+		if isPTT && strings.Contains(input.MimeType, "audio/ogg") {
+			finalMimeType = "audio/ogg; codecs=opus"
+		}
+
+		audioMsg := &waE2E.AudioMessage{
+			URL:           &uploadResp.URL,
+			DirectPath:    &uploadResp.DirectPath,
+			MediaKey:      uploadResp.MediaKey,
+			FileEncSHA256: uploadResp.FileEncSHA256,
+			FileSHA256:    uploadResp.FileSHA256,
+			FileLength:    &uploadResp.FileLength,
+			Mimetype:      proto.String(finalMimeType),
+			ContextInfo: &waE2E.ContextInfo{
+				Expiration: proto.Uint32(0),
+			},
+			PTT:               proto.Bool(isPTT),
+			Seconds:           proto.Uint32(uint32(input.Seconds)),
+			Waveform:          waveform,
+			MediaKeyTimestamp: proto.Int64(time.Now().Unix()),
+		}
+		if input.Quoted != "" {
+			audioMsg.ContextInfo.StanzaID = proto.String(input.Quoted)
+		}
+		return &waE2E.Message{AudioMessage: audioMsg}, nil
+
+	case string(session.MediaDocument):
+		uploadResp, err := m.upload(ctx, client, instanceID, &input, whatsmeow.MediaDocument)
+		if err != nil {
+			return nil, fmt.Errorf("erro ao fazer upload do documento: %w", err)
+		}
+
+		fileName := input.FileName
+		if fileName == "" {
+			exts, _ := mime.ExtensionsByType(input.MimeType)
+			if len(exts) > 0 {
+				fileName = "document" + exts[0]
+			} else {
+				fileName = "document"
+			}
+		}
+
+		docMsg := &waE2E.DocumentMessage{
+			URL:           &uploadResp.URL,
+			DirectPath:    &uploadResp.DirectPath,
+			MediaKey:      uploadResp.MediaKey,
+			FileEncSHA256: uploadResp.FileEncSHA256,
+			FileSHA256:    uploadResp.FileSHA256,
+			FileLength:    &uploadResp.FileLength,
+			Mimetype:      proto.String(input.MimeType),
+			FileName:      proto.String(fileName),
+			ContextInfo:   contextInfo,
+		}
+		if input.Caption != "" {
+			docMsg.Caption = proto.String(input.Caption)
+		}
+		if len(input.Thumbnail) > 0 {
+			docMsg.JPEGThumbnail = input.Thumbnail
+		}
+		return &waE2E.Message{DocumentMessage: docMsg}, nil
+	}
+
+	return nil, fmt.Errorf("%w: tipo %s", session.ErrNotSupported, input.Type)
+}
+
+// mediaTypes associa os tipos de mensagem de mídia aos tipos do whatsmeow.
+var mediaTypes = map[string]whatsmeow.MediaType{
+	string(session.MediaImage):    whatsmeow.MediaImage,
+	string(session.MediaVideo):    whatsmeow.MediaVideo,
+	string(session.MediaAudio):    whatsmeow.MediaAudio,
+	string(session.MediaDocument): whatsmeow.MediaDocument,
+}
+
+// uploadKey identifica, no cache de uploads, a mídia da mensagem: pelo
+// conteúdo ou, sem ele, pelo ID retornado por Upload.
+func (m *Messenger) uploadKey(instanceID string, input session.OutgoingMessage) (uploadKey, bool) {
+	mt, ok := mediaTypes[input.Type]
+	if !ok {
+		return uploadKey{}, false
+	}
+	id := input.MediaID
+	if len(input.Media) > 0 {
+		id = uploadID(input.Media)
+	}
+	return uploadKey{instanceID: instanceID, id: id, mediaType: mt}, true
+}
+
+// upload envia a mídia da mensagem ao WhatsApp, reaproveitando o upload do
+// mesmo conteúdo enquanto a URL for válida. Sem conteúdo, input.MediaID
+// precisa apontar para um upload ainda no cache, cujos mimetype, duração e
+// forma de onda completam a mensagem.
+func (m *Messenger) upload(ctx context.Context, client *whatsmeow.Client, instanceID string, input *session.OutgoingMessage, mt whatsmeow.MediaType) (whatsmeow.UploadResponse, error) {
+	key, _ := m.uploadKey(instanceID, *input)
+	key.mediaType = mt
+	if key.id == "" {
+		return whatsmeow.UploadResponse{}, errors.New("mídia vazia")
+	}
+
+	entry, ok := m.uploads.get(key)
+	if !ok && len(input.Media) == 0 {
+		return whatsmeow.UploadResponse{}, fmt.Errorf("%w: id %s", session.ErrMediaExpired, input.MediaID)
+	}
+	if !ok {
+		var err error
+		entry = uploadEntry{mimeType: input.MimeType, seconds: input.Seconds, waveform: input.Waveform}
+		if entry, err = m.cachedUpload(ctx, client, key, input.Media, entry); err != nil {
+			return whatsmeow.UploadResponse{}, err
+		}
+	} else {
+		m.log.Debug("upload de mídia reaproveitado",
+			zap.String("instance_id", instanceID),
+			zap.String("media_id", key.id),
+			zap.Time("expires_at", entry.expiresAt))
+	}
+	if input.MimeType == "" {
+		input.MimeType = entry.mimeType
+	}
+	if input.Seconds == 0 {
+		input.Seconds = entry.seconds
+	}
+	if len(input.Waveform) == 0 {
+		input.Waveform = entry.waveform
+	}
+	return entry.resp, nil
+}
+
+// cachedUpload faz o upload e guarda no cache a resposta junto com os dados
+// da mídia em entry.
+func (m *Messenger) cachedUpload(ctx context.Context, client *whatsmeow.Client, key uploadKey, data []byte, entry uploadEntry) (uploadEntry, error) {
+	resp, err := client.Upload(ctx, data, key.mediaType)
+	if err != nil {
+		return uploadEntry{}, err
+	}
+	entry.resp = resp
+	return m.uploads.put(key, entry), nil
+}
+
+// Upload envia a mídia ao WhatsApp, ou reaproveita o upload do mesmo
+// conteúdo. O ID retornado pode ser usado no envio de mensagens enquanto o
+// upload estiver no cache.
+func (m *Messenger) Upload(ctx context.Context, instanceID string, data []byte, mimeType string, kind session.MediaKind) (session.UploadedMedia, error) {
+	client, err := m.client(instanceID)
+	if err != nil {
+		return session.UploadedMedia{}, err
+	}
+
+	mt, ok := mediaTypes[string(kind)]
+	if !ok {
+		return session.UploadedMedia{}, fmt.Errorf("%w: mídia %s", session.ErrNotSupported, kind)
+	}
+
+	key := uploadKey{instanceID: instanceID, id: uploadID(data), mediaType: mt}
+	entry, ok := m.uploads.get(key)
+	if !ok {
+		// Os envios por ID não têm o arquivo: a duração e a forma de onda
+		// dos áudios são calculadas aqui e guardadas com o upload.
+		entry = uploadEntry{mimeType: mimeType}
+		if kind == session.MediaAudio {
+			if info, err := audio.Analyze(data, mimeType); err == nil {
+				entry.seconds = info.Seconds()
+				entry.waveform = info.Waveform
+			} else {
+				m.log.Debug("duração do áudio não calculada", zap.String("mimetype", mimeType), zap.Error(err))
+			}
+		}
+		if entry, err = m.cachedUpload(ctx, client, key, data, entry); err != nil {
+			return session.UploadedMedia{}, err
+		}
+	}
+	return session.UploadedMedia{
+		ID:         key.id,
+		URL:        entry.resp.URL,
+		DirectPath: entry.resp.DirectPath,
+		Handle:     entry.resp.Handle,
+		ObjectID:   entry.resp.ObjectID,
+	}, nil
+}
+
+// LookupUpload retorna os dados guardados com o upload de mediaID, enquanto
+// ele estiver no cache.
+func (m *Messenger) LookupUpload(instanceID, mediaID string, kind session.MediaKind) (session.UploadInfo, bool) {
+	mt, ok := mediaTypes[string(kind)]
+	if !ok {
+		return session.UploadInfo{}, false
+	}
+	entry, ok := m.uploads.get(uploadKey{instanceID: instanceID, id: mediaID, mediaType: mt})
+	if !ok {
+		return session.UploadInfo{}, false
+	}
+	return session.UploadInfo{MimeType: entry.mimeType, Seconds: entry.seconds, Waveform: entry.waveform}, true
+}
+
+func (m *Messenger) MarkRead(ctx context.Context, instanceID string, chat, sender types.JID, ids []string, played bool) error {
+	client, err := m.client(instanceID)
+	if err != nil {
+		return err
+	}
+
+	receiptType := types.ReceiptTypeRead
+	if played {
+		receiptType = types.ReceiptTypePlayed
+	}
+	messageIDs := make([]types.MessageID, 0, len(ids))
+	for _, id := range ids {
+		messageIDs = append(messageIDs, types.MessageID(id))
+	}
+	return client.MarkRead(ctx, messageIDs, time.Now(), chat, sender, receiptType)
+}
+
+// Revoke apaga a mensagem para todos. Com sender diferente da instância, o
+// revoke é feito como administrador do grupo.
+func (m *Messenger) Revoke(ctx context.Context, instanceID string, chat, sender types.JID, id string) (session.SentMessage, error) {
+	client, err := m.client(instanceID)
+	if err != nil {
+		return session.SentMessage{}, err
+	}
+
+	resp, err := client.RevokeMessage(ctx, chat, types.MessageID(id))
+	if err != nil {
+		// fallback: usar BuildRevoke para suportar revoke admin quando sender for informado
+		msg := client.BuildRevoke(chat, sender, types.MessageID(id))
+		resp, err = client.SendMessage(ctx, chat, msg)
+		if err != nil {
+			return session.SentMessage{}, err
+		}
+	}
+	return session.SentMessage{ID: resp.ID, Timestamp: resp.Timestamp}, nil
+}
+
+func (m *Messenger) SendPresence(ctx context.Context, instanceID string, presence types.Presence) error {
+	client, err := m.client(instanceID)
+	if err != nil {
+		return err
+	}
+	return client.SendPresence(ctx, presence)
+}
+
+func (m *Messenger) SendChatPresence(ctx context.Context, instanceID string, chat types.JID, state types.ChatPresence, media types.ChatPresenceMedia) error {
+	client, err := m.client(instanceID)
+	if err != nil {
+		return err
+	}
+	return client.SendChatPresence(ctx, chat, state, media)
+}
+
+func (m *Messenger) GetJoinedGroups(ctx context.Context, instanceID string) ([]*types.GroupInfo, error) {
+	client, err := m.client(instanceID)
+	if err != nil {
+		return nil, err
+	}
+	groups, err := client.GetJoinedGroups(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, group := range groups {
+		m.manager.learnGroupParticipants(ctx, instanceID, group.Participants)
+	}
+	return groups, nil
+}
+
+func (m *Messenger) GetGroupInfo(ctx context.Context, instanceID string, group types.JID) (*types.GroupInfo, error) {
+	client, err := m.client(instanceID)
+	if err != nil {
+		return nil, err
+	}
+	info, err := client.GetGroupInfo(ctx, group)
+	if err != nil {
+		return nil, err
+	}
+	m.manager.learnGroupParticipants(ctx, instanceID, info.Participants)
+	return info, nil
+}
+
+func (m *Messenger) CreateGroup(ctx context.Context, instanceID, name string, participants []types.JID) (*types.GroupInfo, error) {
+	client, err := m.client(instanceID)
+	if err != nil {
+		return nil, err
+	}
+	return client.CreateGroup(ctx, whatsmeow.ReqCreateGroup{
+		Name:         name,
+		Participants: participants,
+	})
+}
+
+func (m *Messenger) UpdateGroupParticipants(ctx context.Context, instanceID string, group types.JID, participants []types.JID, action session.ParticipantAction) ([]types.GroupParticipant, error) {
+	client, err := m.client(instanceID)
+	if err != nil {
+		return nil, err
+	}
+
+	var change whatsmeow.ParticipantChange
+	switch action {
+	case session.ParticipantAdd:
+		change = whatsmeow.ParticipantChangeAdd
+	case session.ParticipantRemove:
+		change = whatsmeow.ParticipantChangeRemove
+	case session.ParticipantPromote:
+		change = whatsmeow.ParticipantChangePromote
+	case session.ParticipantDemote:
+		change = whatsmeow.ParticipantChangeDemote
+	default:
+		return nil, fmt.Errorf("%w: ação %s", session.ErrNotSupported, action)
+	}
+	return client.UpdateGroupParticipants(ctx, group, participants, change)
+}
+
+func (m *Messenger) LeaveGroup(ctx context.Context, instanceID string, group types.JID) error {
+	client, err := m.client(instanceID)
+	if err != nil {
+		return err
+	}
+	return client.LeaveGroup(ctx, group)
+}
+
+// sleepContext aguarda d ou o cancelamento de ctx, o que vier primeiro. Usado
+// nas esperas de aquecimento do envio, que ocorrem antes de a mensagem ser
+// gravada e podem ser abandonadas sem efeitos colaterais.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
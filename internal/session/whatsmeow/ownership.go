@@ -0,0 +1,89 @@
+package whatsmeow
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+var ErrNotInstanceOwner = errors.New("instância pertence a outro nó do cluster")
+
+// SetOwnershipChecker restringe as conexões às instâncias que pertencem a
+// este nó. Sem checker (modo de nó único), todas as instâncias são locais.
+func (m *Manager) SetOwnershipChecker(fn func(instanceID string) bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.ownershipChecker = fn
+}
+
+// ActivateSession coloca a instância recém-assumida por este nó na fila de
+// restauração. O cliente não herda ctx, que pertence ao ciclo de quem chama.
+func (m *Manager) ActivateSession(_ context.Context, instanceID string) {
+	m.RestoreAllSessions(context.Background(), []string{instanceID})
+}
+
+// ReleaseSession desconecta a instância sem fazer logout, preservando o
+// device store para que outro nó retome a mesma sessão. Os handlers de evento
+// são removidos antes, para que a desconexão não marque a instância como em
+// erro enquanto o novo dono já está conectando.
+func (m *Manager) ReleaseSession(instanceID string) {
+	m.mu.Lock()
+	client := m.clients[instanceID]
+	delete(m.clients, instanceID)
+	if cancel, exists := m.qrContexts[instanceID]; exists {
+		cancel()
+		delete(m.qrContexts, instanceID)
+	}
+	if cancel, exists := m.syncWorkers[instanceID]; exists {
+		cancel()
+		delete(m.syncWorkers, instanceID)
+	}
+	if timer, exists := m.disconnectDebounce[instanceID]; exists {
+		timer.Stop()
+		delete(m.disconnectDebounce, instanceID)
+	}
+	delete(m.currentQRs, instanceID)
+	delete(m.sessionReady, instanceID)
+	delete(m.connectedAt, instanceID)
+	delete(m.pairCodes, instanceID)
+	m.mu.Unlock()
+
+	m.forgetState(instanceID, "sessão liberada para outro nó")
+
+	if client == nil {
+		return
+	}
+
+	client.RemoveEventHandlers()
+	client.Disconnect()
+
+	m.log.Info("sessão liberada para outro nó",
+		zap.String("instance_id", instanceID),
+	)
+}
+
+// HasPendingLogin informa se a instância está no meio de um login por QR code
+// ou código de pareamento, quando trocar de nó derrubaria o pareamento.
+func (m *Manager) HasPendingLogin(instanceID string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if _, ok := m.qrContexts[instanceID]; ok {
+		return true
+	}
+	if pairedAt, ok := m.pairingSuccess[instanceID]; ok && time.Since(pairedAt) < 60*time.Second {
+		return true
+	}
+	return false
+}
+
+// ownsInstance deve ser chamado com m.mu travado. Um nó em dreno não abre
+// novas conexões.
+func (m *Manager) ownsInstance(instanceID string) bool {
+	if m.draining {
+		return false
+	}
+	return m.ownershipChecker == nil || m.ownershipChecker(instanceID)
+}
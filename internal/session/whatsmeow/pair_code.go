@@ -0,0 +1,143 @@
+package whatsmeow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.mau.fi/whatsmeow"
+	"go.uber.org/zap"
+
+	"github.com/open-apime/apime/internal/storage/model"
+)
+
+// O WhatsApp não informa a validade do código de pareamento, mas encerra o
+// websocket de login quando os QR codes se esgotam (cerca de 160 segundos).
+const pairCodeTTL = 160 * time.Second
+
+// Tempo máximo de espera pela limpeza da janela de login anterior.
+const pairWindowRestartTimeout = 10 * time.Second
+
+const pairCodeClientName = "Chrome (Linux)"
+
+var ErrInvalidPairPhone = errors.New("número de telefone inválido para pareamento")
+
+// GetPairCode gera um código de pareamento para o número informado. O código
+// usa a mesma conexão de login do fluxo de QR code, então o pareamento é
+// acompanhado pelo mesmo canal e expira junto com ele. Por isso uma janela de
+// login já aberta é reiniciada antes de pedir o código, para que ele tenha a
+// validade inteira a partir da emissão.
+func (m *Manager) GetPairCode(ctx context.Context, instanceID, phone string) (model.PairCode, error) {
+	phone = normalizePairPhone(phone)
+	if len(phone) < 8 || len(phone) > 15 {
+		return model.PairCode{}, ErrInvalidPairPhone
+	}
+
+	if current, ok := m.CurrentPairCode(instanceID); ok && current.Phone == phone {
+		return current, nil
+	}
+
+	if err := m.restartPairingWindow(ctx, instanceID); err != nil {
+		return model.PairCode{}, err
+	}
+
+	// Garante um cliente conectado com o canal de QR ativo
+	if _, err := m.GetQR(ctx, instanceID); err != nil {
+		return model.PairCode{}, err
+	}
+
+	m.mu.RLock()
+	client, exists := m.clients[instanceID]
+	startedAt := m.qrStartedAt[instanceID]
+	m.mu.RUnlock()
+
+	if !exists || client == nil {
+		return model.PairCode{}, fmt.Errorf("cliente não encontrado para instância %s", instanceID)
+	}
+	if client.IsLoggedIn() {
+		return model.PairCode{}, fmt.Errorf("instância já conectada, não é necessário código de pareamento")
+	}
+
+	code, err := client.PairPhone(ctx, phone, true, whatsmeow.PairClientChrome, pairCodeClientName)
+	if err != nil {
+		m.log.Error("erro ao gerar código de pareamento",
+			zap.String("instance_id", instanceID),
+			zap.Error(err),
+		)
+		return model.PairCode{}, fmt.Errorf("whatsmeow: gerar código de pareamento: %w", err)
+	}
+
+	if startedAt.IsZero() {
+		startedAt = time.Now()
+	}
+	pairCode := model.PairCode{
+		Code:      code,
+		Phone:     phone,
+		ExpiresAt: startedAt.Add(pairCodeTTL),
+	}
+
+	m.mu.Lock()
+	m.pairCodes[instanceID] = pairCode
+	m.mu.Unlock()
+
+	m.log.Info("código de pareamento gerado",
+		zap.String("instance_id", instanceID),
+		zap.Time("expires_at", pairCode.ExpiresAt),
+	)
+	return pairCode, nil
+}
+
+// restartPairingWindow encerra a janela de login em andamento, se houver, e
+// aguarda a limpeza feita por monitorQRChannel antes de uma nova ser aberta.
+func (m *Manager) restartPairingWindow(ctx context.Context, instanceID string) error {
+	m.mu.RLock()
+	client := m.clients[instanceID]
+	cancel, hasWindow := m.qrContexts[instanceID]
+	done := m.qrDone[instanceID]
+	m.mu.RUnlock()
+
+	if !hasWindow || done == nil {
+		return nil
+	}
+	if client != nil && client.IsLoggedIn() {
+		return fmt.Errorf("instância já conectada, não é necessário código de pareamento")
+	}
+
+	m.log.Info("reiniciando janela de login para gerar código de pareamento",
+		zap.String("instance_id", instanceID),
+	)
+	cancel()
+
+	select {
+	case <-done:
+		return nil
+	case <-time.After(pairWindowRestartTimeout):
+		return fmt.Errorf("whatsmeow: timeout ao reiniciar janela de pareamento")
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// CurrentPairCode retorna o código de pareamento ainda válido da instância, se houver.
+func (m *Manager) CurrentPairCode(instanceID string) (model.PairCode, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	pairCode, ok := m.pairCodes[instanceID]
+	if !ok || time.Now().After(pairCode.ExpiresAt) {
+		return model.PairCode{}, false
+	}
+	return pairCode, true
+}
+
+func normalizePairPhone(phone string) string {
+	var b strings.Builder
+	for _, r := range phone {
+		if r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
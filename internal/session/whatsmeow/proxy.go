@@ -0,0 +1,348 @@
+package whatsmeow
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mau.fi/whatsmeow"
+	"go.uber.org/zap"
+	"golang.org/x/net/proxy"
+
+	"github.com/open-apime/apime/internal/storage"
+	"github.com/open-apime/apime/internal/storage/model"
+)
+
+const (
+	proxyHealthCheckTarget  = "web.whatsapp.com:443"
+	proxyHealthCheckTimeout = 5 * time.Second
+	// Por quanto tempo o resultado da verificação é reaproveitado, para que
+	// cada atualização do diagnóstico não abra uma conexão pelo proxy.
+	proxyHealthCacheTTL = time.Minute
+)
+
+var ErrInvalidProxy = errors.New("configuração de proxy inválida")
+
+type proxyCredentials struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// ProxyHealth é o resultado da verificação de conectividade através do proxy.
+type ProxyHealth struct {
+	Address   string    `json:"address"`
+	Healthy   bool      `json:"healthy"`
+	LatencyMs int64     `json:"latencyMs,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	CheckedAt time.Time `json:"checkedAt"`
+}
+
+func (m *Manager) SetProxyRepository(repo storage.InstanceProxyRepository) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.proxyRepo = repo
+}
+
+// GetProxy retorna o proxy configurado para a instância, com o usuário já
+// descriptografado. A senha nunca é exposta.
+func (m *Manager) GetProxy(ctx context.Context, instanceID string) (model.InstanceProxy, error) {
+	p, err := m.loadProxy(ctx, m.getProxyRepo(), instanceID)
+	if err != nil {
+		return model.InstanceProxy{}, err
+	}
+	if p == nil {
+		return model.InstanceProxy{}, storage.ErrNotFound
+	}
+	p.Password = ""
+	return *p, nil
+}
+
+// SetProxy grava o proxy da instância e o aplica ao cliente ativo, que é
+// reconectado para que o websocket passe a usar o novo caminho.
+func (m *Manager) SetProxy(ctx context.Context, p model.InstanceProxy) (model.InstanceProxy, error) {
+	repo := m.getProxyRepo()
+	if repo == nil {
+		return model.InstanceProxy{}, errors.New("configuração de proxy não disponível")
+	}
+
+	p.Type = model.ProxyType(strings.ToLower(string(p.Type)))
+	p.Host = strings.TrimSpace(p.Host)
+	if p.Type != model.ProxyTypeHTTP && p.Type != model.ProxyTypeSOCKS5 {
+		return model.InstanceProxy{}, fmt.Errorf("%w: tipo deve ser http ou socks5", ErrInvalidProxy)
+	}
+	if p.Host == "" || strings.ContainsAny(p.Host, "/@ ") {
+		return model.InstanceProxy{}, fmt.Errorf("%w: host inválido", ErrInvalidProxy)
+	}
+	if p.Port <= 0 || p.Port > 65535 {
+		return model.InstanceProxy{}, fmt.Errorf("%w: porta inválida", ErrInvalidProxy)
+	}
+
+	p.CredentialsEnc = nil
+	if p.Username != "" || p.Password != "" {
+		data, err := json.Marshal(proxyCredentials{Username: p.Username, Password: p.Password})
+		if err != nil {
+			return model.InstanceProxy{}, err
+		}
+		p.CredentialsEnc, err = m.keyring.Encrypt(data)
+		if err != nil {
+			return model.InstanceProxy{}, fmt.Errorf("criptografar credenciais do proxy: %w", err)
+		}
+	}
+
+	if err := repo.Upsert(ctx, p); err != nil {
+		return model.InstanceProxy{}, err
+	}
+
+	m.forgetProxyHealth(p.InstanceID)
+	m.log.Info("proxy da instância configurado",
+		zap.String("instance_id", p.InstanceID),
+		zap.String("type", string(p.Type)),
+		zap.String("address", net.JoinHostPort(p.Host, strconv.Itoa(p.Port))),
+	)
+
+	m.reconnectWithProxy(p.InstanceID, &p)
+
+	p.Password = ""
+	p.CredentialsEnc = nil
+	return p, nil
+}
+
+// RemoveProxy apaga o proxy da instância, que volta a conectar diretamente.
+func (m *Manager) RemoveProxy(ctx context.Context, instanceID string) error {
+	repo := m.getProxyRepo()
+	if repo == nil {
+		return errors.New("configuração de proxy não disponível")
+	}
+	if err := repo.Delete(ctx, instanceID); err != nil {
+		return err
+	}
+
+	m.forgetProxyHealth(instanceID)
+	m.log.Info("proxy da instância removido", zap.String("instance_id", instanceID))
+	m.reconnectWithProxy(instanceID, nil)
+	return nil
+}
+
+// CheckProxyHealth abre uma conexão com o WhatsApp através do proxy
+// configurado. O resultado é reaproveitado por proxyHealthCacheTTL. Retorna
+// nil quando a instância não usa proxy.
+func (m *Manager) CheckProxyHealth(ctx context.Context, instanceID string) *ProxyHealth {
+	p, err := m.loadProxy(ctx, m.getProxyRepo(), instanceID)
+	if err != nil {
+		return &ProxyHealth{Error: err.Error(), CheckedAt: time.Now()}
+	}
+	if p == nil {
+		return nil
+	}
+
+	address := fmt.Sprintf("%s://%s", p.Type, net.JoinHostPort(p.Host, strconv.Itoa(p.Port)))
+	m.mu.RLock()
+	cached, ok := m.proxyHealth[instanceID]
+	m.mu.RUnlock()
+	if ok && cached.Address == address && time.Since(cached.CheckedAt) < proxyHealthCacheTTL {
+		return &cached
+	}
+
+	health := m.dialProxyHealth(ctx, p, address)
+	m.mu.Lock()
+	m.proxyHealth[instanceID] = *health
+	m.mu.Unlock()
+	return health
+}
+
+func (m *Manager) forgetProxyHealth(instanceID string) {
+	m.mu.Lock()
+	delete(m.proxyHealth, instanceID)
+	m.mu.Unlock()
+}
+
+func (m *Manager) dialProxyHealth(ctx context.Context, p *model.InstanceProxy, address string) *ProxyHealth {
+	health := &ProxyHealth{
+		Address:   address,
+		CheckedAt: time.Now(),
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, proxyHealthCheckTimeout)
+	defer cancel()
+
+	start := time.Now()
+	conn, err := dialThroughProxy(ctx, p, proxyHealthCheckTarget)
+	if err != nil {
+		health.Error = err.Error()
+		return health
+	}
+	_ = conn.Close()
+
+	health.Healthy = true
+	health.LatencyMs = time.Since(start).Milliseconds()
+	return health
+}
+
+// applyInstanceProxy configura o proxy no cliente antes da conexão. Um
+// proxy configurado mas ilegível impede a conexão, para que a instância não
+// saia pelo IP do servidor sem que o operador perceba. O repositório é
+// recebido do chamador porque a restauração roda com m.mu travado.
+func (m *Manager) applyInstanceProxy(ctx context.Context, repo storage.InstanceProxyRepository, instanceID string, client *whatsmeow.Client) error {
+	p, err := m.loadProxy(ctx, repo, instanceID)
+	if err != nil {
+		return fmt.Errorf("carregar proxy da instância: %w", err)
+	}
+	if p == nil {
+		return nil
+	}
+
+	if err := client.SetProxyAddress(proxyURL(p).String()); err != nil {
+		return fmt.Errorf("aplicar proxy da instância: %w", err)
+	}
+
+	m.log.Info("cliente configurado com proxy",
+		zap.String("instance_id", instanceID),
+		zap.String("type", string(p.Type)),
+		zap.String("address", net.JoinHostPort(p.Host, strconv.Itoa(p.Port))),
+	)
+	return nil
+}
+
+// reconnectWithProxy aplica o proxy (ou a conexão direta, quando nil) ao
+// cliente em memória e reconecta o websocket.
+func (m *Manager) reconnectWithProxy(instanceID string, p *model.InstanceProxy) {
+	m.mu.RLock()
+	client, exists := m.clients[instanceID]
+	m.mu.RUnlock()
+	if !exists || client == nil {
+		return
+	}
+
+	addr := ""
+	if p != nil {
+		addr = proxyURL(p).String()
+	}
+	if err := client.SetProxyAddress(addr); err != nil {
+		m.log.Error("erro ao aplicar proxy no cliente ativo",
+			zap.String("instance_id", instanceID),
+			zap.Error(err),
+		)
+		return
+	}
+
+	if !client.IsConnected() {
+		return
+	}
+
+	client.Disconnect()
+	if err := client.Connect(); err != nil {
+		m.log.Error("erro ao reconectar cliente após alterar proxy",
+			zap.String("instance_id", instanceID),
+			zap.Error(err),
+		)
+	}
+}
+
+func (m *Manager) getProxyRepo() storage.InstanceProxyRepository {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.proxyRepo
+}
+
+// loadProxy busca e descriptografa o proxy da instância. Retorna nil quando
+// não há proxy configurado.
+func (m *Manager) loadProxy(ctx context.Context, repo storage.InstanceProxyRepository, instanceID string) (*model.InstanceProxy, error) {
+	if repo == nil {
+		return nil, nil
+	}
+
+	p, err := repo.Get(ctx, instanceID)
+	if err != nil {
+		if err.Error() == "not found" {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	if len(p.CredentialsEnc) > 0 {
+		data, err := m.keyring.Decrypt(p.CredentialsEnc)
+		if err != nil {
+			return nil, fmt.Errorf("descriptografar credenciais do proxy: %w", err)
+		}
+		var creds proxyCredentials
+		if err := json.Unmarshal(data, &creds); err != nil {
+			return nil, fmt.Errorf("decodificar credenciais do proxy: %w", err)
+		}
+		p.Username = creds.Username
+		p.Password = creds.Password
+	}
+	return &p, nil
+}
+
+func proxyURL(p *model.InstanceProxy) *url.URL {
+	u := &url.URL{
+		Scheme: string(p.Type),
+		Host:   net.JoinHostPort(p.Host, strconv.Itoa(p.Port)),
+	}
+	if p.Username != "" || p.Password != "" {
+		u.User = url.UserPassword(p.Username, p.Password)
+	}
+	return u
+}
+
+// dialThroughProxy abre um túnel TCP até target usando o proxy informado.
+func dialThroughProxy(ctx context.Context, p *model.InstanceProxy, target string) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: proxyHealthCheckTimeout}
+
+	if p.Type == model.ProxyTypeSOCKS5 {
+		px, err := proxy.FromURL(proxyURL(p), dialer)
+		if err != nil {
+			return nil, err
+		}
+		if ctxDialer, ok := px.(proxy.ContextDialer); ok {
+			return ctxDialer.DialContext(ctx, "tcp", target)
+		}
+		return px.Dial("tcp", target)
+	}
+
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(p.Host, strconv.Itoa(p.Port)))
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: target},
+		Host:   target,
+		Header: make(http.Header),
+	}
+	if p.Username != "" || p.Password != "" {
+		auth := base64.StdEncoding.EncodeToString([]byte(p.Username + ":" + p.Password))
+		req.Header.Set("Proxy-Authorization", "Basic "+auth)
+	}
+	if err := req.Write(conn); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	resp, err := http.ReadResponse(bufio.NewReader(conn), req)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		_ = conn.Close()
+		return nil, fmt.Errorf("proxy respondeu %s ao CONNECT", resp.Status)
+	}
+
+	_ = conn.SetDeadline(time.Time{})
+	return conn, nil
+}
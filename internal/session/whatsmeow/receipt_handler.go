@@ -10,6 +10,7 @@ import (
 	"go.uber.org/zap"
 
 	"github.com/open-apime/apime/internal/storage"
+	"github.com/open-apime/apime/internal/storage/model"
 )
 
 type ReceiptHandler struct {
@@ -82,6 +83,15 @@ func shouldUpdateStatus(currentStatus, newStatus string) bool {
 	return new > current
 }
 
+const (
+	// stuckLookback limita a busca de envios pendentes às últimas horas;
+	// mensagens mais antigas já passaram pelas verificações anteriores.
+	stuckLookback = 24 * time.Hour
+	// stuckBatchSize é o máximo de envios pendentes verificados por
+	// instância a cada ciclo.
+	stuckBatchSize = 200
+)
+
 type MessageStuckDetector struct {
 	messageRepo   storage.MessageRepository
 	sessionMgr    *Manager
@@ -91,6 +101,7 @@ type MessageStuckDetector struct {
 	stopChan      chan struct{}
 	resetAttempts map[string]time.Time
 	attemptsMu    sync.RWMutex
+	onStuck       func(instanceID string, count int)
 }
 
 func NewMessageStuckDetector(
@@ -109,6 +120,12 @@ func NewMessageStuckDetector(
 	}
 }
 
+// SetOnStuck registra uma função chamada a cada verificação com a quantidade
+// de mensagens da instância marcadas como travadas nela.
+func (d *MessageStuckDetector) SetOnStuck(fn func(instanceID string, count int)) {
+	d.onStuck = fn
+}
+
 func (d *MessageStuckDetector) Start(ctx context.Context, checkInterval time.Duration) {
 	d.checkTicker = time.NewTicker(checkInterval)
 
@@ -148,7 +165,7 @@ func (d *MessageStuckDetector) checkStuckMessages(ctx context.Context) {
 			continue
 		}
 
-		messages, err := d.messageRepo.ListByInstance(ctx, instanceID)
+		messages, err := d.messageRepo.ListPendingOutbound(ctx, instanceID, time.Now().Add(-stuckLookback), stuckBatchSize)
 		if err != nil {
 			d.log.Error("erro ao buscar mensagens para verificar stuck",
 				zap.String("instance_id", instanceID),
@@ -156,8 +173,13 @@ func (d *MessageStuckDetector) checkStuckMessages(ctx context.Context) {
 			continue
 		}
 
+		stuckCount := 0
 		for _, msg := range messages {
-			
+			// Mensagens recebidas ou espelhadas de outros dispositivos não passam pelo envio da API
+			if msg.Direction == model.MessageDirectionInbound || msg.Sender != "" {
+				continue
+			}
+
 			isStuck := (msg.Status == "sent" || msg.Status == "retry" || msg.Status == "delivered") && time.Since(msg.CreatedAt) > d.stuckTimeout
 
 			
@@ -177,6 +199,7 @@ func (d *MessageStuckDetector) checkStuckMessages(ctx context.Context) {
 				if attempted && time.Since(lastAttempt) < retryDebounce {
 					if msg.Status != "failed_stuck" && !isRetry {
 						msg.Status = "failed_stuck"
+						stuckCount++
 						if err := d.messageRepo.Update(ctx, msg); err != nil {
 							d.log.Error("erro ao atualizar status de mensagem stuck",
 								zap.String("message_id", msg.ID),
@@ -203,6 +226,7 @@ func (d *MessageStuckDetector) checkStuckMessages(ctx context.Context) {
 						zap.String("message_id", msg.ID),
 						zap.Error(err))
 					msg.Status = "failed_stuck"
+					stuckCount++
 					if updateErr := d.messageRepo.Update(ctx, msg); updateErr != nil {
 						d.log.Error("erro ao atualizar status após falha no reset",
 							zap.String("message_id", msg.ID),
@@ -214,6 +238,7 @@ func (d *MessageStuckDetector) checkStuckMessages(ctx context.Context) {
 						zap.String("recipient", msg.To),
 						zap.String("message_id", msg.ID))
 					msg.Status = "failed_stuck"
+					stuckCount++
 					if err := d.messageRepo.Update(ctx, msg); err != nil {
 						d.log.Error("erro ao atualizar status de mensagem stuck",
 							zap.String("message_id", msg.ID),
@@ -222,5 +247,9 @@ func (d *MessageStuckDetector) checkStuckMessages(ctx context.Context) {
 				}
 			}
 		}
+
+		if stuckCount > 0 && d.onStuck != nil {
+			d.onStuck(instanceID, stuckCount)
+		}
 	}
 }
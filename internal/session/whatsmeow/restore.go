@@ -0,0 +1,304 @@
+package whatsmeow
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/open-apime/apime/internal/storage/model"
+)
+
+// RestorePolicy controla a restauração das sessões. Conectar centenas de
+// sessões ao mesmo tempo dispara o envio de prekeys de todas de uma vez e
+// esbarra no rate limit do WhatsApp. A política vale a partir da primeira
+// restauração, quando os workers da fila são iniciados.
+type RestorePolicy struct {
+	// Concurrency é o máximo de sessões conectando ao mesmo tempo.
+	Concurrency int
+	// Jitter é a espera aleatória máxima antes de cada conexão.
+	Jitter time.Duration
+}
+
+func DefaultRestorePolicy() RestorePolicy {
+	return RestorePolicy{
+		Concurrency: 5,
+		Jitter:      1500 * time.Millisecond,
+	}
+}
+
+func (m *Manager) SetRestorePolicy(policy RestorePolicy) {
+	if policy.Concurrency <= 0 {
+		policy.Concurrency = 1
+	}
+	if policy.Jitter < 0 {
+		policy.Jitter = 0
+	}
+
+	m.restoreMu.Lock()
+	defer m.restoreMu.Unlock()
+	m.restorePolicy = policy
+}
+
+// restoreActivityTTL é por quanto tempo a atividade das instâncias, usada
+// como prioridade, é reaproveitada entre enfileiramentos. Evita uma consulta
+// por instância quando o cluster redistribui muitas de uma vez.
+const restoreActivityTTL = time.Minute
+
+// SetRestorePriority define a fonte da prioridade da restauração: instâncias
+// com atividade mais recente passam à frente na fila. Sem fonte, a fila segue
+// a ordem de chegada.
+func (m *Manager) SetRestorePriority(fn func(ctx context.Context) (map[string]time.Time, error)) {
+	m.restoreMu.Lock()
+	defer m.restoreMu.Unlock()
+	m.restoreActivitySource = fn
+	m.restoreActivity = nil
+}
+
+// RestoreAllSessions coloca as sessões na fila de restauração, que as conecta
+// em background respeitando a RestorePolicy e a prioridade. A fila é única
+// para o nó: a restauração da inicialização e as instâncias assumidas depois
+// no cluster dividem o mesmo limite de concorrência, e o andamento é
+// acumulado. Até a vez de cada uma, IsRestoring retorna true e os envios são
+// recusados com session.ErrRestoring.
+func (m *Manager) RestoreAllSessions(ctx context.Context, instanceIDs []string) {
+	m.log.Info("enfileirando restauração de sessões",
+		zap.Int("total_instances", len(instanceIDs)),
+		zap.String("storage", m.storageDriver),
+	)
+
+	queue := make([]string, 0, len(instanceIDs))
+	skipped := 0
+	for _, instanceID := range instanceIDs {
+		m.mu.RLock()
+		client, exists := m.clients[instanceID]
+		m.mu.RUnlock()
+
+		if exists && client != nil && client.IsLoggedIn() {
+			m.log.Debug("sessão já está em memória e logada, pulando",
+				zap.String("instance_id", instanceID),
+			)
+			skipped++
+			continue
+		}
+
+		if m.storageDriver != "postgres" {
+			dbPath := filepath.Join(m.baseDir, instanceID+".db")
+			if _, err := os.Stat(dbPath); os.IsNotExist(err) {
+				m.log.Debug("arquivo SQLite não existe, pulando",
+					zap.String("instance_id", instanceID),
+				)
+				skipped++
+				continue
+			}
+		}
+
+		queue = append(queue, instanceID)
+	}
+
+	activity := m.restorePriority(ctx)
+
+	now := time.Now()
+	m.restoreMu.Lock()
+	policy := m.restorePolicy
+	queued := 0
+	for _, instanceID := range queue {
+		if m.restoring[instanceID] {
+			// Já está na fila ou conectando.
+			continue
+		}
+		m.restoring[instanceID] = true
+		m.enqueueRestore(restoreItem{instanceID: instanceID, activity: activity[instanceID]})
+		queued++
+	}
+	if m.restoreProgress.Status != model.SessionRestoreRunning && queued > 0 {
+		m.restoreProgress.Status = model.SessionRestoreRunning
+		m.restoreProgress.FinishedAt = nil
+		if m.restoreProgress.StartedAt == nil {
+			m.restoreProgress.StartedAt = &now
+		}
+	}
+	m.restoreProgress.Total += queued + skipped
+	m.restoreProgress.Skipped += skipped
+	m.restoreProgress.Pending += queued
+	if m.restoreProgress.Status != model.SessionRestoreRunning && queued == 0 {
+		// Nada a conectar: a restauração já começa concluída.
+		if m.restoreProgress.StartedAt == nil {
+			m.restoreProgress.StartedAt = &now
+		}
+		m.restoreProgress.Status = model.SessionRestoreCompleted
+		m.restoreProgress.FinishedAt = &now
+	}
+	if !m.restoreWorkersStarted {
+		m.restoreWorkersStarted = true
+		for i := 0; i < policy.Concurrency; i++ {
+			go m.restoreWorker(policy)
+		}
+	}
+	m.restoreCond.Broadcast()
+	m.restoreMu.Unlock()
+
+	m.log.Info("sessões enfileiradas para restauração",
+		zap.Int("queued", queued),
+		zap.Int("skipped", skipped),
+		zap.Int("concurrency", policy.Concurrency),
+		zap.Duration("jitter", policy.Jitter),
+	)
+}
+
+type restoreItem struct {
+	instanceID string
+	activity   time.Time
+}
+
+// enqueueRestore insere o item na fila ordenada pela atividade mais recente,
+// depois dos itens de mesma prioridade. Chamado com restoreMu.
+func (m *Manager) enqueueRestore(item restoreItem) {
+	i := sort.Search(len(m.restoreQueue), func(i int) bool {
+		return item.activity.After(m.restoreQueue[i].activity)
+	})
+	m.restoreQueue = append(m.restoreQueue, restoreItem{})
+	copy(m.restoreQueue[i+1:], m.restoreQueue[i:])
+	m.restoreQueue[i] = item
+}
+
+// restorePriority retorna a atividade recente das instâncias, consultada no
+// máximo uma vez por restoreActivityTTL.
+func (m *Manager) restorePriority(ctx context.Context) map[string]time.Time {
+	m.restoreMu.Lock()
+	source := m.restoreActivitySource
+	if source == nil || time.Since(m.restoreActivityAt) < restoreActivityTTL {
+		activity := m.restoreActivity
+		m.restoreMu.Unlock()
+		return activity
+	}
+	m.restoreMu.Unlock()
+
+	activity, err := source(ctx)
+	if err != nil {
+		m.log.Warn("erro ao consultar atividade recente das instâncias", zap.Error(err))
+		return nil
+	}
+
+	m.restoreMu.Lock()
+	m.restoreActivity = activity
+	m.restoreActivityAt = time.Now()
+	m.restoreMu.Unlock()
+	return activity
+}
+
+// restoreWorker consome a fila de restauração durante toda a vida do nó.
+// Durante o dreno, as instâncias ainda na fila são liberadas sem conectar.
+func (m *Manager) restoreWorker(policy RestorePolicy) {
+	for {
+		m.restoreMu.Lock()
+		for len(m.restoreQueue) == 0 {
+			m.restoreCond.Wait()
+		}
+		item := m.restoreQueue[0]
+		m.restoreQueue = m.restoreQueue[1:]
+		m.restoreMu.Unlock()
+
+		if m.isDraining() {
+			m.abandonRestore(item.instanceID)
+			continue
+		}
+		m.restoreOne(context.Background(), item.instanceID, policy.Jitter)
+	}
+}
+
+func (m *Manager) restoreOne(ctx context.Context, instanceID string, jitter time.Duration) {
+	if jitter > 0 {
+		select {
+		case <-time.After(time.Duration(rand.Int63n(int64(jitter)))):
+		case <-ctx.Done():
+		}
+	}
+
+	m.restoreMu.Lock()
+	m.restoreProgress.Pending--
+	m.restoreProgress.InProgress++
+	m.restoreMu.Unlock()
+
+	client, err := m.restoreSessionIfExists(ctx, instanceID)
+
+	m.restoreMu.Lock()
+	defer m.restoreMu.Unlock()
+	delete(m.restoring, instanceID)
+	m.restoreProgress.InProgress--
+
+	switch {
+	case errors.Is(err, ErrNotInstanceOwner):
+		m.restoreProgress.Skipped++
+	case err != nil:
+		m.restoreProgress.Failed++
+		m.log.Debug("não foi possível restaurar sessão",
+			zap.String("instance_id", instanceID),
+			zap.Error(err),
+		)
+	case client != nil && client.IsLoggedIn():
+		m.restoreProgress.Restored++
+		m.log.Info("sessão restaurada com sucesso",
+			zap.String("instance_id", instanceID),
+		)
+	default:
+		m.restoreProgress.Failed++
+	}
+	m.finishRestoreIfIdle()
+}
+
+// abandonRestore libera a instância que não chegou a ser restaurada, para que
+// deixe de recusar envios como em restauração.
+func (m *Manager) abandonRestore(instanceID string) {
+	m.restoreMu.Lock()
+	defer m.restoreMu.Unlock()
+	delete(m.restoring, instanceID)
+	m.restoreProgress.Pending--
+	m.restoreProgress.Skipped++
+	m.finishRestoreIfIdle()
+}
+
+// finishRestoreIfIdle marca a restauração como concluída quando a fila se
+// esvazia. Chamado com restoreMu.
+func (m *Manager) finishRestoreIfIdle() {
+	progress := &m.restoreProgress
+	if progress.Status != model.SessionRestoreRunning || progress.Pending > 0 || progress.InProgress > 0 {
+		return
+	}
+	finished := time.Now()
+	progress.Status = model.SessionRestoreCompleted
+	progress.FinishedAt = &finished
+
+	m.log.Info("restauração de sessões concluída",
+		zap.Int("restored", progress.Restored),
+		zap.Int("failed", progress.Failed),
+		zap.Int("skipped", progress.Skipped),
+		zap.Duration("duration", finished.Sub(*progress.StartedAt)),
+	)
+}
+
+// IsRestoring informa se a sessão da instância ainda aguarda a restauração da
+// inicialização.
+func (m *Manager) IsRestoring(instanceID string) bool {
+	m.restoreMu.Lock()
+	defer m.restoreMu.Unlock()
+	return m.restoring[instanceID]
+}
+
+// RestoreProgress retorna o andamento da restauração das sessões.
+func (m *Manager) RestoreProgress() model.SessionRestoreProgress {
+	m.restoreMu.Lock()
+	defer m.restoreMu.Unlock()
+	return m.restoreProgress
+}
+
+func (m *Manager) isDraining() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.draining
+}
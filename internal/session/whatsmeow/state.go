@@ -0,0 +1,406 @@
+package whatsmeow
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+
+	"go.mau.fi/whatsmeow"
+	"go.uber.org/zap"
+
+	"github.com/open-apime/apime/internal/storage"
+	"github.com/open-apime/apime/internal/storage/model"
+)
+
+// ReconnectPolicy controla as reconexões automáticas feitas pelo manager no
+// lugar do auto-reconnect do whatsmeow.
+type ReconnectPolicy struct {
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// MaxAttempts zero significa tentar indefinidamente.
+	MaxAttempts int
+}
+
+func DefaultReconnectPolicy() ReconnectPolicy {
+	return ReconnectPolicy{
+		BaseDelay:   2 * time.Second,
+		MaxDelay:    5 * time.Minute,
+		MaxAttempts: 10,
+	}
+}
+
+// delay dobra a espera a cada tentativa, até MaxDelay, com até 20% de jitter
+// para que instâncias derrubadas juntas não reconectem todas ao mesmo tempo.
+func (p ReconnectPolicy) delay(attempt int) time.Duration {
+	d := p.BaseDelay
+	for i := 1; i < attempt && d < p.MaxDelay; i++ {
+		d *= 2
+	}
+	if d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	if jitter := int64(d) / 5; jitter > 0 {
+		d += time.Duration(rand.Int63n(jitter))
+	}
+	return d
+}
+
+// allowedTransitions lista, para cada estado, os destinos válidos. Estados
+// ausentes do mapa (instância ainda sem estado) aceitam qualquer destino.
+var allowedTransitions = map[model.ConnectionState][]model.ConnectionState{
+	model.ConnectionStatePairing: {
+		model.ConnectionStateConnecting, model.ConnectionStateConnected, model.ConnectionStateReconnecting,
+		model.ConnectionStateLoggedOut, model.ConnectionStateBanned, model.ConnectionStateOutdated,
+		model.ConnectionStateDisconnected,
+	},
+	model.ConnectionStateConnecting: {
+		model.ConnectionStatePairing, model.ConnectionStateConnected, model.ConnectionStateReconnecting,
+		model.ConnectionStateLoggedOut, model.ConnectionStateBanned, model.ConnectionStateOutdated,
+		model.ConnectionStateDisconnected,
+	},
+	model.ConnectionStateConnected: {
+		model.ConnectionStateConnecting, model.ConnectionStateReconnecting, model.ConnectionStateLoggedOut,
+		model.ConnectionStateBanned, model.ConnectionStateOutdated, model.ConnectionStateDisconnected,
+	},
+	model.ConnectionStateReconnecting: {
+		model.ConnectionStateConnecting, model.ConnectionStateConnected, model.ConnectionStateLoggedOut,
+		model.ConnectionStateBanned, model.ConnectionStateOutdated, model.ConnectionStateDisconnected,
+	},
+	model.ConnectionStateLoggedOut: {
+		model.ConnectionStatePairing, model.ConnectionStateConnecting, model.ConnectionStateDisconnected,
+	},
+	model.ConnectionStateBanned: {
+		model.ConnectionStateConnecting, model.ConnectionStateConnected, model.ConnectionStateLoggedOut,
+		model.ConnectionStateDisconnected,
+	},
+	model.ConnectionStateOutdated: {
+		model.ConnectionStatePairing, model.ConnectionStateConnecting, model.ConnectionStateDisconnected,
+	},
+	model.ConnectionStateDisconnected: {
+		model.ConnectionStatePairing, model.ConnectionStateConnecting, model.ConnectionStateConnected,
+		model.ConnectionStateLoggedOut,
+	},
+}
+
+func canTransition(from, to model.ConnectionState) bool {
+	targets, ok := allowedTransitions[from]
+	if !ok {
+		return true
+	}
+	for _, t := range targets {
+		if t == to {
+			return true
+		}
+	}
+	return false
+}
+
+type connectionState struct {
+	state       model.ConnectionState
+	since       time.Time
+	attempts    int
+	nextRetryAt time.Time
+	retryTimer  *time.Timer
+	// downSince marca o início do período sem conexão, que atravessa as
+	// tentativas de reconexão até o próximo connected.
+	downSince time.Time
+}
+
+// isDownState indica os estados em que a instância está sem conexão útil.
+func isDownState(state model.ConnectionState) bool {
+	switch state {
+	case model.ConnectionStateReconnecting, model.ConnectionStateLoggedOut, model.ConnectionStateBanned,
+		model.ConnectionStateOutdated, model.ConnectionStateDisconnected:
+		return true
+	}
+	return false
+}
+
+func (m *Manager) SetReconnectPolicy(policy ReconnectPolicy) {
+	defaults := DefaultReconnectPolicy()
+	if policy.BaseDelay <= 0 {
+		policy.BaseDelay = defaults.BaseDelay
+	}
+	if policy.MaxDelay < policy.BaseDelay {
+		policy.MaxDelay = policy.BaseDelay
+	}
+	if policy.MaxAttempts < 0 {
+		policy.MaxAttempts = 0
+	}
+
+	m.stateMu.Lock()
+	defer m.stateMu.Unlock()
+	m.reconnectPolicy = policy
+}
+
+func (m *Manager) SetStateTransitionRepository(repo storage.InstanceStateTransitionRepository) {
+	m.stateMu.Lock()
+	defer m.stateMu.Unlock()
+	m.stateRepo = repo
+}
+
+// SetStateListener registra uma função chamada a cada transição aplicada.
+// Ela roda na goroutine do evento e não deve bloquear.
+func (m *Manager) SetStateListener(fn func(transition model.InstanceStateTransition)) {
+	m.stateMu.Lock()
+	defer m.stateMu.Unlock()
+	m.stateListener = fn
+}
+
+// stateFor deve ser chamado com m.stateMu travado.
+func (m *Manager) stateFor(instanceID string) *connectionState {
+	st, ok := m.connStates[instanceID]
+	if !ok {
+		st = &connectionState{}
+		m.connStates[instanceID] = st
+	}
+	return st
+}
+
+// transition muda o estado da instância e registra a transição. Transições
+// para o mesmo estado são ignoradas e as não previstas na tabela são
+// descartadas com aviso.
+func (m *Manager) transition(instanceID string, to model.ConnectionState, reason string) bool {
+	m.stateMu.Lock()
+	st := m.stateFor(instanceID)
+	from := st.state
+	if from == to {
+		m.stateMu.Unlock()
+		return false
+	}
+	if !canTransition(from, to) {
+		m.stateMu.Unlock()
+		m.log.Warn("transição de estado de conexão inválida ignorada",
+			zap.String("instance_id", instanceID),
+			zap.String("from", string(from)),
+			zap.String("to", string(to)),
+			zap.String("reason", reason),
+		)
+		return false
+	}
+
+	st.state = to
+	st.since = time.Now()
+	if to != model.ConnectionStateReconnecting && to != model.ConnectionStateConnecting {
+		if st.retryTimer != nil {
+			st.retryTimer.Stop()
+			st.retryTimer = nil
+		}
+		st.nextRetryAt = time.Time{}
+	}
+	if to == model.ConnectionStateConnected {
+		st.attempts = 0
+	}
+	switch {
+	case isDownState(to) && st.downSince.IsZero():
+		st.downSince = st.since
+	case to == model.ConnectionStateConnected || to == model.ConnectionStatePairing:
+		st.downSince = time.Time{}
+	}
+	attempt := st.attempts
+	repo := m.stateRepo
+	listener := m.stateListener
+	m.stateMu.Unlock()
+
+	m.log.Info("estado de conexão alterado",
+		zap.String("instance_id", instanceID),
+		zap.String("from", string(from)),
+		zap.String("to", string(to)),
+		zap.String("reason", reason),
+		zap.Int("attempt", attempt),
+	)
+
+	t := model.InstanceStateTransition{
+		InstanceID: instanceID,
+		From:       from,
+		To:         to,
+		Reason:     reason,
+		Attempt:    attempt,
+		CreatedAt:  time.Now(),
+	}
+	if repo != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := repo.Create(ctx, t); err != nil {
+			m.log.Warn("erro ao registrar transição de estado",
+				zap.String("instance_id", instanceID),
+				zap.Error(err),
+			)
+		}
+	}
+	if listener != nil {
+		listener(t)
+	}
+	return true
+}
+
+// scheduleReconnect agenda a próxima tentativa de reconexão seguindo a
+// política de backoff. Esgotadas as tentativas, a instância fica em
+// disconnected até uma ação manual.
+func (m *Manager) scheduleReconnect(instanceID string, client *whatsmeow.Client, reason string) {
+	if client == nil || client.Store == nil || client.Store.ID == nil {
+		// Sem device pareado não há o que reconectar; o login precisa recomeçar.
+		m.transition(instanceID, model.ConnectionStateDisconnected, reason)
+		return
+	}
+
+	m.stateMu.Lock()
+	st := m.stateFor(instanceID)
+	st.attempts++
+	attempt := st.attempts
+	policy := m.reconnectPolicy
+	m.stateMu.Unlock()
+
+	if policy.MaxAttempts > 0 && attempt > policy.MaxAttempts {
+		m.log.Error("tentativas de reconexão esgotadas",
+			zap.String("instance_id", instanceID),
+			zap.Int("max_attempts", policy.MaxAttempts),
+		)
+		m.transition(instanceID, model.ConnectionStateDisconnected, "tentativas de reconexão esgotadas")
+		m.updateInstanceStatus(instanceID, model.InstanceStatusError)
+		return
+	}
+
+	m.transition(instanceID, model.ConnectionStateReconnecting, reason)
+	m.retryAfter(instanceID, client, policy.delay(attempt))
+}
+
+// retryAfter agenda uma tentativa de conexão do cliente após delay,
+// substituindo qualquer tentativa pendente.
+func (m *Manager) retryAfter(instanceID string, client *whatsmeow.Client, delay time.Duration) {
+	m.stateMu.Lock()
+	defer m.stateMu.Unlock()
+
+	st := m.stateFor(instanceID)
+	if st.retryTimer != nil {
+		st.retryTimer.Stop()
+	}
+	st.nextRetryAt = time.Now().Add(delay)
+	st.retryTimer = time.AfterFunc(delay, func() {
+		m.runReconnect(instanceID, client)
+	})
+
+	m.log.Info("reconexão agendada",
+		zap.String("instance_id", instanceID),
+		zap.Int("attempt", st.attempts),
+		zap.Duration("delay", delay),
+	)
+}
+
+func (m *Manager) runReconnect(instanceID string, client *whatsmeow.Client) {
+	m.stateMu.Lock()
+	if st, ok := m.connStates[instanceID]; ok {
+		st.retryTimer = nil
+		st.nextRetryAt = time.Time{}
+	}
+	m.stateMu.Unlock()
+
+	// A sessão pode ter sido removida, liberada ou recriada enquanto
+	// aguardávamos; nesse caso a tentativa pertence a um cliente obsoleto.
+	m.mu.RLock()
+	current := m.clients[instanceID]
+	m.mu.RUnlock()
+	if current != client || client.IsConnected() {
+		return
+	}
+
+	m.transition(instanceID, model.ConnectionStateConnecting, "tentativa de reconexão")
+	if err := client.Connect(); err != nil && !errors.Is(err, whatsmeow.ErrAlreadyConnected) {
+		m.log.Warn("falha na tentativa de reconexão",
+			zap.String("instance_id", instanceID),
+			zap.Error(err),
+		)
+		m.scheduleReconnect(instanceID, client, "falha ao reconectar: "+err.Error())
+	}
+}
+
+// forgetState cancela tentativas pendentes e registra a saída da instância
+// deste manager. Um logout já registrado é mantido como estado final, pois
+// explica melhor o motivo do encerramento.
+func (m *Manager) forgetState(instanceID, reason string) {
+	m.stateMu.Lock()
+	st, ok := m.connStates[instanceID]
+	if !ok {
+		m.stateMu.Unlock()
+		return
+	}
+	state := st.state
+	m.stateMu.Unlock()
+
+	if state != model.ConnectionStateLoggedOut {
+		m.transition(instanceID, model.ConnectionStateDisconnected, reason)
+	}
+
+	m.stateMu.Lock()
+	if st, ok := m.connStates[instanceID]; ok && st.retryTimer != nil {
+		st.retryTimer.Stop()
+	}
+	delete(m.connStates, instanceID)
+	m.stateMu.Unlock()
+}
+
+// GetConnectionState retorna o estado atual da conexão e as últimas
+// transições. Sem estado em memória (instância de outro nó ou manager
+// reiniciado), usa a última transição registrada.
+func (m *Manager) GetConnectionState(ctx context.Context, instanceID string, limit int) (model.InstanceConnectionState, error) {
+	m.stateMu.Lock()
+	result := model.InstanceConnectionState{InstanceID: instanceID}
+	if st, ok := m.connStates[instanceID]; ok {
+		result = st.snapshot(instanceID)
+	}
+	repo := m.stateRepo
+	m.stateMu.Unlock()
+
+	result.Restoring = m.IsRestoring(instanceID)
+	result.Transitions = []model.InstanceStateTransition{}
+	if repo == nil {
+		return result, nil
+	}
+
+	transitions, err := repo.ListByInstance(ctx, instanceID, limit)
+	if err != nil {
+		return model.InstanceConnectionState{}, err
+	}
+	if transitions != nil {
+		result.Transitions = transitions
+	}
+	if result.State == "" && len(transitions) > 0 {
+		result.State = transitions[0].To
+		result.Since = transitions[0].CreatedAt
+		result.ReconnectAttempts = transitions[0].Attempt
+	}
+	return result, nil
+}
+
+// ConnectionStates retorna o estado em memória de todas as instâncias deste
+// manager, sem o histórico de transições.
+func (m *Manager) ConnectionStates() []model.InstanceConnectionState {
+	m.stateMu.Lock()
+	defer m.stateMu.Unlock()
+
+	states := make([]model.InstanceConnectionState, 0, len(m.connStates))
+	for instanceID, st := range m.connStates {
+		states = append(states, st.snapshot(instanceID))
+	}
+	return states
+}
+
+// snapshot deve ser chamado com m.stateMu travado.
+func (st *connectionState) snapshot(instanceID string) model.InstanceConnectionState {
+	result := model.InstanceConnectionState{
+		InstanceID:        instanceID,
+		State:             st.state,
+		Since:             st.since,
+		ReconnectAttempts: st.attempts,
+	}
+	if !st.nextRetryAt.IsZero() {
+		next := st.nextRetryAt
+		result.NextRetryAt = &next
+	}
+	if !st.downSince.IsZero() {
+		down := st.downSince
+		result.DisconnectedSince = &down
+	}
+	return result
+}
@@ -0,0 +1,439 @@
+package whatsmeow
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"go.mau.fi/whatsmeow/store/sqlstore"
+	"go.uber.org/zap"
+
+	"github.com/open-apime/apime/internal/storage/model"
+)
+
+var ErrSessionActive = errors.New("sessão da instância está ativa neste nó")
+
+// deviceStoreTables lista as tabelas do whatsmeow copiadas na exportação, na
+// ordem de inserção exigida pelas chaves estrangeiras, e a coluna que guarda
+// o JID do device.
+var deviceStoreTables = []struct {
+	name   string
+	jidCol string
+}{
+	{"whatsmeow_device", "jid"},
+	{"whatsmeow_identity_keys", "our_jid"},
+	{"whatsmeow_pre_keys", "jid"},
+	{"whatsmeow_sessions", "our_jid"},
+	{"whatsmeow_sender_keys", "our_jid"},
+	{"whatsmeow_app_state_sync_keys", "jid"},
+	{"whatsmeow_app_state_version", "jid"},
+	{"whatsmeow_app_state_mutation_macs", "jid"},
+	{"whatsmeow_contacts", "our_jid"},
+	{"whatsmeow_chat_settings", "our_jid"},
+	{"whatsmeow_message_secrets", "our_jid"},
+	{"whatsmeow_privacy_tokens", "our_jid"},
+	{"whatsmeow_event_buffer", "our_jid"},
+}
+
+// lidMapTable não tem JID do device. Só é exportada do SQLite, onde o arquivo
+// pertence a uma única instância; no PostgreSQL ela é compartilhada e o
+// whatsmeow reaprende os mapeamentos após conectar.
+const lidMapTable = "whatsmeow_lid_map"
+
+// ExportDeviceStore copia as linhas do device store da instância. A sessão
+// continua utilizável neste nó; quem migra a instância deve liberá-la antes,
+// para que as chaves não avancem depois da cópia.
+func (m *Manager) ExportDeviceStore(ctx context.Context, instanceID string) (model.DeviceStoreDump, error) {
+	db, driver, err := m.openDeviceStoreDB(instanceID, false)
+	if err != nil {
+		return model.DeviceStoreDump{}, err
+	}
+	defer db.Close()
+
+	jid, err := m.deviceJID(ctx, db, driver, instanceID)
+	if err != nil {
+		return model.DeviceStoreDump{}, err
+	}
+
+	dump := model.DeviceStoreDump{JID: jid}
+	for _, t := range deviceStoreTables {
+		query := fmt.Sprintf("SELECT * FROM %s WHERE %s = %s", t.name, t.jidCol, placeholder(driver, 1))
+		table, err := dumpTable(ctx, db, t.name, query, jid)
+		if err != nil {
+			return model.DeviceStoreDump{}, err
+		}
+		dump.Tables = append(dump.Tables, table)
+	}
+	if driver == "sqlite3" {
+		table, err := dumpTable(ctx, db, lidMapTable, "SELECT * FROM "+lidMapTable)
+		if err != nil {
+			return model.DeviceStoreDump{}, err
+		}
+		dump.Tables = append(dump.Tables, table)
+	}
+
+	m.log.Info("device store exportado",
+		zap.String("instance_id", instanceID),
+		zap.String("jid", jid),
+		zap.String("storage", m.storageDriver),
+	)
+	return dump, nil
+}
+
+// ImportDeviceStore grava as linhas exportadas no backend deste nó,
+// substituindo o que houver para o mesmo JID. A instância não pode estar
+// conectada aqui.
+func (m *Manager) ImportDeviceStore(ctx context.Context, instanceID string, dump model.DeviceStoreDump) (err error) {
+	if dump.JID == "" || len(dump.Tables) == 0 {
+		return errors.New("device store vazio")
+	}
+
+	m.mu.RLock()
+	_, active := m.clients[instanceID]
+	m.mu.RUnlock()
+	if active {
+		return ErrSessionActive
+	}
+
+	db, driver, err := m.openDeviceStoreDB(instanceID, true)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	if driver == "sqlite3" {
+		defer func() {
+			if err != nil {
+				db.Close()
+				_ = os.Remove(filepath.Join(m.baseDir, instanceID+".db"))
+			}
+		}()
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := deleteDeviceRows(ctx, tx, driver, dump.JID); err != nil {
+		return err
+	}
+
+	for _, table := range dump.Tables {
+		if !knownDeviceStoreTable(table.Name) {
+			return fmt.Errorf("tabela desconhecida no device store: %s", table.Name)
+		}
+		if err := loadTable(ctx, tx, driver, table); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	m.log.Info("device store importado",
+		zap.String("instance_id", instanceID),
+		zap.String("jid", dump.JID),
+		zap.String("storage", m.storageDriver),
+	)
+	return nil
+}
+
+// DiscardDeviceStore apaga o device store local da instância sem fazer
+// logout, para que a sessão migrada para outro servidor continue válida. A
+// sessão deve ter sido liberada antes com ReleaseSession.
+func (m *Manager) DiscardDeviceStore(ctx context.Context, instanceID, jid string) error {
+	m.mu.RLock()
+	_, active := m.clients[instanceID]
+	m.mu.RUnlock()
+	if active {
+		return ErrSessionActive
+	}
+
+	if m.storageDriver != "postgres" || m.pgConnString == "" {
+		dbPath := filepath.Join(m.baseDir, instanceID+".db")
+		if err := os.Remove(dbPath); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	db, driver, err := m.openDeviceStoreDB(instanceID, false)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := deleteDeviceRows(ctx, tx, driver, jid); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ExportProxy retorna o proxy da instância com as credenciais em texto puro,
+// para que sejam cifradas de novo com a chave do nó de destino.
+func (m *Manager) ExportProxy(ctx context.Context, instanceID string) (*model.InstanceProxy, error) {
+	return m.loadProxy(ctx, m.getProxyRepo(), instanceID)
+}
+
+// openDeviceStoreDB abre uma conexão avulsa com o banco do device store. No
+// SQLite, create prepara um arquivo novo com o schema do whatsmeow.
+func (m *Manager) openDeviceStoreDB(instanceID string, create bool) (*sql.DB, string, error) {
+	if m.storageDriver == "postgres" && m.pgConnString != "" {
+		if m.sharedContainer == nil {
+			return nil, "", errors.New("container PostgreSQL não inicializado")
+		}
+		db, err := sql.Open("postgres", m.pgConnString)
+		if err != nil {
+			return nil, "", err
+		}
+		return db, "postgres", nil
+	}
+
+	dbPath := filepath.Join(m.baseDir, instanceID+".db")
+	dsn := fmt.Sprintf("file:%s?_foreign_keys=on", dbPath)
+	if create {
+		_ = os.Remove(dbPath)
+		container, err := sqlstore.New(context.Background(), "sqlite3", dsn, &zapLogger{log: m.log, module: "whatsmeow"})
+		if err != nil {
+			return nil, "", fmt.Errorf("whatsmeow: criar store: %w", err)
+		}
+		_ = container.Close()
+	} else if _, err := os.Stat(dbPath); err != nil {
+		return nil, "", fmt.Errorf("sessão não encontrada")
+	}
+
+	db, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		return nil, "", err
+	}
+	return db, "sqlite3", nil
+}
+
+func (m *Manager) deviceJID(ctx context.Context, db *sql.DB, driver, instanceID string) (string, error) {
+	if driver == "postgres" {
+		if m.instanceRepo == nil {
+			return "", fmt.Errorf("sessão não encontrada")
+		}
+		inst, err := m.instanceRepo.GetByID(ctx, instanceID)
+		if err != nil {
+			return "", err
+		}
+		if inst.WhatsAppJID == "" {
+			return "", fmt.Errorf("sessão não encontrada")
+		}
+		return inst.WhatsAppJID, nil
+	}
+
+	var jid string
+	err := db.QueryRowContext(ctx, "SELECT jid FROM whatsmeow_device LIMIT 1").Scan(&jid)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", fmt.Errorf("sessão não encontrada")
+	}
+	return jid, err
+}
+
+func dumpTable(ctx context.Context, db *sql.DB, name, query string, args ...any) (model.DeviceStoreTable, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return model.DeviceStoreTable{}, fmt.Errorf("ler %s: %w", name, err)
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return model.DeviceStoreTable{}, err
+	}
+	colTypes, err := rows.ColumnTypes()
+	if err != nil {
+		return model.DeviceStoreTable{}, err
+	}
+
+	table := model.DeviceStoreTable{Name: name, Columns: columns, Rows: [][]model.DeviceStoreValue{}}
+	for rows.Next() {
+		raw := make([]any, len(columns))
+		ptrs := make([]any, len(columns))
+		for i := range raw {
+			ptrs[i] = &raw[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return model.DeviceStoreTable{}, fmt.Errorf("ler %s: %w", name, err)
+		}
+
+		row := make([]model.DeviceStoreValue, len(columns))
+		for i, v := range raw {
+			row[i] = storeValue(colTypes[i].DatabaseTypeName(), v)
+		}
+		table.Rows = append(table.Rows, row)
+	}
+	return table, rows.Err()
+}
+
+// storeValue converte o valor lido pelo driver usando o tipo declarado da
+// coluna: o SQLite devolve bytea como []byte e o lib/pq devolve TEXT e UUID
+// como []byte em alguns casos.
+func storeValue(dbType string, v any) model.DeviceStoreValue {
+	if v == nil {
+		return model.DeviceStoreValue{Kind: "null"}
+	}
+
+	dbType = strings.ToUpper(dbType)
+	switch {
+	case strings.Contains(dbType, "BYTEA") || strings.Contains(dbType, "BLOB"):
+		switch b := v.(type) {
+		case []byte:
+			return model.DeviceStoreValue{Kind: "bytes", Bytes: b}
+		case string:
+			return model.DeviceStoreValue{Kind: "bytes", Bytes: []byte(b)}
+		}
+	case strings.Contains(dbType, "BOOL"):
+		switch b := v.(type) {
+		case bool:
+			return model.DeviceStoreValue{Kind: "bool", Bool: b}
+		case int64:
+			return model.DeviceStoreValue{Kind: "bool", Bool: b != 0}
+		}
+	case strings.Contains(dbType, "INT"):
+		switch n := v.(type) {
+		case int64:
+			return model.DeviceStoreValue{Kind: "int", Int: n}
+		case bool:
+			if n {
+				return model.DeviceStoreValue{Kind: "int", Int: 1}
+			}
+			return model.DeviceStoreValue{Kind: "int"}
+		}
+	}
+
+	switch t := v.(type) {
+	case []byte:
+		if dbType == "" {
+			return model.DeviceStoreValue{Kind: "bytes", Bytes: t}
+		}
+		return model.DeviceStoreValue{Kind: "text", Text: string(t)}
+	case string:
+		return model.DeviceStoreValue{Kind: "text", Text: t}
+	case int64:
+		return model.DeviceStoreValue{Kind: "int", Int: t}
+	case bool:
+		return model.DeviceStoreValue{Kind: "bool", Bool: t}
+	default:
+		return model.DeviceStoreValue{Kind: "text", Text: fmt.Sprint(t)}
+	}
+}
+
+func deleteDeviceRows(ctx context.Context, tx *sql.Tx, driver, jid string) error {
+	for i := len(deviceStoreTables) - 1; i >= 0; i-- {
+		t := deviceStoreTables[i]
+		query := fmt.Sprintf("DELETE FROM %s WHERE %s = %s", t.name, t.jidCol, placeholder(driver, 1))
+		if _, err := tx.ExecContext(ctx, query, jid); err != nil {
+			return fmt.Errorf("limpar %s: %w", t.name, err)
+		}
+	}
+	return nil
+}
+
+func driverValue(v model.DeviceStoreValue) any {
+	switch v.Kind {
+	case "bytes":
+		if v.Bytes == nil {
+			return []byte{}
+		}
+		return v.Bytes
+	case "bool":
+		return v.Bool
+	case "int":
+		return v.Int
+	case "text":
+		return v.Text
+	default:
+		return nil
+	}
+}
+
+// loadTable insere as linhas usando apenas as colunas que existem no schema
+// de destino, que pode estar numa versão diferente do whatsmeow.
+func loadTable(ctx context.Context, tx *sql.Tx, driver string, table model.DeviceStoreTable) error {
+	rows, err := tx.QueryContext(ctx, fmt.Sprintf("SELECT * FROM %s WHERE 1 = 0", table.Name))
+	if err != nil {
+		return fmt.Errorf("ler schema de %s: %w", table.Name, err)
+	}
+	targetCols, err := rows.Columns()
+	rows.Close()
+	if err != nil {
+		return err
+	}
+	existing := make(map[string]bool, len(targetCols))
+	for _, c := range targetCols {
+		existing[c] = true
+	}
+
+	var cols []string
+	var idx []int
+	for i, c := range table.Columns {
+		if existing[c] {
+			cols = append(cols, c)
+			idx = append(idx, i)
+		}
+	}
+	if len(cols) == 0 || len(table.Rows) == 0 {
+		return nil
+	}
+
+	marks := make([]string, len(cols))
+	for i := range marks {
+		marks[i] = placeholder(driver, i+1)
+	}
+	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s) ON CONFLICT DO NOTHING",
+		table.Name, strings.Join(cols, ", "), strings.Join(marks, ", "))
+
+	stmt, err := tx.PrepareContext(ctx, query)
+	if err != nil {
+		return fmt.Errorf("preparar %s: %w", table.Name, err)
+	}
+	defer stmt.Close()
+
+	args := make([]any, len(cols))
+	for _, row := range table.Rows {
+		if len(row) != len(table.Columns) {
+			return fmt.Errorf("linha inválida em %s", table.Name)
+		}
+		for i, j := range idx {
+			args[i] = driverValue(row[j])
+		}
+		if _, err := stmt.ExecContext(ctx, args...); err != nil {
+			return fmt.Errorf("gravar %s: %w", table.Name, err)
+		}
+	}
+	return nil
+}
+
+func knownDeviceStoreTable(name string) bool {
+	if name == lidMapTable {
+		return true
+	}
+	for _, t := range deviceStoreTables {
+		if t.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func placeholder(driver string, n int) string {
+	if driver == "postgres" {
+		return fmt.Sprintf("$%d", n)
+	}
+	return "?"
+}
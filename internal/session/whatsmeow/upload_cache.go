@@ -0,0 +1,114 @@
+package whatsmeow
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mau.fi/whatsmeow"
+)
+
+const (
+	// uploadTTL vale para uploads cuja URL não informa a expiração.
+	uploadTTL = 7 * 24 * time.Hour
+	// uploadMargin antecipa a expiração, para não reaproveitar uma mídia que
+	// expira antes de o destinatário baixá-la.
+	uploadMargin = time.Hour
+)
+
+// uploadKey identifica uma mídia enviada: o mesmo arquivo vira uploads
+// diferentes para cada tipo de mídia, já que a chave de criptografia depende
+// do tipo.
+type uploadKey struct {
+	instanceID string
+	id         string
+	mediaType  whatsmeow.MediaType
+}
+
+// uploadEntry guarda, além da resposta do upload, os dados da mídia usados
+// pelos envios que a referenciam só pelo ID.
+type uploadEntry struct {
+	resp     whatsmeow.UploadResponse
+	mimeType string
+	// seconds e waveform são a duração e a forma de onda dos áudios.
+	seconds   int
+	waveform  []byte
+	expiresAt time.Time
+}
+
+// uploadCache guarda as respostas de upload por instância, para que a mesma
+// mídia enviada a vários destinatários seja criptografada e enviada ao
+// WhatsApp uma única vez enquanto a URL for válida.
+type uploadCache struct {
+	mu      sync.Mutex
+	entries map[uploadKey]uploadEntry
+}
+
+func newUploadCache() *uploadCache {
+	return &uploadCache{entries: make(map[uploadKey]uploadEntry)}
+}
+
+// uploadID é o identificador da mídia retornado por Upload: os primeiros 16
+// bytes do SHA-256 do conteúdo, em hexadecimal.
+func uploadID(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:16])
+}
+
+func (c *uploadCache) get(key uploadKey) (uploadEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok {
+		return uploadEntry{}, false
+	}
+	if time.Now().After(entry.expiresAt) {
+		delete(c.entries, key)
+		return uploadEntry{}, false
+	}
+	return entry, true
+}
+
+func (c *uploadCache) put(key uploadKey, entry uploadEntry) uploadEntry {
+	entry.expiresAt = uploadExpiry(entry.resp, time.Now())
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Remove as entradas vencidas a cada novo upload, para o cache não
+	// crescer com mídias que não serão mais usadas.
+	now := time.Now()
+	for k, e := range c.entries {
+		if now.After(e.expiresAt) {
+			delete(c.entries, k)
+		}
+	}
+	c.entries[key] = entry
+	return entry
+}
+
+// uploadExpiry lê a expiração do parâmetro oe (timestamp Unix em
+// hexadecimal) da URL da mídia.
+func uploadExpiry(resp whatsmeow.UploadResponse, now time.Time) time.Time {
+	for _, raw := range []string{resp.URL, resp.DirectPath} {
+		_, query, ok := strings.Cut(raw, "?")
+		if !ok {
+			continue
+		}
+		values, err := url.ParseQuery(query)
+		if err != nil {
+			continue
+		}
+		oe, err := strconv.ParseInt(values.Get("oe"), 16, 64)
+		if err != nil || oe <= 0 {
+			continue
+		}
+		return time.Unix(oe, 0).Add(-uploadMargin)
+	}
+	return now.Add(uploadTTL)
+}
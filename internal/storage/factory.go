@@ -16,18 +16,27 @@ import (
 )
 
 type Repositories struct {
-	Instance     InstanceRepository
-	Message      MessageRepository
-	EventLog     EventLogRepository
-	User         UserRepository
-	APIToken     APITokenRepository
-	DeviceConfig DeviceConfigRepository
-	HistorySync  HistorySyncRepository
-	Contact      ContactRepository
-	RedisClient  *storage_redis.Client
-	WebhookQueue queue.Queue
-	OutboxQueue  queue.Queue
-	RateLimiter  ratelimiter.Limiter
+	Instance      InstanceRepository
+	Message       MessageRepository
+	EventLog      EventLogRepository
+	User          UserRepository
+	APIToken      APITokenRepository
+	DeviceConfig  DeviceConfigRepository
+	HistorySync   HistorySyncRepository
+	Contact       ContactRepository
+	Chat          ChatRepository
+	MessageStatus MessageStatusEventRepository
+	InstanceProxy InstanceProxyRepository
+	CloudAPI      InstanceCloudAPIRepository
+	LIDMapping    LIDMappingRepository
+	MediaRef      MediaRefRepository
+	MediaPolicy   MediaPolicyRepository
+	InstanceState InstanceStateTransitionRepository
+	AlertRule     AlertRuleRepository
+	RedisClient   *storage_redis.Client
+	WebhookQueue  queue.Queue
+	OutboxQueue   queue.Queue
+	RateLimiter   ratelimiter.Limiter
 }
 
 func NewRepositories(cfg config.Config, log *zap.Logger) (*Repositories, error) {
@@ -78,18 +87,27 @@ func NewRepositories(cfg config.Config, log *zap.Logger) (*Repositories, error)
 
 		log.Info("repositórios SQLite criados com sucesso", zap.String("data_dir", cfg.Storage.DataDir))
 		return &Repositories{
-			Instance:     sqlite.NewInstanceRepository(db),
-			Message:      sqlite.NewMessageRepository(db),
-			EventLog:     sqlite.NewEventLogRepository(db),
-			User:         sqlite.NewUserRepository(db),
-			APIToken:     sqlite.NewAPITokenRepository(db),
-			DeviceConfig: sqlite.NewDeviceConfigRepository(db),
-			HistorySync:  sqlite.NewHistorySyncRepository(db),
-			Contact:      sqlite.NewContactRepository(db),
-			RedisClient:  storeRedis,
-			WebhookQueue: webhookQueue,
-			OutboxQueue:  outboxQueue,
-			RateLimiter:  rateLimiter,
+			Instance:      sqlite.NewInstanceRepository(db),
+			Message:       sqlite.NewMessageRepository(db),
+			EventLog:      sqlite.NewEventLogRepository(db),
+			User:          sqlite.NewUserRepository(db),
+			APIToken:      sqlite.NewAPITokenRepository(db),
+			DeviceConfig:  sqlite.NewDeviceConfigRepository(db),
+			HistorySync:   sqlite.NewHistorySyncRepository(db),
+			Contact:       sqlite.NewContactRepository(db),
+			Chat:          sqlite.NewChatRepository(db),
+			MessageStatus: sqlite.NewMessageStatusEventRepository(db),
+			InstanceProxy: sqlite.NewInstanceProxyRepository(db),
+			CloudAPI:      sqlite.NewInstanceCloudAPIRepository(db),
+			LIDMapping:    sqlite.NewLIDMappingRepository(db),
+			MediaRef:      sqlite.NewMediaRefRepository(db),
+			MediaPolicy:   sqlite.NewMediaPolicyRepository(db),
+			InstanceState: sqlite.NewInstanceStateTransitionRepository(db),
+			AlertRule:     sqlite.NewAlertRuleRepository(db),
+			RedisClient:   storeRedis,
+			WebhookQueue:  webhookQueue,
+			OutboxQueue:   outboxQueue,
+			RateLimiter:   rateLimiter,
 		}, nil
 
 	case "postgres":
@@ -102,18 +120,27 @@ func NewRepositories(cfg config.Config, log *zap.Logger) (*Repositories, error)
 
 		log.Info("repositórios PostgreSQL criados com sucesso")
 		return &Repositories{
-			Instance:     postgres.NewInstanceRepository(db),
-			Message:      postgres.NewMessageRepository(db),
-			EventLog:     postgres.NewEventLogRepository(db),
-			User:         postgres.NewUserRepository(db),
-			APIToken:     postgres.NewAPITokenRepository(db),
-			DeviceConfig: postgres.NewDeviceConfigRepository(db),
-			HistorySync:  postgres.NewHistorySyncRepository(db),
-			Contact:      postgres.NewContactRepository(db),
-			RedisClient:  storeRedis,
-			WebhookQueue: webhookQueue,
-			OutboxQueue:  outboxQueue,
-			RateLimiter:  rateLimiter,
+			Instance:      postgres.NewInstanceRepository(db),
+			Message:       postgres.NewMessageRepository(db),
+			EventLog:      postgres.NewEventLogRepository(db),
+			User:          postgres.NewUserRepository(db),
+			APIToken:      postgres.NewAPITokenRepository(db),
+			DeviceConfig:  postgres.NewDeviceConfigRepository(db),
+			HistorySync:   postgres.NewHistorySyncRepository(db),
+			Contact:       postgres.NewContactRepository(db),
+			Chat:          postgres.NewChatRepository(db),
+			MessageStatus: postgres.NewMessageStatusEventRepository(db),
+			InstanceProxy: postgres.NewInstanceProxyRepository(db),
+			CloudAPI:      postgres.NewInstanceCloudAPIRepository(db),
+			LIDMapping:    postgres.NewLIDMappingRepository(db),
+			MediaRef:      postgres.NewMediaRefRepository(db),
+			MediaPolicy:   postgres.NewMediaPolicyRepository(db),
+			InstanceState: postgres.NewInstanceStateTransitionRepository(db),
+			AlertRule:     postgres.NewAlertRuleRepository(db),
+			RedisClient:   storeRedis,
+			WebhookQueue:  webhookQueue,
+			OutboxQueue:   outboxQueue,
+			RateLimiter:   rateLimiter,
 		}, nil
 
 	default:
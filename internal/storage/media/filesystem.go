@@ -0,0 +1,327 @@
+package media
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/open-apime/apime/internal/storage/model"
+)
+
+// metaSuffix identifica o arquivo de metadados gravado ao lado da mídia.
+const metaSuffix = ".meta.json"
+
+// FileStorage guarda as mídias em disco, em um diretório por instância, cada
+// uma com um arquivo de metadados ao lado.
+type FileStorage struct {
+	retention
+	baseDir string
+	log     *zap.Logger
+	mu      sync.RWMutex
+}
+
+func NewFileStorage(baseDir string, ttl time.Duration, log *zap.Logger) (*FileStorage, error) {
+	if err := os.MkdirAll(baseDir, 0755); err != nil {
+		return nil, fmt.Errorf("criar diretório de mídia: %w", err)
+	}
+
+	s := &FileStorage{
+		retention: retention{ttl: ttl},
+		baseDir:   baseDir,
+		log:       log,
+	}
+
+	go s.startCleanupJob()
+
+	return s, nil
+}
+
+func (s *FileStorage) Save(ctx context.Context, instanceID string, messageID string, data []byte, mimetype string, fileName string) (string, error) {
+	mediaID := newMediaID(messageID, data, mimetype)
+	if err := s.Put(ctx, instanceID, mediaID, data, mimetype, fileName); err != nil {
+		return "", err
+	}
+	return mediaID, nil
+}
+
+func (s *FileStorage) Put(ctx context.Context, instanceID string, mediaID string, data []byte, mimetype string, fileName string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	filePath, ok := s.path(instanceID, mediaID)
+	if !ok {
+		return fmt.Errorf("mídia inválida: %s/%s", instanceID, mediaID)
+	}
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return fmt.Errorf("criar diretório da instância: %w", err)
+	}
+	if err := s.makeRoom(ctx, instanceID, mediaID, int64(len(data))); err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(filePath, data, 0644); err != nil {
+		return fmt.Errorf("salvar arquivo: %w", err)
+	}
+
+	hash := md5.Sum(data)
+	meta, err := json.Marshal(Info{
+		MimeType: mimetype,
+		Size:     int64(len(data)),
+		FileName: fileName,
+		ETag:     hex.EncodeToString(hash[:]),
+		ModTime:  time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filePath+metaSuffix, meta, 0644); err != nil {
+		return fmt.Errorf("salvar metadados: %w", err)
+	}
+
+	s.log.Info("mídia salva",
+		zap.String("instance_id", instanceID),
+		zap.String("media_id", mediaID),
+		zap.Int("size", len(data)),
+		zap.String("mimetype", mimetype),
+	)
+
+	return nil
+}
+
+func (s *FileStorage) Get(ctx context.Context, instanceID string, mediaID string) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	filePath, ok := s.path(instanceID, mediaID)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("ler arquivo: %w", err)
+	}
+
+	return data, nil
+}
+
+func (s *FileStorage) Open(ctx context.Context, instanceID string, mediaID string) (*Object, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	filePath, ok := s.path(instanceID, mediaID)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	f, err := os.Open(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("abrir arquivo: %w", err)
+	}
+	stat, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("abrir arquivo: %w", err)
+	}
+
+	var info Info
+	if meta, err := os.ReadFile(filePath + metaSuffix); err == nil {
+		_ = json.Unmarshal(meta, &info)
+	}
+	if info.MimeType == "" {
+		info.MimeType = mimetypeFromExtension(mediaID)
+	}
+	if info.ETag == "" {
+		info.ETag = fmt.Sprintf("%s-%x", mediaID, stat.Size())
+	}
+	info.Size = stat.Size()
+	info.ModTime = stat.ModTime()
+
+	return &Object{Info: info, Content: f}, nil
+}
+
+func (s *FileStorage) Exists(ctx context.Context, instanceID string, mediaID string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	filePath, ok := s.path(instanceID, mediaID)
+	if !ok {
+		return false
+	}
+	_, err := os.Stat(filePath)
+	return err == nil
+}
+
+// GetPath retorna o caminho completo do arquivo
+func (s *FileStorage) GetPath(instanceID string, mediaID string) string {
+	return filepath.Join(s.baseDir, instanceID, mediaID)
+}
+
+// path monta o caminho da mídia, recusando IDs que sairiam do diretório da
+// instância ou que apontem para os metadados.
+func (s *FileStorage) path(instanceID, mediaID string) (string, bool) {
+	if !validID(instanceID) || !validID(mediaID) || strings.HasSuffix(mediaID, metaSuffix) {
+		return "", false
+	}
+	return filepath.Join(s.baseDir, instanceID, mediaID), true
+}
+
+// makeRoom remove as mídias mais antigas da instância até a nova caber na
+// cota. Deve ser chamado com s.mu travado.
+func (s *FileStorage) makeRoom(ctx context.Context, instanceID, mediaID string, size int64) error {
+	policy := s.policy(ctx, instanceID)
+	if policy.QuotaBytes <= 0 {
+		return nil
+	}
+	if size > policy.QuotaBytes {
+		return ErrQuotaExceeded
+	}
+
+	objects, err := s.list(instanceID)
+	if err != nil {
+		return err
+	}
+	others := objects[:0]
+	for _, obj := range objects {
+		if obj.MediaID != mediaID {
+			others = append(others, obj)
+		}
+	}
+	for _, obj := range evictions(others, 0, policy.QuotaBytes, size, time.Now()) {
+		s.remove(obj)
+		s.log.Info("mídia removida pela cota da instância",
+			zap.String("instance_id", instanceID),
+			zap.String("media_id", obj.MediaID),
+			zap.Int64("quota_bytes", policy.QuotaBytes),
+		)
+	}
+	return nil
+}
+
+// Usage retorna o espaço ocupado pelas mídias da instância.
+func (s *FileStorage) Usage(ctx context.Context, instanceID string) (model.MediaUsage, error) {
+	if !validID(instanceID) {
+		return model.MediaUsage{}, fmt.Errorf("instância inválida: %s", instanceID)
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	objects, err := s.list(instanceID)
+	if err != nil {
+		return model.MediaUsage{}, err
+	}
+	return s.usage(instanceID, objects, s.policy(ctx, instanceID)), nil
+}
+
+// list lista as mídias da instância, sem os arquivos de metadados.
+func (s *FileStorage) list(instanceID string) ([]storedObject, error) {
+	entries, err := os.ReadDir(filepath.Join(s.baseDir, instanceID))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("listar mídias: %w", err)
+	}
+
+	var objects []storedObject
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasSuffix(entry.Name(), metaSuffix) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		objects = append(objects, storedObject{
+			InstanceID: instanceID,
+			MediaID:    entry.Name(),
+			Size:       info.Size(),
+			ModTime:    info.ModTime(),
+		})
+	}
+	return objects, nil
+}
+
+// remove apaga a mídia e seus metadados.
+func (s *FileStorage) remove(obj storedObject) bool {
+	filePath := filepath.Join(s.baseDir, obj.InstanceID, obj.MediaID)
+	if err := os.Remove(filePath); err != nil && !os.IsNotExist(err) {
+		s.log.Warn("erro ao deletar arquivo de mídia", zap.String("path", filePath), zap.Error(err))
+		return false
+	}
+	_ = os.Remove(filePath + metaSuffix)
+	return true
+}
+
+// startCleanupJob inicia job de limpeza periódica
+func (s *FileStorage) startCleanupJob() {
+	ticker := time.NewTicker(30 * time.Minute)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		s.cleanup(context.Background())
+	}
+}
+
+// cleanup remove as mídias que passaram da retenção da instância (ou do TTL
+// global) e, nas instâncias com cota, as mais antigas até caber nela.
+func (s *FileStorage) cleanup(ctx context.Context) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.log.Info("iniciando limpeza de mídia temporária", zap.Duration("ttl", s.ttl))
+
+	policies, err := s.allPolicies(ctx)
+	if err != nil {
+		s.log.Error("erro ao carregar políticas de mídia, usando o TTL global", zap.Error(err))
+		policies = map[string]model.MediaPolicy{}
+	}
+
+	entries, err := os.ReadDir(s.baseDir)
+	if err != nil {
+		s.log.Error("erro durante limpeza", zap.Error(err))
+		return
+	}
+
+	var deleted, checked int
+	now := time.Now()
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		instanceID := entry.Name()
+		objects, err := s.list(instanceID)
+		if err != nil {
+			s.log.Warn("erro ao listar mídias da instância", zap.String("instance_id", instanceID), zap.Error(err))
+			continue
+		}
+		checked += len(objects)
+
+		policy := policies[instanceID]
+		for _, obj := range evictions(objects, s.retentionFor(policy), policy.QuotaBytes, 0, now) {
+			if s.remove(obj) {
+				deleted++
+				s.log.Debug("arquivo expirado deletado", zap.String("instance_id", instanceID), zap.String("media_id", obj.MediaID))
+			}
+		}
+	}
+
+	s.log.Info("limpeza de mídia concluída",
+		zap.Int("checked", checked),
+		zap.Int("deleted", deleted),
+	)
+}
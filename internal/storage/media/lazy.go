@@ -0,0 +1,118 @@
+package media
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/open-apime/apime/internal/storage"
+	"github.com/open-apime/apime/internal/storage/model"
+)
+
+// lazyFetchTimeout limita o download sob demanda, que segue mesmo se o
+// cliente que o iniciou desistir, para servir quem está esperando.
+const lazyFetchTimeout = 2 * time.Minute
+
+// Downloader baixa e descriptografa a mídia de uma referência guardada.
+type Downloader interface {
+	DownloadMediaRef(ctx context.Context, ref model.MediaRef) ([]byte, error)
+}
+
+// LazyFetcher baixa no primeiro acesso as mídias que foram guardadas apenas
+// como referência e grava o arquivo no Storage, onde os próximos acessos o
+// encontram.
+type LazyFetcher struct {
+	store      Storage
+	refs       storage.MediaRefRepository
+	downloader Downloader
+	log        *zap.Logger
+
+	mu       sync.Mutex
+	inflight map[string]*lazyFetch
+}
+
+type lazyFetch struct {
+	done chan struct{}
+	err  error
+}
+
+func NewLazyFetcher(store Storage, refs storage.MediaRefRepository, downloader Downloader, log *zap.Logger) *LazyFetcher {
+	return &LazyFetcher{
+		store:      store,
+		refs:       refs,
+		downloader: downloader,
+		log:        log,
+		inflight:   make(map[string]*lazyFetch),
+	}
+}
+
+// Fetch baixa a mídia referenciada e a grava no Storage. Acessos simultâneos
+// à mesma mídia aguardam um único download. Retorna ErrNotFound quando não há
+// referência para a mídia.
+func (f *LazyFetcher) Fetch(ctx context.Context, instanceID, mediaID string) error {
+	key := instanceID + "/" + mediaID
+
+	f.mu.Lock()
+	call, running := f.inflight[key]
+	if !running {
+		call = &lazyFetch{done: make(chan struct{})}
+		f.inflight[key] = call
+	}
+	f.mu.Unlock()
+
+	if !running {
+		go func() {
+			fetchCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), lazyFetchTimeout)
+			defer cancel()
+			call.err = f.fetch(fetchCtx, instanceID, mediaID)
+
+			f.mu.Lock()
+			delete(f.inflight, key)
+			f.mu.Unlock()
+			close(call.done)
+		}()
+	}
+
+	select {
+	case <-call.done:
+		return call.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (f *LazyFetcher) fetch(ctx context.Context, instanceID, mediaID string) error {
+	ref, err := f.refs.Get(ctx, instanceID, mediaID)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			return ErrNotFound
+		}
+		return err
+	}
+
+	start := time.Now()
+	data, err := f.downloader.DownloadMediaRef(ctx, ref)
+	if err != nil {
+		f.log.Warn("erro ao baixar mídia sob demanda",
+			zap.String("instance_id", instanceID),
+			zap.String("media_id", mediaID),
+			zap.Error(err),
+		)
+		return err
+	}
+
+	if err := f.store.Put(ctx, instanceID, mediaID, data, ref.MimeType, ref.FileName); err != nil {
+		return err
+	}
+
+	f.log.Info("mídia baixada sob demanda",
+		zap.String("instance_id", instanceID),
+		zap.String("media_id", mediaID),
+		zap.Int("size", len(data)),
+		zap.Duration("duration", time.Since(start)),
+	)
+	return nil
+}
@@ -0,0 +1,144 @@
+package media
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/open-apime/apime/internal/storage"
+	"github.com/open-apime/apime/internal/storage/model"
+)
+
+var ErrQuotaExceeded = errors.New("mídia maior que a cota da instância")
+
+// storedObject é uma mídia guardada, como listada pelo backend.
+type storedObject struct {
+	InstanceID string
+	MediaID    string
+	Size       int64
+	ModTime    time.Time
+}
+
+// retention aplica o TTL global e as políticas por instância. É compartilhada
+// pelos backends, que só listam e removem os objetos.
+type retention struct {
+	ttl      time.Duration
+	mu       sync.RWMutex
+	policies storage.MediaPolicyRepository
+}
+
+// SetPolicyRepository habilita a retenção e a cota por instância. Sem ele,
+// todas as instâncias usam o TTL global e não têm cota.
+func (r *retention) SetPolicyRepository(repo storage.MediaPolicyRepository) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.policies = repo
+}
+
+func (r *retention) policyRepo() storage.MediaPolicyRepository {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.policies
+}
+
+// policy retorna a política da instância, ou a padrão quando não há uma.
+func (r *retention) policy(ctx context.Context, instanceID string) model.MediaPolicy {
+	if repo := r.policyRepo(); repo != nil {
+		if policy, err := repo.Get(ctx, instanceID); err == nil {
+			return policy
+		}
+	}
+	return model.MediaPolicy{InstanceID: instanceID}
+}
+
+// allPolicies retorna as políticas configuradas, por instância.
+func (r *retention) allPolicies(ctx context.Context) (map[string]model.MediaPolicy, error) {
+	policies := make(map[string]model.MediaPolicy)
+	repo := r.policyRepo()
+	if repo == nil {
+		return policies, nil
+	}
+	list, err := repo.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, policy := range list {
+		policies[policy.InstanceID] = policy
+	}
+	return policies, nil
+}
+
+func (r *retention) retentionFor(policy model.MediaPolicy) time.Duration {
+	if policy.RetentionSeconds > 0 {
+		return time.Duration(policy.RetentionSeconds) * time.Second
+	}
+	return r.ttl
+}
+
+// usage soma os objetos da instância por tipo de mídia.
+func (r *retention) usage(instanceID string, objects []storedObject, policy model.MediaPolicy) model.MediaUsage {
+	usage := model.MediaUsage{
+		InstanceID:       instanceID,
+		ByType:           make(map[string]model.MediaTypeUsage),
+		RetentionSeconds: int64(r.retentionFor(policy) / time.Second),
+		QuotaBytes:       policy.QuotaBytes,
+	}
+	for _, obj := range objects {
+		usage.Bytes += obj.Size
+		usage.Files++
+		kind := mediaKind(obj.MediaID)
+		byType := usage.ByType[kind]
+		byType.Bytes += obj.Size
+		byType.Files++
+		usage.ByType[kind] = byType
+	}
+	return usage
+}
+
+// evictions escolhe os objetos de uma instância a remover: os mais antigos
+// que ttl (zero não expira) e, se a cota for excedida com incoming bytes a
+// mais, os mais antigos até caber.
+func evictions(objects []storedObject, ttl time.Duration, quota int64, incoming int64, now time.Time) []storedObject {
+	sorted := append([]storedObject(nil), objects...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].ModTime.Before(sorted[j].ModTime) })
+
+	var evicted []storedObject
+	var total int64
+	kept := sorted[:0]
+	for _, obj := range sorted {
+		if ttl > 0 && obj.ModTime.Before(now.Add(-ttl)) {
+			evicted = append(evicted, obj)
+			continue
+		}
+		kept = append(kept, obj)
+		total += obj.Size
+	}
+
+	if quota > 0 {
+		for len(kept) > 0 && total+incoming > quota {
+			evicted = append(evicted, kept[0])
+			total -= kept[0].Size
+			kept = kept[1:]
+		}
+	}
+	return evicted
+}
+
+// mediaKind classifica a mídia pela extensão do ID; o que não é imagem,
+// vídeo ou áudio conta como documento.
+func mediaKind(mediaID string) string {
+	mimetype := mimetypeFromExtension(mediaID)
+	switch {
+	case strings.HasPrefix(mimetype, "image/"):
+		return "image"
+	case strings.HasPrefix(mimetype, "video/"):
+		return "video"
+	case strings.HasPrefix(mimetype, "audio/"):
+		return "audio"
+	default:
+		return "document"
+	}
+}
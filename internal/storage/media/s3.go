@@ -0,0 +1,799 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/open-apime/apime/internal/storage/model"
+)
+
+const (
+	s3MinPartSize     = 5 << 20
+	s3DefaultPartSize = 8 << 20
+	s3LifecycleRuleID = "apime-media-ttl"
+	s3FileNameHeader  = "X-Amz-Meta-Filename"
+)
+
+// S3Config configura o backend compatível com S3 (AWS S3, MinIO, R2).
+type S3Config struct {
+	// Endpoint é a URL do serviço. Vazio usa o endpoint regional da AWS.
+	Endpoint  string
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	// Prefix é prefixado às chaves dos objetos, ex.: "media/".
+	Prefix string
+	// PathStyle endereça o bucket no caminho (endpoint/bucket/chave), como
+	// exigem MinIO e a maioria dos serviços locais.
+	PathStyle bool
+	// PartSize é o tamanho das partes do upload multipart; mídias maiores
+	// que uma parte são enviadas em partes.
+	PartSize int
+	// Lifecycle aplica ao bucket uma regra de expiração para o prefixo em vez
+	// da limpeza periódica. A regra tem granularidade de dias.
+	Lifecycle bool
+}
+
+// S3Storage guarda as mídias em um bucket compatível com S3, para que
+// qualquer nó possa servi-las. As requisições são assinadas com SigV4.
+type S3Storage struct {
+	retention
+	cfg      S3Config
+	endpoint *url.URL
+	client   *http.Client
+	log      *zap.Logger
+	// lifecycle indica que a regra do bucket aplica o TTL global; a limpeza
+	// periódica cuida só das instâncias com política própria.
+	lifecycle bool
+}
+
+func NewS3Storage(cfg S3Config, ttl time.Duration, log *zap.Logger) (*S3Storage, error) {
+	if cfg.Bucket == "" {
+		return nil, fmt.Errorf("bucket S3 não configurado")
+	}
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", cfg.Region)
+	}
+	if cfg.PartSize <= 0 {
+		cfg.PartSize = s3DefaultPartSize
+	}
+	if cfg.PartSize < s3MinPartSize {
+		cfg.PartSize = s3MinPartSize
+	}
+
+	endpoint, err := url.Parse(strings.TrimRight(cfg.Endpoint, "/"))
+	if err != nil || endpoint.Host == "" {
+		return nil, fmt.Errorf("endpoint S3 inválido: %s", cfg.Endpoint)
+	}
+
+	s := &S3Storage{
+		retention: retention{ttl: ttl},
+		cfg:       cfg,
+		endpoint:  endpoint,
+		client:    &http.Client{Timeout: 10 * time.Minute},
+		log:       log,
+	}
+
+	if cfg.Lifecycle {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		err := s.applyLifecycle(ctx)
+		cancel()
+		if err == nil {
+			s.lifecycle = true
+		} else {
+			log.Warn("erro ao aplicar regra de expiração no bucket, usando limpeza periódica", zap.Error(err))
+		}
+	}
+
+	go s.startCleanupJob()
+
+	return s, nil
+}
+
+func (s *S3Storage) Save(ctx context.Context, instanceID string, messageID string, data []byte, mimetype string, fileName string) (string, error) {
+	mediaID := newMediaID(messageID, data, mimetype)
+	if err := s.Put(ctx, instanceID, mediaID, data, mimetype, fileName); err != nil {
+		return "", err
+	}
+	return mediaID, nil
+}
+
+func (s *S3Storage) Put(ctx context.Context, instanceID string, mediaID string, data []byte, mimetype string, fileName string) error {
+	if !validID(instanceID) || !validID(mediaID) {
+		return fmt.Errorf("mídia inválida: %s/%s", instanceID, mediaID)
+	}
+	key := s.key(instanceID, mediaID)
+	if err := s.makeRoom(ctx, instanceID, mediaID, int64(len(data))); err != nil {
+		return err
+	}
+
+	header := http.Header{}
+	header.Set("Content-Type", mimetype)
+	if fileName != "" {
+		// Metadados do S3 só aceitam ASCII
+		header.Set(s3FileNameHeader, url.PathEscape(fileName))
+	}
+
+	var err error
+	if len(data) > s.cfg.PartSize {
+		err = s.putMultipart(ctx, key, data, header)
+	} else {
+		var resp *http.Response
+		resp, err = s.do(ctx, http.MethodPut, key, nil, data, header)
+		if err == nil {
+			resp.Body.Close()
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("salvar objeto: %w", err)
+	}
+
+	s.log.Info("mídia salva",
+		zap.String("instance_id", instanceID),
+		zap.String("media_id", mediaID),
+		zap.Int("size", len(data)),
+		zap.String("mimetype", mimetype),
+		zap.String("bucket", s.cfg.Bucket),
+	)
+
+	return nil
+}
+
+func (s *S3Storage) Get(ctx context.Context, instanceID string, mediaID string) ([]byte, error) {
+	if !validID(mediaID) {
+		return nil, ErrNotFound
+	}
+	resp, err := s.do(ctx, http.MethodGet, s.key(instanceID, mediaID), nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("ler objeto: %w", err)
+	}
+	return data, nil
+}
+
+// Open lê os metadados com HEAD; o conteúdo só é baixado na primeira leitura,
+// a partir da posição pedida, para atender Range sem baixar o objeto inteiro.
+func (s *S3Storage) Open(ctx context.Context, instanceID string, mediaID string) (*Object, error) {
+	if !validID(mediaID) {
+		return nil, ErrNotFound
+	}
+	key := s.key(instanceID, mediaID)
+	resp, err := s.do(ctx, http.MethodHead, key, nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+
+	info := Info{
+		MimeType: resp.Header.Get("Content-Type"),
+		Size:     resp.ContentLength,
+		ETag:     strings.Trim(resp.Header.Get("ETag"), `"`),
+	}
+	if info.MimeType == "" || info.MimeType == "binary/octet-stream" {
+		info.MimeType = mimetypeFromExtension(mediaID)
+	}
+	if name, err := url.PathUnescape(resp.Header.Get(s3FileNameHeader)); err == nil {
+		info.FileName = name
+	}
+	if modTime, err := http.ParseTime(resp.Header.Get("Last-Modified")); err == nil {
+		info.ModTime = modTime
+	}
+
+	return &Object{Info: info, Content: &s3Reader{ctx: ctx, s: s, key: key, size: info.Size}}, nil
+}
+
+func (s *S3Storage) Exists(ctx context.Context, instanceID string, mediaID string) bool {
+	if !validID(mediaID) {
+		return false
+	}
+	resp, err := s.do(ctx, http.MethodHead, s.key(instanceID, mediaID), nil, nil, nil)
+	if err != nil {
+		return false
+	}
+	resp.Body.Close()
+	return true
+}
+
+func (s *S3Storage) key(instanceID, mediaID string) string {
+	return s.cfg.Prefix + instanceID + "/" + mediaID
+}
+
+type s3InitiateMultipartResult struct {
+	UploadID string `xml:"UploadId"`
+}
+
+type s3CompletedPart struct {
+	PartNumber int    `xml:"PartNumber"`
+	ETag       string `xml:"ETag"`
+}
+
+type s3CompleteMultipartUpload struct {
+	XMLName xml.Name          `xml:"CompleteMultipartUpload"`
+	Parts   []s3CompletedPart `xml:"Part"`
+}
+
+// putMultipart envia o objeto em partes de PartSize. Em caso de erro o upload
+// é abortado para não deixar partes cobradas no bucket.
+func (s *S3Storage) putMultipart(ctx context.Context, key string, data []byte, header http.Header) error {
+	resp, err := s.do(ctx, http.MethodPost, key, url.Values{"uploads": {""}}, nil, header)
+	if err != nil {
+		return err
+	}
+	var initiated s3InitiateMultipartResult
+	err = xml.NewDecoder(resp.Body).Decode(&initiated)
+	resp.Body.Close()
+	if err != nil {
+		return fmt.Errorf("iniciar upload multipart: %w", err)
+	}
+
+	uploadID := initiated.UploadID
+	var parts []s3CompletedPart
+	for offset, number := 0, 1; offset < len(data); offset, number = offset+s.cfg.PartSize, number+1 {
+		end := offset + s.cfg.PartSize
+		if end > len(data) {
+			end = len(data)
+		}
+
+		query := url.Values{"partNumber": {strconv.Itoa(number)}, "uploadId": {uploadID}}
+		resp, err := s.do(ctx, http.MethodPut, key, query, data[offset:end], nil)
+		if err != nil {
+			s.abortMultipart(key, uploadID)
+			return fmt.Errorf("enviar parte %d: %w", number, err)
+		}
+		resp.Body.Close()
+		parts = append(parts, s3CompletedPart{PartNumber: number, ETag: resp.Header.Get("ETag")})
+	}
+
+	body, err := xml.Marshal(s3CompleteMultipartUpload{Parts: parts})
+	if err != nil {
+		s.abortMultipart(key, uploadID)
+		return err
+	}
+	resp, err = s.do(ctx, http.MethodPost, key, url.Values{"uploadId": {uploadID}}, body, nil)
+	if err != nil {
+		s.abortMultipart(key, uploadID)
+		return fmt.Errorf("concluir upload multipart: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// O S3 pode responder 200 com um erro no corpo ao concluir o upload
+	respBody, _ := io.ReadAll(resp.Body)
+	if bytes.Contains(respBody, []byte("<Error>")) {
+		s.abortMultipart(key, uploadID)
+		return fmt.Errorf("concluir upload multipart: %s", s3ErrorCode(respBody))
+	}
+	return nil
+}
+
+func (s *S3Storage) abortMultipart(key, uploadID string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	resp, err := s.do(ctx, http.MethodDelete, key, url.Values{"uploadId": {uploadID}}, nil, nil)
+	if err != nil {
+		s.log.Warn("erro ao abortar upload multipart", zap.String("key", key), zap.Error(err))
+		return
+	}
+	resp.Body.Close()
+}
+
+type s3LifecycleConfiguration struct {
+	Rules []s3LifecycleRule `xml:"Rule"`
+}
+
+// s3LifecycleRule guarda a regra como veio do bucket, para regravá-la sem
+// perder campos que este cliente não conhece.
+type s3LifecycleRule struct {
+	ID  string `xml:"ID"`
+	Raw []byte `xml:",innerxml"`
+}
+
+// applyLifecycle grava no bucket a regra que expira as mídias do prefixo. Como
+// o PUT substitui a configuração inteira, as regras já existentes no bucket
+// são lidas e mantidas; só a regra apime-media-ttl é trocada.
+func (s *S3Storage) applyLifecycle(ctx context.Context) error {
+	days := s.lifecycleDays()
+
+	current, err := s.getLifecycle(ctx)
+	if err != nil {
+		return fmt.Errorf("ler lifecycle do bucket: %w", err)
+	}
+
+	var body bytes.Buffer
+	body.WriteString(`<LifecycleConfiguration xmlns="http://s3.amazonaws.com/doc/2006-03-01/">`)
+	kept := 0
+	for _, rule := range current.Rules {
+		if rule.ID == s3LifecycleRuleID {
+			continue
+		}
+		body.WriteString("<Rule>")
+		body.Write(rule.Raw)
+		body.WriteString("</Rule>")
+		kept++
+	}
+	fmt.Fprintf(&body,
+		`<Rule><ID>%s</ID><Filter><Prefix>%s</Prefix></Filter><Status>Enabled</Status><Expiration><Days>%d</Days></Expiration></Rule>`,
+		s3LifecycleRuleID, xmlEscape(s.cfg.Prefix), days,
+	)
+	body.WriteString(`</LifecycleConfiguration>`)
+
+	sum := md5.Sum(body.Bytes())
+	header := http.Header{}
+	header.Set("Content-MD5", base64.StdEncoding.EncodeToString(sum[:]))
+
+	resp, err := s.do(ctx, http.MethodPut, "", url.Values{"lifecycle": {""}}, body.Bytes(), header)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	s.log.Info("regra de expiração aplicada ao bucket",
+		zap.String("bucket", s.cfg.Bucket),
+		zap.String("prefix", s.cfg.Prefix),
+		zap.Int("days", days),
+		zap.Int("other_rules", kept),
+	)
+	return nil
+}
+
+// lifecycleDays é o TTL global em dias, arredondado para cima, como a regra
+// do bucket exige.
+func (s *S3Storage) lifecycleDays() int {
+	return max(1, int((s.ttl+24*time.Hour-1)/(24*time.Hour)))
+}
+
+// MaxRetention é a maior retenção que uma política de instância pode ter:
+// com a regra de lifecycle ativa, o bucket remove as mídias do prefixo depois
+// dela, qualquer que seja a política. Zero quando não há limite.
+func (s *S3Storage) MaxRetention() time.Duration {
+	if !s.lifecycle {
+		return 0
+	}
+	return time.Duration(s.lifecycleDays()) * 24 * time.Hour
+}
+
+// getLifecycle lê a configuração de lifecycle do bucket. Bucket sem
+// configuração responde 404 e retorna uma configuração vazia.
+func (s *S3Storage) getLifecycle(ctx context.Context) (s3LifecycleConfiguration, error) {
+	resp, err := s.do(ctx, http.MethodGet, "", url.Values{"lifecycle": {""}}, nil, nil)
+	if errors.Is(err, ErrNotFound) {
+		return s3LifecycleConfiguration{}, nil
+	}
+	if err != nil {
+		return s3LifecycleConfiguration{}, err
+	}
+	defer resp.Body.Close()
+
+	var cfg s3LifecycleConfiguration
+	if err := xml.NewDecoder(resp.Body).Decode(&cfg); err != nil {
+		return s3LifecycleConfiguration{}, err
+	}
+	return cfg, nil
+}
+
+type s3ListResult struct {
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+	Contents              []struct {
+		Key          string    `xml:"Key"`
+		Size         int64     `xml:"Size"`
+		LastModified time.Time `xml:"LastModified"`
+	} `xml:"Contents"`
+}
+
+// makeRoom remove as mídias mais antigas da instância até a nova caber na
+// cota.
+func (s *S3Storage) makeRoom(ctx context.Context, instanceID, mediaID string, size int64) error {
+	policy := s.policy(ctx, instanceID)
+	if policy.QuotaBytes <= 0 {
+		return nil
+	}
+	if size > policy.QuotaBytes {
+		return ErrQuotaExceeded
+	}
+
+	objects, err := s.list(ctx, instanceID)
+	if err != nil {
+		return err
+	}
+	others := objects[:0]
+	for _, obj := range objects {
+		if obj.MediaID != mediaID {
+			others = append(others, obj)
+		}
+	}
+	for _, obj := range evictions(others, 0, policy.QuotaBytes, size, time.Now()) {
+		s.remove(ctx, obj)
+		s.log.Info("mídia removida pela cota da instância",
+			zap.String("instance_id", instanceID),
+			zap.String("media_id", obj.MediaID),
+			zap.Int64("quota_bytes", policy.QuotaBytes),
+		)
+	}
+	return nil
+}
+
+// Usage retorna o espaço ocupado pelas mídias da instância no bucket.
+func (s *S3Storage) Usage(ctx context.Context, instanceID string) (model.MediaUsage, error) {
+	if !validID(instanceID) {
+		return model.MediaUsage{}, fmt.Errorf("instância inválida: %s", instanceID)
+	}
+	objects, err := s.list(ctx, instanceID)
+	if err != nil {
+		return model.MediaUsage{}, err
+	}
+	return s.usage(instanceID, objects, s.policy(ctx, instanceID)), nil
+}
+
+// list lista as mídias da instância, ou de todas quando instanceID é vazio.
+func (s *S3Storage) list(ctx context.Context, instanceID string) ([]storedObject, error) {
+	prefix := s.cfg.Prefix
+	if instanceID != "" {
+		prefix += instanceID + "/"
+	}
+
+	var objects []storedObject
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		resp, err := s.do(ctx, http.MethodGet, "", query, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		var list s3ListResult
+		err = xml.NewDecoder(resp.Body).Decode(&list)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("listar objetos: %w", err)
+		}
+
+		for _, obj := range list.Contents {
+			owner, mediaID, ok := strings.Cut(strings.TrimPrefix(obj.Key, s.cfg.Prefix), "/")
+			if !ok || mediaID == "" {
+				continue
+			}
+			objects = append(objects, storedObject{
+				InstanceID: owner,
+				MediaID:    mediaID,
+				Size:       obj.Size,
+				ModTime:    obj.LastModified,
+			})
+		}
+
+		if !list.IsTruncated || list.NextContinuationToken == "" {
+			return objects, nil
+		}
+		token = list.NextContinuationToken
+	}
+}
+
+func (s *S3Storage) remove(ctx context.Context, obj storedObject) bool {
+	key := s.key(obj.InstanceID, obj.MediaID)
+	resp, err := s.do(ctx, http.MethodDelete, key, nil, nil, nil)
+	if err != nil {
+		s.log.Warn("erro ao deletar objeto de mídia", zap.String("key", key), zap.Error(err))
+		return false
+	}
+	resp.Body.Close()
+	return true
+}
+
+// startCleanupJob inicia job de limpeza periódica
+func (s *S3Storage) startCleanupJob() {
+	ticker := time.NewTicker(30 * time.Minute)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		s.cleanup(context.Background())
+	}
+}
+
+// cleanup remove os objetos que passaram da retenção da instância (ou do TTL
+// global) e, nas instâncias com cota, os mais antigos até caber nela. Com a
+// regra de lifecycle ativa, só as instâncias com política são verificadas.
+func (s *S3Storage) cleanup(ctx context.Context) {
+	policies, err := s.allPolicies(ctx)
+	if err != nil {
+		s.log.Error("erro ao carregar políticas de mídia, usando o TTL global", zap.Error(err))
+		policies = map[string]model.MediaPolicy{}
+	}
+	if s.lifecycle && len(policies) == 0 {
+		return
+	}
+
+	s.log.Info("iniciando limpeza de mídia temporária", zap.Duration("ttl", s.ttl), zap.String("bucket", s.cfg.Bucket))
+
+	objects, err := s.list(ctx, "")
+	if err != nil {
+		s.log.Error("erro durante limpeza", zap.Error(err))
+		return
+	}
+
+	byInstance := make(map[string][]storedObject)
+	for _, obj := range objects {
+		byInstance[obj.InstanceID] = append(byInstance[obj.InstanceID], obj)
+	}
+
+	var deleted int
+	now := time.Now()
+	for instanceID, instanceObjects := range byInstance {
+		policy, hasPolicy := policies[instanceID]
+		ttl := s.retentionFor(policy)
+		if s.lifecycle {
+			if !hasPolicy {
+				continue
+			}
+			if policy.RetentionSeconds == 0 {
+				ttl = 0
+			}
+		}
+		for _, obj := range evictions(instanceObjects, ttl, policy.QuotaBytes, 0, now) {
+			if s.remove(ctx, obj) {
+				deleted++
+				s.log.Debug("objeto expirado deletado", zap.String("instance_id", instanceID), zap.String("media_id", obj.MediaID))
+			}
+		}
+	}
+
+	s.log.Info("limpeza de mídia concluída",
+		zap.Int("checked", len(objects)),
+		zap.Int("deleted", deleted),
+	)
+}
+
+// do assina e executa a requisição. Respostas fora da faixa 2xx viram erro;
+// 404 vira ErrNotFound.
+func (s *S3Storage) do(ctx context.Context, method, key string, query url.Values, body []byte, header http.Header) (*http.Response, error) {
+	u := *s.endpoint
+	path := "/" + key
+	if s.cfg.PathStyle {
+		path = "/" + s.cfg.Bucket + path
+	} else {
+		u.Host = s.cfg.Bucket + "." + u.Host
+	}
+	u.Path = path
+	u.RawPath = s3URIEncode(path, false)
+	u.RawQuery = s3CanonicalQuery(query)
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range header {
+		for _, v := range values {
+			req.Header.Add(name, v)
+		}
+	}
+	req.ContentLength = int64(len(body))
+	s.sign(req, u, body, time.Now())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp, nil
+	}
+
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound && method != http.MethodDelete {
+		return nil, ErrNotFound
+	}
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	return nil, fmt.Errorf("s3 %s %s: status %d: %s", method, key, resp.StatusCode, s3ErrorCode(respBody))
+}
+
+// sign aplica a assinatura AWS Signature Version 4 à requisição.
+func (s *S3Storage) sign(req *http.Request, u url.URL, body []byte, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := sha256Hex(body)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{
+		"host":                 u.Host,
+		"x-amz-content-sha256": payloadHash,
+		"x-amz-date":           amzDate,
+	}
+	if md5Header := req.Header.Get("Content-MD5"); md5Header != "" {
+		headers["content-md5"] = md5Header
+	}
+	// Os cabeçalhos x-amz-* precisam entrar na assinatura
+	for name, values := range req.Header {
+		if lower := strings.ToLower(name); strings.HasPrefix(lower, "x-amz-meta-") {
+			headers[lower] = strings.Join(values, ",")
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(headers[name]) + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		u.RawPath,
+		u.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + s.cfg.Region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	signingKey := hmacSHA256([]byte("AWS4"+s.cfg.SecretKey), date)
+	signingKey = hmacSHA256(signingKey, s.cfg.Region)
+	signingKey = hmacSHA256(signingKey, "s3")
+	signingKey = hmacSHA256(signingKey, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.cfg.AccessKey, scope, signedHeaders, signature,
+	))
+}
+
+// s3Reader lê o objeto com GET a partir do offset atual. Seek só descarta a
+// resposta aberta; a próxima leitura pede o restante com Range.
+type s3Reader struct {
+	ctx    context.Context
+	s      *S3Storage
+	key    string
+	size   int64
+	offset int64
+	body   io.ReadCloser
+}
+
+func (r *s3Reader) Read(p []byte) (int, error) {
+	if r.offset >= r.size {
+		return 0, io.EOF
+	}
+	if r.body == nil {
+		header := http.Header{}
+		header.Set("Range", fmt.Sprintf("bytes=%d-", r.offset))
+		resp, err := r.s.do(r.ctx, http.MethodGet, r.key, nil, nil, header)
+		if err != nil {
+			return 0, err
+		}
+		r.body = resp.Body
+	}
+	n, err := r.body.Read(p)
+	r.offset += int64(n)
+	return n, err
+}
+
+func (r *s3Reader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += r.offset
+	case io.SeekEnd:
+		offset += r.size
+	default:
+		return 0, fmt.Errorf("whence inválido: %d", whence)
+	}
+	if offset < 0 {
+		return 0, fmt.Errorf("posição negativa: %d", offset)
+	}
+	if offset != r.offset && r.body != nil {
+		r.body.Close()
+		r.body = nil
+	}
+	r.offset = offset
+	return offset, nil
+}
+
+func (r *s3Reader) Close() error {
+	if r.body == nil {
+		return nil
+	}
+	err := r.body.Close()
+	r.body = nil
+	return err
+}
+
+// s3CanonicalQuery monta a query ordenada e codificada como exige o SigV4.
+func s3CanonicalQuery(query url.Values) string {
+	if len(query) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		for _, v := range query[k] {
+			parts = append(parts, s3URIEncode(k, true)+"="+s3URIEncode(v, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// s3URIEncode codifica tudo exceto os caracteres não reservados; a barra só é
+// codificada fora do caminho.
+func s3URIEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c >= 'A' && c <= 'Z', c >= 'a' && c <= 'z', c >= '0' && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func s3ErrorCode(body []byte) string {
+	var e struct {
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	if err := xml.Unmarshal(body, &e); err != nil || e.Code == "" {
+		return strings.TrimSpace(string(body))
+	}
+	return e.Code + ": " + e.Message
+}
+
+func xmlEscape(s string) string {
+	var b strings.Builder
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
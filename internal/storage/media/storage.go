@@ -3,153 +3,115 @@ package media
 import (
 	"context"
 	"crypto/md5"
+	"errors"
 	"fmt"
-	"io/fs"
-	"os"
-	"path/filepath"
-	"sync"
+	"io"
+	"path"
+	"strings"
 	"time"
 
-	"go.uber.org/zap"
+	"github.com/open-apime/apime/internal/storage"
+	"github.com/open-apime/apime/internal/storage/model"
 )
 
-type Storage struct {
-	baseDir string
-	ttl     time.Duration
-	log     *zap.Logger
-	mu      sync.RWMutex
+var ErrNotFound = errors.New("mídia não encontrada")
+
+// Storage guarda as mídias das mensagens, servidas em /api/media. As mídias
+// expiram depois do TTL configurado no backend, ou da retenção da instância.
+type Storage interface {
+	// Save grava a mídia com o mimetype, o tamanho e o nome original
+	// (opcional), usados depois ao servi-la.
+	Save(ctx context.Context, instanceID string, messageID string, data []byte, mimetype string, fileName string) (string, error)
+	// Put grava a mídia com um ID já definido, como o das mídias baixadas sob
+	// demanda.
+	Put(ctx context.Context, instanceID string, mediaID string, data []byte, mimetype string, fileName string) error
+	Get(ctx context.Context, instanceID string, mediaID string) ([]byte, error)
+	// Open abre a mídia para leitura sob demanda, com seus metadados.
+	Open(ctx context.Context, instanceID string, mediaID string) (*Object, error)
+	Exists(ctx context.Context, instanceID string, mediaID string) bool
+	// Usage soma o espaço ocupado pelas mídias da instância, por tipo.
+	Usage(ctx context.Context, instanceID string) (model.MediaUsage, error)
+	// SetPolicyRepository habilita a retenção e a cota por instância.
+	SetPolicyRepository(repo storage.MediaPolicyRepository)
 }
 
-func NewStorage(baseDir string, ttl time.Duration, log *zap.Logger) (*Storage, error) {
-	if err := os.MkdirAll(baseDir, 0755); err != nil {
-		return nil, fmt.Errorf("criar diretório de mídia: %w", err)
-	}
-
-	s := &Storage{
-		baseDir: baseDir,
-		ttl:     ttl,
-		log:     log,
-	}
-
-	go s.startCleanupJob()
-
-	return s, nil
+// Info são os metadados gravados junto com a mídia.
+type Info struct {
+	MimeType string    `json:"mimeType"`
+	Size     int64     `json:"size"`
+	FileName string    `json:"fileName,omitempty"`
+	ETag     string    `json:"etag"`
+	ModTime  time.Time `json:"modTime"`
 }
 
-func (s *Storage) Save(ctx context.Context, instanceID string, messageID string, data []byte, mimetype string) (string, error) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	instanceDir := filepath.Join(s.baseDir, instanceID)
-	if err := os.MkdirAll(instanceDir, 0755); err != nil {
-		return "", fmt.Errorf("criar diretório da instância: %w", err)
-	}
-
-	hash := md5.Sum(data)
-	ext := getExtensionFromMimetype(mimetype)
-	mediaID := fmt.Sprintf("%s_%x%s", messageID, hash[:4], ext)
-
-	filePath := filepath.Join(instanceDir, mediaID)
-	if err := os.WriteFile(filePath, data, 0644); err != nil {
-		return "", fmt.Errorf("salvar arquivo: %w", err)
-	}
-
-	s.log.Info("mídia salva",
-		zap.String("instance_id", instanceID),
-		zap.String("media_id", mediaID),
-		zap.Int("size", len(data)),
-		zap.String("mimetype", mimetype),
-	)
-
-	return mediaID, nil
+// Object é uma mídia aberta. Content permite Seek, para atender requisições
+// com Range sem carregar o arquivo inteiro.
+type Object struct {
+	Info
+	Content io.ReadSeekCloser
 }
 
-func (s *Storage) Get(ctx context.Context, instanceID string, mediaID string) ([]byte, error) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-
-	filePath := filepath.Join(s.baseDir, instanceID, mediaID)
-	data, err := os.ReadFile(filePath)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return nil, fmt.Errorf("mídia não encontrada")
-		}
-		return nil, fmt.Errorf("ler arquivo: %w", err)
-	}
-
-	return data, nil
+func (o *Object) Close() error {
+	return o.Content.Close()
 }
 
-func (s *Storage) Exists(instanceID string, mediaID string) bool {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-
-	filePath := filepath.Join(s.baseDir, instanceID, mediaID)
-	_, err := os.Stat(filePath)
-	return err == nil
+// validID recusa IDs que escapariam do diretório ou do prefixo da instância.
+func validID(id string) bool {
+	return id != "" && id != "." && id != ".." && !strings.ContainsAny(id, `/\`) && path.Clean(id) == id
 }
 
-// GetPath retorna o caminho completo do arquivo
-func (s *Storage) GetPath(instanceID string, mediaID string) string {
-	return filepath.Join(s.baseDir, instanceID, mediaID)
+// newMediaID monta o ID da mídia a partir da mensagem e do conteúdo, com a
+// extensão do mimetype para que o tipo possa ser servido sem metadados.
+func newMediaID(messageID string, data []byte, mimetype string) string {
+	hash := md5.Sum(data)
+	return fmt.Sprintf("%s_%x%s", messageID, hash[:4], getExtensionFromMimetype(mimetype))
 }
 
-// startCleanupJob inicia job de limpeza periódica
-func (s *Storage) startCleanupJob() {
-	ticker := time.NewTicker(30 * time.Minute)
-	defer ticker.Stop()
-
-	for range ticker.C {
-		s.cleanup()
+// NewLazyMediaID monta o ID de uma mídia que ainda não foi baixada, a partir
+// do hash do arquivo informado na mensagem.
+func NewLazyMediaID(messageID string, fileSHA256 []byte, mimetype string) string {
+	if len(fileSHA256) < 4 {
+		sum := md5.Sum([]byte(messageID))
+		fileSHA256 = sum[:]
 	}
+	return fmt.Sprintf("%s_%x%s", messageID, fileSHA256[:4], getExtensionFromMimetype(mimetype))
 }
 
-// cleanup remove arquivos mais antigos que o TTL
-func (s *Storage) cleanup() {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	s.log.Info("iniciando limpeza de mídia temporária", zap.Duration("ttl", s.ttl))
-
-	var deleted, checked int
-	cutoff := time.Now().Add(-s.ttl)
-
-	err := filepath.WalkDir(s.baseDir, func(path string, d fs.DirEntry, err error) error {
-		if err != nil {
-			return nil // Ignorar erros e continuar
-		}
-
-		if d.IsDir() {
-			return nil
-		}
-
-		checked++
-
-		info, err := d.Info()
-		if err != nil {
-			return nil
-		}
-
-		if info.ModTime().Before(cutoff) {
-			if err := os.Remove(path); err != nil {
-				s.log.Warn("erro ao deletar arquivo expirado", zap.String("path", path), zap.Error(err))
-			} else {
-				deleted++
-				s.log.Debug("arquivo expirado deletado", zap.String("path", path))
-			}
-		}
-
-		return nil
-	})
+// ThumbnailID monta o ID da miniatura JPEG de uma mídia.
+func ThumbnailID(mediaID string) string {
+	return strings.TrimSuffix(mediaID, path.Ext(mediaID)) + "_thumb.jpg"
+}
 
-	if err != nil {
-		s.log.Error("erro durante limpeza", zap.Error(err))
+// mimetypeFromExtension é usado para mídias gravadas antes dos metadados.
+func mimetypeFromExtension(mediaID string) string {
+	switch strings.ToLower(path.Ext(mediaID)) {
+	case ".jpg", ".jpeg":
+		return "image/jpeg"
+	case ".png":
+		return "image/png"
+	case ".gif":
+		return "image/gif"
+	case ".webp":
+		return "image/webp"
+	case ".mp4":
+		return "video/mp4"
+	case ".3gp":
+		return "video/3gpp"
+	case ".ogg":
+		return "audio/ogg"
+	case ".mp3":
+		return "audio/mpeg"
+	case ".m4a":
+		return "audio/mp4"
+	case ".pdf":
+		return "application/pdf"
+	case ".doc":
+		return "application/msword"
+	case ".docx":
+		return "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
+	default:
+		return "application/octet-stream"
 	}
-
-	s.log.Info("limpeza de mídia concluída",
-		zap.Int("checked", checked),
-		zap.Int("deleted", deleted),
-	)
 }
 
 func getExtensionFromMimetype(mimetype string) string {
@@ -0,0 +1,63 @@
+package media
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+var (
+	ErrURLExpired       = errors.New("URL de mídia expirada")
+	ErrInvalidSignature = errors.New("assinatura da URL de mídia inválida")
+)
+
+// URLSigner assina as URLs de mídia com HMAC e validade, para que só quem
+// recebeu a URL no webhook consiga baixar a mídia.
+type URLSigner struct {
+	key []byte
+	ttl time.Duration
+}
+
+// NewURLSigner deriva a chave de assinatura do segredo, separada de outros
+// usos do mesmo segredo.
+func NewURLSigner(secret string, ttl time.Duration) *URLSigner {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("apime:media-url"))
+	return &URLSigner{key: mac.Sum(nil), ttl: ttl}
+}
+
+// SignURL monta a URL de download da mídia com exp e sig.
+func (s *URLSigner) SignURL(baseURL, instanceID, mediaID string) string {
+	exp := time.Now().Add(s.ttl).Unix()
+	query := url.Values{}
+	query.Set("exp", strconv.FormatInt(exp, 10))
+	query.Set("sig", s.signature(instanceID, mediaID, exp))
+	return fmt.Sprintf("%s/api/media/%s/%s?%s", baseURL, instanceID, mediaID, query.Encode())
+}
+
+// Verify confere a assinatura e a validade recebidas na URL.
+func (s *URLSigner) Verify(instanceID, mediaID, exp, sig string) error {
+	expUnix, err := strconv.ParseInt(exp, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	expected := s.signature(instanceID, mediaID, expUnix)
+	if !hmac.Equal([]byte(expected), []byte(sig)) {
+		return ErrInvalidSignature
+	}
+	if time.Now().Unix() > expUnix {
+		return ErrURLExpired
+	}
+	return nil
+}
+
+func (s *URLSigner) signature(instanceID, mediaID string, exp int64) string {
+	mac := hmac.New(sha256.New, s.key)
+	fmt.Fprintf(mac, "%s\n%s\n%d", instanceID, mediaID, exp)
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
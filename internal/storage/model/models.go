@@ -11,6 +11,98 @@ const (
 	InstanceStatusDisconnected InstanceStatus = "disconnected"
 )
 
+// ConnectionState é o estado detalhado da conexão da instância com o
+// WhatsApp. Status continua sendo o resumo exibido e filtrado pela API.
+type ConnectionState string
+
+const (
+	ConnectionStatePairing      ConnectionState = "pairing"
+	ConnectionStateConnecting   ConnectionState = "connecting"
+	ConnectionStateConnected    ConnectionState = "connected"
+	ConnectionStateReconnecting ConnectionState = "reconnecting"
+	ConnectionStateLoggedOut    ConnectionState = "logged_out"
+	ConnectionStateBanned       ConnectionState = "banned"
+	ConnectionStateOutdated     ConnectionState = "outdated"
+	// ConnectionStateDisconnected indica que não há nova tentativa agendada:
+	// desconexão manual, sessão assumida por outra conexão ou tentativas
+	// de reconexão esgotadas.
+	ConnectionStateDisconnected ConnectionState = "disconnected"
+)
+
+// InstanceStateTransition registra uma mudança de estado de conexão.
+type InstanceStateTransition struct {
+	ID         string          `json:"id"`
+	InstanceID string          `json:"instanceId"`
+	From       ConnectionState `json:"from,omitempty"`
+	To         ConnectionState `json:"to"`
+	Reason     string          `json:"reason,omitempty"`
+	Attempt    int             `json:"attempt,omitempty"`
+	CreatedAt  time.Time       `json:"createdAt"`
+}
+
+// InstanceConnectionState resume o estado atual da conexão e as últimas
+// transições registradas. Restoring indica que a sessão ainda aguarda a
+// restauração feita na inicialização do nó.
+type InstanceConnectionState struct {
+	InstanceID        string                    `json:"instanceId"`
+	State             ConnectionState           `json:"state"`
+	Since             time.Time                 `json:"since,omitempty"`
+	ReconnectAttempts int                       `json:"reconnectAttempts"`
+	NextRetryAt       *time.Time                `json:"nextRetryAt,omitempty"`
+	DisconnectedSince *time.Time                `json:"disconnectedSince,omitempty"`
+	Restoring         bool                      `json:"restoring,omitempty"`
+	Transitions       []InstanceStateTransition `json:"transitions"`
+}
+
+// SessionRestoreStatus é a fase da restauração das sessões na inicialização.
+type SessionRestoreStatus string
+
+const (
+	SessionRestoreIdle      SessionRestoreStatus = "idle"
+	SessionRestoreRunning   SessionRestoreStatus = "restoring"
+	SessionRestoreCompleted SessionRestoreStatus = "completed"
+)
+
+// SessionRestoreProgress resume a restauração das sessões do nó, somando a
+// da inicialização e a das instâncias assumidas depois.
+type SessionRestoreProgress struct {
+	Status     SessionRestoreStatus `json:"status"`
+	Total      int                  `json:"total"`
+	Restored   int                  `json:"restored"`
+	Failed     int                  `json:"failed"`
+	Skipped    int                  `json:"skipped"`
+	Pending    int                  `json:"pending"`
+	InProgress int                  `json:"inProgress"`
+	StartedAt  *time.Time           `json:"startedAt,omitempty"`
+	FinishedAt *time.Time           `json:"finishedAt,omitempty"`
+}
+
+// InstanceProvider é o backend que conecta a instância ao WhatsApp.
+type InstanceProvider string
+
+const (
+	// InstanceProviderWhatsmeow conecta como um dispositivo vinculado, pareado
+	// por QR code ou código de pareamento.
+	InstanceProviderWhatsmeow InstanceProvider = "whatsmeow"
+	// InstanceProviderCloudAPI usa um número da API oficial (Cloud API) da Meta.
+	InstanceProviderCloudAPI InstanceProvider = "cloud_api"
+	// InstanceProviderSandbox simula um número sem falar com o WhatsApp, para
+	// desenvolvimento e testes automatizados.
+	InstanceProviderSandbox InstanceProvider = "sandbox"
+)
+
+// MediaDownloadMode define quando a mídia recebida é baixada do WhatsApp.
+type MediaDownloadMode string
+
+const (
+	// MediaDownloadLazy guarda só as chaves da mídia e a baixa no primeiro
+	// acesso à URL do webhook.
+	MediaDownloadLazy MediaDownloadMode = "lazy"
+	// MediaDownloadEager baixa a mídia antes de enviar o webhook, até o
+	// tamanho máximo configurado.
+	MediaDownloadEager MediaDownloadMode = "eager"
+)
+
 type Instance struct {
 	ID                   string            `json:"id"`
 	Name                 string            `json:"name"`
@@ -27,20 +119,175 @@ type Instance struct {
 	HistorySyncCycleID   string            `json:"historySyncCycleId"`
 	HistorySyncUpdatedAt *time.Time        `json:"historySyncUpdatedAt,omitempty"`
 	MetaCompatible       bool              `json:"metaCompatible"`
+	Provider             InstanceProvider  `json:"provider"`
+	MediaDownload        MediaDownloadMode `json:"mediaDownload"`
 	CreatedAt            time.Time         `json:"createdAt"`
 	UpdatedAt            time.Time         `json:"updatedAt"`
 }
 
+type MessageDirection string
+
+const (
+	MessageDirectionInbound  MessageDirection = "inbound"
+	MessageDirectionOutbound MessageDirection = "outbound"
+)
+
 type Message struct {
-	ID         string     `json:"id"`
-	InstanceID string     `json:"instanceId"`
-	WhatsAppID string     `json:"whatsappId,omitempty"`
-	To         string     `json:"to"`
-	Type       string     `json:"type"`
-	Payload    string     `json:"payload"`
-	Status     string     `json:"status"`
-	DeliveredAt *time.Time `json:"deliveredAt,omitempty"`
-	CreatedAt  time.Time  `json:"createdAt"`
+	ID          string           `json:"id"`
+	InstanceID  string           `json:"instanceId"`
+	WhatsAppID  string           `json:"whatsappId,omitempty"`
+	Direction   MessageDirection `json:"direction"`
+	ChatJID     string           `json:"chatJid,omitempty"`
+	Sender      string           `json:"sender,omitempty"`
+	To          string           `json:"to"`
+	Type        string           `json:"type"`
+	Payload     string           `json:"payload"`
+	QuotedID    string           `json:"quotedId,omitempty"`
+	MediaID     string           `json:"mediaId,omitempty"`
+	Status      string           `json:"status"`
+	DeliveredAt *time.Time       `json:"deliveredAt,omitempty"`
+	CreatedAt   time.Time        `json:"createdAt"`
+}
+
+// Chat representa uma conversa de uma instância, agregando a última mensagem
+// trocada e a quantidade de mensagens recebidas ainda não lidas.
+type Chat struct {
+	InstanceID    string     `json:"instanceId"`
+	JID           string     `json:"jid"`
+	Name          string     `json:"name,omitempty"`
+	UnreadCount   int        `json:"unreadCount"`
+	LastMessageAt *time.Time `json:"lastMessageAt,omitempty"`
+	LastMessage   *Message   `json:"lastMessage,omitempty"`
+	CreatedAt     time.Time  `json:"createdAt"`
+	UpdatedAt     time.Time  `json:"updatedAt"`
+}
+
+// MessageStatusEvent registra uma confirmação recebida para uma mensagem. Em
+// grupos há um evento por participante; em conversas individuais, o
+// participante é o próprio destinatário.
+type MessageStatusEvent struct {
+	ID          string    `json:"id"`
+	InstanceID  string    `json:"instanceId"`
+	MessageID   string    `json:"messageId"`
+	ChatJID     string    `json:"chatJid,omitempty"`
+	Participant string    `json:"participant,omitempty"`
+	Status      string    `json:"status"`
+	OccurredAt  time.Time `json:"occurredAt"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+// PairCode é o código de pareamento gerado para conectar a instância pelo
+// número de telefone, sem leitura de QR code.
+type PairCode struct {
+	Code      string    `json:"code"`
+	Phone     string    `json:"phone"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+type ProxyType string
+
+const (
+	ProxyTypeHTTP   ProxyType = "http"
+	ProxyTypeSOCKS5 ProxyType = "socks5"
+)
+
+// InstanceProxy é o proxy de saída usado pela instância para o websocket e
+// para upload e download de mídia. Usuário e senha são gravados apenas de
+// forma criptografada, em CredentialsEnc.
+type InstanceProxy struct {
+	InstanceID     string    `json:"instanceId"`
+	Type           ProxyType `json:"type"`
+	Host           string    `json:"host"`
+	Port           int       `json:"port"`
+	Username       string    `json:"username,omitempty"`
+	Password       string    `json:"-"`
+	CredentialsEnc []byte    `json:"-"`
+	CreatedAt      time.Time `json:"createdAt"`
+	UpdatedAt      time.Time `json:"updatedAt"`
+}
+
+// InstanceCloudAPI são as credenciais de uma instância da Cloud API. O access
+// token e o app secret são gravados apenas de forma criptografada, em
+// SecretsEnc.
+type InstanceCloudAPI struct {
+	InstanceID        string    `json:"instanceId"`
+	PhoneNumberID     string    `json:"phoneNumberId"`
+	BusinessAccountID string    `json:"businessAccountId,omitempty"`
+	AccessToken       string    `json:"-"`
+	AppSecret         string    `json:"-"`
+	SecretsEnc        []byte    `json:"-"`
+	VerifyToken       string    `json:"verifyToken"`
+	HasAppSecret      bool      `json:"hasAppSecret"`
+	WebhookURL        string    `json:"webhookUrl,omitempty"`
+	CreatedAt         time.Time `json:"createdAt"`
+	UpdatedAt         time.Time `json:"updatedAt"`
+}
+
+// LIDMappingSource indica onde a sessão aprendeu a associação entre LID e
+// número.
+type LIDMappingSource string
+
+const (
+	LIDMappingSourceMessage     LIDMappingSource = "message"
+	LIDMappingSourceGroup       LIDMappingSource = "group"
+	LIDMappingSourceHistorySync LIDMappingSource = "history_sync"
+	LIDMappingSourceSession     LIDMappingSource = "session"
+)
+
+// LIDMapping associa o LID de um contato (usuario@lid) ao seu número
+// (telefone@s.whatsapp.net), na visão de uma instância.
+type LIDMapping struct {
+	InstanceID string           `json:"instanceId"`
+	LID        string           `json:"lid"`
+	PN         string           `json:"pn"`
+	Source     LIDMappingSource `json:"source"`
+	CreatedAt  time.Time        `json:"createdAt"`
+	UpdatedAt  time.Time        `json:"updatedAt"`
+}
+
+// MediaRef guarda o necessário para baixar e descriptografar uma mídia do
+// WhatsApp depois, sem tê-la baixado ao receber a mensagem.
+type MediaRef struct {
+	InstanceID    string    `json:"instanceId"`
+	MediaID       string    `json:"mediaId"`
+	MessageID     string    `json:"messageId"`
+	MediaType     string    `json:"mediaType"`
+	DirectPath    string    `json:"-"`
+	MediaKey      []byte    `json:"-"`
+	FileEncSHA256 []byte    `json:"-"`
+	FileSHA256    []byte    `json:"-"`
+	FileLength    int64     `json:"fileLength"`
+	MimeType      string    `json:"mimeType"`
+	FileName      string    `json:"fileName,omitempty"`
+	CreatedAt     time.Time `json:"createdAt"`
+}
+
+// MediaPolicy define por quanto tempo as mídias de uma instância ficam
+// guardadas e quanto espaço podem ocupar. RetentionSeconds zero usa o TTL
+// global; QuotaBytes zero desliga a cota.
+type MediaPolicy struct {
+	InstanceID       string    `json:"instanceId"`
+	RetentionSeconds int64     `json:"retentionSeconds"`
+	QuotaBytes       int64     `json:"quotaBytes"`
+	CreatedAt        time.Time `json:"createdAt"`
+	UpdatedAt        time.Time `json:"updatedAt"`
+}
+
+// MediaUsage é o espaço ocupado pelas mídias guardadas de uma instância.
+type MediaUsage struct {
+	InstanceID       string                    `json:"instanceId"`
+	Bytes            int64                     `json:"bytes"`
+	Files            int                       `json:"files"`
+	ByType           map[string]MediaTypeUsage `json:"byType"`
+	RetentionSeconds int64                     `json:"retentionSeconds"`
+	QuotaBytes       int64                     `json:"quotaBytes"`
+}
+
+// MediaTypeUsage é o uso de um tipo de mídia (image, video, audio ou
+// document).
+type MediaTypeUsage struct {
+	Bytes int64 `json:"bytes"`
+	Files int   `json:"files"`
 }
 
 type EventLog struct {
@@ -96,6 +343,9 @@ const (
 	HistorySyncStatusRunning   HistorySyncStatus = "running"
 	HistorySyncStatusCompleted HistorySyncStatus = "completed"
 	HistorySyncStatusFailed    HistorySyncStatus = "failed"
+	// HistorySyncStatusInterrupted indica um ciclo com chunks pendentes
+	// parado pela desconexão; ele é retomado na próxima conexão.
+	HistorySyncStatusInterrupted HistorySyncStatus = "interrupted"
 )
 
 type HistorySyncPayloadStatus string
@@ -108,14 +358,24 @@ const (
 )
 
 type WhatsappHistorySync struct {
-	ID          string                   `json:"id"`
-	InstanceID  string                   `json:"instanceId"`
-	PayloadType string                   `json:"payloadType"`
-	Payload     []byte                   `json:"payload"`
-	CycleID     string                   `json:"cycleId"`
-	Status      HistorySyncPayloadStatus `json:"status"`
-	CreatedAt   time.Time                `json:"createdAt"`
-	ProcessedAt *time.Time               `json:"processedAt"`
+	ID               string                   `json:"id"`
+	InstanceID       string                   `json:"instanceId"`
+	PayloadType      string                   `json:"payloadType"`
+	Payload          []byte                   `json:"payload"`
+	CycleID          string                   `json:"cycleId"`
+	Status           HistorySyncPayloadStatus `json:"status"`
+	MessagesImported int                      `json:"messagesImported"`
+	CreatedAt        time.Time                `json:"createdAt"`
+	ProcessedAt      *time.Time               `json:"processedAt"`
+}
+
+// HistorySyncProgress resume o andamento de um ciclo de history sync.
+type HistorySyncProgress struct {
+	CycleID          string `json:"cycleId"`
+	ChunksTotal      int    `json:"chunksTotal"`
+	ChunksDone       int    `json:"chunksDone"`
+	ChunksFailed     int    `json:"chunksFailed"`
+	MessagesImported int    `json:"messagesImported"`
 }
 
 type Contact struct {
@@ -124,3 +384,68 @@ type Contact struct {
 	CreatedAt time.Time `json:"createdAt"`
 	UpdatedAt time.Time `json:"updatedAt"`
 }
+
+// AlertType identifica a condição monitorada por uma regra de alerta.
+type AlertType string
+
+const (
+	AlertTypeLoggedOut AlertType = "logged_out"
+	AlertTypeBanned    AlertType = "banned"
+	// AlertTypeDisconnected dispara quando a instância fica desconectada por
+	// mais de Threshold minutos.
+	AlertTypeDisconnected AlertType = "disconnected"
+	// AlertTypeStuckMessages dispara quando mais de Threshold mensagens
+	// travam na última hora.
+	AlertTypeStuckMessages AlertType = "stuck_messages"
+	// AlertTypeWebhookFailures dispara após Threshold falhas consecutivas de
+	// entrega de webhook.
+	AlertTypeWebhookFailures AlertType = "webhook_failures"
+)
+
+// AlertChannel é o canal de notificação de uma regra.
+type AlertChannel string
+
+const (
+	AlertChannelEmail   AlertChannel = "email"
+	AlertChannelWebhook AlertChannel = "webhook"
+	AlertChannelSlack   AlertChannel = "slack"
+)
+
+// AlertRule associa uma condição a um canal. Sem InstanceID, a regra vale
+// para todas as instâncias do usuário.
+type AlertRule struct {
+	ID              string       `json:"id"`
+	UserID          string       `json:"userId"`
+	InstanceID      string       `json:"instanceId,omitempty"`
+	Type            AlertType    `json:"type"`
+	Threshold       int          `json:"threshold"`
+	Channel         AlertChannel `json:"channel"`
+	Target          string       `json:"target"`
+	CooldownSeconds int          `json:"cooldownSeconds"`
+	Enabled         bool         `json:"enabled"`
+	CreatedAt       time.Time    `json:"createdAt"`
+	UpdatedAt       time.Time    `json:"updatedAt"`
+}
+
+// DeviceStoreDump é a cópia das linhas do device store do whatsmeow de uma
+// instância, independente do backend (SQLite ou PostgreSQL) de origem.
+type DeviceStoreDump struct {
+	JID    string             `json:"jid"`
+	Tables []DeviceStoreTable `json:"tables"`
+}
+
+type DeviceStoreTable struct {
+	Name    string               `json:"name"`
+	Columns []string             `json:"columns"`
+	Rows    [][]DeviceStoreValue `json:"rows"`
+}
+
+// DeviceStoreValue guarda o valor de uma célula com o tipo explícito, para
+// que bytea, inteiros e booleanos sobrevivam à troca de backend.
+type DeviceStoreValue struct {
+	Kind  string `json:"k"`
+	Int   int64  `json:"i,omitempty"`
+	Bool  bool   `json:"b,omitempty"`
+	Text  string `json:"s,omitempty"`
+	Bytes []byte `json:"x,omitempty"`
+}
@@ -0,0 +1,133 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/open-apime/apime/internal/storage/model"
+)
+
+type alertRuleRepo struct {
+	db *DB
+}
+
+func NewAlertRuleRepository(db *DB) *alertRuleRepo {
+	return &alertRuleRepo{db: db}
+}
+
+const alertRuleColumns = `id, user_id, instance_id, type, threshold, channel, target, cooldown_seconds, enabled, created_at, updated_at`
+
+func (r *alertRuleRepo) Create(ctx context.Context, rule model.AlertRule) (model.AlertRule, error) {
+	if rule.ID == "" {
+		rule.ID = uuid.New().String()
+	}
+	now := time.Now()
+	rule.CreatedAt = now
+	rule.UpdatedAt = now
+
+	query := `
+		INSERT INTO alert_rules (` + alertRuleColumns + `)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		RETURNING ` + alertRuleColumns
+
+	row := r.db.Pool.QueryRow(ctx, query,
+		rule.ID, rule.UserID, nullableString(rule.InstanceID), string(rule.Type), rule.Threshold,
+		string(rule.Channel), rule.Target, rule.CooldownSeconds, rule.Enabled, rule.CreatedAt, rule.UpdatedAt,
+	)
+	return scanAlertRule(row)
+}
+
+func (r *alertRuleRepo) GetByID(ctx context.Context, id string) (model.AlertRule, error) {
+	query := `SELECT ` + alertRuleColumns + ` FROM alert_rules WHERE id = $1`
+	return scanAlertRule(r.db.Pool.QueryRow(ctx, query, id))
+}
+
+func (r *alertRuleRepo) List(ctx context.Context) ([]model.AlertRule, error) {
+	query := `SELECT ` + alertRuleColumns + ` FROM alert_rules ORDER BY created_at DESC`
+	return r.list(ctx, query)
+}
+
+func (r *alertRuleRepo) ListByUser(ctx context.Context, userID string) ([]model.AlertRule, error) {
+	query := `SELECT ` + alertRuleColumns + ` FROM alert_rules WHERE user_id = $1 ORDER BY created_at DESC`
+	return r.list(ctx, query, userID)
+}
+
+func (r *alertRuleRepo) list(ctx context.Context, query string, args ...any) ([]model.AlertRule, error) {
+	rows, err := r.db.Pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rules := make([]model.AlertRule, 0)
+	for rows.Next() {
+		rule, err := scanAlertRule(rows)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, rows.Err()
+}
+
+func (r *alertRuleRepo) Update(ctx context.Context, rule model.AlertRule) (model.AlertRule, error) {
+	rule.UpdatedAt = time.Now()
+
+	query := `
+		UPDATE alert_rules
+		SET instance_id = $2, type = $3, threshold = $4, channel = $5, target = $6,
+			cooldown_seconds = $7, enabled = $8, updated_at = $9
+		WHERE id = $1
+		RETURNING ` + alertRuleColumns
+
+	row := r.db.Pool.QueryRow(ctx, query,
+		rule.ID, nullableString(rule.InstanceID), string(rule.Type), rule.Threshold, string(rule.Channel),
+		rule.Target, rule.CooldownSeconds, rule.Enabled, rule.UpdatedAt,
+	)
+	return scanAlertRule(row)
+}
+
+func (r *alertRuleRepo) Delete(ctx context.Context, id string) error {
+	result, err := r.db.Pool.Exec(ctx, `DELETE FROM alert_rules WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func scanAlertRule(row pgx.Row) (model.AlertRule, error) {
+	var rule model.AlertRule
+	var instanceID *string
+	var ruleType, channel string
+
+	err := row.Scan(
+		&rule.ID, &rule.UserID, &instanceID, &ruleType, &rule.Threshold, &channel,
+		&rule.Target, &rule.CooldownSeconds, &rule.Enabled, &rule.CreatedAt, &rule.UpdatedAt,
+	)
+	if err == pgx.ErrNoRows {
+		return model.AlertRule{}, ErrNotFound
+	}
+	if err != nil {
+		return model.AlertRule{}, err
+	}
+
+	if instanceID != nil {
+		rule.InstanceID = *instanceID
+	}
+	rule.Type = model.AlertType(ruleType)
+	rule.Channel = model.AlertChannel(channel)
+	return rule, nil
+}
+
+func nullableString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
@@ -0,0 +1,133 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/open-apime/apime/internal/storage/model"
+)
+
+type chatRepo struct {
+	db *DB
+}
+
+func NewChatRepository(db *DB) *chatRepo {
+	return &chatRepo{db: db}
+}
+
+func (r *chatRepo) UpsertLastMessage(ctx context.Context, instanceID, jid, name string, msg model.Message, unreadDelta int) error {
+	// A última mensagem só é substituída quando a nova é mais recente, o que
+	// mantém a conversa correta ao importar mensagens antigas.
+	query := `
+		INSERT INTO chats (instance_id, jid, name, last_message_id, last_message_at, unread_count, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NOW(), NOW())
+		ON CONFLICT (instance_id, jid) DO UPDATE SET
+			name = COALESCE(EXCLUDED.name, chats.name),
+			last_message_id = CASE
+				WHEN chats.last_message_at IS NULL OR EXCLUDED.last_message_at >= chats.last_message_at THEN EXCLUDED.last_message_id
+				ELSE chats.last_message_id
+			END,
+			last_message_at = GREATEST(chats.last_message_at, EXCLUDED.last_message_at),
+			unread_count = chats.unread_count + EXCLUDED.unread_count,
+			updated_at = NOW()
+	`
+	_, err := r.db.Pool.Exec(ctx, query, instanceID, jid, nullIfEmpty(name), msg.ID, msg.CreatedAt, unreadDelta)
+	return err
+}
+
+func (r *chatRepo) MarkRead(ctx context.Context, instanceID, jid string) error {
+	query := `UPDATE chats SET unread_count = 0, updated_at = NOW() WHERE instance_id = $1 AND jid = $2`
+	_, err := r.db.Pool.Exec(ctx, query, instanceID, jid)
+	return err
+}
+
+func (r *chatRepo) ListByInstance(ctx context.Context, instanceID string) ([]model.Chat, error) {
+	query := `
+		SELECT c.instance_id, c.jid, c.name, c.unread_count, c.last_message_at, c.created_at, c.updated_at,
+			m.id, m.instance_id, m.whatsapp_id, m.direction, m.chat_jid, m.sender, m.recipient, m.type, m.payload,
+			m.quoted_id, m.media_id, m.status, m.delivered_at, m.created_at
+		FROM chats c
+		LEFT JOIN message_queue m ON m.id = c.last_message_id
+		WHERE c.instance_id = $1
+		ORDER BY c.last_message_at DESC NULLS LAST
+	`
+
+	rows, err := r.db.Pool.Query(ctx, query, instanceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var chats []model.Chat
+	for rows.Next() {
+		var chat model.Chat
+		var name *string
+		var msgID, msgInstanceID, whatsappID, direction, chatJID, sender, recipient, msgType *string
+		var quotedID, mediaID, status *string
+		var payload []byte
+		var deliveredAt, msgCreatedAt *time.Time
+
+		if err := rows.Scan(
+			&chat.InstanceID, &chat.JID, &name, &chat.UnreadCount, &chat.LastMessageAt, &chat.CreatedAt, &chat.UpdatedAt,
+			&msgID, &msgInstanceID, &whatsappID, &direction, &chatJID, &sender, &recipient, &msgType, &payload,
+			&quotedID, &mediaID, &status, &deliveredAt, &msgCreatedAt,
+		); err != nil {
+			return nil, err
+		}
+
+		chat.Name = derefString(name)
+
+		if msgID != nil {
+			last := model.Message{
+				ID:          *msgID,
+				InstanceID:  derefString(msgInstanceID),
+				WhatsAppID:  derefString(whatsappID),
+				Direction:   model.MessageDirection(derefString(direction)),
+				ChatJID:     derefString(chatJID),
+				Sender:      derefString(sender),
+				To:          derefString(recipient),
+				Type:        derefString(msgType),
+				Payload:     decodeMessagePayload(payload),
+				QuotedID:    derefString(quotedID),
+				MediaID:     derefString(mediaID),
+				Status:      derefString(status),
+				DeliveredAt: deliveredAt,
+			}
+			if msgCreatedAt != nil {
+				last.CreatedAt = *msgCreatedAt
+			}
+			chat.LastMessage = &last
+		}
+
+		chats = append(chats, chat)
+	}
+
+	return chats, rows.Err()
+}
+
+func (r *chatRepo) LastActivityByInstance(ctx context.Context) (map[string]time.Time, error) {
+	query := `
+		SELECT instance_id, MAX(last_message_at)
+		FROM chats
+		WHERE last_message_at IS NOT NULL
+		GROUP BY instance_id
+	`
+
+	rows, err := r.db.Pool.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	activity := make(map[string]time.Time)
+	for rows.Next() {
+		var instanceID string
+		var lastMessageAt time.Time
+		if err := rows.Scan(&instanceID, &lastMessageAt); err != nil {
+			return nil, err
+		}
+		activity[instanceID] = lastMessageAt
+	}
+
+	return activity, rows.Err()
+}
@@ -88,6 +88,40 @@ func (r *historySyncRepo) UpdateStatus(ctx context.Context, id string, status mo
 	return nil
 }
 
+func (r *historySyncRepo) UpdateResult(ctx context.Context, id string, status model.HistorySyncPayloadStatus, processedAt *time.Time, messagesImported int) error {
+	query := `UPDATE whatsapp_history_syncs SET status = $2, processed_at = $3, messages_imported = $4 WHERE id = $1`
+	result, err := r.db.Pool.Exec(ctx, query, id, string(status), processedAt, messagesImported)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+func (r *historySyncRepo) GetCycleProgress(ctx context.Context, instanceID, cycleID string) (model.HistorySyncProgress, error) {
+	query := `SELECT
+            COUNT(*),
+            COUNT(*) FILTER (WHERE status = 'done'),
+            COUNT(*) FILTER (WHERE status = 'error'),
+            COALESCE(SUM(messages_imported), 0)
+        FROM whatsapp_history_syncs
+        WHERE instance_id = $1 AND COALESCE(cycle_id::text, '') = $2`
+
+	progress := model.HistorySyncProgress{CycleID: cycleID}
+	err := r.db.Pool.QueryRow(ctx, query, instanceID, cycleID).Scan(
+		&progress.ChunksTotal,
+		&progress.ChunksDone,
+		&progress.ChunksFailed,
+		&progress.MessagesImported,
+	)
+	if err != nil {
+		return model.HistorySyncProgress{}, err
+	}
+	return progress, nil
+}
+
 func (r *historySyncRepo) DeleteByInstance(ctx context.Context, instanceID string) error {
 	_, err := r.db.Pool.Exec(ctx, `DELETE FROM whatsapp_history_syncs WHERE instance_id = $1`, instanceID)
 	return err
@@ -0,0 +1,60 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/open-apime/apime/internal/storage/model"
+)
+
+type instanceCloudAPIRepo struct {
+	db *DB
+}
+
+func NewInstanceCloudAPIRepository(db *DB) *instanceCloudAPIRepo {
+	return &instanceCloudAPIRepo{db: db}
+}
+
+func (r *instanceCloudAPIRepo) Get(ctx context.Context, instanceID string) (model.InstanceCloudAPI, error) {
+	query := `
+		SELECT instance_id, phone_number_id, COALESCE(business_account_id, ''), secrets_enc, verify_token, created_at, updated_at
+		FROM instance_cloud_api
+		WHERE instance_id = $1
+	`
+
+	var cfg model.InstanceCloudAPI
+	err := r.db.Pool.QueryRow(ctx, query, instanceID).Scan(
+		&cfg.InstanceID, &cfg.PhoneNumberID, &cfg.BusinessAccountID, &cfg.SecretsEnc,
+		&cfg.VerifyToken, &cfg.CreatedAt, &cfg.UpdatedAt,
+	)
+	if err == pgx.ErrNoRows {
+		return model.InstanceCloudAPI{}, ErrNotFound
+	}
+	if err != nil {
+		return model.InstanceCloudAPI{}, err
+	}
+	return cfg, nil
+}
+
+func (r *instanceCloudAPIRepo) Upsert(ctx context.Context, cfg model.InstanceCloudAPI) error {
+	query := `
+		INSERT INTO instance_cloud_api (instance_id, phone_number_id, business_account_id, secrets_enc, verify_token, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, NOW(), NOW())
+		ON CONFLICT (instance_id) DO UPDATE SET
+			phone_number_id = EXCLUDED.phone_number_id,
+			business_account_id = EXCLUDED.business_account_id,
+			secrets_enc = EXCLUDED.secrets_enc,
+			verify_token = EXCLUDED.verify_token,
+			updated_at = NOW()
+	`
+	_, err := r.db.Pool.Exec(ctx, query,
+		cfg.InstanceID, cfg.PhoneNumberID, nullIfEmpty(cfg.BusinessAccountID), cfg.SecretsEnc, cfg.VerifyToken,
+	)
+	return err
+}
+
+func (r *instanceCloudAPIRepo) Delete(ctx context.Context, instanceID string) error {
+	_, err := r.db.Pool.Exec(ctx, `DELETE FROM instance_cloud_api WHERE instance_id = $1`, instanceID)
+	return err
+}
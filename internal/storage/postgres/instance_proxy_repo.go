@@ -0,0 +1,64 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/open-apime/apime/internal/storage/model"
+)
+
+type instanceProxyRepo struct {
+	db *DB
+}
+
+func NewInstanceProxyRepository(db *DB) *instanceProxyRepo {
+	return &instanceProxyRepo{db: db}
+}
+
+func (r *instanceProxyRepo) Get(ctx context.Context, instanceID string) (model.InstanceProxy, error) {
+	query := `
+		SELECT instance_id, type, host, port, credentials_enc, created_at, updated_at
+		FROM instance_proxies
+		WHERE instance_id = $1
+	`
+
+	var proxy model.InstanceProxy
+	var proxyType string
+
+	err := r.db.Pool.QueryRow(ctx, query, instanceID).Scan(
+		&proxy.InstanceID, &proxyType, &proxy.Host, &proxy.Port,
+		&proxy.CredentialsEnc, &proxy.CreatedAt, &proxy.UpdatedAt,
+	)
+	if err == pgx.ErrNoRows {
+		return model.InstanceProxy{}, ErrNotFound
+	}
+	if err != nil {
+		return model.InstanceProxy{}, err
+	}
+
+	proxy.Type = model.ProxyType(proxyType)
+	return proxy, nil
+}
+
+func (r *instanceProxyRepo) Upsert(ctx context.Context, proxy model.InstanceProxy) error {
+	query := `
+		INSERT INTO instance_proxies (instance_id, type, host, port, credentials_enc, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, NOW(), NOW())
+		ON CONFLICT (instance_id) DO UPDATE SET
+			type = EXCLUDED.type,
+			host = EXCLUDED.host,
+			port = EXCLUDED.port,
+			credentials_enc = EXCLUDED.credentials_enc,
+			updated_at = NOW()
+	`
+	_, err := r.db.Pool.Exec(ctx, query,
+		proxy.InstanceID, string(proxy.Type), proxy.Host, proxy.Port, proxy.CredentialsEnc,
+	)
+	return err
+}
+
+func (r *instanceProxyRepo) Delete(ctx context.Context, instanceID string) error {
+	_, err := r.db.Pool.Exec(ctx, `DELETE FROM instance_proxies WHERE instance_id = $1`, instanceID)
+	return err
+}
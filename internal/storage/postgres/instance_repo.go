@@ -28,23 +28,29 @@ func (r *instanceRepo) Create(ctx context.Context, inst model.Instance) (model.I
 	if inst.HistorySyncStatus == "" {
 		inst.HistorySyncStatus = model.HistorySyncStatusPending
 	}
+	if inst.Provider == "" {
+		inst.Provider = model.InstanceProviderWhatsmeow
+	}
+	if inst.MediaDownload == "" {
+		inst.MediaDownload = model.MediaDownloadLazy
+	}
 
 	query := `
 		INSERT INTO instances (id, name, owner_user_id, whatsapp_jid, status, session_blob, webhook_url, webhook_secret, instance_token_hash, instance_token_updated_at,
-		                       history_sync_status, history_sync_cycle_id, history_sync_updated_at, meta_compatible, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)
+		                       history_sync_status, history_sync_cycle_id, history_sync_updated_at, meta_compatible, provider, media_download, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $17, $18, $15, $16)
 		RETURNING id, name, owner_user_id, COALESCE(whatsapp_jid, ''), status, COALESCE(webhook_url, ''), COALESCE(webhook_secret, ''), COALESCE(instance_token_hash, ''), instance_token_updated_at,
-		          history_sync_status, COALESCE(history_sync_cycle_id::text, ''), history_sync_updated_at, meta_compatible, created_at, updated_at
+		          history_sync_status, COALESCE(history_sync_cycle_id::text, ''), history_sync_updated_at, meta_compatible, provider, media_download, created_at, updated_at
 	`
 
 	err := r.db.Pool.QueryRow(ctx, query,
 		inst.ID, inst.Name, inst.OwnerUserID, nullIfEmpty(inst.WhatsAppJID), string(inst.Status), inst.SessionBlob,
 		nullIfEmpty(inst.WebhookURL), nullIfEmpty(inst.WebhookSecret), nullIfEmpty(inst.TokenHash), inst.TokenUpdatedAt,
 		string(inst.HistorySyncStatus), nullIfEmpty(inst.HistorySyncCycleID), inst.HistorySyncUpdatedAt, inst.MetaCompatible,
-		inst.CreatedAt, inst.UpdatedAt,
+		inst.CreatedAt, inst.UpdatedAt, string(inst.Provider), string(inst.MediaDownload),
 	).Scan(
 		&inst.ID, &inst.Name, &inst.OwnerUserID, &inst.WhatsAppJID, &inst.Status, &inst.WebhookURL, &inst.WebhookSecret, &inst.TokenHash, &inst.TokenUpdatedAt,
-		&inst.HistorySyncStatus, &inst.HistorySyncCycleID, &inst.HistorySyncUpdatedAt, &inst.MetaCompatible,
+		&inst.HistorySyncStatus, &inst.HistorySyncCycleID, &inst.HistorySyncUpdatedAt, &inst.MetaCompatible, &inst.Provider, &inst.MediaDownload,
 		&inst.CreatedAt, &inst.UpdatedAt,
 	)
 
@@ -58,7 +64,7 @@ func (r *instanceRepo) Create(ctx context.Context, inst model.Instance) (model.I
 func (r *instanceRepo) GetByTokenHash(ctx context.Context, tokenHash string) (model.Instance, error) {
 	query := `
 		SELECT id, name, owner_user_id, COALESCE(whatsapp_jid, ''), status, session_blob, COALESCE(webhook_url, ''), COALESCE(webhook_secret, ''), COALESCE(instance_token_hash, ''), instance_token_updated_at,
-		       history_sync_status, COALESCE(history_sync_cycle_id::text, ''), history_sync_updated_at, meta_compatible, created_at, updated_at
+		       history_sync_status, COALESCE(history_sync_cycle_id::text, ''), history_sync_updated_at, meta_compatible, provider, media_download, created_at, updated_at
 		FROM instances
 		WHERE instance_token_hash = $1
 	`
@@ -67,7 +73,7 @@ func (r *instanceRepo) GetByTokenHash(ctx context.Context, tokenHash string) (mo
 	err := r.db.Pool.QueryRow(ctx, query, tokenHash).Scan(
 		&inst.ID, &inst.Name, &inst.OwnerUserID, &inst.WhatsAppJID, &inst.Status, &inst.SessionBlob,
 		&inst.WebhookURL, &inst.WebhookSecret, &inst.TokenHash, &inst.TokenUpdatedAt,
-		&inst.HistorySyncStatus, &inst.HistorySyncCycleID, &inst.HistorySyncUpdatedAt, &inst.MetaCompatible,
+		&inst.HistorySyncStatus, &inst.HistorySyncCycleID, &inst.HistorySyncUpdatedAt, &inst.MetaCompatible, &inst.Provider, &inst.MediaDownload,
 		&inst.CreatedAt, &inst.UpdatedAt,
 	)
 	if err == pgx.ErrNoRows {
@@ -82,7 +88,7 @@ func (r *instanceRepo) GetByTokenHash(ctx context.Context, tokenHash string) (mo
 func (r *instanceRepo) GetByID(ctx context.Context, id string) (model.Instance, error) {
 	query := `
 		SELECT id, name, owner_user_id, COALESCE(whatsapp_jid, ''), status, session_blob, COALESCE(webhook_url, ''), COALESCE(webhook_secret, ''), COALESCE(instance_token_hash, ''), instance_token_updated_at,
-		       history_sync_status, COALESCE(history_sync_cycle_id::text, ''), history_sync_updated_at, meta_compatible, created_at, updated_at
+		       history_sync_status, COALESCE(history_sync_cycle_id::text, ''), history_sync_updated_at, meta_compatible, provider, media_download, created_at, updated_at
 		FROM instances
 		WHERE id = $1
 	`
@@ -92,7 +98,7 @@ func (r *instanceRepo) GetByID(ctx context.Context, id string) (model.Instance,
 	err := r.db.Pool.QueryRow(ctx, query, id).Scan(
 		&inst.ID, &inst.Name, &inst.OwnerUserID, &inst.WhatsAppJID, &inst.Status, &inst.SessionBlob,
 		&inst.WebhookURL, &inst.WebhookSecret, &inst.TokenHash, &inst.TokenUpdatedAt,
-		&inst.HistorySyncStatus, &inst.HistorySyncCycleID, &inst.HistorySyncUpdatedAt, &inst.MetaCompatible,
+		&inst.HistorySyncStatus, &inst.HistorySyncCycleID, &inst.HistorySyncUpdatedAt, &inst.MetaCompatible, &inst.Provider, &inst.MediaDownload,
 		&inst.CreatedAt, &inst.UpdatedAt,
 	)
 
@@ -109,7 +115,7 @@ func (r *instanceRepo) GetByID(ctx context.Context, id string) (model.Instance,
 func (r *instanceRepo) List(ctx context.Context) ([]model.Instance, error) {
 	query := `
 		SELECT i.id, i.name, i.owner_user_id, COALESCE(u.email, ''), COALESCE(i.whatsapp_jid, ''), i.status, COALESCE(i.webhook_url, ''), COALESCE(i.webhook_secret, ''), COALESCE(i.instance_token_hash, ''), i.instance_token_updated_at,
-		       i.history_sync_status, COALESCE(i.history_sync_cycle_id::text, ''), i.history_sync_updated_at, i.meta_compatible, i.created_at, i.updated_at
+		       i.history_sync_status, COALESCE(i.history_sync_cycle_id::text, ''), i.history_sync_updated_at, i.meta_compatible, i.provider, i.media_download, i.created_at, i.updated_at
 		FROM instances i
 		LEFT JOIN users u ON i.owner_user_id = u.id
 		ORDER BY i.created_at DESC
@@ -127,7 +133,7 @@ func (r *instanceRepo) List(ctx context.Context) ([]model.Instance, error) {
 		if err := rows.Scan(
 			&inst.ID, &inst.Name, &inst.OwnerUserID, &inst.OwnerEmail, &inst.WhatsAppJID, &inst.Status,
 			&inst.WebhookURL, &inst.WebhookSecret, &inst.TokenHash, &inst.TokenUpdatedAt,
-			&inst.HistorySyncStatus, &inst.HistorySyncCycleID, &inst.HistorySyncUpdatedAt, &inst.MetaCompatible,
+			&inst.HistorySyncStatus, &inst.HistorySyncCycleID, &inst.HistorySyncUpdatedAt, &inst.MetaCompatible, &inst.Provider, &inst.MediaDownload,
 			&inst.CreatedAt, &inst.UpdatedAt,
 		); err != nil {
 			return nil, err
@@ -142,7 +148,7 @@ func (r *instanceRepo) List(ctx context.Context) ([]model.Instance, error) {
 func (r *instanceRepo) ListByOwner(ctx context.Context, ownerUserID string) ([]model.Instance, error) {
 	query := `
 		SELECT i.id, i.name, i.owner_user_id, COALESCE(u.email, ''), COALESCE(i.whatsapp_jid, ''), i.status, COALESCE(i.webhook_url, ''), COALESCE(i.webhook_secret, ''), COALESCE(i.instance_token_hash, ''), i.instance_token_updated_at,
-		       i.history_sync_status, COALESCE(i.history_sync_cycle_id::text, ''), i.history_sync_updated_at, i.meta_compatible, i.created_at, i.updated_at
+		       i.history_sync_status, COALESCE(i.history_sync_cycle_id::text, ''), i.history_sync_updated_at, i.meta_compatible, i.provider, i.media_download, i.created_at, i.updated_at
 		FROM instances i
 		LEFT JOIN users u ON i.owner_user_id = u.id
 		WHERE i.owner_user_id = $1
@@ -161,7 +167,7 @@ func (r *instanceRepo) ListByOwner(ctx context.Context, ownerUserID string) ([]m
 		if err := rows.Scan(
 			&inst.ID, &inst.Name, &inst.OwnerUserID, &inst.OwnerEmail, &inst.WhatsAppJID, &inst.Status,
 			&inst.WebhookURL, &inst.WebhookSecret, &inst.TokenHash, &inst.TokenUpdatedAt,
-			&inst.HistorySyncStatus, &inst.HistorySyncCycleID, &inst.HistorySyncUpdatedAt, &inst.MetaCompatible,
+			&inst.HistorySyncStatus, &inst.HistorySyncCycleID, &inst.HistorySyncUpdatedAt, &inst.MetaCompatible, &inst.Provider, &inst.MediaDownload,
 			&inst.CreatedAt, &inst.UpdatedAt,
 		); err != nil {
 			return nil, err
@@ -179,20 +185,20 @@ func (r *instanceRepo) Update(ctx context.Context, inst model.Instance) (model.I
 	query := `
 		UPDATE instances
 		SET name = $2, owner_user_id = $3, whatsapp_jid = $4, status = $5, session_blob = $6, webhook_url = $7, webhook_secret = $8, instance_token_hash = $9, instance_token_updated_at = $10,
-		    history_sync_status = $11, history_sync_cycle_id = $12, history_sync_updated_at = $13, meta_compatible = $14, updated_at = $15
+		    history_sync_status = $11, history_sync_cycle_id = $12, history_sync_updated_at = $13, meta_compatible = $14, provider = $16, media_download = $17, updated_at = $15
 		WHERE id = $1
 		RETURNING id, name, owner_user_id, COALESCE(whatsapp_jid, ''), status, COALESCE(webhook_url, ''), COALESCE(webhook_secret, ''), COALESCE(instance_token_hash, ''), instance_token_updated_at,
-		          history_sync_status, COALESCE(history_sync_cycle_id::text, ''), history_sync_updated_at, meta_compatible, created_at, updated_at
+		          history_sync_status, COALESCE(history_sync_cycle_id::text, ''), history_sync_updated_at, meta_compatible, provider, media_download, created_at, updated_at
 	`
 
 	err := r.db.Pool.QueryRow(ctx, query,
 		inst.ID, inst.Name, inst.OwnerUserID, nullIfEmpty(inst.WhatsAppJID), string(inst.Status), inst.SessionBlob,
 		nullIfEmpty(inst.WebhookURL), nullIfEmpty(inst.WebhookSecret), nullIfEmpty(inst.TokenHash), inst.TokenUpdatedAt,
 		string(inst.HistorySyncStatus), nullIfEmpty(inst.HistorySyncCycleID), inst.HistorySyncUpdatedAt, inst.MetaCompatible,
-		inst.UpdatedAt,
+		inst.UpdatedAt, string(inst.Provider), string(inst.MediaDownload),
 	).Scan(
 		&inst.ID, &inst.Name, &inst.OwnerUserID, &inst.WhatsAppJID, &inst.Status, &inst.WebhookURL, &inst.WebhookSecret, &inst.TokenHash, &inst.TokenUpdatedAt,
-		&inst.HistorySyncStatus, &inst.HistorySyncCycleID, &inst.HistorySyncUpdatedAt, &inst.MetaCompatible,
+		&inst.HistorySyncStatus, &inst.HistorySyncCycleID, &inst.HistorySyncUpdatedAt, &inst.MetaCompatible, &inst.Provider, &inst.MediaDownload,
 		&inst.CreatedAt, &inst.UpdatedAt,
 	)
 
@@ -206,6 +212,17 @@ func (r *instanceRepo) Update(ctx context.Context, inst model.Instance) (model.I
 	return inst, nil
 }
 
+func (r *instanceRepo) SwapSessionBlob(ctx context.Context, id string, old, blob []byte) (bool, error) {
+	result, err := r.db.Pool.Exec(ctx,
+		`UPDATE instances SET session_blob = $3 WHERE id = $1 AND session_blob = $2`,
+		id, old, blob,
+	)
+	if err != nil {
+		return false, err
+	}
+	return result.RowsAffected() > 0, nil
+}
+
 func nullIfEmpty(v string) *string {
 	if v == "" {
 		return nil
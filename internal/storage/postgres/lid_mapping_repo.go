@@ -0,0 +1,53 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/open-apime/apime/internal/storage/model"
+)
+
+type lidMappingRepo struct {
+	db *DB
+}
+
+func NewLIDMappingRepository(db *DB) *lidMappingRepo {
+	return &lidMappingRepo{db: db}
+}
+
+func (r *lidMappingRepo) Upsert(ctx context.Context, mapping model.LIDMapping) error {
+	query := `
+		INSERT INTO lid_mappings (instance_id, lid, pn, source, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, NOW(), NOW())
+		ON CONFLICT (instance_id, lid) DO UPDATE SET
+			pn = EXCLUDED.pn,
+			source = EXCLUDED.source,
+			updated_at = NOW()
+		WHERE lid_mappings.pn <> EXCLUDED.pn
+	`
+	_, err := r.db.Pool.Exec(ctx, query, mapping.InstanceID, mapping.LID, mapping.PN, string(mapping.Source))
+	return err
+}
+
+func (r *lidMappingRepo) GetByLID(ctx context.Context, instanceID, lid string) (model.LIDMapping, error) {
+	query := `
+		SELECT instance_id, lid, pn, source, created_at, updated_at
+		FROM lid_mappings
+		WHERE instance_id = $1 AND lid = $2
+	`
+
+	var mapping model.LIDMapping
+	var source string
+	err := r.db.Pool.QueryRow(ctx, query, instanceID, lid).Scan(
+		&mapping.InstanceID, &mapping.LID, &mapping.PN, &source, &mapping.CreatedAt, &mapping.UpdatedAt,
+	)
+	if err == pgx.ErrNoRows {
+		return model.LIDMapping{}, ErrNotFound
+	}
+	if err != nil {
+		return model.LIDMapping{}, err
+	}
+	mapping.Source = model.LIDMappingSource(source)
+	return mapping, nil
+}
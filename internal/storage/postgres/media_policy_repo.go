@@ -0,0 +1,79 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/open-apime/apime/internal/storage/model"
+)
+
+type mediaPolicyRepo struct {
+	db *DB
+}
+
+func NewMediaPolicyRepository(db *DB) *mediaPolicyRepo {
+	return &mediaPolicyRepo{db: db}
+}
+
+func (r *mediaPolicyRepo) Get(ctx context.Context, instanceID string) (model.MediaPolicy, error) {
+	query := `
+		SELECT instance_id, retention_seconds, quota_bytes, created_at, updated_at
+		FROM media_policies
+		WHERE instance_id = $1
+	`
+
+	var policy model.MediaPolicy
+	err := r.db.Pool.QueryRow(ctx, query, instanceID).Scan(
+		&policy.InstanceID, &policy.RetentionSeconds, &policy.QuotaBytes, &policy.CreatedAt, &policy.UpdatedAt,
+	)
+	if err == pgx.ErrNoRows {
+		return model.MediaPolicy{}, ErrNotFound
+	}
+	if err != nil {
+		return model.MediaPolicy{}, err
+	}
+	return policy, nil
+}
+
+func (r *mediaPolicyRepo) List(ctx context.Context) ([]model.MediaPolicy, error) {
+	query := `
+		SELECT instance_id, retention_seconds, quota_bytes, created_at, updated_at
+		FROM media_policies
+	`
+
+	rows, err := r.db.Pool.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var policies []model.MediaPolicy
+	for rows.Next() {
+		var policy model.MediaPolicy
+		if err := rows.Scan(&policy.InstanceID, &policy.RetentionSeconds, &policy.QuotaBytes, &policy.CreatedAt, &policy.UpdatedAt); err != nil {
+			return nil, err
+		}
+		policies = append(policies, policy)
+	}
+
+	return policies, rows.Err()
+}
+
+func (r *mediaPolicyRepo) Upsert(ctx context.Context, policy model.MediaPolicy) error {
+	query := `
+		INSERT INTO media_policies (instance_id, retention_seconds, quota_bytes, created_at, updated_at)
+		VALUES ($1, $2, $3, NOW(), NOW())
+		ON CONFLICT (instance_id) DO UPDATE SET
+			retention_seconds = EXCLUDED.retention_seconds,
+			quota_bytes = EXCLUDED.quota_bytes,
+			updated_at = NOW()
+	`
+	_, err := r.db.Pool.Exec(ctx, query, policy.InstanceID, policy.RetentionSeconds, policy.QuotaBytes)
+	return err
+}
+
+func (r *mediaPolicyRepo) Delete(ctx context.Context, instanceID string) error {
+	_, err := r.db.Pool.Exec(ctx, `DELETE FROM media_policies WHERE instance_id = $1`, instanceID)
+	return err
+}
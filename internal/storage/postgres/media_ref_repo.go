@@ -0,0 +1,53 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/open-apime/apime/internal/storage/model"
+)
+
+type mediaRefRepo struct {
+	db *DB
+}
+
+func NewMediaRefRepository(db *DB) *mediaRefRepo {
+	return &mediaRefRepo{db: db}
+}
+
+func (r *mediaRefRepo) Create(ctx context.Context, ref model.MediaRef) error {
+	query := `
+		INSERT INTO media_refs (instance_id, media_id, message_id, media_type, direct_path, media_key, file_enc_sha256, file_sha256, file_length, mimetype, file_name, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, NOW())
+		ON CONFLICT (instance_id, media_id) DO NOTHING
+	`
+	_, err := r.db.Pool.Exec(ctx, query,
+		ref.InstanceID, ref.MediaID, ref.MessageID, ref.MediaType, ref.DirectPath,
+		ref.MediaKey, ref.FileEncSHA256, ref.FileSHA256, ref.FileLength,
+		ref.MimeType, nullIfEmpty(ref.FileName),
+	)
+	return err
+}
+
+func (r *mediaRefRepo) Get(ctx context.Context, instanceID, mediaID string) (model.MediaRef, error) {
+	query := `
+		SELECT instance_id, media_id, message_id, media_type, direct_path, media_key, file_enc_sha256, file_sha256, file_length, mimetype, COALESCE(file_name, ''), created_at
+		FROM media_refs
+		WHERE instance_id = $1 AND media_id = $2
+	`
+
+	var ref model.MediaRef
+	err := r.db.Pool.QueryRow(ctx, query, instanceID, mediaID).Scan(
+		&ref.InstanceID, &ref.MediaID, &ref.MessageID, &ref.MediaType, &ref.DirectPath,
+		&ref.MediaKey, &ref.FileEncSHA256, &ref.FileSHA256, &ref.FileLength,
+		&ref.MimeType, &ref.FileName, &ref.CreatedAt,
+	)
+	if err == pgx.ErrNoRows {
+		return model.MediaRef{}, ErrNotFound
+	}
+	if err != nil {
+		return model.MediaRef{}, err
+	}
+	return ref, nil
+}
@@ -3,6 +3,7 @@ package postgres
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"time"
 
 	"github.com/google/uuid"
@@ -11,6 +12,8 @@ import (
 	"github.com/open-apime/apime/internal/storage/model"
 )
 
+const messageColumns = `id, instance_id, whatsapp_id, direction, chat_jid, sender, recipient, type, payload, quoted_id, media_id, status, delivered_at, created_at`
+
 type messageRepo struct {
 	db *DB
 }
@@ -20,10 +23,57 @@ func NewMessageRepository(db *DB) *messageRepo {
 }
 
 func (r *messageRepo) Create(ctx context.Context, msg model.Message) (model.Message, error) {
+	return r.insert(ctx, msg, "")
+}
+
+// messageDedupConflict é o alvo do índice único parcial de
+// (instance_id, whatsapp_id), usado para gravar cada mensagem do WhatsApp uma
+// única vez.
+const messageDedupConflict = `ON CONFLICT (instance_id, whatsapp_id) WHERE whatsapp_id IS NOT NULL AND whatsapp_id <> '' DO NOTHING`
+
+// CreateIfNotExists grava a mensagem se ainda não houver outra com o mesmo
+// ID do WhatsApp na instância. A verificação é feita pelo índice único, então
+// importações e eventos concorrentes não duplicam a mensagem.
+func (r *messageRepo) CreateIfNotExists(ctx context.Context, msg model.Message) (model.Message, bool, error) {
+	if msg.WhatsAppID == "" {
+		created, err := r.Create(ctx, msg)
+		if err != nil {
+			return model.Message{}, false, err
+		}
+		return created, true, nil
+	}
+
+	created, err := r.insert(ctx, msg, messageDedupConflict)
+	if err == nil {
+		return created, true, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return model.Message{}, false, err
+	}
+
+	var existingID string
+	if err := r.db.Pool.QueryRow(ctx,
+		`SELECT id FROM message_queue WHERE instance_id = $1 AND whatsapp_id = $2 LIMIT 1`,
+		msg.InstanceID, msg.WhatsAppID,
+	).Scan(&existingID); err != nil {
+		return model.Message{}, false, err
+	}
+	msg.ID = existingID
+	return msg, false, nil
+}
+
+// insert grava a mensagem com a cláusula de conflito informada. Sem linha
+// inserida, retorna pgx.ErrNoRows.
+func (r *messageRepo) insert(ctx context.Context, msg model.Message, conflict string) (model.Message, error) {
 	if msg.ID == "" {
 		msg.ID = uuid.New().String()
 	}
-	msg.CreatedAt = time.Now()
+	if msg.Direction == "" {
+		msg.Direction = model.MessageDirectionOutbound
+	}
+	if msg.CreatedAt.IsZero() {
+		msg.CreatedAt = time.Now()
+	}
 
 	payloadJSON, err := json.Marshal(map[string]interface{}{
 		"text": msg.Payload,
@@ -33,95 +83,80 @@ func (r *messageRepo) Create(ctx context.Context, msg model.Message) (model.Mess
 	}
 
 	query := `
-		INSERT INTO message_queue (id, instance_id, whatsapp_id, recipient, type, payload, status, created_at)
-		VALUES ($1, $2, $3, $4, $5, $6::jsonb, $7, $8)
-		RETURNING id, instance_id, whatsapp_id, recipient, type, payload, status, created_at
+		INSERT INTO message_queue (id, instance_id, whatsapp_id, direction, chat_jid, sender, recipient, type, payload, quoted_id, media_id, status, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9::jsonb, $10, $11, $12, $13)
+		` + conflict + `
+		RETURNING ` + messageColumns + `
 	`
 
-	var payloadBytes []byte
-	var whatsappID *string
-	err = r.db.Pool.QueryRow(ctx, query,
-		msg.ID, msg.InstanceID, msg.WhatsAppID, msg.To, msg.Type, payloadJSON, msg.Status, msg.CreatedAt,
-	).Scan(
-		&msg.ID, &msg.InstanceID, &whatsappID, &msg.To, &msg.Type, &payloadBytes, &msg.Status, &msg.CreatedAt,
-	)
-
-	if err != nil {
-		return model.Message{}, err
-	}
+	return scanMessage(r.db.Pool.QueryRow(ctx, query,
+		msg.ID, msg.InstanceID, msg.WhatsAppID, string(msg.Direction), nullIfEmpty(msg.ChatJID), nullIfEmpty(msg.Sender),
+		msg.To, msg.Type, payloadJSON, nullIfEmpty(msg.QuotedID), nullIfEmpty(msg.MediaID), msg.Status, msg.CreatedAt,
+	))
+}
 
-	if whatsappID != nil {
-		msg.WhatsAppID = *whatsappID
-	}
+func (r *messageRepo) ListByInstance(ctx context.Context, instanceID string, before *time.Time, limit int) ([]model.Message, error) {
+	query := `
+		SELECT ` + messageColumns + `
+		FROM message_queue
+		WHERE instance_id = $1 AND ($2::timestamptz IS NULL OR created_at < $2)
+		ORDER BY created_at DESC
+		LIMIT $3
+	`
 
-	var payloadMap map[string]interface{}
-	if err := json.Unmarshal(payloadBytes, &payloadMap); err == nil {
-		if text, ok := payloadMap["text"].(string); ok {
-			msg.Payload = text
-		} else {
-			msg.Payload = string(payloadBytes)
-		}
-	} else {
-		msg.Payload = string(payloadBytes)
+	rows, err := r.db.Pool.Query(ctx, query, instanceID, before, limit)
+	if err != nil {
+		return nil, err
 	}
+	defer rows.Close()
 
-	return msg, nil
+	return scanMessages(rows)
 }
 
-func (r *messageRepo) ListByInstance(ctx context.Context, instanceID string) ([]model.Message, error) {
+func (r *messageRepo) ListPendingOutbound(ctx context.Context, instanceID string, since time.Time, limit int) ([]model.Message, error) {
 	query := `
-		SELECT id, instance_id, whatsapp_id, recipient, type, payload, status, delivered_at, created_at
+		SELECT ` + messageColumns + `
 		FROM message_queue
-		WHERE instance_id = $1
-		ORDER BY created_at DESC
-		LIMIT 100
+		WHERE instance_id = $1 AND direction = 'outbound' AND (sender IS NULL OR sender = '')
+			AND status IN ('sent', 'retry', 'delivered') AND created_at >= $2
+		ORDER BY created_at ASC
+		LIMIT $3
 	`
 
-	rows, err := r.db.Pool.Query(ctx, query, instanceID)
+	rows, err := r.db.Pool.Query(ctx, query, instanceID, since, limit)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var messages []model.Message
-	for rows.Next() {
-		var msg model.Message
-		var payloadBytes []byte
-		var whatsappID *string
-		if err := rows.Scan(
-			&msg.ID, &msg.InstanceID, &whatsappID, &msg.To, &msg.Type, &payloadBytes, &msg.Status, &msg.DeliveredAt, &msg.CreatedAt,
-		); err != nil {
-			return nil, err
-		}
-
-		if whatsappID != nil {
-			msg.WhatsAppID = *whatsappID
-		}
+	return scanMessages(rows)
+}
 
-		var payloadMap map[string]interface{}
-		if err := json.Unmarshal(payloadBytes, &payloadMap); err == nil {
-			if text, ok := payloadMap["text"].(string); ok {
-				msg.Payload = text
-			} else {
-				msg.Payload = string(payloadBytes)
-			}
-		} else {
-			msg.Payload = string(payloadBytes)
-		}
+func (r *messageRepo) ListByChat(ctx context.Context, instanceID, chatJID string, before *time.Time, limit int) ([]model.Message, error) {
+	query := `
+		SELECT ` + messageColumns + `
+		FROM message_queue
+		WHERE instance_id = $1 AND chat_jid = $2 AND ($3::timestamptz IS NULL OR created_at < $3)
+		ORDER BY created_at DESC
+		LIMIT $4
+	`
 
-		messages = append(messages, msg)
+	rows, err := r.db.Pool.Query(ctx, query, instanceID, chatJID, before, limit)
+	if err != nil {
+		return nil, err
 	}
+	defer rows.Close()
 
-	return messages, rows.Err()
+	return scanMessages(rows)
 }
 
 func (r *messageRepo) Update(ctx context.Context, msg model.Message) error {
 	query := `
 		UPDATE message_queue
-		SET status = $1, whatsapp_id = $2, delivered_at = $3
-		WHERE id = $4
+		SET status = $1, whatsapp_id = $2, delivered_at = $3, chat_jid = COALESCE($4, chat_jid)
+		WHERE id = $5
 	`
-	_, err := r.db.Pool.Exec(ctx, query, msg.Status, msg.WhatsAppID, msg.DeliveredAt, msg.ID)
+	_, err := r.db.Pool.Exec(ctx, query, msg.Status, msg.WhatsAppID, msg.DeliveredAt, nullIfEmpty(msg.ChatJID), msg.ID)
 	return err
 }
 
@@ -137,47 +172,43 @@ func (r *messageRepo) UpdateStatusByWhatsAppID(ctx context.Context, whatsappID s
 
 func (r *messageRepo) GetByWhatsAppID(ctx context.Context, whatsappID string) (model.Message, error) {
 	query := `
-		SELECT id, instance_id, whatsapp_id, recipient, type, payload, status, delivered_at, created_at
+		SELECT ` + messageColumns + `
 		FROM message_queue
 		WHERE whatsapp_id = $1
 		LIMIT 1
 	`
 
-	var msg model.Message
-	var payloadBytes []byte
-	var wID *string
-	err := r.db.Pool.QueryRow(ctx, query, whatsappID).Scan(
-		&msg.ID, &msg.InstanceID, &wID, &msg.To, &msg.Type, &payloadBytes, &msg.Status, &msg.DeliveredAt, &msg.CreatedAt,
-	)
-
+	msg, err := scanMessage(r.db.Pool.QueryRow(ctx, query, whatsappID))
 	if err == pgx.ErrNoRows {
 		return model.Message{}, ErrNotFound
 	}
 	if err != nil {
 		return model.Message{}, err
 	}
+	return msg, nil
+}
 
-	if wID != nil {
-		msg.WhatsAppID = *wID
-	}
+func (r *messageRepo) GetByInstanceAndID(ctx context.Context, instanceID, id string) (model.Message, error) {
+	query := `
+		SELECT ` + messageColumns + `
+		FROM message_queue
+		WHERE instance_id = $1 AND (id::text = $2 OR whatsapp_id = $2)
+		LIMIT 1
+	`
 
-	var payloadMap map[string]interface{}
-	if err := json.Unmarshal(payloadBytes, &payloadMap); err == nil {
-		if text, ok := payloadMap["text"].(string); ok {
-			msg.Payload = text
-		} else {
-			msg.Payload = string(payloadBytes)
-		}
-	} else {
-		msg.Payload = string(payloadBytes)
+	msg, err := scanMessage(r.db.Pool.QueryRow(ctx, query, instanceID, id))
+	if err == pgx.ErrNoRows {
+		return model.Message{}, ErrNotFound
+	}
+	if err != nil {
+		return model.Message{}, err
 	}
-
 	return msg, nil
 }
 
 func (r *messageRepo) GetPendingMessages(ctx context.Context, limit int) ([]model.Message, error) {
 	query := `
-		SELECT id, instance_id, whatsapp_id, recipient, type, payload, status, delivered_at, created_at
+		SELECT ` + messageColumns + `
 		FROM message_queue
 		WHERE status = 'queued'
 		ORDER BY created_at ASC
@@ -190,40 +221,63 @@ func (r *messageRepo) GetPendingMessages(ctx context.Context, limit int) ([]mode
 	}
 	defer rows.Close()
 
+	return scanMessages(rows)
+}
+
+func (r *messageRepo) DeleteByInstanceID(ctx context.Context, instanceID string) error {
+	query := `DELETE FROM message_queue WHERE instance_id = $1`
+	_, err := r.db.Pool.Exec(ctx, query, instanceID)
+	return err
+}
+
+func scanMessages(rows pgx.Rows) ([]model.Message, error) {
 	var messages []model.Message
 	for rows.Next() {
-		var msg model.Message
-		var payloadBytes []byte
-		var whatsappID *string
-		if err := rows.Scan(
-			&msg.ID, &msg.InstanceID, &whatsappID, &msg.To, &msg.Type, &payloadBytes, &msg.Status, &msg.DeliveredAt, &msg.CreatedAt,
-		); err != nil {
+		msg, err := scanMessage(rows)
+		if err != nil {
 			return nil, err
 		}
+		messages = append(messages, msg)
+	}
+	return messages, rows.Err()
+}
 
-		if whatsappID != nil {
-			msg.WhatsAppID = *whatsappID
-		}
-
-		var payloadMap map[string]interface{}
-		if err := json.Unmarshal(payloadBytes, &payloadMap); err == nil {
-			if text, ok := payloadMap["text"].(string); ok {
-				msg.Payload = text
-			} else {
-				msg.Payload = string(payloadBytes)
-			}
-		} else {
-			msg.Payload = string(payloadBytes)
-		}
+func scanMessage(row pgx.Row) (model.Message, error) {
+	var msg model.Message
+	var payloadBytes []byte
+	var direction string
+	var whatsappID, chatJID, sender, quotedID, mediaID *string
 
-		messages = append(messages, msg)
+	if err := row.Scan(
+		&msg.ID, &msg.InstanceID, &whatsappID, &direction, &chatJID, &sender, &msg.To, &msg.Type, &payloadBytes,
+		&quotedID, &mediaID, &msg.Status, &msg.DeliveredAt, &msg.CreatedAt,
+	); err != nil {
+		return model.Message{}, err
 	}
 
-	return messages, rows.Err()
+	msg.Direction = model.MessageDirection(direction)
+	msg.WhatsAppID = derefString(whatsappID)
+	msg.ChatJID = derefString(chatJID)
+	msg.Sender = derefString(sender)
+	msg.QuotedID = derefString(quotedID)
+	msg.MediaID = derefString(mediaID)
+	msg.Payload = decodeMessagePayload(payloadBytes)
+	return msg, nil
 }
 
-func (r *messageRepo) DeleteByInstanceID(ctx context.Context, instanceID string) error {
-	query := `DELETE FROM message_queue WHERE instance_id = $1`
-	_, err := r.db.Pool.Exec(ctx, query, instanceID)
-	return err
+func decodeMessagePayload(payloadBytes []byte) string {
+	var payloadMap map[string]interface{}
+	if err := json.Unmarshal(payloadBytes, &payloadMap); err == nil {
+		if text, ok := payloadMap["text"].(string); ok {
+			return text
+		}
+	}
+	return string(payloadBytes)
+}
+
+func derefString(v *string) string {
+	if v == nil {
+		return ""
+	}
+	return *v
 }
@@ -0,0 +1,73 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/open-apime/apime/internal/storage/model"
+)
+
+type messageStatusEventRepo struct {
+	db *DB
+}
+
+func NewMessageStatusEventRepository(db *DB) *messageStatusEventRepo {
+	return &messageStatusEventRepo{db: db}
+}
+
+func (r *messageStatusEventRepo) Create(ctx context.Context, event model.MessageStatusEvent) error {
+	if event.ID == "" {
+		event.ID = uuid.New().String()
+	}
+	if event.OccurredAt.IsZero() {
+		event.OccurredAt = time.Now()
+	}
+
+	query := `
+		INSERT INTO message_status_events (id, instance_id, message_id, chat_jid, participant, status, occurred_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, NOW())
+		ON CONFLICT (instance_id, message_id, participant, status) DO NOTHING
+	`
+	_, err := r.db.Pool.Exec(ctx, query,
+		event.ID,
+		event.InstanceID,
+		event.MessageID,
+		nullIfEmpty(event.ChatJID),
+		event.Participant,
+		event.Status,
+		event.OccurredAt,
+	)
+	return err
+}
+
+func (r *messageStatusEventRepo) ListByMessage(ctx context.Context, instanceID, messageID string) ([]model.MessageStatusEvent, error) {
+	query := `
+		SELECT id, instance_id, message_id, chat_jid, participant, status, occurred_at, created_at
+		FROM message_status_events
+		WHERE instance_id = $1 AND message_id = $2
+		ORDER BY occurred_at ASC, created_at ASC
+	`
+
+	rows, err := r.db.Pool.Query(ctx, query, instanceID, messageID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []model.MessageStatusEvent
+	for rows.Next() {
+		var event model.MessageStatusEvent
+		var chatJID *string
+
+		if err := rows.Scan(&event.ID, &event.InstanceID, &event.MessageID, &chatJID, &event.Participant, &event.Status, &event.OccurredAt, &event.CreatedAt); err != nil {
+			return nil, err
+		}
+
+		event.ChatJID = derefString(chatJID)
+		events = append(events, event)
+	}
+
+	return events, rows.Err()
+}
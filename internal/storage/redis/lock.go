@@ -17,7 +17,9 @@ type Lock struct {
 }
 
 func (l *Lock) Acquire(ctx context.Context) (bool, error) {
-	l.value = uuid.New().String()
+	if l.value == "" {
+		l.value = uuid.New().String()
+	}
 	acquired, err := l.client.rdb.SetNX(ctx, l.key, l.value, l.ttl).Result()
 	if err != nil {
 		return false, fmt.Errorf("lock acquire: %w", err)
@@ -25,6 +27,23 @@ func (l *Lock) Acquire(ctx context.Context) (bool, error) {
 	return acquired, nil
 }
 
+// Refresh renova o TTL do lock se ele ainda pertencer a este detentor.
+// Retorna false quando o lock expirou ou foi assumido por outro detentor.
+func (l *Lock) Refresh(ctx context.Context) (bool, error) {
+	script := `
+		if redis.call("get", KEYS[1]) == ARGV[1] then
+			return redis.call("pexpire", KEYS[1], ARGV[2])
+		else
+			return 0
+		end
+	`
+	res, err := l.client.rdb.Eval(ctx, script, []string{l.key}, l.value, l.ttl.Milliseconds()).Int()
+	if err != nil && err != redis.Nil {
+		return false, fmt.Errorf("lock refresh: %w", err)
+	}
+	return res == 1, nil
+}
+
 func (l *Lock) Release(ctx context.Context) error {
 	script := `
 		if redis.call("get", KEYS[1]) == ARGV[1] then
@@ -40,6 +59,18 @@ func (l *Lock) Release(ctx context.Context) error {
 	return nil
 }
 
+// Holder retorna o valor gravado no lock, ou vazio quando ele está livre.
+func (l *Lock) Holder(ctx context.Context) (string, error) {
+	holder, err := l.client.rdb.Get(ctx, l.key).Result()
+	if err == redis.Nil {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("lock holder: %w", err)
+	}
+	return holder, nil
+}
+
 func NewLock(client *Client, key string, ttl time.Duration) *Lock {
 	return &Lock{
 		client: client,
@@ -47,3 +78,14 @@ func NewLock(client *Client, key string, ttl time.Duration) *Lock {
 		ttl:    ttl,
 	}
 }
+
+// NewOwnedLock cria um lock cujo valor identifica o detentor, permitindo que
+// outros processos descubram quem o possui via Holder.
+func NewOwnedLock(client *Client, key, owner string, ttl time.Duration) *Lock {
+	return &Lock{
+		client: client,
+		key:    key,
+		value:  owner,
+		ttl:    ttl,
+	}
+}
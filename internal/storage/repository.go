@@ -17,15 +17,31 @@ type InstanceRepository interface {
 	List(ctx context.Context) ([]model.Instance, error)
 	ListByOwner(ctx context.Context, ownerUserID string) ([]model.Instance, error)
 	Update(ctx context.Context, instance model.Instance) (model.Instance, error)
+	// SwapSessionBlob troca o SessionBlob da instância por blob apenas se o
+	// gravado ainda for old, sem tocar nos demais campos. Retorna false
+	// quando o blob mudou desde a leitura.
+	SwapSessionBlob(ctx context.Context, id string, old, blob []byte) (bool, error)
 	Delete(ctx context.Context, id string) error
 }
 
 type MessageRepository interface {
 	Create(ctx context.Context, message model.Message) (model.Message, error)
-	ListByInstance(ctx context.Context, instanceID string) ([]model.Message, error)
+	// CreateIfNotExists grava a mensagem apenas se ainda não houver outra com o
+	// mesmo WhatsAppID na instância. Retorna true quando a mensagem foi criada.
+	CreateIfNotExists(ctx context.Context, message model.Message) (model.Message, bool, error)
+	// ListByInstance pagina as mensagens da instância, das mais recentes para
+	// as mais antigas, a partir de before (exclusivo) quando informado.
+	ListByInstance(ctx context.Context, instanceID string, before *time.Time, limit int) ([]model.Message, error)
+	// ListPendingOutbound retorna os envios da API criados desde since que
+	// ainda aguardam confirmação (status sent, retry ou delivered), dos mais
+	// antigos para os mais recentes.
+	ListPendingOutbound(ctx context.Context, instanceID string, since time.Time, limit int) ([]model.Message, error)
+	ListByChat(ctx context.Context, instanceID, chatJID string, before *time.Time, limit int) ([]model.Message, error)
 	Update(ctx context.Context, msg model.Message) error
 	UpdateStatusByWhatsAppID(ctx context.Context, whatsappID string, status string) error
 	GetByWhatsAppID(ctx context.Context, whatsappID string) (model.Message, error)
+	// GetByInstanceAndID busca a mensagem da instância pelo ID interno ou pelo ID do WhatsApp.
+	GetByInstanceAndID(ctx context.Context, instanceID, id string) (model.Message, error)
 	GetPendingMessages(ctx context.Context, limit int) ([]model.Message, error)
 	DeleteByInstanceID(ctx context.Context, instanceID string) error
 }
@@ -64,6 +80,8 @@ type HistorySyncRepository interface {
 	ListPendingByInstance(ctx context.Context, instanceID string) ([]model.WhatsappHistorySync, error)
 	ListPendingByCycle(ctx context.Context, instanceID, cycleID string) ([]model.WhatsappHistorySync, error)
 	UpdateStatus(ctx context.Context, id string, status model.HistorySyncPayloadStatus, processedAt *time.Time) error
+	UpdateResult(ctx context.Context, id string, status model.HistorySyncPayloadStatus, processedAt *time.Time, messagesImported int) error
+	GetCycleProgress(ctx context.Context, instanceID, cycleID string) (model.HistorySyncProgress, error)
 	DeleteByInstance(ctx context.Context, instanceID string) error
 }
 
@@ -71,3 +89,73 @@ type ContactRepository interface {
 	Upsert(ctx context.Context, contact model.Contact) error
 	GetByPhone(ctx context.Context, phone string) (model.Contact, error)
 }
+
+type ChatRepository interface {
+	// UpsertLastMessage cria ou atualiza a conversa com a mensagem informada,
+	// somando unreadDelta ao contador de não lidas.
+	UpsertLastMessage(ctx context.Context, instanceID, jid, name string, msg model.Message, unreadDelta int) error
+	MarkRead(ctx context.Context, instanceID, jid string) error
+	ListByInstance(ctx context.Context, instanceID string) ([]model.Chat, error)
+	// LastActivityByInstance retorna a data da mensagem mais recente de cada
+	// instância com conversas.
+	LastActivityByInstance(ctx context.Context) (map[string]time.Time, error)
+}
+
+// MessageStatusEventRepository guarda o histórico de confirmações das mensagens.
+type MessageStatusEventRepository interface {
+	// Create ignora confirmações repetidas do mesmo participante e status.
+	Create(ctx context.Context, event model.MessageStatusEvent) error
+	ListByMessage(ctx context.Context, instanceID, messageID string) ([]model.MessageStatusEvent, error)
+}
+
+type InstanceProxyRepository interface {
+	Get(ctx context.Context, instanceID string) (model.InstanceProxy, error)
+	Upsert(ctx context.Context, proxy model.InstanceProxy) error
+	Delete(ctx context.Context, instanceID string) error
+}
+
+// LIDMappingRepository guarda as associações entre LID e número aprendidas
+// pelas sessões, para que os webhooks tragam sempre o número do contato.
+type LIDMappingRepository interface {
+	Upsert(ctx context.Context, mapping model.LIDMapping) error
+	GetByLID(ctx context.Context, instanceID, lid string) (model.LIDMapping, error)
+}
+
+// MediaRefRepository guarda as referências das mídias ainda não baixadas,
+// usadas no download sob demanda.
+type MediaRefRepository interface {
+	Create(ctx context.Context, ref model.MediaRef) error
+	Get(ctx context.Context, instanceID, mediaID string) (model.MediaRef, error)
+}
+
+// MediaPolicyRepository guarda a retenção e a cota de mídia de cada instância.
+type MediaPolicyRepository interface {
+	Get(ctx context.Context, instanceID string) (model.MediaPolicy, error)
+	List(ctx context.Context) ([]model.MediaPolicy, error)
+	Upsert(ctx context.Context, policy model.MediaPolicy) error
+	Delete(ctx context.Context, instanceID string) error
+}
+
+// InstanceCloudAPIRepository guarda as credenciais das instâncias da Cloud API.
+type InstanceCloudAPIRepository interface {
+	Get(ctx context.Context, instanceID string) (model.InstanceCloudAPI, error)
+	Upsert(ctx context.Context, cfg model.InstanceCloudAPI) error
+	Delete(ctx context.Context, instanceID string) error
+}
+
+// InstanceStateTransitionRepository guarda o histórico da máquina de estados
+// de conexão das instâncias.
+type InstanceStateTransitionRepository interface {
+	Create(ctx context.Context, transition model.InstanceStateTransition) error
+	// ListByInstance retorna as transições mais recentes primeiro.
+	ListByInstance(ctx context.Context, instanceID string, limit int) ([]model.InstanceStateTransition, error)
+}
+
+type AlertRuleRepository interface {
+	Create(ctx context.Context, rule model.AlertRule) (model.AlertRule, error)
+	GetByID(ctx context.Context, id string) (model.AlertRule, error)
+	List(ctx context.Context) ([]model.AlertRule, error)
+	ListByUser(ctx context.Context, userID string) ([]model.AlertRule, error)
+	Update(ctx context.Context, rule model.AlertRule) (model.AlertRule, error)
+	Delete(ctx context.Context, id string) error
+}
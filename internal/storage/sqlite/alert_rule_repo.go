@@ -0,0 +1,142 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/open-apime/apime/internal/storage/model"
+)
+
+type alertRuleRepo struct {
+	db *DB
+}
+
+func NewAlertRuleRepository(db *DB) *alertRuleRepo {
+	return &alertRuleRepo{db: db}
+}
+
+const alertRuleColumns = `id, user_id, instance_id, type, threshold, channel, target, cooldown_seconds, enabled, created_at, updated_at`
+
+type alertRuleScanner interface {
+	Scan(dest ...any) error
+}
+
+func (r *alertRuleRepo) Create(ctx context.Context, rule model.AlertRule) (model.AlertRule, error) {
+	if rule.ID == "" {
+		rule.ID = uuid.New().String()
+	}
+	now := time.Now()
+	rule.CreatedAt = now
+	rule.UpdatedAt = now
+
+	query := `
+		INSERT INTO alert_rules (` + alertRuleColumns + `)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+	_, err := r.db.Conn.ExecContext(ctx, query,
+		rule.ID, rule.UserID, nullableString(rule.InstanceID), string(rule.Type), rule.Threshold,
+		string(rule.Channel), rule.Target, rule.CooldownSeconds, rule.Enabled,
+		rule.CreatedAt.Format(time.RFC3339), rule.UpdatedAt.Format(time.RFC3339),
+	)
+	if err != nil {
+		return model.AlertRule{}, err
+	}
+	return rule, nil
+}
+
+func (r *alertRuleRepo) GetByID(ctx context.Context, id string) (model.AlertRule, error) {
+	query := `SELECT ` + alertRuleColumns + ` FROM alert_rules WHERE id = ?`
+	return scanAlertRule(r.db.Conn.QueryRowContext(ctx, query, id))
+}
+
+func (r *alertRuleRepo) List(ctx context.Context) ([]model.AlertRule, error) {
+	query := `SELECT ` + alertRuleColumns + ` FROM alert_rules ORDER BY created_at DESC`
+	return r.list(ctx, query)
+}
+
+func (r *alertRuleRepo) ListByUser(ctx context.Context, userID string) ([]model.AlertRule, error) {
+	query := `SELECT ` + alertRuleColumns + ` FROM alert_rules WHERE user_id = ? ORDER BY created_at DESC`
+	return r.list(ctx, query, userID)
+}
+
+func (r *alertRuleRepo) list(ctx context.Context, query string, args ...any) ([]model.AlertRule, error) {
+	rows, err := r.db.Conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rules := make([]model.AlertRule, 0)
+	for rows.Next() {
+		rule, err := scanAlertRule(rows)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, rows.Err()
+}
+
+func (r *alertRuleRepo) Update(ctx context.Context, rule model.AlertRule) (model.AlertRule, error) {
+	rule.UpdatedAt = time.Now()
+
+	query := `
+		UPDATE alert_rules
+		SET instance_id = ?, type = ?, threshold = ?, channel = ?, target = ?,
+			cooldown_seconds = ?, enabled = ?, updated_at = ?
+		WHERE id = ?
+	`
+	result, err := r.db.Conn.ExecContext(ctx, query,
+		nullableString(rule.InstanceID), string(rule.Type), rule.Threshold, string(rule.Channel),
+		rule.Target, rule.CooldownSeconds, rule.Enabled, rule.UpdatedAt.Format(time.RFC3339), rule.ID,
+	)
+	if err != nil {
+		return model.AlertRule{}, err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return model.AlertRule{}, ErrNotFound
+	}
+	return r.GetByID(ctx, rule.ID)
+}
+
+func (r *alertRuleRepo) Delete(ctx context.Context, id string) error {
+	result, err := r.db.Conn.ExecContext(ctx, `DELETE FROM alert_rules WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func scanAlertRule(row alertRuleScanner) (model.AlertRule, error) {
+	var rule model.AlertRule
+	var instanceID sql.NullString
+	var ruleType, channel, createdAt, updatedAt string
+
+	err := row.Scan(
+		&rule.ID, &rule.UserID, &instanceID, &ruleType, &rule.Threshold, &channel,
+		&rule.Target, &rule.CooldownSeconds, &rule.Enabled, &createdAt, &updatedAt,
+	)
+	if err != nil {
+		return model.AlertRule{}, mapError(err)
+	}
+
+	rule.InstanceID = instanceID.String
+	rule.Type = model.AlertType(ruleType)
+	rule.Channel = model.AlertChannel(channel)
+	rule.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+	rule.UpdatedAt, _ = time.Parse(time.RFC3339, updatedAt)
+	return rule, nil
+}
+
+func nullableString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
@@ -0,0 +1,147 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/open-apime/apime/internal/storage/model"
+)
+
+type chatRepo struct {
+	db *DB
+}
+
+func NewChatRepository(db *DB) *chatRepo {
+	return &chatRepo{db: db}
+}
+
+func (r *chatRepo) UpsertLastMessage(ctx context.Context, instanceID, jid, name string, msg model.Message, unreadDelta int) error {
+	// As datas são gravadas em UTC: last_message_at é comparado como texto,
+	// e o RFC3339 só ordena corretamente com o mesmo fuso.
+	now := time.Now().UTC().Format(time.RFC3339)
+	msgAt := msg.CreatedAt.UTC().Format(time.RFC3339)
+
+	// A última mensagem só é substituída quando a nova é mais recente, o que
+	// mantém a conversa correta ao importar mensagens antigas.
+	query := `
+		INSERT INTO chats (instance_id, jid, name, last_message_id, last_message_at, unread_count, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(instance_id, jid) DO UPDATE SET
+			name = COALESCE(excluded.name, chats.name),
+			last_message_id = CASE
+				WHEN chats.last_message_at IS NULL OR excluded.last_message_at >= chats.last_message_at THEN excluded.last_message_id
+				ELSE chats.last_message_id
+			END,
+			last_message_at = CASE
+				WHEN chats.last_message_at IS NULL OR excluded.last_message_at >= chats.last_message_at THEN excluded.last_message_at
+				ELSE chats.last_message_at
+			END,
+			unread_count = chats.unread_count + excluded.unread_count,
+			updated_at = excluded.updated_at
+	`
+	_, err := r.db.Conn.ExecContext(ctx, query, instanceID, jid, nullIfEmpty(name), msg.ID, msgAt, unreadDelta, now, now)
+	return err
+}
+
+func (r *chatRepo) MarkRead(ctx context.Context, instanceID, jid string) error {
+	query := `UPDATE chats SET unread_count = 0, updated_at = ? WHERE instance_id = ? AND jid = ?`
+	_, err := r.db.Conn.ExecContext(ctx, query, time.Now().UTC().Format(time.RFC3339), instanceID, jid)
+	return err
+}
+
+func (r *chatRepo) ListByInstance(ctx context.Context, instanceID string) ([]model.Chat, error) {
+	query := `
+		SELECT c.instance_id, c.jid, c.name, c.unread_count, c.last_message_at, c.created_at, c.updated_at,
+			m.id, m.instance_id, m.whatsapp_id, m.direction, m.chat_jid, m.sender, m.recipient, m.type, m.payload,
+			m.quoted_id, m.media_id, m.status, m.delivered_at, m.created_at
+		FROM chats c
+		LEFT JOIN message_queue m ON m.id = c.last_message_id
+		WHERE c.instance_id = ?
+		ORDER BY c.last_message_at DESC
+	`
+
+	rows, err := r.db.Conn.QueryContext(ctx, query, instanceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var chats []model.Chat
+	for rows.Next() {
+		var chat model.Chat
+		var name, lastMessageAt sql.NullString
+		var createdAt, updatedAt string
+		var msgID, msgInstanceID, whatsappID, direction, chatJID, sender, recipient, msgType, payload sql.NullString
+		var quotedID, mediaID, status, deliveredAt, msgCreatedAt sql.NullString
+
+		if err := rows.Scan(
+			&chat.InstanceID, &chat.JID, &name, &chat.UnreadCount, &lastMessageAt, &createdAt, &updatedAt,
+			&msgID, &msgInstanceID, &whatsappID, &direction, &chatJID, &sender, &recipient, &msgType, &payload,
+			&quotedID, &mediaID, &status, &deliveredAt, &msgCreatedAt,
+		); err != nil {
+			return nil, err
+		}
+
+		chat.Name = name.String
+		if lastMessageAt.Valid {
+			chat.LastMessageAt = parseTimePtr(lastMessageAt.String)
+		}
+		chat.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+		chat.UpdatedAt, _ = time.Parse(time.RFC3339, updatedAt)
+
+		if msgID.Valid {
+			last := model.Message{
+				ID:         msgID.String,
+				InstanceID: msgInstanceID.String,
+				WhatsAppID: whatsappID.String,
+				Direction:  model.MessageDirection(direction.String),
+				ChatJID:    chatJID.String,
+				Sender:     sender.String,
+				To:         recipient.String,
+				Type:       msgType.String,
+				Payload:    decodeMessagePayload(payload.String),
+				QuotedID:   quotedID.String,
+				MediaID:    mediaID.String,
+				Status:     status.String,
+			}
+			if deliveredAt.Valid {
+				last.DeliveredAt = parseTimePtr(deliveredAt.String)
+			}
+			last.CreatedAt, _ = time.Parse(time.RFC3339, msgCreatedAt.String)
+			chat.LastMessage = &last
+		}
+
+		chats = append(chats, chat)
+	}
+
+	return chats, rows.Err()
+}
+
+func (r *chatRepo) LastActivityByInstance(ctx context.Context) (map[string]time.Time, error) {
+	query := `
+		SELECT instance_id, MAX(last_message_at)
+		FROM chats
+		WHERE last_message_at IS NOT NULL
+		GROUP BY instance_id
+	`
+
+	rows, err := r.db.Conn.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	activity := make(map[string]time.Time)
+	for rows.Next() {
+		var instanceID, lastMessageAt string
+		if err := rows.Scan(&instanceID, &lastMessageAt); err != nil {
+			return nil, err
+		}
+		if t := parseTimePtr(lastMessageAt); t != nil {
+			activity[instanceID] = *t
+		}
+	}
+
+	return activity, rows.Err()
+}
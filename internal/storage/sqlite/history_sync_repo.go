@@ -93,6 +93,43 @@ func (r *historySyncRepo) UpdateStatus(ctx context.Context, id string, status mo
     return nil
 }
 
+func (r *historySyncRepo) UpdateResult(ctx context.Context, id string, status model.HistorySyncPayloadStatus, processedAt *time.Time, messagesImported int) error {
+    query := `UPDATE whatsapp_history_syncs
+        SET status = ?, processed_at = ?, messages_imported = ?
+        WHERE id = ?`
+
+    result, err := r.db.Conn.ExecContext(ctx, query, string(status), formatTimePtr(processedAt), messagesImported, id)
+    if err != nil {
+        return err
+    }
+    if rows, _ := result.RowsAffected(); rows == 0 {
+        return sql.ErrNoRows
+    }
+    return nil
+}
+
+func (r *historySyncRepo) GetCycleProgress(ctx context.Context, instanceID, cycleID string) (model.HistorySyncProgress, error) {
+    query := `SELECT
+            COUNT(*),
+            COALESCE(SUM(CASE WHEN status = 'done' THEN 1 ELSE 0 END), 0),
+            COALESCE(SUM(CASE WHEN status = 'error' THEN 1 ELSE 0 END), 0),
+            COALESCE(SUM(messages_imported), 0)
+        FROM whatsapp_history_syncs
+        WHERE instance_id = ? AND COALESCE(cycle_id, '') = ?`
+
+    progress := model.HistorySyncProgress{CycleID: cycleID}
+    err := r.db.Conn.QueryRowContext(ctx, query, instanceID, cycleID).Scan(
+        &progress.ChunksTotal,
+        &progress.ChunksDone,
+        &progress.ChunksFailed,
+        &progress.MessagesImported,
+    )
+    if err != nil {
+        return model.HistorySyncProgress{}, err
+    }
+    return progress, nil
+}
+
 func (r *historySyncRepo) DeleteByInstance(ctx context.Context, instanceID string) error {
     _, err := r.db.Conn.ExecContext(ctx, `DELETE FROM whatsapp_history_syncs WHERE instance_id = ?`, instanceID)
     return err
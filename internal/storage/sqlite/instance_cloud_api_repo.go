@@ -0,0 +1,68 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/open-apime/apime/internal/storage/model"
+)
+
+type instanceCloudAPIRepo struct {
+	db *DB
+}
+
+func NewInstanceCloudAPIRepository(db *DB) *instanceCloudAPIRepo {
+	return &instanceCloudAPIRepo{db: db}
+}
+
+func (r *instanceCloudAPIRepo) Get(ctx context.Context, instanceID string) (model.InstanceCloudAPI, error) {
+	query := `
+		SELECT instance_id, phone_number_id, business_account_id, secrets_enc, verify_token, created_at, updated_at
+		FROM instance_cloud_api
+		WHERE instance_id = ?
+	`
+
+	var cfg model.InstanceCloudAPI
+	var businessAccountID sql.NullString
+	var createdAt, updatedAt string
+
+	err := r.db.Conn.QueryRowContext(ctx, query, instanceID).Scan(
+		&cfg.InstanceID, &cfg.PhoneNumberID, &businessAccountID, &cfg.SecretsEnc,
+		&cfg.VerifyToken, &createdAt, &updatedAt,
+	)
+	if err != nil {
+		return model.InstanceCloudAPI{}, mapError(err)
+	}
+
+	cfg.BusinessAccountID = businessAccountID.String
+	cfg.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+	cfg.UpdatedAt, _ = time.Parse(time.RFC3339, updatedAt)
+
+	return cfg, nil
+}
+
+func (r *instanceCloudAPIRepo) Upsert(ctx context.Context, cfg model.InstanceCloudAPI) error {
+	now := time.Now().Format(time.RFC3339)
+
+	query := `
+		INSERT INTO instance_cloud_api (instance_id, phone_number_id, business_account_id, secrets_enc, verify_token, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(instance_id) DO UPDATE SET
+			phone_number_id = excluded.phone_number_id,
+			business_account_id = excluded.business_account_id,
+			secrets_enc = excluded.secrets_enc,
+			verify_token = excluded.verify_token,
+			updated_at = excluded.updated_at
+	`
+	_, err := r.db.Conn.ExecContext(ctx, query,
+		cfg.InstanceID, cfg.PhoneNumberID, nullIfEmpty(cfg.BusinessAccountID), cfg.SecretsEnc,
+		cfg.VerifyToken, now, now,
+	)
+	return err
+}
+
+func (r *instanceCloudAPIRepo) Delete(ctx context.Context, instanceID string) error {
+	_, err := r.db.Conn.ExecContext(ctx, `DELETE FROM instance_cloud_api WHERE instance_id = ?`, instanceID)
+	return err
+}
@@ -0,0 +1,66 @@
+package sqlite
+
+import (
+	"context"
+	"time"
+
+	"github.com/open-apime/apime/internal/storage/model"
+)
+
+type instanceProxyRepo struct {
+	db *DB
+}
+
+func NewInstanceProxyRepository(db *DB) *instanceProxyRepo {
+	return &instanceProxyRepo{db: db}
+}
+
+func (r *instanceProxyRepo) Get(ctx context.Context, instanceID string) (model.InstanceProxy, error) {
+	query := `
+		SELECT instance_id, type, host, port, credentials_enc, created_at, updated_at
+		FROM instance_proxies
+		WHERE instance_id = ?
+	`
+
+	var proxy model.InstanceProxy
+	var proxyType, createdAt, updatedAt string
+
+	err := r.db.Conn.QueryRowContext(ctx, query, instanceID).Scan(
+		&proxy.InstanceID, &proxyType, &proxy.Host, &proxy.Port,
+		&proxy.CredentialsEnc, &createdAt, &updatedAt,
+	)
+	if err != nil {
+		return model.InstanceProxy{}, mapError(err)
+	}
+
+	proxy.Type = model.ProxyType(proxyType)
+	proxy.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+	proxy.UpdatedAt, _ = time.Parse(time.RFC3339, updatedAt)
+
+	return proxy, nil
+}
+
+func (r *instanceProxyRepo) Upsert(ctx context.Context, proxy model.InstanceProxy) error {
+	now := time.Now().Format(time.RFC3339)
+
+	query := `
+		INSERT INTO instance_proxies (instance_id, type, host, port, credentials_enc, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(instance_id) DO UPDATE SET
+			type = excluded.type,
+			host = excluded.host,
+			port = excluded.port,
+			credentials_enc = excluded.credentials_enc,
+			updated_at = excluded.updated_at
+	`
+	_, err := r.db.Conn.ExecContext(ctx, query,
+		proxy.InstanceID, string(proxy.Type), proxy.Host, proxy.Port,
+		proxy.CredentialsEnc, now, now,
+	)
+	return err
+}
+
+func (r *instanceProxyRepo) Delete(ctx context.Context, instanceID string) error {
+	_, err := r.db.Conn.ExecContext(ctx, `DELETE FROM instance_proxies WHERE instance_id = ?`, instanceID)
+	return err
+}
@@ -28,17 +28,23 @@ func (r *instanceRepo) Create(ctx context.Context, inst model.Instance) (model.I
 	if inst.HistorySyncStatus == "" {
 		inst.HistorySyncStatus = model.HistorySyncStatusPending
 	}
+	if inst.Provider == "" {
+		inst.Provider = model.InstanceProviderWhatsmeow
+	}
+	if inst.MediaDownload == "" {
+		inst.MediaDownload = model.MediaDownloadLazy
+	}
 
 	query := `
-		INSERT INTO instances (id, name, owner_user_id, whatsapp_jid, status, session_blob, webhook_url, webhook_secret, instance_token_hash, instance_token_updated_at, history_sync_status, history_sync_cycle_id, history_sync_updated_at, meta_compatible, created_at, updated_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		INSERT INTO instances (id, name, owner_user_id, whatsapp_jid, status, session_blob, webhook_url, webhook_secret, instance_token_hash, instance_token_updated_at, history_sync_status, history_sync_cycle_id, history_sync_updated_at, meta_compatible, provider, media_download, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
 
 	_, err := r.db.Conn.ExecContext(ctx, query,
 		inst.ID, inst.Name, inst.OwnerUserID, nullIfEmpty(inst.WhatsAppJID), string(inst.Status), inst.SessionBlob,
 		nullIfEmpty(inst.WebhookURL), nullIfEmpty(inst.WebhookSecret), nullIfEmpty(inst.TokenHash),
 		formatTimePtr(inst.TokenUpdatedAt), string(inst.HistorySyncStatus), nullIfEmpty(inst.HistorySyncCycleID), formatTimePtr(inst.HistorySyncUpdatedAt),
-		inst.MetaCompatible,
+		inst.MetaCompatible, string(inst.Provider), string(inst.MediaDownload),
 		inst.CreatedAt.Format(time.RFC3339), inst.UpdatedAt.Format(time.RFC3339),
 	)
 
@@ -52,7 +58,7 @@ func (r *instanceRepo) Create(ctx context.Context, inst model.Instance) (model.I
 func (r *instanceRepo) GetByTokenHash(ctx context.Context, tokenHash string) (model.Instance, error) {
 	query := `
 		SELECT id, name, owner_user_id, COALESCE(whatsapp_jid, ''), status, session_blob, COALESCE(webhook_url, ''), COALESCE(webhook_secret, ''), COALESCE(instance_token_hash, ''), instance_token_updated_at,
-		       history_sync_status, COALESCE(history_sync_cycle_id, ''), history_sync_updated_at, meta_compatible, created_at, updated_at
+		       history_sync_status, COALESCE(history_sync_cycle_id, ''), history_sync_updated_at, meta_compatible, provider, media_download, created_at, updated_at
 		FROM instances
 		WHERE instance_token_hash = ?
 	`
@@ -64,7 +70,7 @@ func (r *instanceRepo) GetByTokenHash(ctx context.Context, tokenHash string) (mo
 	err := r.db.Conn.QueryRowContext(ctx, query, tokenHash).Scan(
 		&inst.ID, &inst.Name, &inst.OwnerUserID, &inst.WhatsAppJID, &inst.Status, &inst.SessionBlob,
 		&inst.WebhookURL, &inst.WebhookSecret, &inst.TokenHash, &tokenUpdatedAt,
-		&inst.HistorySyncStatus, &inst.HistorySyncCycleID, &historySyncUpdatedAt, &inst.MetaCompatible,
+		&inst.HistorySyncStatus, &inst.HistorySyncCycleID, &historySyncUpdatedAt, &inst.MetaCompatible, &inst.Provider, &inst.MediaDownload,
 		&createdAt, &updatedAt,
 	)
 	if err != nil {
@@ -82,7 +88,7 @@ func (r *instanceRepo) GetByTokenHash(ctx context.Context, tokenHash string) (mo
 func (r *instanceRepo) GetByID(ctx context.Context, id string) (model.Instance, error) {
 	query := `
 		SELECT id, name, owner_user_id, COALESCE(whatsapp_jid, ''), status, session_blob, COALESCE(webhook_url, ''), COALESCE(webhook_secret, ''), COALESCE(instance_token_hash, ''), instance_token_updated_at,
-		       history_sync_status, COALESCE(history_sync_cycle_id, ''), history_sync_updated_at, meta_compatible, created_at, updated_at
+		       history_sync_status, COALESCE(history_sync_cycle_id, ''), history_sync_updated_at, meta_compatible, provider, media_download, created_at, updated_at
 		FROM instances
 		WHERE id = ?
 	`
@@ -94,7 +100,7 @@ func (r *instanceRepo) GetByID(ctx context.Context, id string) (model.Instance,
 	err := r.db.Conn.QueryRowContext(ctx, query, id).Scan(
 		&inst.ID, &inst.Name, &inst.OwnerUserID, &inst.WhatsAppJID, &inst.Status, &inst.SessionBlob,
 		&inst.WebhookURL, &inst.WebhookSecret, &inst.TokenHash, &tokenUpdatedAt,
-		&inst.HistorySyncStatus, &inst.HistorySyncCycleID, &historySyncUpdatedAt, &inst.MetaCompatible,
+		&inst.HistorySyncStatus, &inst.HistorySyncCycleID, &historySyncUpdatedAt, &inst.MetaCompatible, &inst.Provider, &inst.MediaDownload,
 		&createdAt, &updatedAt,
 	)
 	if err != nil {
@@ -112,7 +118,7 @@ func (r *instanceRepo) GetByID(ctx context.Context, id string) (model.Instance,
 func (r *instanceRepo) List(ctx context.Context) ([]model.Instance, error) {
 	query := `
 		SELECT i.id, i.name, i.owner_user_id, COALESCE(u.email, ''), COALESCE(i.whatsapp_jid, ''), i.status, COALESCE(i.webhook_url, ''), COALESCE(i.webhook_secret, ''), COALESCE(i.instance_token_hash, ''), i.instance_token_updated_at,
-		       i.history_sync_status, COALESCE(i.history_sync_cycle_id, ''), i.history_sync_updated_at, i.meta_compatible, i.created_at, i.updated_at
+		       i.history_sync_status, COALESCE(i.history_sync_cycle_id, ''), i.history_sync_updated_at, i.meta_compatible, i.provider, i.media_download, i.created_at, i.updated_at
 		FROM instances i
 		LEFT JOIN users u ON i.owner_user_id = u.id
 		ORDER BY i.created_at DESC
@@ -133,7 +139,7 @@ func (r *instanceRepo) List(ctx context.Context) ([]model.Instance, error) {
 		if err := rows.Scan(
 			&inst.ID, &inst.Name, &inst.OwnerUserID, &inst.OwnerEmail, &inst.WhatsAppJID, &inst.Status,
 			&inst.WebhookURL, &inst.WebhookSecret, &inst.TokenHash, &tokenUpdatedAt,
-			&inst.HistorySyncStatus, &inst.HistorySyncCycleID, &historySyncUpdatedAt, &inst.MetaCompatible,
+			&inst.HistorySyncStatus, &inst.HistorySyncCycleID, &historySyncUpdatedAt, &inst.MetaCompatible, &inst.Provider, &inst.MediaDownload,
 			&createdAt, &updatedAt,
 		); err != nil {
 			return nil, err
@@ -153,7 +159,7 @@ func (r *instanceRepo) List(ctx context.Context) ([]model.Instance, error) {
 func (r *instanceRepo) ListByOwner(ctx context.Context, ownerUserID string) ([]model.Instance, error) {
 	query := `
 		SELECT i.id, i.name, i.owner_user_id, COALESCE(u.email, ''), COALESCE(i.whatsapp_jid, ''), i.status, COALESCE(i.webhook_url, ''), COALESCE(i.webhook_secret, ''), COALESCE(i.instance_token_hash, ''), i.instance_token_updated_at,
-		       i.history_sync_status, COALESCE(i.history_sync_cycle_id, ''), i.history_sync_updated_at, i.meta_compatible, i.created_at, i.updated_at
+		       i.history_sync_status, COALESCE(i.history_sync_cycle_id, ''), i.history_sync_updated_at, i.meta_compatible, i.provider, i.media_download, i.created_at, i.updated_at
 		FROM instances i
 		LEFT JOIN users u ON i.owner_user_id = u.id
 		WHERE i.owner_user_id = ?
@@ -175,7 +181,7 @@ func (r *instanceRepo) ListByOwner(ctx context.Context, ownerUserID string) ([]m
 		if err := rows.Scan(
 			&inst.ID, &inst.Name, &inst.OwnerUserID, &inst.OwnerEmail, &inst.WhatsAppJID, &inst.Status,
 			&inst.WebhookURL, &inst.WebhookSecret, &inst.TokenHash, &tokenUpdatedAt,
-			&inst.HistorySyncStatus, &inst.HistorySyncCycleID, &historySyncUpdatedAt, &inst.MetaCompatible,
+			&inst.HistorySyncStatus, &inst.HistorySyncCycleID, &historySyncUpdatedAt, &inst.MetaCompatible, &inst.Provider, &inst.MediaDownload,
 			&createdAt, &updatedAt,
 		); err != nil {
 			return nil, err
@@ -198,7 +204,7 @@ func (r *instanceRepo) Update(ctx context.Context, inst model.Instance) (model.I
 	query := `
 		UPDATE instances
 		SET name = ?, owner_user_id = ?, whatsapp_jid = ?, status = ?, session_blob = ?, webhook_url = ?, webhook_secret = ?, instance_token_hash = ?, instance_token_updated_at = ?,
-		    history_sync_status = ?, history_sync_cycle_id = ?, history_sync_updated_at = ?, meta_compatible = ?, updated_at = ?
+		    history_sync_status = ?, history_sync_cycle_id = ?, history_sync_updated_at = ?, meta_compatible = ?, provider = ?, media_download = ?, updated_at = ?
 		WHERE id = ?
 	`
 
@@ -206,7 +212,7 @@ func (r *instanceRepo) Update(ctx context.Context, inst model.Instance) (model.I
 		inst.Name, inst.OwnerUserID, nullIfEmpty(inst.WhatsAppJID), string(inst.Status), inst.SessionBlob,
 		nullIfEmpty(inst.WebhookURL), nullIfEmpty(inst.WebhookSecret), nullIfEmpty(inst.TokenHash),
 		formatTimePtr(inst.TokenUpdatedAt), string(inst.HistorySyncStatus), nullIfEmpty(inst.HistorySyncCycleID), formatTimePtr(inst.HistorySyncUpdatedAt),
-		inst.MetaCompatible,
+		inst.MetaCompatible, string(inst.Provider), string(inst.MediaDownload),
 		inst.UpdatedAt.Format(time.RFC3339), inst.ID,
 	)
 	if err != nil {
@@ -221,6 +227,21 @@ func (r *instanceRepo) Update(ctx context.Context, inst model.Instance) (model.I
 	return inst, nil
 }
 
+func (r *instanceRepo) SwapSessionBlob(ctx context.Context, id string, old, blob []byte) (bool, error) {
+	result, err := r.db.Conn.ExecContext(ctx,
+		`UPDATE instances SET session_blob = ? WHERE id = ? AND session_blob = ?`,
+		blob, id, old,
+	)
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rows > 0, nil
+}
+
 func nullIfEmpty(v string) *string {
 	if v == "" {
 		return nil
@@ -0,0 +1,75 @@
+package sqlite
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/open-apime/apime/internal/storage/model"
+)
+
+type instanceStateTransitionRepo struct {
+	db *DB
+}
+
+func NewInstanceStateTransitionRepository(db *DB) *instanceStateTransitionRepo {
+	return &instanceStateTransitionRepo{db: db}
+}
+
+func (r *instanceStateTransitionRepo) Create(ctx context.Context, t model.InstanceStateTransition) error {
+	if t.ID == "" {
+		t.ID = uuid.New().String()
+	}
+	if t.CreatedAt.IsZero() {
+		t.CreatedAt = time.Now()
+	}
+
+	query := `
+		INSERT INTO instance_state_transitions (id, instance_id, from_state, to_state, reason, attempt, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`
+	_, err := r.db.Conn.ExecContext(ctx, query,
+		t.ID,
+		t.InstanceID,
+		string(t.From),
+		string(t.To),
+		t.Reason,
+		t.Attempt,
+		t.CreatedAt.Format(time.RFC3339),
+	)
+	return err
+}
+
+func (r *instanceStateTransitionRepo) ListByInstance(ctx context.Context, instanceID string, limit int) ([]model.InstanceStateTransition, error) {
+	query := `
+		SELECT id, instance_id, from_state, to_state, reason, attempt, created_at
+		FROM instance_state_transitions
+		WHERE instance_id = ?
+		ORDER BY created_at DESC, rowid DESC
+		LIMIT ?
+	`
+
+	rows, err := r.db.Conn.QueryContext(ctx, query, instanceID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var transitions []model.InstanceStateTransition
+	for rows.Next() {
+		var t model.InstanceStateTransition
+		var from, to, createdAt string
+
+		if err := rows.Scan(&t.ID, &t.InstanceID, &from, &to, &t.Reason, &t.Attempt, &createdAt); err != nil {
+			return nil, err
+		}
+
+		t.From = model.ConnectionState(from)
+		t.To = model.ConnectionState(to)
+		t.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+		transitions = append(transitions, t)
+	}
+
+	return transitions, rows.Err()
+}
@@ -0,0 +1,54 @@
+package sqlite
+
+import (
+	"context"
+	"time"
+
+	"github.com/open-apime/apime/internal/storage/model"
+)
+
+type lidMappingRepo struct {
+	db *DB
+}
+
+func NewLIDMappingRepository(db *DB) *lidMappingRepo {
+	return &lidMappingRepo{db: db}
+}
+
+func (r *lidMappingRepo) Upsert(ctx context.Context, mapping model.LIDMapping) error {
+	now := time.Now().Format(time.RFC3339)
+
+	query := `
+		INSERT INTO lid_mappings (instance_id, lid, pn, source, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(instance_id, lid) DO UPDATE SET
+			pn = excluded.pn,
+			source = excluded.source,
+			updated_at = excluded.updated_at
+		WHERE lid_mappings.pn <> excluded.pn
+	`
+	_, err := r.db.Conn.ExecContext(ctx, query, mapping.InstanceID, mapping.LID, mapping.PN, string(mapping.Source), now, now)
+	return err
+}
+
+func (r *lidMappingRepo) GetByLID(ctx context.Context, instanceID, lid string) (model.LIDMapping, error) {
+	query := `
+		SELECT instance_id, lid, pn, source, created_at, updated_at
+		FROM lid_mappings
+		WHERE instance_id = ? AND lid = ?
+	`
+
+	var mapping model.LIDMapping
+	var source, createdAt, updatedAt string
+	err := r.db.Conn.QueryRowContext(ctx, query, instanceID, lid).Scan(
+		&mapping.InstanceID, &mapping.LID, &mapping.PN, &source, &createdAt, &updatedAt,
+	)
+	if err != nil {
+		return model.LIDMapping{}, mapError(err)
+	}
+
+	mapping.Source = model.LIDMappingSource(source)
+	mapping.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+	mapping.UpdatedAt, _ = time.Parse(time.RFC3339, updatedAt)
+	return mapping, nil
+}
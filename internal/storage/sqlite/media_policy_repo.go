@@ -0,0 +1,84 @@
+package sqlite
+
+import (
+	"context"
+	"time"
+
+	"github.com/open-apime/apime/internal/storage/model"
+)
+
+type mediaPolicyRepo struct {
+	db *DB
+}
+
+func NewMediaPolicyRepository(db *DB) *mediaPolicyRepo {
+	return &mediaPolicyRepo{db: db}
+}
+
+func (r *mediaPolicyRepo) Get(ctx context.Context, instanceID string) (model.MediaPolicy, error) {
+	query := `
+		SELECT instance_id, retention_seconds, quota_bytes, created_at, updated_at
+		FROM media_policies
+		WHERE instance_id = ?
+	`
+
+	var policy model.MediaPolicy
+	var createdAt, updatedAt string
+	err := r.db.Conn.QueryRowContext(ctx, query, instanceID).Scan(
+		&policy.InstanceID, &policy.RetentionSeconds, &policy.QuotaBytes, &createdAt, &updatedAt,
+	)
+	if err != nil {
+		return model.MediaPolicy{}, mapError(err)
+	}
+
+	policy.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+	policy.UpdatedAt, _ = time.Parse(time.RFC3339, updatedAt)
+	return policy, nil
+}
+
+func (r *mediaPolicyRepo) List(ctx context.Context) ([]model.MediaPolicy, error) {
+	query := `
+		SELECT instance_id, retention_seconds, quota_bytes, created_at, updated_at
+		FROM media_policies
+	`
+
+	rows, err := r.db.Conn.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var policies []model.MediaPolicy
+	for rows.Next() {
+		var policy model.MediaPolicy
+		var createdAt, updatedAt string
+		if err := rows.Scan(&policy.InstanceID, &policy.RetentionSeconds, &policy.QuotaBytes, &createdAt, &updatedAt); err != nil {
+			return nil, err
+		}
+		policy.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+		policy.UpdatedAt, _ = time.Parse(time.RFC3339, updatedAt)
+		policies = append(policies, policy)
+	}
+
+	return policies, rows.Err()
+}
+
+func (r *mediaPolicyRepo) Upsert(ctx context.Context, policy model.MediaPolicy) error {
+	now := time.Now().Format(time.RFC3339)
+
+	query := `
+		INSERT INTO media_policies (instance_id, retention_seconds, quota_bytes, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(instance_id) DO UPDATE SET
+			retention_seconds = excluded.retention_seconds,
+			quota_bytes = excluded.quota_bytes,
+			updated_at = excluded.updated_at
+	`
+	_, err := r.db.Conn.ExecContext(ctx, query, policy.InstanceID, policy.RetentionSeconds, policy.QuotaBytes, now, now)
+	return err
+}
+
+func (r *mediaPolicyRepo) Delete(ctx context.Context, instanceID string) error {
+	_, err := r.db.Conn.ExecContext(ctx, `DELETE FROM media_policies WHERE instance_id = ?`, instanceID)
+	return err
+}
@@ -0,0 +1,55 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/open-apime/apime/internal/storage/model"
+)
+
+type mediaRefRepo struct {
+	db *DB
+}
+
+func NewMediaRefRepository(db *DB) *mediaRefRepo {
+	return &mediaRefRepo{db: db}
+}
+
+func (r *mediaRefRepo) Create(ctx context.Context, ref model.MediaRef) error {
+	query := `
+		INSERT INTO media_refs (instance_id, media_id, message_id, media_type, direct_path, media_key, file_enc_sha256, file_sha256, file_length, mimetype, file_name, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(instance_id, media_id) DO NOTHING
+	`
+	_, err := r.db.Conn.ExecContext(ctx, query,
+		ref.InstanceID, ref.MediaID, ref.MessageID, ref.MediaType, ref.DirectPath,
+		ref.MediaKey, ref.FileEncSHA256, ref.FileSHA256, ref.FileLength,
+		ref.MimeType, nullIfEmpty(ref.FileName), time.Now().Format(time.RFC3339),
+	)
+	return err
+}
+
+func (r *mediaRefRepo) Get(ctx context.Context, instanceID, mediaID string) (model.MediaRef, error) {
+	query := `
+		SELECT instance_id, media_id, message_id, media_type, direct_path, media_key, file_enc_sha256, file_sha256, file_length, mimetype, file_name, created_at
+		FROM media_refs
+		WHERE instance_id = ? AND media_id = ?
+	`
+
+	var ref model.MediaRef
+	var fileName sql.NullString
+	var createdAt string
+	err := r.db.Conn.QueryRowContext(ctx, query, instanceID, mediaID).Scan(
+		&ref.InstanceID, &ref.MediaID, &ref.MessageID, &ref.MediaType, &ref.DirectPath,
+		&ref.MediaKey, &ref.FileEncSHA256, &ref.FileSHA256, &ref.FileLength,
+		&ref.MimeType, &fileName, &createdAt,
+	)
+	if err != nil {
+		return model.MediaRef{}, mapError(err)
+	}
+
+	ref.FileName = fileName.String
+	ref.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+	return ref, nil
+}
@@ -10,6 +10,8 @@ import (
 	"github.com/open-apime/apime/internal/storage/model"
 )
 
+const messageColumns = `id, instance_id, whatsapp_id, direction, chat_jid, sender, recipient, type, payload, quoted_id, media_id, status, delivered_at, created_at`
+
 type messageRepo struct {
 	db *DB
 }
@@ -19,84 +21,152 @@ func NewMessageRepository(db *DB) *messageRepo {
 }
 
 func (r *messageRepo) Create(ctx context.Context, msg model.Message) (model.Message, error) {
+	msg, query, args, err := insertMessage(msg, "")
+	if err != nil {
+		return model.Message{}, err
+	}
+	if _, err := r.db.Conn.ExecContext(ctx, query, args...); err != nil {
+		return model.Message{}, err
+	}
+	return msg, nil
+}
+
+// messageDedupConflict é o alvo do índice único parcial de
+// (instance_id, whatsapp_id), usado para gravar cada mensagem do WhatsApp uma
+// única vez.
+const messageDedupConflict = `ON CONFLICT (instance_id, whatsapp_id) WHERE whatsapp_id IS NOT NULL AND whatsapp_id <> '' DO NOTHING`
+
+// CreateIfNotExists grava a mensagem se ainda não houver outra com o mesmo
+// ID do WhatsApp na instância. A verificação é feita pelo índice único, então
+// importações e eventos concorrentes não duplicam a mensagem.
+func (r *messageRepo) CreateIfNotExists(ctx context.Context, msg model.Message) (model.Message, bool, error) {
+	if msg.WhatsAppID == "" {
+		created, err := r.Create(ctx, msg)
+		if err != nil {
+			return model.Message{}, false, err
+		}
+		return created, true, nil
+	}
+
+	msg, query, args, err := insertMessage(msg, messageDedupConflict+` RETURNING id`)
+	if err != nil {
+		return model.Message{}, false, err
+	}
+	var insertedID string
+	err = r.db.Conn.QueryRowContext(ctx, query, args...).Scan(&insertedID)
+	if err == nil {
+		return msg, true, nil
+	}
+	if err != sql.ErrNoRows {
+		return model.Message{}, false, err
+	}
+
+	var existingID string
+	if err := r.db.Conn.QueryRowContext(ctx,
+		`SELECT id FROM message_queue WHERE instance_id = ? AND whatsapp_id = ? LIMIT 1`,
+		msg.InstanceID, msg.WhatsAppID,
+	).Scan(&existingID); err != nil {
+		return model.Message{}, false, err
+	}
+	msg.ID = existingID
+	return msg, false, nil
+}
+
+// insertMessage preenche os valores padrão da mensagem e monta o INSERT,
+// com suffix depois de VALUES.
+func insertMessage(msg model.Message, suffix string) (model.Message, string, []any, error) {
 	if msg.ID == "" {
 		msg.ID = uuid.New().String()
 	}
-	msg.CreatedAt = time.Now()
+	if msg.Direction == "" {
+		msg.Direction = model.MessageDirectionOutbound
+	}
+	if msg.CreatedAt.IsZero() {
+		msg.CreatedAt = time.Now()
+	}
 
 	payloadJSON, err := json.Marshal(map[string]interface{}{
 		"text": msg.Payload,
 	})
 	if err != nil {
-		return model.Message{}, err
+		return model.Message{}, "", nil, err
 	}
 
 	query := `
-		INSERT INTO message_queue (id, instance_id, whatsapp_id, recipient, type, payload, status, created_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		INSERT INTO message_queue (id, instance_id, whatsapp_id, direction, chat_jid, sender, recipient, type, payload, quoted_id, media_id, status, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		` + suffix
+
+	args := []any{
+		msg.ID, msg.InstanceID, msg.WhatsAppID, string(msg.Direction), nullIfEmpty(msg.ChatJID), nullIfEmpty(msg.Sender),
+		msg.To, msg.Type, string(payloadJSON), nullIfEmpty(msg.QuotedID), nullIfEmpty(msg.MediaID), msg.Status, msg.CreatedAt.Format(time.RFC3339),
+	}
+	return msg, query, args, nil
+}
+
+func (r *messageRepo) ListByInstance(ctx context.Context, instanceID string, before *time.Time, limit int) ([]model.Message, error) {
+	query := `
+		SELECT ` + messageColumns + `
+		FROM message_queue
+		WHERE instance_id = ? AND created_at < ?
+		ORDER BY created_at DESC
+		LIMIT ?
 	`
 
-	_, err = r.db.Conn.ExecContext(ctx, query,
-		msg.ID, msg.InstanceID, msg.WhatsAppID, msg.To, msg.Type, string(payloadJSON), msg.Status, msg.CreatedAt.Format(time.RFC3339),
-	)
+	cursor := time.Now().Add(time.Minute)
+	if before != nil {
+		cursor = *before
+	}
 
+	rows, err := r.db.Conn.QueryContext(ctx, query, instanceID, cursor.Format(time.RFC3339), limit)
 	if err != nil {
-		return model.Message{}, err
+		return nil, err
 	}
+	defer rows.Close()
 
-	return msg, nil
+	return scanMessages(rows)
 }
 
-func (r *messageRepo) ListByInstance(ctx context.Context, instanceID string) ([]model.Message, error) {
+func (r *messageRepo) ListPendingOutbound(ctx context.Context, instanceID string, since time.Time, limit int) ([]model.Message, error) {
 	query := `
-		SELECT id, instance_id, whatsapp_id, recipient, type, payload, status, delivered_at, created_at
+		SELECT ` + messageColumns + `
 		FROM message_queue
-		WHERE instance_id = ?
-		ORDER BY created_at DESC
-		LIMIT 100
+		WHERE instance_id = ? AND direction = 'outbound' AND (sender IS NULL OR sender = '')
+			AND status IN ('sent', 'retry', 'delivered') AND created_at >= ?
+		ORDER BY created_at ASC
+		LIMIT ?
 	`
 
-	rows, err := r.db.Conn.QueryContext(ctx, query, instanceID)
+	rows, err := r.db.Conn.QueryContext(ctx, query, instanceID, since.Format(time.RFC3339), limit)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var messages []model.Message
-	for rows.Next() {
-		var msg model.Message
-		var payloadStr string
-		var createdAt string
-		var whatsappID, deliveredAt sql.NullString
-
-		if err := rows.Scan(
-			&msg.ID, &msg.InstanceID, &whatsappID, &msg.To, &msg.Type, &payloadStr, &msg.Status, &deliveredAt, &createdAt,
-		); err != nil {
-			return nil, err
-		}
+	return scanMessages(rows)
+}
 
-		msg.WhatsAppID = whatsappID.String
-		if deliveredAt.Valid {
-			t, _ := time.Parse(time.RFC3339, deliveredAt.String)
-			msg.DeliveredAt = &t
-		}
+func (r *messageRepo) ListByChat(ctx context.Context, instanceID, chatJID string, before *time.Time, limit int) ([]model.Message, error) {
+	query := `
+		SELECT ` + messageColumns + `
+		FROM message_queue
+		WHERE instance_id = ? AND chat_jid = ? AND created_at < ?
+		ORDER BY created_at DESC
+		LIMIT ?
+	`
 
-		var payloadMap map[string]interface{}
-		if err := json.Unmarshal([]byte(payloadStr), &payloadMap); err == nil {
-			if text, ok := payloadMap["text"].(string); ok {
-				msg.Payload = text
-			} else {
-				msg.Payload = payloadStr
-			}
-		} else {
-			msg.Payload = payloadStr
-		}
+	cursor := time.Now().Add(time.Minute)
+	if before != nil {
+		cursor = *before
+	}
 
-		msg.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
-		messages = append(messages, msg)
+	rows, err := r.db.Conn.QueryContext(ctx, query, instanceID, chatJID, cursor.Format(time.RFC3339), limit)
+	if err != nil {
+		return nil, err
 	}
+	defer rows.Close()
 
-	return messages, rows.Err()
+	return scanMessages(rows)
 }
 
 func (r *messageRepo) Update(ctx context.Context, msg model.Message) error {
@@ -107,10 +177,10 @@ func (r *messageRepo) Update(ctx context.Context, msg model.Message) error {
 
 	query := `
 		UPDATE message_queue
-		SET status = ?, whatsapp_id = ?, delivered_at = ?
+		SET status = ?, whatsapp_id = ?, delivered_at = ?, chat_jid = COALESCE(?, chat_jid)
 		WHERE id = ?
 	`
-	_, err := r.db.Conn.ExecContext(ctx, query, msg.Status, msg.WhatsAppID, deliveredAt, msg.ID)
+	_, err := r.db.Conn.ExecContext(ctx, query, msg.Status, msg.WhatsAppID, deliveredAt, nullIfEmpty(msg.ChatJID), msg.ID)
 	return err
 }
 
@@ -127,52 +197,38 @@ func (r *messageRepo) UpdateStatusByWhatsAppID(ctx context.Context, whatsappID s
 
 func (r *messageRepo) GetByWhatsAppID(ctx context.Context, whatsappID string) (model.Message, error) {
 	query := `
-		SELECT id, instance_id, whatsapp_id, recipient, type, payload, status, delivered_at, created_at
+		SELECT ` + messageColumns + `
 		FROM message_queue
 		WHERE whatsapp_id = ?
 		LIMIT 1
 	`
 
-	var msg model.Message
-	var payloadStr string
-	var createdAt string
-	var wID, deliveredAt sql.NullString
-
-	err := r.db.Conn.QueryRowContext(ctx, query, whatsappID).Scan(
-		&msg.ID, &msg.InstanceID, &wID, &msg.To, &msg.Type, &payloadStr, &msg.Status, &deliveredAt, &createdAt,
-	)
-
+	msg, err := scanMessage(r.db.Conn.QueryRowContext(ctx, query, whatsappID))
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return model.Message{}, err // Retorna o erro nativo para evitar import cycle
-		}
+		// Retorna o erro nativo (sql.ErrNoRows) para evitar import cycle
 		return model.Message{}, err
 	}
+	return msg, nil
+}
 
-	msg.WhatsAppID = wID.String
-	if deliveredAt.Valid {
-		t, _ := time.Parse(time.RFC3339, deliveredAt.String)
-		msg.DeliveredAt = &t
-	}
+func (r *messageRepo) GetByInstanceAndID(ctx context.Context, instanceID, id string) (model.Message, error) {
+	query := `
+		SELECT ` + messageColumns + `
+		FROM message_queue
+		WHERE instance_id = ? AND (id = ? OR whatsapp_id = ?)
+		LIMIT 1
+	`
 
-	var payloadMap map[string]interface{}
-	if err := json.Unmarshal([]byte(payloadStr), &payloadMap); err == nil {
-		if text, ok := payloadMap["text"].(string); ok {
-			msg.Payload = text
-		} else {
-			msg.Payload = payloadStr
-		}
-	} else {
-		msg.Payload = payloadStr
+	msg, err := scanMessage(r.db.Conn.QueryRowContext(ctx, query, instanceID, id, id))
+	if err != nil {
+		return model.Message{}, mapError(err)
 	}
-
-	msg.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
 	return msg, nil
 }
 
 func (r *messageRepo) GetPendingMessages(ctx context.Context, limit int) ([]model.Message, error) {
 	query := `
-		SELECT id, instance_id, whatsapp_id, recipient, type, payload, status, delivered_at, created_at
+		SELECT ` + messageColumns + `
 		FROM message_queue
 		WHERE status = 'queued'
 		ORDER BY created_at ASC
@@ -185,45 +241,63 @@ func (r *messageRepo) GetPendingMessages(ctx context.Context, limit int) ([]mode
 	}
 	defer rows.Close()
 
+	return scanMessages(rows)
+}
+
+func (r *messageRepo) DeleteByInstanceID(ctx context.Context, instanceID string) error {
+	query := `DELETE FROM message_queue WHERE instance_id = ?`
+	_, err := r.db.Conn.ExecContext(ctx, query, instanceID)
+	return err
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanMessages(rows *sql.Rows) ([]model.Message, error) {
 	var messages []model.Message
 	for rows.Next() {
-		var msg model.Message
-		var payloadStr string
-		var createdAt string
-		var whatsappID, deliveredAt sql.NullString
-
-		if err := rows.Scan(
-			&msg.ID, &msg.InstanceID, &whatsappID, &msg.To, &msg.Type, &payloadStr, &msg.Status, &deliveredAt, &createdAt,
-		); err != nil {
+		msg, err := scanMessage(rows)
+		if err != nil {
 			return nil, err
 		}
+		messages = append(messages, msg)
+	}
+	return messages, rows.Err()
+}
 
-		msg.WhatsAppID = whatsappID.String
-		if deliveredAt.Valid {
-			t, _ := time.Parse(time.RFC3339, deliveredAt.String)
-			msg.DeliveredAt = &t
-		}
-
-		var payloadMap map[string]interface{}
-		if err := json.Unmarshal([]byte(payloadStr), &payloadMap); err == nil {
-			if text, ok := payloadMap["text"].(string); ok {
-				msg.Payload = text
-			} else {
-				msg.Payload = payloadStr
-			}
-		} else {
-			msg.Payload = payloadStr
-		}
+func scanMessage(row rowScanner) (model.Message, error) {
+	var msg model.Message
+	var payloadStr, direction, createdAt string
+	var whatsappID, chatJID, sender, quotedID, mediaID, deliveredAt sql.NullString
 
-		msg.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
-		messages = append(messages, msg)
+	if err := row.Scan(
+		&msg.ID, &msg.InstanceID, &whatsappID, &direction, &chatJID, &sender, &msg.To, &msg.Type, &payloadStr,
+		&quotedID, &mediaID, &msg.Status, &deliveredAt, &createdAt,
+	); err != nil {
+		return model.Message{}, err
 	}
 
-	return messages, rows.Err()
+	msg.WhatsAppID = whatsappID.String
+	msg.Direction = model.MessageDirection(direction)
+	msg.ChatJID = chatJID.String
+	msg.Sender = sender.String
+	msg.QuotedID = quotedID.String
+	msg.MediaID = mediaID.String
+	if deliveredAt.Valid {
+		msg.DeliveredAt = parseTimePtr(deliveredAt.String)
+	}
+	msg.Payload = decodeMessagePayload(payloadStr)
+	msg.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+	return msg, nil
 }
 
-func (r *messageRepo) DeleteByInstanceID(ctx context.Context, instanceID string) error {
-	query := `DELETE FROM message_queue WHERE instance_id = ?`
-	_, err := r.db.Conn.ExecContext(ctx, query, instanceID)
-	return err
+func decodeMessagePayload(payloadStr string) string {
+	var payloadMap map[string]interface{}
+	if err := json.Unmarshal([]byte(payloadStr), &payloadMap); err == nil {
+		if text, ok := payloadMap["text"].(string); ok {
+			return text
+		}
+	}
+	return payloadStr
 }
@@ -0,0 +1,78 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/open-apime/apime/internal/storage/model"
+)
+
+type messageStatusEventRepo struct {
+	db *DB
+}
+
+func NewMessageStatusEventRepository(db *DB) *messageStatusEventRepo {
+	return &messageStatusEventRepo{db: db}
+}
+
+func (r *messageStatusEventRepo) Create(ctx context.Context, event model.MessageStatusEvent) error {
+	if event.ID == "" {
+		event.ID = uuid.New().String()
+	}
+	if event.OccurredAt.IsZero() {
+		event.OccurredAt = time.Now()
+	}
+
+	query := `
+		INSERT INTO message_status_events (id, instance_id, message_id, chat_jid, participant, status, occurred_at, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(instance_id, message_id, participant, status) DO NOTHING
+	`
+	_, err := r.db.Conn.ExecContext(ctx, query,
+		event.ID,
+		event.InstanceID,
+		event.MessageID,
+		nullIfEmpty(event.ChatJID),
+		event.Participant,
+		event.Status,
+		event.OccurredAt.Format(time.RFC3339),
+		time.Now().Format(time.RFC3339),
+	)
+	return err
+}
+
+func (r *messageStatusEventRepo) ListByMessage(ctx context.Context, instanceID, messageID string) ([]model.MessageStatusEvent, error) {
+	query := `
+		SELECT id, instance_id, message_id, chat_jid, participant, status, occurred_at, created_at
+		FROM message_status_events
+		WHERE instance_id = ? AND message_id = ?
+		ORDER BY occurred_at ASC, created_at ASC
+	`
+
+	rows, err := r.db.Conn.QueryContext(ctx, query, instanceID, messageID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []model.MessageStatusEvent
+	for rows.Next() {
+		var event model.MessageStatusEvent
+		var chatJID sql.NullString
+		var occurredAt, createdAt string
+
+		if err := rows.Scan(&event.ID, &event.InstanceID, &event.MessageID, &chatJID, &event.Participant, &event.Status, &occurredAt, &createdAt); err != nil {
+			return nil, err
+		}
+
+		event.ChatJID = chatJID.String
+		event.OccurredAt, _ = time.Parse(time.RFC3339, occurredAt)
+		event.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+		events = append(events, event)
+	}
+
+	return events, rows.Err()
+}
@@ -55,7 +55,11 @@ func (d *Delivery) Deliver(ctx context.Context, url string, secret string, event
 		if attempt > 0 {
 			backoff := time.Duration(attempt) * time.Second
 			d.log.Info("delivery: retry", zap.Int("attempt", attempt), zap.Duration("backoff", backoff))
-			time.Sleep(backoff)
+			select {
+			case <-ctx.Done():
+				return fmt.Errorf("delivery: interrompido: %w", ctx.Err())
+			case <-time.After(backoff):
+			}
 		}
 
 		resp, err := d.client.Do(req)
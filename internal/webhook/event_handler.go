@@ -9,41 +9,89 @@ import (
 
 	"github.com/google/uuid"
 	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/proto/waE2E"
 	"go.mau.fi/whatsmeow/types"
 	"go.mau.fi/whatsmeow/types/events"
 	"go.uber.org/zap"
 
 	"github.com/open-apime/apime/internal/pkg/queue"
+	"github.com/open-apime/apime/internal/pkg/thumbnail"
+	"github.com/open-apime/apime/internal/service/chat"
+	whatsmeow_session "github.com/open-apime/apime/internal/session/whatsmeow"
 	"github.com/open-apime/apime/internal/storage"
 	"github.com/open-apime/apime/internal/storage/media"
+	"github.com/open-apime/apime/internal/storage/model"
 )
 
+// thumbnailSize é o maior lado, em pixels, das miniaturas das mídias
+// recebidas.
+const thumbnailSize = 320
+
 type InstanceChecker interface {
 	HasWebhook(ctx context.Context, instanceID string) bool
 	IsMetaCompatible(ctx context.Context, instanceID string) bool
+	MediaDownloadMode(ctx context.Context, instanceID string) model.MediaDownloadMode
+}
+
+// MessageRecorder persiste as mensagens observadas na sessão e mantém as
+// conversas atualizadas, independentemente de haver webhook configurado.
+type MessageRecorder interface {
+	RecordEvent(ctx context.Context, instanceID string, evt *events.Message, mediaID string) (bool, error)
+	MarkRead(ctx context.Context, instanceID, chatJID string) error
 }
 
 type EventHandler struct {
 	queue           queue.Queue
 	log             *zap.Logger
-	mediaStorage    *media.Storage
+	mediaStorage    media.Storage
 	messageRepo     storage.MessageRepository
+	statusRepo      storage.MessageStatusEventRepository
+	recorder        MessageRecorder
 	apiBaseURL      string
+	urlSigner       *media.URLSigner
+	mediaRefs       storage.MediaRefRepository
+	eagerMaxSize    int64
 	instanceChecker InstanceChecker
 }
 
-func NewEventHandler(q queue.Queue, log *zap.Logger, mediaStorage *media.Storage, messageRepo storage.MessageRepository, apiBaseURL string, instanceChecker InstanceChecker) *EventHandler {
+func NewEventHandler(q queue.Queue, log *zap.Logger, mediaStorage media.Storage, messageRepo storage.MessageRepository, statusRepo storage.MessageStatusEventRepository, recorder MessageRecorder, apiBaseURL string, instanceChecker InstanceChecker) *EventHandler {
 	return &EventHandler{
 		queue:           q,
 		log:             log,
 		mediaStorage:    mediaStorage,
 		messageRepo:     messageRepo,
+		statusRepo:      statusRepo,
+		recorder:        recorder,
 		apiBaseURL:      apiBaseURL,
 		instanceChecker: instanceChecker,
 	}
 }
 
 func (h *EventHandler) Handle(ctx context.Context, instanceID string, instanceJID string, client *whatsmeow.Client, evt any) {
+	var mediaID string
+	if e, ok := evt.(*events.Message); ok {
+		mediaID = h.downloadMessageMedia(ctx, instanceID, client, e)
+	}
+	h.HandleWithMedia(ctx, instanceID, instanceJID, evt, mediaID)
+}
+
+// HandleWithMedia processa o evento com a mídia já salva no storage. Usado por
+// provedores que baixam a mídia por conta própria, como a Cloud API.
+func (h *EventHandler) HandleWithMedia(ctx context.Context, instanceID string, instanceJID string, evt any, mediaID string) {
+	var mediaURL, thumbnailURL string
+	switch e := evt.(type) {
+	case *events.Message:
+		if mediaID != "" {
+			mediaURL = h.buildMediaURL(instanceID, mediaID)
+			if thumbID := h.saveThumbnail(ctx, instanceID, mediaID, e.Message); thumbID != "" {
+				thumbnailURL = h.buildMediaURL(instanceID, thumbID)
+			}
+		}
+		h.recordMessage(ctx, instanceID, e, mediaID)
+	case *events.Receipt:
+		h.handleReceipt(ctx, instanceID, e)
+	}
+
 	if h.instanceChecker != nil && !h.instanceChecker.HasWebhook(ctx, instanceID) {
 		h.log.Info("[dispatcher] evento ignorado: instância sem webhook configurado", zap.String("instance", instanceID))
 		return
@@ -51,36 +99,16 @@ func (h *EventHandler) Handle(ctx context.Context, instanceID string, instanceJI
 
 	h.log.Debug("[dispatcher] processando evento para webhook", zap.String("instance", instanceID), zap.String("type", fmt.Sprintf("%T", evt)))
 
-	if receipt, ok := evt.(*events.Receipt); ok {
-		status := string(receipt.Type)
-		if receipt.Type == types.ReceiptTypeRetry {
-			h.log.Warn("[dispatcher] RECEBIDO RETRY RECEIPT - Destinatário não conseguiu decriptar a mensagem",
-				zap.Strings("msg_ids", receipt.MessageIDs),
-				zap.String("chat", receipt.Chat.String()))
-		}
-
-		for _, msgID := range receipt.MessageIDs {
-			if err := h.messageRepo.UpdateStatusByWhatsAppID(ctx, msgID, status); err != nil {
-				h.log.Warn("[dispatcher] erro ao atualizar status da mensagem via receipt",
-					zap.String("msg_id", msgID),
-					zap.String("status", status),
-					zap.Error(err))
-			} else {
-				h.log.Info("[dispatcher] status da mensagem atualizado via receipt",
-					zap.String("msg_id", msgID),
-					zap.String("status", status))
-			}
-		}
-	}
-
 	var eventType string
 	var payload map[string]interface{}
 
-	if h.instanceChecker.IsMetaCompatible(ctx, instanceID) {
-		payload = h.normalizeEventToMeta(ctx, instanceID, instanceJID, client, evt)
+	// O fim do history sync não tem equivalente no formato Meta e segue sempre no formato nativo.
+	_, isHistorySync := evt.(*whatsmeow_session.HistorySyncCompleted)
+	if !isHistorySync && h.instanceChecker.IsMetaCompatible(ctx, instanceID) {
+		payload = h.normalizeEventToMeta(instanceID, instanceJID, mediaURL, evt)
 		eventType = "meta_event"
 	} else {
-		payload = h.normalizeEvent(ctx, instanceID, client, evt)
+		payload = h.normalizeEvent(mediaURL, thumbnailURL, evt)
 		if instanceJID != "" {
 			payload["instanceJID"] = instanceJID
 		}
@@ -111,42 +139,16 @@ func (h *EventHandler) Handle(ctx context.Context, instanceID string, instanceJI
 	)
 }
 
-func (h *EventHandler) normalizeEvent(ctx context.Context, instanceID string, client *whatsmeow.Client, evt any) map[string]interface{} {
+func (h *EventHandler) normalizeEvent(mediaURL string, thumbnailURL string, evt any) map[string]interface{} {
 	result := make(map[string]interface{})
 
 	switch evt := evt.(type) {
 	case *events.Message:
 		result["type"] = "message"
 
-		// Determinar o JID correto do remetente
-		// Se o Sender for um LID (@lid), usar o SenderAlt que contém o número real
-		senderJID := evt.Info.Sender.String()
-		if strings.Contains(senderJID, "@lid") && !evt.Info.SenderAlt.IsEmpty() {
-			senderJID = evt.Info.SenderAlt.String()
-		}
-		result["from"] = senderJID
-
-		// Determinar o Chat JID correto (identificador ESTÁVEL da conversa)
-		// Se Chat for um LID, precisamos buscar o número real em outros campos
-		chatJID := evt.Info.Chat.String()
-		if strings.Contains(chatJID, "@lid") {
-			// Para mensagens ENVIADAS (isFromMe=true), o número real do DESTINATÁRIO está em RecipientAlt
-			// Para mensagens RECEBIDAS (isFromMe=false), o número real do REMETENTE está em SenderAlt
-			if evt.Info.IsFromMe && !evt.Info.RecipientAlt.IsEmpty() && strings.Contains(evt.Info.RecipientAlt.String(), "@s.whatsapp.net") {
-				chatJID = evt.Info.RecipientAlt.String()
-			} else if !evt.Info.IsFromMe && !evt.Info.SenderAlt.IsEmpty() && strings.Contains(evt.Info.SenderAlt.String(), "@s.whatsapp.net") {
-				chatJID = evt.Info.SenderAlt.String()
-			}
-			// Se ainda for LID, logar para debug
-			if strings.Contains(chatJID, "@lid") {
-				h.log.Warn("Chat ainda é LID após resolução",
-					zap.String("original_chat", evt.Info.Chat.String()),
-					zap.String("resolved_chat", chatJID),
-					zap.String("recipientAlt", evt.Info.RecipientAlt.String()),
-					zap.String("senderAlt", evt.Info.SenderAlt.String()),
-					zap.Bool("isFromMe", evt.Info.IsFromMe))
-			}
-		}
+		result["from"] = chat.ResolveSenderJID(evt.Info)
+
+		chatJID := h.resolveChatJID(evt.Info)
 		result["chatJID"] = chatJID
 		result["to"] = chatJID
 		result["isFromMe"] = evt.Info.IsFromMe
@@ -167,7 +169,6 @@ func (h *EventHandler) normalizeEvent(ctx context.Context, instanceID string, cl
 
 		// Mídia (imagem, vídeo, documento, áudio) - agora com download
 		if img := evt.Message.GetImageMessage(); img != nil {
-			h.log.Info("detectada imagem, iniciando processamento", zap.String("msg_id", evt.Info.ID))
 			result["mediaType"] = "image"
 			if img.GetCaption() != "" {
 				result["caption"] = img.GetCaption()
@@ -175,16 +176,13 @@ func (h *EventHandler) normalizeEvent(ctx context.Context, instanceID string, cl
 			result["mimetype"] = img.GetMimetype()
 			result["fileSize"] = img.GetFileLength()
 
-			// Baixar mídia localmente
-			if client != nil && h.mediaStorage != nil {
-				if mediaURL := h.downloadAndSaveMedia(ctx, instanceID, evt.Info.ID, client, img, img.GetMimetype()); mediaURL != "" {
-					result["mediaUrl"] = mediaURL
-				} else {
-					h.log.Warn("falha ao baixar imagem, enviando webhook sem URL", zap.String("msg_id", evt.Info.ID))
-				}
+			if mediaURL != "" {
+				result["mediaUrl"] = mediaURL
+			}
+			if thumbnailURL != "" {
+				result["thumbnailUrl"] = thumbnailURL
 			}
 		} else if vid := evt.Message.GetVideoMessage(); vid != nil {
-			h.log.Info("detectado vídeo, iniciando processamento", zap.String("msg_id", evt.Info.ID))
 			result["mediaType"] = "video"
 			if vid.GetCaption() != "" {
 				result["caption"] = vid.GetCaption()
@@ -193,13 +191,11 @@ func (h *EventHandler) normalizeEvent(ctx context.Context, instanceID string, cl
 			result["fileSize"] = vid.GetFileLength()
 			result["duration"] = vid.GetSeconds()
 
-			// Baixar mídia localmente
-			if client != nil && h.mediaStorage != nil {
-				if mediaURL := h.downloadAndSaveMedia(ctx, instanceID, evt.Info.ID, client, vid, vid.GetMimetype()); mediaURL != "" {
-					result["mediaUrl"] = mediaURL
-				} else {
-					h.log.Warn("falha ao baixar vídeo, enviando webhook sem URL", zap.String("msg_id", evt.Info.ID))
-				}
+			if mediaURL != "" {
+				result["mediaUrl"] = mediaURL
+			}
+			if thumbnailURL != "" {
+				result["thumbnailUrl"] = thumbnailURL
 			}
 		} else if doc := evt.Message.GetDocumentMessage(); doc != nil {
 			result["mediaType"] = "document"
@@ -209,11 +205,11 @@ func (h *EventHandler) normalizeEvent(ctx context.Context, instanceID string, cl
 			result["mimetype"] = doc.GetMimetype()
 			result["fileSize"] = doc.GetFileLength()
 
-			// Baixar mídia localmente
-			if client != nil && h.mediaStorage != nil {
-				if mediaURL := h.downloadAndSaveMedia(ctx, instanceID, evt.Info.ID, client, doc, doc.GetMimetype()); mediaURL != "" {
-					result["mediaUrl"] = mediaURL
-				}
+			if mediaURL != "" {
+				result["mediaUrl"] = mediaURL
+			}
+			if thumbnailURL != "" {
+				result["thumbnailUrl"] = thumbnailURL
 			}
 		} else if aud := evt.Message.GetAudioMessage(); aud != nil {
 			result["mediaType"] = "audio"
@@ -222,11 +218,8 @@ func (h *EventHandler) normalizeEvent(ctx context.Context, instanceID string, cl
 			result["duration"] = aud.GetSeconds()
 			result["ptt"] = aud.GetPTT() // Push-to-Talk
 
-			// Baixar mídia localmente
-			if client != nil && h.mediaStorage != nil {
-				if mediaURL := h.downloadAndSaveMedia(ctx, instanceID, evt.Info.ID, client, aud, aud.GetMimetype()); mediaURL != "" {
-					result["mediaUrl"] = mediaURL
-				}
+			if mediaURL != "" {
+				result["mediaUrl"] = mediaURL
 			}
 		} else if loc := evt.Message.GetLocationMessage(); loc != nil {
 			result["mediaType"] = "location"
@@ -241,21 +234,18 @@ func (h *EventHandler) normalizeEvent(ctx context.Context, instanceID string, cl
 			result["contactNumber"] = con.GetVcard()
 		} else if stk := evt.Message.GetStickerMessage(); stk != nil {
 			result["mediaType"] = "sticker"
-			// Baixar mídia localmente
-			if client != nil && h.mediaStorage != nil {
-				if mediaURL := h.downloadAndSaveMedia(ctx, instanceID, evt.Info.ID, client, stk, stk.GetMimetype()); mediaURL != "" {
-					result["mediaUrl"] = mediaURL
-				}
+			if mediaURL != "" {
+				result["mediaUrl"] = mediaURL
 			}
 		}
 	case *events.Receipt:
 		result["type"] = "receipt"
 		result["messageIds"] = evt.MessageIDs
 		result["timestamp"] = evt.Timestamp
-		result["chat"] = evt.Chat.String()
+		result["chat"] = receiptChatJID(evt)
 		result["isGroup"] = evt.IsGroup
-		if !evt.Sender.IsEmpty() {
-			result["from"] = evt.Sender.String()
+		if sender := receiptSender(evt); !sender.IsEmpty() {
+			result["from"] = sender.String()
 		}
 		if !evt.MessageSender.IsEmpty() {
 			result["messageSender"] = evt.MessageSender.String()
@@ -275,6 +265,16 @@ func (h *EventHandler) normalizeEvent(ctx context.Context, instanceID string, cl
 	case *events.LoggedOut:
 		result["type"] = "disconnected"
 		result["reason"] = evt.Reason.String()
+	case *whatsmeow_session.HistorySyncCompleted:
+		result["type"] = "history_sync.completed"
+		result["cycleId"] = evt.Progress.CycleID
+		result["status"] = string(evt.Status)
+		result["chunksTotal"] = evt.Progress.ChunksTotal
+		result["chunksDone"] = evt.Progress.ChunksDone
+		result["chunksFailed"] = evt.Progress.ChunksFailed
+		result["messagesImported"] = evt.Progress.MessagesImported
+		result["startedAt"] = evt.StartedAt
+		result["finishedAt"] = evt.FinishedAt
 	default:
 		result["type"] = "unknown"
 		result["eventType"] = fmt.Sprintf("%T", evt)
@@ -288,9 +288,35 @@ func (h *EventHandler) normalizeEvent(ctx context.Context, instanceID string, cl
 	return result
 }
 
+// downloadMessageMedia baixa a mídia da mensagem (se houver) e salva localmente.
+// Retorna o ID da mídia salva ou string vazia.
+func (h *EventHandler) downloadMessageMedia(ctx context.Context, instanceID string, client *whatsmeow.Client, evt *events.Message) string {
+	if client == nil || h.mediaStorage == nil {
+		return ""
+	}
+
+	downloadable, mimetype := downloadableFromMessage(evt.Message)
+	if downloadable == nil {
+		return ""
+	}
+
+	fileName := evt.Message.GetDocumentMessage().GetFileName()
+	if h.useLazyMedia(ctx, instanceID, downloadable) {
+		if mediaID := h.saveMediaRef(ctx, instanceID, evt.Info.ID, downloadable, mimetype, fileName); mediaID != "" {
+			return mediaID
+		}
+	}
+
+	mediaID := h.downloadAndSaveMedia(ctx, instanceID, evt.Info.ID, client, downloadable, mimetype, fileName)
+	if mediaID == "" {
+		h.log.Warn("falha ao baixar mídia, seguindo sem URL", zap.String("msg_id", evt.Info.ID))
+	}
+	return mediaID
+}
+
 // downloadAndSaveMedia baixa mídia usando o cliente WhatsMeow e salva localmente.
-// Retorna a URL para acessar a mídia via API.
-func (h *EventHandler) downloadAndSaveMedia(ctx context.Context, instanceID string, messageID string, client *whatsmeow.Client, downloadable whatsmeow.DownloadableMessage, mimetype string) string {
+// Retorna o ID da mídia salva.
+func (h *EventHandler) downloadAndSaveMedia(ctx context.Context, instanceID string, messageID string, client *whatsmeow.Client, downloadable whatsmeow.DownloadableMessage, mimetype string, fileName string) string {
 	h.log.Info("baixando mídia",
 		zap.String("instance_id", instanceID),
 		zap.String("message_id", messageID),
@@ -315,7 +341,7 @@ func (h *EventHandler) downloadAndSaveMedia(ctx context.Context, instanceID stri
 	)
 
 	// Salvar no storage
-	mediaID, err := h.mediaStorage.Save(ctx, instanceID, messageID, data, mimetype)
+	mediaID, err := h.mediaStorage.Save(ctx, instanceID, messageID, data, mimetype, fileName)
 	if err != nil {
 		h.log.Error("erro ao salvar mídia",
 			zap.String("instance_id", instanceID),
@@ -325,16 +351,288 @@ func (h *EventHandler) downloadAndSaveMedia(ctx context.Context, instanceID stri
 		return ""
 	}
 
-	// Construir URL para acesso via API
-	mediaURL := fmt.Sprintf("%s/api/media/%s/%s", h.apiBaseURL, instanceID, mediaID)
-
-	h.log.Info("mídia salva e URL gerada",
+	h.log.Info("mídia salva",
 		zap.String("instance_id", instanceID),
 		zap.String("media_id", mediaID),
-		zap.String("media_url", mediaURL),
 	)
 
-	return mediaURL
+	return mediaID
+}
+
+// saveThumbnail grava a miniatura JPEG da mídia: gerada a partir do arquivo,
+// quando ele já foi salvo e é uma imagem, ou a que veio na própria mensagem.
+// Retorna o ID da miniatura ou string vazia.
+func (h *EventHandler) saveThumbnail(ctx context.Context, instanceID string, mediaID string, msg *waE2E.Message) string {
+	if h.mediaStorage == nil {
+		return ""
+	}
+	embedded, mimetype, ok := thumbnailSource(msg)
+	if !ok {
+		return ""
+	}
+
+	thumb := embedded
+	if strings.HasPrefix(mimetype, "image/") {
+		if data, err := h.mediaStorage.Get(ctx, instanceID, mediaID); err == nil {
+			if generated, err := thumbnail.JPEG(data, thumbnailSize); err == nil {
+				thumb = generated
+			} else {
+				h.log.Debug("miniatura não gerada", zap.String("media_id", mediaID), zap.Error(err))
+			}
+		}
+	}
+	if len(thumb) == 0 {
+		return ""
+	}
+
+	thumbID := media.ThumbnailID(mediaID)
+	if err := h.mediaStorage.Put(ctx, instanceID, thumbID, thumb, "image/jpeg", ""); err != nil {
+		h.log.Warn("erro ao salvar miniatura",
+			zap.String("instance_id", instanceID),
+			zap.String("media_id", mediaID),
+			zap.Error(err),
+		)
+		return ""
+	}
+	return thumbID
+}
+
+// thumbnailSource retorna a miniatura enviada na mensagem e o mimetype da
+// mídia, para imagens, vídeos e documentos.
+func thumbnailSource(msg *waE2E.Message) ([]byte, string, bool) {
+	if img := msg.GetImageMessage(); img != nil {
+		return img.GetJPEGThumbnail(), img.GetMimetype(), true
+	}
+	if vid := msg.GetVideoMessage(); vid != nil {
+		return vid.GetJPEGThumbnail(), vid.GetMimetype(), true
+	}
+	if doc := msg.GetDocumentMessage(); doc != nil {
+		return doc.GetJPEGThumbnail(), doc.GetMimetype(), true
+	}
+	return nil, "", false
+}
+
+// SetLazyMedia habilita o download sob demanda: nas instâncias em modo lazy,
+// e nas em modo eager para mídias acima de eagerMaxSize, o webhook sai com a
+// URL e só as chaves da mídia são guardadas.
+func (h *EventHandler) SetLazyMedia(refs storage.MediaRefRepository, eagerMaxSize int64) {
+	h.mediaRefs = refs
+	h.eagerMaxSize = eagerMaxSize
+}
+
+// useLazyMedia decide se a mídia fica para o primeiro acesso à URL.
+func (h *EventHandler) useLazyMedia(ctx context.Context, instanceID string, downloadable whatsmeow.DownloadableMessage) bool {
+	if h.mediaRefs == nil || downloadable.GetDirectPath() == "" {
+		return false
+	}
+	if h.instanceChecker != nil && h.instanceChecker.MediaDownloadMode(ctx, instanceID) == model.MediaDownloadEager {
+		return h.eagerMaxSize > 0 && mediaFileLength(downloadable) > h.eagerMaxSize
+	}
+	return true
+}
+
+// saveMediaRef guarda as chaves da mídia para o download sob demanda.
+// Retorna o ID da mídia ou string vazia em caso de erro.
+func (h *EventHandler) saveMediaRef(ctx context.Context, instanceID string, messageID string, downloadable whatsmeow.DownloadableMessage, mimetype string, fileName string) string {
+	ref := model.MediaRef{
+		InstanceID:    instanceID,
+		MediaID:       media.NewLazyMediaID(messageID, downloadable.GetFileSHA256(), mimetype),
+		MessageID:     messageID,
+		MediaType:     string(whatsmeow.GetMediaType(downloadable)),
+		DirectPath:    downloadable.GetDirectPath(),
+		MediaKey:      downloadable.GetMediaKey(),
+		FileEncSHA256: downloadable.GetFileEncSHA256(),
+		FileSHA256:    downloadable.GetFileSHA256(),
+		FileLength:    mediaFileLength(downloadable),
+		MimeType:      mimetype,
+		FileName:      fileName,
+	}
+	if err := h.mediaRefs.Create(ctx, ref); err != nil {
+		h.log.Warn("erro ao guardar referência da mídia, baixando agora",
+			zap.String("instance_id", instanceID),
+			zap.String("message_id", messageID),
+			zap.Error(err),
+		)
+		return ""
+	}
+
+	h.log.Debug("mídia guardada para download sob demanda",
+		zap.String("instance_id", instanceID),
+		zap.String("media_id", ref.MediaID),
+		zap.Int64("size", ref.FileLength),
+	)
+	return ref.MediaID
+}
+
+// mediaFileLength retorna o tamanho informado na mensagem, ou -1.
+func mediaFileLength(downloadable whatsmeow.DownloadableMessage) int64 {
+	if sized, ok := downloadable.(interface{ GetFileLength() uint64 }); ok {
+		return int64(sized.GetFileLength())
+	}
+	return -1
+}
+
+// SetMediaURLSigner faz as URLs de mídia dos webhooks saírem assinadas e com
+// validade.
+func (h *EventHandler) SetMediaURLSigner(signer *media.URLSigner) {
+	h.urlSigner = signer
+}
+
+// buildMediaURL monta a URL de acesso à mídia via API, assinada quando houver
+// um URLSigner.
+func (h *EventHandler) buildMediaURL(instanceID, mediaID string) string {
+	if h.urlSigner != nil {
+		return h.urlSigner.SignURL(h.apiBaseURL, instanceID, mediaID)
+	}
+	return fmt.Sprintf("%s/api/media/%s/%s", h.apiBaseURL, instanceID, mediaID)
+}
+
+// recordMessage persiste a mensagem no histórico de conversas da instância.
+func (h *EventHandler) recordMessage(ctx context.Context, instanceID string, evt *events.Message, mediaID string) {
+	if h.recorder == nil {
+		return
+	}
+
+	if _, err := h.recorder.RecordEvent(ctx, instanceID, evt, mediaID); err != nil {
+		h.log.Warn("erro ao persistir mensagem",
+			zap.String("instance_id", instanceID),
+			zap.String("msg_id", evt.Info.ID),
+			zap.Error(err))
+	}
+}
+
+// handleReceipt atualiza o status das mensagens enviadas e zera o contador de
+// não lidas quando a conversa é lida em outro dispositivo.
+func (h *EventHandler) handleReceipt(ctx context.Context, instanceID string, receipt *events.Receipt) {
+	status := receiptStatus(receipt.Type)
+	if receipt.Type == types.ReceiptTypeRetry {
+		h.log.Warn("[dispatcher] RECEBIDO RETRY RECEIPT - Destinatário não conseguiu decriptar a mensagem",
+			zap.Strings("msg_ids", receipt.MessageIDs),
+			zap.String("chat", receipt.Chat.String()))
+	}
+
+	if receipt.Type == types.ReceiptTypeReadSelf && h.recorder != nil {
+		// A conversa é identificada pelo número, como em recordMessage.
+		chatJID := chat.ResolveChatJID(types.MessageInfo{MessageSource: receipt.MessageSource})
+		if err := h.recorder.MarkRead(ctx, instanceID, chatJID); err != nil {
+			h.log.Warn("[dispatcher] erro ao marcar conversa como lida",
+				zap.String("chat", chatJID),
+				zap.Error(err))
+		}
+		return
+	}
+
+	h.recordReceiptEvents(ctx, instanceID, receipt, status)
+
+	for _, msgID := range receipt.MessageIDs {
+		if err := h.messageRepo.UpdateStatusByWhatsAppID(ctx, msgID, status); err != nil {
+			h.log.Warn("[dispatcher] erro ao atualizar status da mensagem via receipt",
+				zap.String("msg_id", msgID),
+				zap.String("status", status),
+				zap.Error(err))
+		} else {
+			h.log.Info("[dispatcher] status da mensagem atualizado via receipt",
+				zap.String("msg_id", msgID),
+				zap.String("status", status))
+		}
+	}
+}
+
+// recordReceiptEvents guarda a confirmação no histórico de status, com um
+// evento por mensagem confirmada.
+func (h *EventHandler) recordReceiptEvents(ctx context.Context, instanceID string, receipt *events.Receipt, status string) {
+	if h.statusRepo == nil {
+		return
+	}
+	switch receipt.Type {
+	case types.ReceiptTypeDelivered, types.ReceiptTypeRead, types.ReceiptTypePlayed, types.ReceiptTypeRetry:
+	default:
+		return
+	}
+
+	participant := receiptSender(receipt)
+	var participantJID string
+	if !participant.IsEmpty() {
+		participantJID = participant.ToNonAD().String()
+	}
+
+	for _, msgID := range receipt.MessageIDs {
+		err := h.statusRepo.Create(ctx, model.MessageStatusEvent{
+			InstanceID:  instanceID,
+			MessageID:   msgID,
+			ChatJID:     receipt.Chat.String(),
+			Participant: participantJID,
+			Status:      status,
+			OccurredAt:  receipt.Timestamp,
+		})
+		if err != nil {
+			h.log.Warn("[dispatcher] erro ao registrar histórico de status",
+				zap.String("msg_id", msgID),
+				zap.String("status", status),
+				zap.Error(err))
+		}
+	}
+}
+
+// receiptSender retorna quem enviou a confirmação, preferindo o número real
+// (SenderAlt) quando o Sender for um LID.
+func receiptSender(receipt *events.Receipt) types.JID {
+	if receipt.Sender.Server == types.HiddenUserServer && !receipt.SenderAlt.IsEmpty() {
+		return receipt.SenderAlt
+	}
+	return receipt.Sender
+}
+
+// receiptChatJID retorna a conversa da confirmação. Em conversas individuais
+// identificadas por LID, quem confirma é o próprio contato.
+func receiptChatJID(receipt *events.Receipt) string {
+	if !receipt.IsGroup && receipt.Chat.Server == types.HiddenUserServer {
+		if sender := receiptSender(receipt); sender.Server == types.DefaultUserServer {
+			return sender.ToNonAD().String()
+		}
+	}
+	return receipt.Chat.String()
+}
+
+// receiptStatus converte o tipo da confirmação no status da mensagem. O
+// WhatsApp envia a confirmação de entrega sem tipo.
+func receiptStatus(receiptType types.ReceiptType) string {
+	if receiptType == types.ReceiptTypeDelivered {
+		return "delivered"
+	}
+	return string(receiptType)
+}
+
+// resolveChatJID retorna o identificador estável da conversa, registrando
+// quando não for possível trocar o LID pelo número real.
+func (h *EventHandler) resolveChatJID(info types.MessageInfo) string {
+	chatJID := chat.ResolveChatJID(info)
+	if strings.Contains(chatJID, "@lid") {
+		h.log.Warn("Chat ainda é LID após resolução",
+			zap.String("original_chat", info.Chat.String()),
+			zap.String("resolved_chat", chatJID),
+			zap.String("recipientAlt", info.RecipientAlt.String()),
+			zap.String("senderAlt", info.SenderAlt.String()),
+			zap.Bool("isFromMe", info.IsFromMe))
+	}
+	return chatJID
+}
+
+// downloadableFromMessage retorna a parte baixável da mensagem e seu mimetype.
+func downloadableFromMessage(msg *waE2E.Message) (whatsmeow.DownloadableMessage, string) {
+	switch {
+	case msg.GetImageMessage() != nil:
+		return msg.GetImageMessage(), msg.GetImageMessage().GetMimetype()
+	case msg.GetVideoMessage() != nil:
+		return msg.GetVideoMessage(), msg.GetVideoMessage().GetMimetype()
+	case msg.GetDocumentMessage() != nil:
+		return msg.GetDocumentMessage(), msg.GetDocumentMessage().GetMimetype()
+	case msg.GetAudioMessage() != nil:
+		return msg.GetAudioMessage(), msg.GetAudioMessage().GetMimetype()
+	case msg.GetStickerMessage() != nil:
+		return msg.GetStickerMessage(), msg.GetStickerMessage().GetMimetype()
+	}
+	return nil, ""
 }
 
 // generateEventID gera um ID único para o evento.
@@ -342,7 +640,7 @@ func (h *EventHandler) generateEventID() string {
 	return uuid.New().String()
 }
 
-func (h *EventHandler) normalizeEventToMeta(ctx context.Context, instanceID string, instanceJID string, client *whatsmeow.Client, evt any) map[string]interface{} {
+func (h *EventHandler) normalizeEventToMeta(instanceID string, instanceJID string, mediaURL string, evt any) map[string]interface{} {
 	// Estrutura básica do Meta Cloud API Webhook
 	value := map[string]interface{}{
 		"messaging_product": "whatsapp",
@@ -355,11 +653,7 @@ func (h *EventHandler) normalizeEventToMeta(ctx context.Context, instanceID stri
 	switch evt := evt.(type) {
 	case *events.Message:
 		// Extrair remetente
-		senderJID := evt.Info.Sender.String()
-		if strings.Contains(senderJID, "@lid") && !evt.Info.SenderAlt.IsEmpty() {
-			senderJID = evt.Info.SenderAlt.String()
-		}
-		from := strings.Split(senderJID, "@")[0]
+		from := strings.Split(chat.ResolveSenderJID(evt.Info), "@")[0]
 
 		// Contatos
 		value["contacts"] = []map[string]interface{}{
@@ -394,11 +688,8 @@ func (h *EventHandler) normalizeEventToMeta(ctx context.Context, instanceID stri
 				"mime_type": img.GetMimetype(),
 				"caption":   img.GetCaption(),
 			}
-			// Baixar mídia localmente
-			if client != nil && h.mediaStorage != nil {
-				if mediaURL := h.downloadAndSaveMedia(ctx, instanceID, evt.Info.ID, client, img, img.GetMimetype()); mediaURL != "" {
-					mediaBody["link"] = mediaURL
-				}
+			if mediaURL != "" {
+				mediaBody["link"] = mediaURL
 			}
 			message["image"] = mediaBody
 		} else if vid := evt.Message.GetVideoMessage(); vid != nil {
@@ -407,10 +698,8 @@ func (h *EventHandler) normalizeEventToMeta(ctx context.Context, instanceID stri
 				"mime_type": vid.GetMimetype(),
 				"caption":   vid.GetCaption(),
 			}
-			if client != nil && h.mediaStorage != nil {
-				if mediaURL := h.downloadAndSaveMedia(ctx, instanceID, evt.Info.ID, client, vid, vid.GetMimetype()); mediaURL != "" {
-					mediaBody["link"] = mediaURL
-				}
+			if mediaURL != "" {
+				mediaBody["link"] = mediaURL
 			}
 			message["video"] = mediaBody
 		} else if aud := evt.Message.GetAudioMessage(); aud != nil {
@@ -421,10 +710,8 @@ func (h *EventHandler) normalizeEventToMeta(ctx context.Context, instanceID stri
 			mediaBody := map[string]interface{}{
 				"mime_type": aud.GetMimetype(),
 			}
-			if client != nil && h.mediaStorage != nil {
-				if mediaURL := h.downloadAndSaveMedia(ctx, instanceID, evt.Info.ID, client, aud, aud.GetMimetype()); mediaURL != "" {
-					mediaBody["link"] = mediaURL
-				}
+			if mediaURL != "" {
+				mediaBody["link"] = mediaURL
 			}
 			message["audio"] = mediaBody
 		} else if doc := evt.Message.GetDocumentMessage(); doc != nil {
@@ -434,10 +721,8 @@ func (h *EventHandler) normalizeEventToMeta(ctx context.Context, instanceID stri
 				"caption":   doc.GetCaption(),
 				"filename":  doc.GetTitle(),
 			}
-			if client != nil && h.mediaStorage != nil {
-				if mediaURL := h.downloadAndSaveMedia(ctx, instanceID, evt.Info.ID, client, doc, doc.GetMimetype()); mediaURL != "" {
-					mediaBody["link"] = mediaURL
-				}
+			if mediaURL != "" {
+				mediaBody["link"] = mediaURL
 			}
 			message["document"] = mediaBody
 		} else {
@@ -465,7 +750,7 @@ func (h *EventHandler) normalizeEventToMeta(ctx context.Context, instanceID stri
 					if !evt.MessageSender.IsEmpty() {
 						return strings.Split(evt.MessageSender.String(), "@")[0]
 					}
-					return strings.Split(evt.Chat.String(), "@")[0]
+					return strings.Split(receiptChatJID(evt), "@")[0]
 				}(),
 			})
 		}
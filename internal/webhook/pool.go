@@ -25,6 +25,14 @@ type Pool struct {
 	wg         sync.WaitGroup
 	ctx        context.Context
 	cancel     context.CancelFunc
+
+	// O dispatcher tem contexto próprio para que o dreno pare de consumir a
+	// fila enquanto os workers ainda entregam o que já foi despachado.
+	dispatchWg     sync.WaitGroup
+	dispatchCtx    context.Context
+	dispatchCancel context.CancelFunc
+
+	onDelivery func(instanceID string, err error)
 }
 
 type poolWorker struct {
@@ -33,6 +41,8 @@ type poolWorker struct {
 	log      *zap.Logger
 	delivery *delivery.Delivery
 	instRepo storage.InstanceRepository
+
+	onDelivery func(instanceID string, err error)
 }
 
 func NewPool(
@@ -57,18 +67,26 @@ func NewPool(
 	}
 }
 
+// SetDeliveryObserver registra uma função chamada após cada tentativa de
+// entrega, com err nil em caso de sucesso. Deve ser chamado antes de Start.
+func (p *Pool) SetDeliveryObserver(fn func(instanceID string, err error)) {
+	p.onDelivery = fn
+}
+
 func (p *Pool) Start(ctx context.Context) {
 	p.ctx, p.cancel = context.WithCancel(ctx)
+	p.dispatchCtx, p.dispatchCancel = context.WithCancel(p.ctx)
 
 	p.log.Info("webhook pool: iniciando", zap.Int("workers", p.numWorkers))
 
 	for i := 0; i < p.numWorkers; i++ {
 		worker := &poolWorker{
-			id:       i,
-			taskChan: p.taskChan,
-			log:      p.log,
-			delivery: p.delivery,
-			instRepo: p.instanceRepo,
+			id:         i,
+			taskChan:   p.taskChan,
+			log:        p.log,
+			delivery:   p.delivery,
+			instRepo:   p.instanceRepo,
+			onDelivery: p.onDelivery,
 		}
 		p.workers[i] = worker
 
@@ -76,7 +94,7 @@ func (p *Pool) Start(ctx context.Context) {
 		go p.runWorker(worker)
 	}
 
-	p.wg.Add(1)
+	p.dispatchWg.Add(1)
 	go p.runDispatcher()
 
 	p.log.Info("webhook pool: iniciada com sucesso")
@@ -84,22 +102,78 @@ func (p *Pool) Start(ctx context.Context) {
 
 func (p *Pool) Stop() {
 	p.log.Info("webhook pool: encerrando")
-	p.cancel()
-	p.wg.Wait()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	p.Drain(ctx)
+}
+
+// StopDispatch para de consumir a fila sem esperar os workers: os eventos
+// ainda na fila ficam para os outros nós ou para a próxima inicialização.
+func (p *Pool) StopDispatch() {
+	if p.dispatchCancel != nil {
+		p.dispatchCancel()
+	}
+}
+
+// Drain para de consumir a fila e deixa os workers entregarem os eventos já
+// despachados até o prazo de ctx. O que não for entregue no prazo volta para
+// a fila.
+func (p *Pool) Drain(ctx context.Context) {
+	p.log.Info("webhook pool: drenando entregas em andamento")
+	p.dispatchCancel()
+	p.dispatchWg.Wait()
 	close(p.taskChan)
-	p.log.Info("webhook pool: encerrada")
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		p.log.Warn("webhook pool: prazo de dreno esgotado, interrompendo entregas")
+		p.cancel()
+		<-done
+	}
+	p.cancel()
+
+	requeued := 0
+	for event := range p.taskChan {
+		p.requeue(event)
+		requeued++
+	}
+
+	p.log.Info("webhook pool: encerrada", zap.Int("requeued", requeued))
+}
+
+// requeue devolve o evento à fila com contexto próprio, já que é chamado
+// depois que o contexto da pool foi cancelado.
+func (p *Pool) requeue(event *queue.Event) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := p.queue.Enqueue(ctx, *event); err != nil {
+		p.log.Error("webhook pool: erro ao devolver evento à fila",
+			zap.String("eventId", event.ID),
+			zap.Error(err),
+		)
+	}
 }
 
 func (p *Pool) runDispatcher() {
-	defer p.wg.Done()
+	defer p.dispatchWg.Done()
 
 	for {
 		select {
-		case <-p.ctx.Done():
+		case <-p.dispatchCtx.Done():
 			return
 		default:
-			event, err := p.queue.Dequeue(p.ctx, 1*time.Second)
+			event, err := p.queue.Dequeue(p.dispatchCtx, 1*time.Second)
 			if err != nil {
+				if p.dispatchCtx.Err() != nil {
+					continue
+				}
 				p.log.Error("webhook pool: erro ao desenfileirar", zap.Error(err))
 				continue
 			}
@@ -110,7 +184,8 @@ func (p *Pool) runDispatcher() {
 
 			select {
 			case p.taskChan <- event:
-			case <-p.ctx.Done():
+			case <-p.dispatchCtx.Done():
+				p.requeue(event)
 				return
 			case <-time.After(5 * time.Second):
 				p.log.Warn("webhook pool: taskChan cheio, descartando evento", zap.String("eventId", event.ID))
@@ -133,12 +208,15 @@ func (p *Pool) runWorker(worker *poolWorker) {
 			if event == nil {
 				return
 			}
-			worker.processEvent(p.ctx, event)
+			if !worker.processEvent(p.ctx, event) && p.ctx.Err() != nil {
+				p.requeue(event)
+			}
 		}
 	}
 }
 
-func (w *poolWorker) processEvent(ctx context.Context, event *queue.Event) {
+// processEvent retorna false quando a entrega falhou.
+func (w *poolWorker) processEvent(ctx context.Context, event *queue.Event) bool {
 	prefix := fmt.Sprintf("[worker %d]", w.id+1)
 	w.log.Debug(fmt.Sprintf("%s webhook pool: processando evento", prefix), zap.String("eventId", event.ID))
 
@@ -148,14 +226,14 @@ func (w *poolWorker) processEvent(ctx context.Context, event *queue.Event) {
 			zap.String("eventId", event.ID),
 			zap.Error(err),
 		)
-		return
+		return false
 	}
 
 	if inst.WebhookURL == "" {
 		w.log.Warn(fmt.Sprintf("%s webhook pool: instância sem webhook configurado", prefix),
 			zap.String("instanceId", event.InstanceID),
 		)
-		return
+		return true
 	}
 
 	payload := map[string]interface{}{
@@ -166,15 +244,21 @@ func (w *poolWorker) processEvent(ctx context.Context, event *queue.Event) {
 		"createdAt":  event.CreatedAt,
 	}
 
-	if err := w.delivery.Deliver(ctx, inst.WebhookURL, inst.WebhookSecret, payload); err != nil {
+	err = w.delivery.Deliver(ctx, inst.WebhookURL, inst.WebhookSecret, payload)
+	// Entregas interrompidas pelo encerramento não contam como falha do destino.
+	if w.onDelivery != nil && ctx.Err() == nil {
+		w.onDelivery(event.InstanceID, err)
+	}
+	if err != nil {
 		w.log.Error(fmt.Sprintf("%s webhook pool: falha na entrega", prefix),
 			zap.String("eventId", event.ID),
 			zap.Error(err),
 		)
-		return
+		return false
 	}
 
 	w.log.Info(fmt.Sprintf("%s webhook pool: evento entregue com sucesso", prefix),
 		zap.String("eventId", event.ID),
 	)
+	return true
 }